@@ -3,82 +3,303 @@ package aof
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"redis/resp"
 	"sync"
+	"time"
 )
 
 // AOF represents the Append-Only File structure for data persistence
+// Like in Redis 7, it is made of several files in its own directory: a base file written
+// by the last rewrite, followed by incremental files the commands are appended to, which
+// are listed in order by a manifest
 type AOF struct {
-	file   *os.File
-	writer *bufio.Writer
-	mu     sync.Mutex
+	dir      string    // Directory holding the files and the manifest
+	filename string    // Prefix of the names of the files, such as database.aof
+	manifest *manifest // Files of the AOF, as saved on disk
+	file     *os.File  // Last incremental file, which values are appended to
+	writer   *bufio.Writer
+	mu       sync.Mutex
+	policy   FsyncPolicy // When the file is fsynced
+	size     int64       // Size of all the files, including the writes not fsynced yet
+	writeErr error       // Error of the last write, if it failed
+	closed   bool
+
+	// LoadTruncated makes Load drop an incomplete command at the end of the AOF instead of
+	// failing, like aof-load-truncated. It is set by default
+	LoadTruncated bool
+
+	baseSize     int64 // Size of the AOF when it was opened or last rewritten
+	rewriting    bool  // Set while Rewrite runs
+	rewriteFrom  int64 // Size of the AOF when the rewrite started, the part the new base file replaces
+	rewriteIncrs int   // Number of incremental files the new base file replaces
+	rewriteErr   error // Error of the last rewrite, if it failed
+
+	syncMu    sync.Mutex // Held while fsync runs, so concurrent callers share a single fsync
+	synced    int64      // Size of the AOF when it was last fsynced
+	lastFsync time.Time  // Time of the last fsync
+	fsyncErr  error      // Error of the last fsync, if it failed
+	fsyncs    int64      // Number of fsyncs
+
+	done chan struct{} // Closed by Close to stop the background fsync
 }
 
-// NewAOF creates a new AOF instance with the given filename
-// It opens (or creates) the file and sets up a buffered writer
-func NewAOF(filename string) (*AOF, error) {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+// Stats describes the state of the AOF, as reported by INFO persistence
+type Stats struct {
+	Policy       FsyncPolicy
+	Size         int64     // Size of all the files in bytes
+	PendingBytes int64     // Bytes written to the file but not fsynced yet
+	LastFsync    time.Time // Zero if the file was never fsynced
+	Fsyncs       int64
+	WriteErr     error // Error of the last write, if it failed
+	FsyncErr     error // Error of the last fsync, if it failed
+
+	BaseSize          int64 // Size of the AOF when it was opened or last rewritten
+	RewriteInProgress bool
+	RewriteErr        error // Error of the last rewrite, if it failed
+}
+
+// NewAOF opens the AOF stored in dir, whose files are named after filename
+// The directory and the manifest are created if they do not exist. An AOF written as a
+// single file by previous versions, at the path filename, is converted to the base file
+// of the new layout
+// The file is fsynced every second until SetFsyncPolicy selects another policy
+func NewAOF(dir, filename string) (*AOF, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	m, err := loadManifest(dir, filename)
+	if errors.Is(err, errNoManifest) {
+		m, err = createManifest(dir, filename)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &AOF{
-		file:   file,
-		writer: bufio.NewWriter(file),
-	}, nil
+	aof := &AOF{
+		dir:      dir,
+		filename: filename,
+		manifest: m,
+		policy:   FsyncEverySec,
+		done:     make(chan struct{}),
+
+		LoadTruncated: true,
+	}
+	// The last incremental file is only created once the manifest listing it is saved
+	last := m.incrs[len(m.incrs)-1]
+	if aof.file, err = os.OpenFile(aof.path(last.name), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666); err != nil {
+		return nil, err
+	}
+	aof.writer = bufio.NewWriter(aof.file)
+
+	for _, name := range m.files() {
+		info, err := os.Stat(aof.path(name))
+		if err != nil {
+			aof.file.Close()
+			return nil, err
+		}
+		aof.size += info.Size()
+	}
+	aof.synced = aof.size
+	aof.baseSize = aof.size
+
+	go aof.fsyncEverySecond()
+	return aof, nil
 }
 
-// Write appends a RESP value to the AOF
-// It uses a mutex to ensure thread-safety
-func (aof *AOF) Write(value resp.Value) error {
-	aof.mu.Lock()
-	defer aof.mu.Unlock()
+// Exists reports whether an AOF is stored in dir, or as a single file at the path filename
+func Exists(dir, filename string) bool {
+	if _, err := os.Stat(filepath.Join(dir, manifestName(filename))); err == nil {
+		return true
+	}
+	_, err := os.Stat(filename)
+	return err == nil
+}
 
-	if _, err := aof.writer.Write(value.Marshal()); err != nil {
-		return err
+// createManifest creates the manifest of a new AOF, with a first incremental file
+// A single-file AOF found at the path filename becomes the base file: it is linked into
+// the directory and only removed once the manifest listing it is saved, so it is never lost
+func createManifest(dir, filename string) (*manifest, error) {
+	m := &manifest{incrs: []manifestFile{{name: incrName(filename, 1), seq: 1, kind: fileIncr}}}
+
+	if _, err := os.Stat(filename); err == nil {
+		base := manifestFile{name: baseName(filename, 1), seq: 1, kind: fileBase}
+		path := filepath.Join(dir, base.name)
+		// Left over by a conversion that was interrupted
+		os.Remove(path)
+		if err := os.Link(filename, path); err != nil {
+			return nil, err
+		}
+		m.base = &base
+	}
+
+	if err := m.save(dir, filename); err != nil {
+		return nil, err
+	}
+	if m.base != nil {
+		fmt.Printf("Converted %s to the AOF directory %s\n", filename, dir)
+		if err := os.Remove(filename); err != nil {
+			return nil, err
+		}
 	}
+	return m, nil
+}
 
-	return aof.writer.Flush()
+// path returns the path of a file of the AOF
+func (aof *AOF) path(name string) string {
+	return filepath.Join(aof.dir, name)
+}
+
+// SetFsyncPolicy changes when the file is fsynced
+func (aof *AOF) SetFsyncPolicy(policy FsyncPolicy) {
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+	aof.policy = policy
 }
 
-// Close flushes any remaining data and closes the AOF file
+// Write appends RESP values to the AOF
+// The values are written together, so a transaction is never interleaved with other commands
+// They are handed to the operating system before Write returns, but only fsynced
+// as the policy says: with FsyncAlways, Commit must be called before acknowledging them
 // It uses a mutex to ensure thread-safety
-func (aof *AOF) Close() error {
+func (aof *AOF) Write(values ...resp.Value) error {
 	aof.mu.Lock()
 	defer aof.mu.Unlock()
 
-	if err := aof.writer.Flush(); err != nil {
-		return err
+	for _, value := range values {
+		n, err := aof.writer.Write(value.Marshal())
+		aof.size += int64(n)
+		if err != nil {
+			aof.writeErr = err
+			return err
+		}
 	}
 
-	return aof.file.Close()
+	aof.writeErr = aof.writer.Flush()
+	return aof.writeErr
 }
 
-// Load reads the AOF file from the beginning and applies each command
-// using the provided handler function
-// It uses a mutex to ensure thread-safety
-func (aof *AOF) Load(handler func(resp.Value)) error {
+// Commit makes the values written so far durable if the policy is FsyncAlways,
+// and does nothing otherwise
+// Concurrent callers are grouped into a single fsync, so writers that commit
+// at the same time wait for one disk flush instead of one each
+func (aof *AOF) Commit() error {
 	aof.mu.Lock()
-	defer aof.mu.Unlock()
+	policy := aof.policy
+	aof.mu.Unlock()
 
-	if _, err := aof.file.Seek(0, 0); err != nil {
-		return err
+	if policy != FsyncAlways {
+		return nil
 	}
+	return aof.Sync()
+}
+
+// Sync fsyncs the values written so far
+// If an fsync is already running, Sync waits for it and only fsyncs again
+// if it did not cover every value written before Sync was called
+func (aof *AOF) Sync() error {
+	aof.mu.Lock()
+	target := aof.size
+	aof.mu.Unlock()
 
-	reader := resp.NewResp(aof.file)
+	aof.syncMu.Lock()
+	defer aof.syncMu.Unlock()
+
+	if aof.synced >= target {
+		return aof.fsyncErr
+	}
+
+	// Writes keep going while the disk is flushed, and are covered by the next fsync.
+	// The values written while waiting for the previous one are covered by this one
+	aof.mu.Lock()
+	size := aof.size
+	aof.mu.Unlock()
+
+	aof.fsyncErr = aof.file.Sync()
+	aof.fsyncs++
+	if aof.fsyncErr == nil {
+		aof.synced = size
+		aof.lastFsync = time.Now()
+	}
+	return aof.fsyncErr
+}
+
+// Stats returns the state of the AOF
+func (aof *AOF) Stats() Stats {
+	aof.mu.Lock()
+	stats := Stats{
+		Policy:            aof.policy,
+		Size:              aof.size,
+		WriteErr:          aof.writeErr,
+		BaseSize:          aof.baseSize,
+		RewriteInProgress: aof.rewriting,
+		RewriteErr:        aof.rewriteErr,
+	}
+	aof.mu.Unlock()
+
+	aof.syncMu.Lock()
+	defer aof.syncMu.Unlock()
+	stats.PendingBytes = max(stats.Size-aof.synced, 0)
+	stats.LastFsync = aof.lastFsync
+	stats.Fsyncs = aof.fsyncs
+	stats.FsyncErr = aof.fsyncErr
+	return stats
+}
+
+// fsyncEverySecond fsyncs the file once per second while the policy is FsyncEverySec
+// The fsync runs without blocking writers, which keep appending to the file meanwhile
+func (aof *AOF) fsyncEverySecond() {
+	ticker := time.NewTicker(fsyncInterval)
+	defer ticker.Stop()
 
 	for {
-		value, err := reader.Read()
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
+		select {
+		case <-ticker.C:
+			aof.mu.Lock()
+			policy := aof.policy
+			aof.mu.Unlock()
+
+			if policy == FsyncEverySec {
+				if err := aof.Sync(); err != nil {
+					fmt.Printf("Error syncing AOF: %v\n", err)
+				}
 			}
-			return err
+		case <-aof.done:
+			return
 		}
+	}
+}
+
+// Close flushes any remaining data, fsyncs it unless the policy is FsyncNo, and closes the AOF file
+// Closing it again does nothing
+// It uses a mutex to ensure thread-safety
+func (aof *AOF) Close() error {
+	aof.mu.Lock()
+	closed := aof.closed
+	aof.mu.Unlock()
+	if closed {
+		return nil
+	}
+	close(aof.done)
 
-		handler(value)
+	// A background fsync is finished before the file is closed
+	aof.syncMu.Lock()
+	defer aof.syncMu.Unlock()
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+
+	aof.closed = true
+	if err := aof.writer.Flush(); err != nil {
+		return err
+	}
+	if aof.policy != FsyncNo {
+		if err := aof.file.Sync(); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return aof.file.Close()
 }
@@ -0,0 +1,44 @@
+package aof
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FsyncPolicy tells when the AOF is flushed to disk with fsync, like the appendfsync setting
+type FsyncPolicy int
+
+const (
+	// FsyncAlways fsyncs the commands before they are acknowledged to clients
+	FsyncAlways FsyncPolicy = iota
+	// FsyncEverySec fsyncs in the background once per second, losing at most a second of writes
+	FsyncEverySec
+	// FsyncNo never fsyncs, leaving it to the operating system
+	FsyncNo
+)
+
+// fsyncInterval is how often the AOF is fsynced with FsyncEverySec
+const fsyncInterval = time.Second
+
+// fsyncPolicyNames are the names of the policies in the appendfsync setting
+var fsyncPolicyNames = map[FsyncPolicy]string{
+	FsyncAlways:   "always",
+	FsyncEverySec: "everysec",
+	FsyncNo:       "no",
+}
+
+// String returns the name of the policy, as in the appendfsync setting
+func (p FsyncPolicy) String() string {
+	return fsyncPolicyNames[p]
+}
+
+// ParseFsyncPolicy returns the policy with the given name: always, everysec or no
+func ParseFsyncPolicy(name string) (FsyncPolicy, error) {
+	for policy, policyName := range fsyncPolicyNames {
+		if strings.EqualFold(name, policyName) {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid appendfsync policy %q", name)
+}
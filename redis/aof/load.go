@@ -0,0 +1,172 @@
+package aof
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"redis/resp"
+	"strings"
+)
+
+var (
+	// ErrTruncated is returned when a file of the AOF ends in the middle of a command,
+	// as it does when the server stops while writing it
+	ErrTruncated = errors.New("unexpected end of file")
+	// ErrBadFormat is returned when a file of the AOF holds something else than commands
+	ErrBadFormat = errors.New("bad file format")
+	// ErrIncompleteMulti is returned when a file of the AOF ends inside a MULTI ... EXEC block,
+	// which is always written at once, so it was cut short like a truncated command
+	ErrIncompleteMulti = fmt.Errorf("%w: MULTI without EXEC", ErrTruncated)
+)
+
+// Load reads the files of the AOF in the order of the manifest, and applies each command
+// using the provided handler function
+// If the last file with data ends in the middle of a command and LoadTruncated is set, the
+// incomplete command is dropped with a warning and the file is truncated after the last
+// complete one. A transaction without its EXEC is reverted the same way, so that the writes
+// appended after it are not replayed as part of it. Any other invalid content stops the
+// loading with an error
+// It uses a mutex to ensure thread-safety
+func (aof *AOF) Load(handler func(resp.Value)) error {
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+
+	files := aof.manifest.files()
+	sizes := make([]int64, len(files))
+	last := -1
+	for i, name := range files {
+		info, err := os.Stat(aof.path(name))
+		if err != nil {
+			return err
+		}
+		sizes[i] = info.Size()
+		if sizes[i] > 0 {
+			last = i
+		}
+	}
+
+	for i, name := range files {
+		offset, err := loadFile(aof.path(name), handler)
+		if errors.Is(err, ErrTruncated) && i == last && aof.LoadTruncated {
+			fmt.Printf("!!! Warning: short read while loading the AOF file %s!!!\n", name)
+			if errors.Is(err, ErrIncompleteMulti) {
+				fmt.Printf("Revert incomplete MULTI/EXEC transaction: truncating %s to %d bytes\n", name, offset)
+			} else {
+				fmt.Printf("Truncating %s to %d bytes, dropping the incomplete command at the end\n", name, offset)
+			}
+			if err := os.Truncate(aof.path(name), offset); err != nil {
+				return err
+			}
+			aof.size -= sizes[i] - offset
+			aof.synced = aof.size
+			aof.baseSize = aof.size
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s at offset %d: %w (the check-aof command can find and remove the bad data)", name, offset, err)
+		}
+	}
+	return nil
+}
+
+// loadFile applies each command of a file of the AOF
+// Returns the offset after the last command that was applied
+func loadFile(path string, handler func(resp.Value)) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return Scan(file, handler)
+}
+
+// countingReader counts the bytes read from a reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read reads from the underlying reader, counting the bytes
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Scan reads the commands of a file of the AOF, calling handler with each of them
+// Returns the offset after the last complete command: the size of the file if it is valid,
+// otherwise the offset of the first bad record, with ErrTruncated if the file ends in the
+// middle of it or ErrBadFormat if it is not a command
+// A file ending inside a MULTI ... EXEC block is truncated at its MULTI, and ErrIncompleteMulti
+// is returned with the offset of the MULTI. handler was already called with its commands
+func Scan(r io.Reader, handler func(resp.Value)) (int64, error) {
+	counter := &countingReader{r: r}
+	buffered := bufio.NewReader(counter)
+	// The RESP reader shares the buffer, so the offset is known between commands
+	reader := resp.NewResp(buffered)
+	multi := int64(-1)
+
+	for {
+		offset := counter.n - int64(buffered.Buffered())
+
+		next, err := buffered.Peek(1)
+		if err == io.EOF {
+			if multi >= 0 {
+				return multi, ErrIncompleteMulti
+			}
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		// Commands are always arrays, so anything else is not read as an inline command
+		if next[0] != resp.ARRAY {
+			return offset, fmt.Errorf("%w: expected '*', got %q", ErrBadFormat, next[0])
+		}
+
+		value, err := reader.Read()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			if multi >= 0 {
+				return multi, ErrIncompleteMulti
+			}
+			return offset, ErrTruncated
+		}
+		if err != nil {
+			return offset, fmt.Errorf("%w: %v", ErrBadFormat, err)
+		}
+		if len(value.Array) == 0 {
+			return offset, fmt.Errorf("%w: empty command", ErrBadFormat)
+		}
+		for _, arg := range value.Array {
+			if arg.Kind != resp.KindBulk {
+				return offset, fmt.Errorf("%w: command argument is not a bulk string", ErrBadFormat)
+			}
+		}
+		switch name := string(value.Array[0].Bulk); {
+		case strings.EqualFold(name, "MULTI"):
+			multi = offset
+		case strings.EqualFold(name, "EXEC"):
+			multi = -1
+		}
+
+		handler(value)
+	}
+}
+
+// Files returns the paths of the files of the AOF stored in dir, whose files are named
+// after filename, in the order they are replayed
+func Files(dir, filename string) ([]string, error) {
+	m, err := loadManifest(dir, filename)
+	if err != nil {
+		return nil, err
+	}
+	paths := m.files()
+	for i, name := range paths {
+		paths[i] = filepath.Join(dir, name)
+	}
+	return paths, nil
+}
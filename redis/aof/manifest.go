@@ -0,0 +1,198 @@
+package aof
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Types of the files listed in a manifest
+const (
+	fileBase = "b" // Snapshot of the data written by a rewrite, replayed first
+	fileIncr = "i" // Commands written since, replayed in order after the base
+)
+
+// manifestFile is a file of the AOF, as listed in the manifest
+type manifestFile struct {
+	name string
+	seq  int
+	kind string
+}
+
+// manifest lists the files that make up the AOF, like the manifest of Redis 7:
+// at most one base file, followed by the incremental files in the order they were written.
+// Each line describes one file, such as "file database.aof.1.incr.aof seq 1 type i"
+type manifest struct {
+	base  *manifestFile
+	incrs []manifestFile
+}
+
+// errNoManifest is returned when the AOF directory has no manifest yet
+var errNoManifest = errors.New("no manifest")
+
+// manifestName returns the name of the manifest of the AOF
+func manifestName(filename string) string {
+	return filename + ".manifest"
+}
+
+// baseName returns the name of the base file with the given sequence number
+func baseName(filename string, seq int) string {
+	return filename + "." + strconv.Itoa(seq) + ".base.aof"
+}
+
+// incrName returns the name of the incremental file with the given sequence number
+func incrName(filename string, seq int) string {
+	return filename + "." + strconv.Itoa(seq) + ".incr.aof"
+}
+
+// loadManifest reads the manifest of the AOF from its directory
+// Returns errNoManifest if there is none
+func loadManifest(dir, filename string) (*manifest, error) {
+	file, err := os.Open(filepath.Join(dir, manifestName(filename)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNoManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m := &manifest{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		f, err := parseManifestLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest line %d: %v", line, err)
+		}
+		switch f.kind {
+		case fileBase:
+			if m.base != nil {
+				return nil, fmt.Errorf("invalid manifest line %d: more than one base file", line)
+			}
+			m.base = &f
+		case fileIncr:
+			if len(m.incrs) > 0 && f.seq <= m.incrs[len(m.incrs)-1].seq {
+				return nil, fmt.Errorf("invalid manifest line %d: incremental files out of order", line)
+			}
+			m.incrs = append(m.incrs, f)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// The commands are always appended to the last incremental file
+	if len(m.incrs) == 0 {
+		return nil, errors.New("invalid manifest: no incremental file")
+	}
+	return m, nil
+}
+
+// parseManifestLine parses the key-value pairs describing a file in the manifest
+// The "h" type of Redis, for files kept as history, is accepted and ignored
+func parseManifestLine(line string) (manifestFile, error) {
+	fields := strings.Fields(line)
+	if len(fields)%2 != 0 {
+		return manifestFile{}, errors.New("odd number of fields")
+	}
+
+	var f manifestFile
+	for i := 0; i < len(fields); i += 2 {
+		switch value := fields[i+1]; fields[i] {
+		case "file":
+			if strings.ContainsAny(value, `/\`) {
+				return manifestFile{}, fmt.Errorf("invalid file name %q", value)
+			}
+			f.name = value
+		case "seq":
+			seq, err := strconv.Atoi(value)
+			if err != nil || seq < 1 {
+				return manifestFile{}, fmt.Errorf("invalid sequence number %q", value)
+			}
+			f.seq = seq
+		case "type":
+			if value != fileBase && value != fileIncr && value != "h" {
+				return manifestFile{}, fmt.Errorf("invalid file type %q", value)
+			}
+			f.kind = value
+		}
+	}
+	if f.name == "" || f.seq == 0 || f.kind == "" {
+		return manifestFile{}, errors.New("missing file, seq or type")
+	}
+	return f, nil
+}
+
+// String formats the manifest as it is written to disk
+func (m *manifest) String() string {
+	var b strings.Builder
+	files := m.incrs
+	if m.base != nil {
+		files = append([]manifestFile{*m.base}, m.incrs...)
+	}
+	for _, f := range files {
+		fmt.Fprintf(&b, "file %s seq %d type %s\n", f.name, f.seq, f.kind)
+	}
+	return b.String()
+}
+
+// files returns the names of the files of the AOF, in the order they are replayed
+func (m *manifest) files() []string {
+	var names []string
+	if m.base != nil {
+		names = append(names, m.base.name)
+	}
+	for _, f := range m.incrs {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// save replaces the manifest on disk atomically: it is written to a temporary
+// file which is fsynced, then renamed over the previous one
+func (m *manifest) save(dir, filename string) error {
+	temp := filepath.Join(dir, "temp-"+manifestName(filename))
+	if err := writeFileSync(temp, []byte(m.String())); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, filepath.Join(dir, manifestName(filename))); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return syncDir(dir)
+}
+
+// writeFileSync writes data to a new file and fsyncs it
+func writeFileSync(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir fsyncs a directory, which makes the files created or renamed in it durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,170 @@
+package aof
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"redis/resp"
+)
+
+var (
+	// ErrRewriteInProgress is returned when a rewrite is started while another one runs
+	ErrRewriteInProgress = errors.New("ERR Background append only file rewriting already in progress")
+	// errClosed is returned when the AOF is closed before a rewrite completes
+	errClosed = errors.New("AOF closed")
+)
+
+// StartRewrite marks the point in time a rewrite starts from: the values written from now on
+// go to a new incremental file, which is kept when Rewrite replaces the files before it
+// Returns ErrRewriteInProgress if a rewrite already runs
+func (aof *AOF) StartRewrite() error {
+	aof.syncMu.Lock()
+	defer aof.syncMu.Unlock()
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+
+	if aof.rewriting {
+		return ErrRewriteInProgress
+	}
+	if aof.closed {
+		return errClosed
+	}
+
+	// The current file is complete on disk before the manifest moves past it
+	if err := aof.writer.Flush(); err != nil {
+		return err
+	}
+	if err := aof.file.Sync(); err != nil {
+		return err
+	}
+
+	last := aof.manifest.incrs[len(aof.manifest.incrs)-1]
+	incr := manifestFile{name: incrName(aof.filename, last.seq+1), seq: last.seq + 1, kind: fileIncr}
+	file, err := os.OpenFile(aof.path(incr.name), os.O_CREATE|os.O_RDWR|os.O_APPEND|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	m := &manifest{base: aof.manifest.base, incrs: append(aof.manifest.incrs[:len(aof.manifest.incrs):len(aof.manifest.incrs)], incr)}
+	if err := m.save(aof.dir, aof.filename); err != nil {
+		file.Close()
+		os.Remove(aof.path(incr.name))
+		return err
+	}
+
+	aof.file.Close()
+	aof.file = file
+	aof.writer = bufio.NewWriter(file)
+	aof.manifest = m
+	aof.synced = aof.size
+	aof.rewriting = true
+	aof.rewriteFrom = aof.size
+	aof.rewriteIncrs = len(m.incrs) - 1
+	return nil
+}
+
+// Rewrite replaces the files of the AOF up to the point where StartRewrite was called
+// with a new base file holding the values generate passes to write, which rebuild the data
+// as it was then. Each value goes straight to the buffer of the file, so the data is never
+// held in memory as a whole
+// The base file is written under a temporary name and fsynced, then renamed and listed
+// in a new manifest, so the AOF is complete at any moment even if the server stops.
+// Writes carry on in the incremental file meanwhile
+func (aof *AOF) Rewrite(generate func(write func(resp.Value))) error {
+	temp := aof.path("temp-rewriteaof-" + aof.filename)
+	err := writeTemp(temp, generate)
+	if err == nil {
+		err = aof.swap(temp)
+	}
+
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+	if err != nil {
+		os.Remove(temp)
+	}
+	aof.rewriting = false
+	aof.rewriteErr = err
+	return err
+}
+
+// writeTemp writes the values generated to a new file and fsyncs it
+// After a failed write, the values that follow are dropped and the error is returned
+func writeTemp(temp string, generate func(write func(resp.Value))) error {
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	generate(func(value resp.Value) {
+		if err == nil {
+			_, err = writer.Write(value.Marshal())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// swap makes the temporary file the base file of the AOF, and removes the files it replaces
+func (aof *AOF) swap(temp string) error {
+	aof.syncMu.Lock()
+	defer aof.syncMu.Unlock()
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+
+	if aof.closed {
+		return errClosed
+	}
+	info, err := os.Stat(temp)
+	if err != nil {
+		return err
+	}
+
+	seq := 1
+	if aof.manifest.base != nil {
+		seq = aof.manifest.base.seq + 1
+	}
+	base := manifestFile{name: baseName(aof.filename, seq), seq: seq, kind: fileBase}
+	if err := os.Rename(temp, aof.path(base.name)); err != nil {
+		return err
+	}
+	m := &manifest{base: &base, incrs: aof.manifest.incrs[aof.rewriteIncrs:]}
+	if err := m.save(aof.dir, aof.filename); err != nil {
+		os.Remove(aof.path(base.name))
+		return err
+	}
+
+	// The replaced files are no longer listed, so they are not needed even if removing them fails
+	if old := aof.manifest.base; old != nil {
+		os.Remove(aof.path(old.name))
+	}
+	for _, incr := range aof.manifest.incrs[:aof.rewriteIncrs] {
+		os.Remove(aof.path(incr.name))
+	}
+
+	aof.manifest = m
+	aof.size += info.Size() - aof.rewriteFrom
+	aof.synced += info.Size() - aof.rewriteFrom
+	aof.baseSize = aof.size
+	return nil
+}
+
+// NeedsRewrite returns true if no rewrite runs and the file is at least minSize bytes
+// and grew by at least percentage percent since it was opened or last rewritten,
+// like auto-aof-rewrite-percentage and auto-aof-rewrite-min-size
+// A percentage of 0 disables automatic rewrites
+func (aof *AOF) NeedsRewrite(percentage int, minSize int64) bool {
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+
+	if aof.rewriting || percentage <= 0 || aof.size < minSize {
+		return false
+	}
+	base := max(aof.baseSize, 1)
+	return (aof.size-base)*100/base >= int64(percentage)
+}
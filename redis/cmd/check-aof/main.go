@@ -0,0 +1,99 @@
+// check-aof checks that an AOF only holds complete commands, like redis-check-aof
+//
+// Usage:
+//
+//	check-aof [-fix] <file.aof | file.aof.manifest>
+//
+// Given a manifest, it checks every file it lists, in order. It reports the offset of the
+// first bad record, and with -fix truncates the file there, dropping everything after it.
+// Only the last file of a manifest can be fixed, since the ones after a truncated file would
+// be replayed without the commands it lost
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"redis/aof"
+	"redis/resp"
+	"strings"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "truncate the file at the first bad record")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-fix] <file.aof | file.aof.manifest>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+	files := []string{path}
+	if strings.HasSuffix(path, ".manifest") {
+		var err error
+		files, err = aof.Files(filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), ".manifest"))
+		if err != nil {
+			fmt.Printf("Cannot read the manifest %s: %v\n", path, err)
+			os.Exit(1)
+		}
+	}
+
+	for i, file := range files {
+		if !checkFile(file, *fix && i == len(files)-1) {
+			os.Exit(1)
+		}
+	}
+}
+
+// checkFile checks a file of the AOF and prints the result
+// With fix, a bad file is truncated at its first bad record
+// Returns true if the file is valid, or was fixed
+func checkFile(path string, fix bool) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Cannot open %s: %v\n", path, err)
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		fmt.Printf("Cannot open %s: %v\n", path, err)
+		return false
+	}
+
+	commands := 0
+	offset, err := aof.Scan(file, func(resp.Value) { commands++ })
+	file.Close()
+
+	fmt.Printf("AOF analyzed: filename=%s, size=%d, ok_up_to=%d, commands=%d, diff=%d\n",
+		path, info.Size(), offset, commands, info.Size()-offset)
+	if err == nil {
+		fmt.Printf("AOF %s is valid\n", path)
+		return true
+	}
+
+	if errors.Is(err, aof.ErrIncompleteMulti) {
+		fmt.Printf("AOF %s ends inside the MULTI/EXEC transaction at offset %d\n", path, offset)
+	} else if errors.Is(err, aof.ErrTruncated) {
+		fmt.Printf("AOF %s ends with an incomplete command at offset %d\n", path, offset)
+	} else {
+		fmt.Printf("AOF %s has a bad record at offset %d: %v\n", path, offset, err)
+	}
+	if !fix {
+		fmt.Println("Run again with -fix to truncate the file there")
+		return false
+	}
+
+	if err := os.Truncate(path, offset); err != nil {
+		fmt.Printf("Failed to truncate %s: %v\n", path, err)
+		return false
+	}
+	fmt.Printf("Successfully truncated %s from %d to %d bytes\n", path, info.Size(), offset)
+	return true
+}
@@ -3,6 +3,8 @@ package command
 import (
 	"redis/resp"
 	"redis/storage"
+	"strconv"
+	"strings"
 )
 
 // 1) -> https://redis.io/docs/latest/commands/ping
@@ -10,20 +12,70 @@ import (
 // It returns "PONG" if no argument is provided, otherwise it returns the first argument
 func Ping(s *storage.Storage, args []string) resp.Value {
 	if len(args) == 0 {
-		return resp.Value{Type: "string", Str: "PONG"}
+		return resp.SimpleString("PONG")
 	}
-	return resp.Value{Type: "string", Str: args[0]}
+	return resp.SimpleString(args[0])
 }
 
 // 2) -> https://redis.io/docs/latest/commands/set
 // Set handles the SET command
 // It sets a key to hold a string value in the storage
+// Supported options: EX seconds | PX milliseconds | EXAT unix-time-seconds |
+// PXAT unix-time-milliseconds | KEEPTTL, NX | XX and GET
 func Set(s *storage.Storage, args []string) resp.Value {
-	if len(args) != 2 {
-		return resp.Value{Type: "error", Str: "ERR wrong number of arguments for 'set' command"}
+	if len(args) < 2 {
+		return wrongArgs("set")
 	}
-	s.Set(args[0], args[1])
-	return resp.Value{Type: "string", Str: "OK"}
+
+	var opts storage.SetOptions
+	hasExpire := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GET":
+			opts.Get = true
+		case "KEEPTTL":
+			if hasExpire {
+				return syntaxError()
+			}
+			opts.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasExpire || opts.KeepTTL || i+1 == len(args) {
+				return syntaxError()
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return notInteger()
+			}
+			at, ok := expireAt(strings.ToUpper(args[i]), n)
+			if n <= 0 || !ok {
+				return invalidExpire("set")
+			}
+			opts.ExpireAt = at
+			hasExpire = true
+			i++
+		default:
+			return syntaxError()
+		}
+	}
+
+	old, existed, ok, err := s.SetWithOptions(args[0], args[1], opts)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if opts.Get {
+		if !existed {
+			return resp.Null()
+		}
+		return resp.Bulk(old)
+	}
+	if !ok {
+		return resp.Null()
+	}
+	return resp.OK()
 }
 
 // 3) -> https://redis.io/docs/latest/commands/get
@@ -31,13 +83,16 @@ func Set(s *storage.Storage, args []string) resp.Value {
 // It retrieves the value of a key from the storage
 func Get(s *storage.Storage, args []string) resp.Value {
 	if len(args) != 1 {
-		return resp.Value{Type: "error", Str: "ERR wrong number of arguments for 'get' command"}
+		return resp.Err("ERR wrong number of arguments for 'get' command")
+	}
+	value, ok, err := s.Get(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
 	}
-	value, ok := s.Get(args[0])
 	if !ok {
-		return resp.Value{Type: "null"}
+		return resp.Null()
 	}
-	return resp.Value{Type: "bulk", Bulk: value}
+	return resp.Bulk(value)
 }
 
 // 4) -> https://redis.io/docs/latest/commands/del
@@ -46,7 +101,7 @@ func Get(s *storage.Storage, args []string) resp.Value {
 // Returns the number of keys that were removed
 func Del(s *storage.Storage, args []string) resp.Value {
 	if len(args) < 1 {
-		return resp.Value{Type: "error", Str: "ERR wrong number of arguments for 'del' command"}
+		return resp.Err("ERR wrong number of arguments for 'del' command")
 	}
 	count := 0
 	for _, key := range args {
@@ -54,7 +109,7 @@ func Del(s *storage.Storage, args []string) resp.Value {
 			count++
 		}
 	}
-	return resp.Value{Type: "integer", Num: count}
+	return resp.Integer(int64(count))
 }
 
 // 5) -> https://redis.io/docs/latest/commands/exists
@@ -63,10 +118,10 @@ func Del(s *storage.Storage, args []string) resp.Value {
 // Returns the number of keys that exist
 func Exists(s *storage.Storage, args []string) resp.Value {
 	if len(args) < 1 {
-		return resp.Value{Type: "error", Str: "ERR wrong number of arguments for 'exists' command"}
+		return resp.Err("ERR wrong number of arguments for 'exists' command")
 	}
 	count := s.Exists(args...)
-	return resp.Value{Type: "integer", Num: count}
+	return resp.Integer(int64(count))
 }
 
 // 6) -> https://redis.io/docs/latest/commands/incr
@@ -74,12 +129,27 @@ func Exists(s *storage.Storage, args []string) resp.Value {
 // It increments the integer value of a key by one
 // If the key does not exist, it is set to 0 before performing the operation
 func Incr(s *storage.Storage, args []string) resp.Value {
-    if len(args) != 1 {
-        return resp.Value{Type: "error", Str: "ERR wrong number of arguments for 'incr' command"}
-    }
-    newValue, err := s.IncrBy(args[0], 1)
-    if err != nil {
-        return resp.Value{Type: "error", Str: err.Error()}
-    }
-    return resp.Value{Type: "integer", Num: newValue}
+	if len(args) != 1 {
+		return resp.Err("ERR wrong number of arguments for 'incr' command")
+	}
+	newValue, err := s.IncrBy(args[0], 1)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(newValue))
+}
+
+// wrongArgs returns the error reply for a command called with the wrong number of arguments
+func wrongArgs(name string) resp.Value {
+	return resp.Err("ERR wrong number of arguments for '" + name + "' command")
+}
+
+// syntaxError returns the generic syntax error reply
+func syntaxError() resp.Value {
+	return resp.Err("ERR syntax error")
+}
+
+// notInteger returns the error reply for an argument that is not a valid integer
+func notInteger() resp.Value {
+	return resp.Err("ERR value is not an integer or out of range")
 }
@@ -0,0 +1,99 @@
+// https://redis.io/docs/latest/commands/select/
+package command
+
+import (
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+)
+
+// dbOutOfRange returns the error reply for a database index that does not exist
+func dbOutOfRange() resp.Value {
+	return resp.Err("ERR DB index is out of range")
+}
+
+// 1) -> https://redis.io/docs/latest/commands/move
+// Move handles the MOVE command
+// It moves a key, with its time to live, to another database
+// Returns 1 if the key was moved, and 0 if it does not exist or already exists in the destination
+func Move(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("move")
+	}
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return notInteger()
+	}
+	dst := s.DB(index)
+	if dst == nil {
+		return dbOutOfRange()
+	}
+	if dst == s {
+		return resp.Err("ERR source and destination objects are the same")
+	}
+	if !s.Move(args[0], dst) {
+		return resp.Integer(0)
+	}
+	return resp.Integer(1)
+}
+
+// 2) -> https://redis.io/docs/latest/commands/swapdb
+// SwapDB handles the SWAPDB command
+// It swaps the keys of two databases
+func SwapDB(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("swapdb")
+	}
+	first, err := strconv.Atoi(args[0])
+	if err != nil {
+		return resp.Err("ERR invalid first DB index")
+	}
+	second, err := strconv.Atoi(args[1])
+	if err != nil {
+		return resp.Err("ERR invalid second DB index")
+	}
+	if s.DB(first) == nil || s.DB(second) == nil {
+		return dbOutOfRange()
+	}
+	s.SwapDB(first, second)
+	return resp.OK()
+}
+
+// flushMode checks the optional ASYNC or SYNC argument of FLUSHDB and FLUSHALL
+// Both free the keys in the background, see Storage.FlushDB
+func flushMode(args []string) bool {
+	return len(args) == 0 || (len(args) == 1 && (strings.EqualFold(args[0], "ASYNC") || strings.EqualFold(args[0], "SYNC")))
+}
+
+// 3) -> https://redis.io/docs/latest/commands/flushdb
+// FlushDB handles the FLUSHDB command
+// It removes every key of the selected database
+func FlushDB(s *storage.Storage, args []string) resp.Value {
+	if !flushMode(args) {
+		return syntaxError()
+	}
+	s.FlushDB()
+	return resp.OK()
+}
+
+// 4) -> https://redis.io/docs/latest/commands/flushall
+// FlushAll handles the FLUSHALL command
+// It removes every key of every database
+func FlushAll(s *storage.Storage, args []string) resp.Value {
+	if !flushMode(args) {
+		return syntaxError()
+	}
+	s.FlushAll()
+	return resp.OK()
+}
+
+// 5) -> https://redis.io/docs/latest/commands/dbsize
+// DBSize handles the DBSIZE command
+// Returns the number of keys in the selected database
+func DBSize(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 0 {
+		return wrongArgs("dbsize")
+	}
+	return resp.Integer(int64(s.DBSize()))
+}
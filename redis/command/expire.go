@@ -0,0 +1,178 @@
+package command
+
+import (
+	"math"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+)
+
+// expireUnits maps each EXPIRE variant to the unit of its TTL argument
+var expireUnits = map[string]string{
+	"EXPIRE":    "EX",
+	"PEXPIRE":   "PX",
+	"EXPIREAT":  "EXAT",
+	"PEXPIREAT": "PXAT",
+}
+
+// expireAt converts a TTL argument into an absolute unix time in milliseconds
+// unit is one of EX (relative seconds), PX (relative milliseconds),
+// EXAT (absolute seconds) or PXAT (absolute milliseconds)
+// Returns false if the result does not fit in an int64
+func expireAt(unit string, n int64) (int64, bool) {
+	if unit == "EX" || unit == "EXAT" {
+		if n > math.MaxInt64/1000 || n < math.MinInt64/1000 {
+			return 0, false
+		}
+		n *= 1000
+	}
+	if unit == "EX" || unit == "PX" {
+		now := storage.Now()
+		if n > 0 && n > math.MaxInt64-now {
+			return 0, false
+		}
+		n += now
+	}
+	return n, true
+}
+
+// invalidExpire returns the error reply for an out of range expire time
+func invalidExpire(name string) resp.Value {
+	return resp.Err("ERR invalid expire time in '" + name + "' command")
+}
+
+// expireGeneric implements the EXPIRE family of commands
+func expireGeneric(s *storage.Storage, args []string, name string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs(name)
+	}
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return notInteger()
+	}
+	at, ok := expireAt(expireUnits[strings.ToUpper(name)], n)
+	if !ok {
+		return invalidExpire(name)
+	}
+	if s.Expire(args[0], at) {
+		return resp.Integer(1)
+	}
+	return resp.Integer(0)
+}
+
+// 1) -> https://redis.io/docs/latest/commands/expire
+// Expire handles the EXPIRE command
+// It sets a timeout in seconds on a key, after which the key is deleted
+// Returns 1 if the timeout was set, 0 if the key does not exist
+func Expire(s *storage.Storage, args []string) resp.Value {
+	return expireGeneric(s, args, "expire")
+}
+
+// 2) -> https://redis.io/docs/latest/commands/pexpire
+// PExpire handles the PEXPIRE command
+// It works like EXPIRE but the timeout is given in milliseconds
+func PExpire(s *storage.Storage, args []string) resp.Value {
+	return expireGeneric(s, args, "pexpire")
+}
+
+// 3) -> https://redis.io/docs/latest/commands/expireat
+// ExpireAt handles the EXPIREAT command
+// It sets the expiry of a key to an absolute unix timestamp in seconds
+func ExpireAt(s *storage.Storage, args []string) resp.Value {
+	return expireGeneric(s, args, "expireat")
+}
+
+// 4) -> https://redis.io/docs/latest/commands/pexpireat
+// PExpireAt handles the PEXPIREAT command
+// It sets the expiry of a key to an absolute unix timestamp in milliseconds
+// This is also the form in which every expiry is written to the AOF
+func PExpireAt(s *storage.Storage, args []string) resp.Value {
+	return expireGeneric(s, args, "pexpireat")
+}
+
+// 5) -> https://redis.io/docs/latest/commands/ttl
+// TTL handles the TTL command
+// It returns the remaining time to live of a key in seconds,
+// -1 if the key has no expiry and -2 if the key does not exist
+func TTL(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("ttl")
+	}
+	ttl := s.TTL(args[0])
+	if ttl > 0 {
+		// Round to the closest second, like Redis does
+		ttl = (ttl + 500) / 1000
+	}
+	return resp.Integer(ttl)
+}
+
+// 6) -> https://redis.io/docs/latest/commands/pttl
+// PTTL handles the PTTL command
+// It works like TTL but returns the time to live in milliseconds
+func PTTL(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("pttl")
+	}
+	return resp.Integer(s.TTL(args[0]))
+}
+
+// 7) -> https://redis.io/docs/latest/commands/persist
+// Persist handles the PERSIST command
+// It removes the existing timeout on a key
+// Returns 1 if the timeout was removed, 0 if the key has no timeout or does not exist
+func Persist(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("persist")
+	}
+	if s.Persist(args[0]) {
+		return resp.Integer(1)
+	}
+	return resp.Integer(0)
+}
+
+// RewriteExpire converts a command with a relative or absolute expiry into the form
+// that is written to the AOF, so that replaying it later yields the same deadline
+// EXPIRE, PEXPIRE and EXPIREAT become PEXPIREAT, and the EX, PX and EXAT options of
+// SET become PXAT. Any other command, or a malformed one, is returned unchanged
+func RewriteExpire(cmd string, args []string) (string, []string) {
+	switch strings.ToUpper(cmd) {
+	case "EXPIRE", "PEXPIRE", "EXPIREAT":
+		if len(args) != 2 {
+			return cmd, args
+		}
+		at, ok := rewriteExpireArg(expireUnits[strings.ToUpper(cmd)], args[1])
+		if !ok {
+			return cmd, args
+		}
+		return "PEXPIREAT", []string{args[0], at}
+	case "SET":
+		for i := 2; i+1 < len(args); i++ {
+			unit := strings.ToUpper(args[i])
+			if unit != "EX" && unit != "PX" && unit != "EXAT" {
+				continue
+			}
+			at, ok := rewriteExpireArg(unit, args[i+1])
+			if !ok {
+				return cmd, args
+			}
+			rewritten := append([]string{}, args...)
+			rewritten[i], rewritten[i+1] = "PXAT", at
+			return cmd, rewritten
+		}
+	}
+	return cmd, args
+}
+
+// rewriteExpireArg converts a single TTL argument into absolute milliseconds
+func rewriteExpireArg(unit, arg string) (string, bool) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return "", false
+	}
+	at, ok := expireAt(unit, n)
+	if !ok {
+		return "", false
+	}
+	return strconv.FormatInt(at, 10), true
+}
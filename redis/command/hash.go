@@ -0,0 +1,210 @@
+package command
+
+import (
+	"math"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+)
+
+// 1) -> https://redis.io/docs/latest/commands/hset
+// HSet handles the HSET command
+// It sets the specified fields to their respective values in the hash stored at key
+// Returns the number of fields that were added
+func HSet(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return wrongArgs("hset")
+	}
+	added, err := s.HSet(args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(added))
+}
+
+// 2) -> https://redis.io/docs/latest/commands/hsetnx
+// HSetNX handles the HSETNX command
+// It sets a field in the hash stored at key only if the field does not yet exist
+// Returns 1 if the field was set, 0 otherwise
+func HSetNX(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("hsetnx")
+	}
+	ok, err := s.HSetNX(args[0], args[1], args[2])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if ok {
+		return resp.Integer(1)
+	}
+	return resp.Integer(0)
+}
+
+// 3) -> https://redis.io/docs/latest/commands/hget
+// HGet handles the HGET command
+// It returns the value associated with field in the hash stored at key
+func HGet(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("hget")
+	}
+	value, ok, err := s.HGet(args[0], args[1])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if !ok {
+		return resp.Null()
+	}
+	return resp.Bulk(value)
+}
+
+// 4) -> https://redis.io/docs/latest/commands/hmget
+// HMGet handles the HMGET command
+// It returns the values associated with the specified fields in the hash stored at key
+// Missing fields are returned as null
+func HMGet(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("hmget")
+	}
+	values, found, err := s.HMGet(args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	array := make([]resp.Value, len(values))
+	for i, value := range values {
+		if found[i] {
+			array[i] = resp.Bulk(value)
+		} else {
+			array[i] = resp.Null()
+		}
+	}
+	return resp.Array(array...)
+}
+
+// 5) -> https://redis.io/docs/latest/commands/hdel
+// HDel handles the HDEL command
+// It removes the specified fields from the hash stored at key
+// Returns the number of fields that were removed
+func HDel(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("hdel")
+	}
+	removed, err := s.HDel(args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(removed))
+}
+
+// 6) -> https://redis.io/docs/latest/commands/hgetall
+// HGetAll handles the HGETALL command
+// It returns all fields and values of the hash stored at key
+func HGetAll(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("hgetall")
+	}
+	pairs, err := s.HGetAll(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	// A map for RESP3 clients, the fields and values alternating in an array for RESP2
+	pairsValue := bulkArray(pairs)
+	pairsValue.Kind = resp.KindMap
+	return pairsValue
+}
+
+// 7) -> https://redis.io/docs/latest/commands/hkeys
+// HKeys handles the HKEYS command
+// It returns all field names in the hash stored at key
+func HKeys(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("hkeys")
+	}
+	fields, err := s.HKeys(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return bulkArray(fields)
+}
+
+// 8) -> https://redis.io/docs/latest/commands/hvals
+// HVals handles the HVALS command
+// It returns all values in the hash stored at key
+func HVals(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("hvals")
+	}
+	values, err := s.HVals(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return bulkArray(values)
+}
+
+// 9) -> https://redis.io/docs/latest/commands/hlen
+// HLen handles the HLEN command
+// It returns the number of fields contained in the hash stored at key
+func HLen(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("hlen")
+	}
+	length, err := s.HLen(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(length))
+}
+
+// 10) -> https://redis.io/docs/latest/commands/hexists
+// HExists handles the HEXISTS command
+// It returns 1 if field is an existing field in the hash stored at key, 0 otherwise
+func HExists(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("hexists")
+	}
+	ok, err := s.HExists(args[0], args[1])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if ok {
+		return resp.Integer(1)
+	}
+	return resp.Integer(0)
+}
+
+// 11) -> https://redis.io/docs/latest/commands/hincrby
+// HIncrBy handles the HINCRBY command
+// It increments the integer value of a field in the hash stored at key
+// Returns the value of the field after the increment
+func HIncrBy(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("hincrby")
+	}
+	amount, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return notInteger()
+	}
+	value, err := s.HIncrBy(args[0], args[1], amount)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(value)
+}
+
+// 12) -> https://redis.io/docs/latest/commands/hincrbyfloat
+// HIncrByFloat handles the HINCRBYFLOAT command
+// It increments the float value of a field in the hash stored at key
+// Returns the value of the field after the increment, as a bulk string
+func HIncrByFloat(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("hincrbyfloat")
+	}
+	amount, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return resp.Err("ERR value is not a valid float")
+	}
+	value, err := s.HIncrByFloat(args[0], args[1], amount)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Bulk(strconv.FormatFloat(value, 'f', -1, 64))
+}
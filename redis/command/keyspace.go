@@ -0,0 +1,189 @@
+// https://redis.io/docs/latest/develop/use/keyspace/
+package command
+
+import (
+	"math"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+)
+
+// 1) -> https://redis.io/docs/latest/commands/keys
+// Keys handles the KEYS command
+// It returns every key matching a glob-style pattern, in no particular order
+func Keys(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("keys")
+	}
+	return bulkArray(s.Keys(args[0]))
+}
+
+// 2) -> https://redis.io/docs/latest/commands/scan
+// Scan handles the SCAN command
+// It returns the next keys of an iteration over the database, with the cursor to continue it
+// Supported options: MATCH pattern, COUNT count and TYPE type
+func Scan(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("scan")
+	}
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return resp.Err("ERR invalid cursor")
+	}
+
+	opts := storage.ScanOptions{Count: 10}
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return syntaxError()
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			opts.Match = args[i+1]
+			if opts.Match == "*" {
+				opts.Match = ""
+			}
+		case "COUNT":
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return notInteger()
+			}
+			if count < 1 {
+				return syntaxError()
+			}
+			opts.Count = count
+		case "TYPE":
+			opts.Type = strings.ToLower(args[i+1])
+			if !knownType(opts.Type) {
+				return resp.Err("ERR unknown type name '" + args[i+1] + "'")
+			}
+		default:
+			return syntaxError()
+		}
+	}
+
+	keys, next := s.Scan(int(min(cursor, math.MaxInt32)), opts)
+	return resp.Array(resp.Bulk(strconv.Itoa(next)), bulkArray(keys))
+}
+
+// knownType reports whether name is a data type reported by TYPE
+func knownType(name string) bool {
+	for kind := storage.KindString; kind <= storage.KindStream; kind++ {
+		if kind.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 3) -> https://redis.io/docs/latest/commands/randomkey
+// RandomKey handles the RANDOMKEY command
+// Returns a random key of the database, or null if it is empty
+func RandomKey(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 0 {
+		return wrongArgs("randomkey")
+	}
+	key, ok := s.RandomKey()
+	if !ok {
+		return resp.Null()
+	}
+	return resp.Bulk(key)
+}
+
+// 4) -> https://redis.io/docs/latest/commands/rename
+// Rename handles the RENAME command
+// It renames a key, overwriting the new name if it exists
+func Rename(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("rename")
+	}
+	if _, err := s.Rename(args[0], args[1], false); err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.OK()
+}
+
+// 5) -> https://redis.io/docs/latest/commands/renamenx
+// RenameNX handles the RENAMENX command
+// It renames a key only if the new name does not exist
+// Returns 1 if the key was renamed, and 0 if the new name exists
+func RenameNX(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("renamenx")
+	}
+	renamed, err := s.Rename(args[0], args[1], true)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if !renamed {
+		return resp.Integer(0)
+	}
+	return resp.Integer(1)
+}
+
+// 6) -> https://redis.io/docs/latest/commands/type
+// Type handles the TYPE command
+// Returns the data type held by a key, or none if it does not exist
+func Type(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("type")
+	}
+	kind, ok := s.Type(args[0])
+	if !ok {
+		return resp.SimpleString("none")
+	}
+	return resp.SimpleString(kind.String())
+}
+
+// 7) -> https://redis.io/docs/latest/commands/copy
+// Copy handles the COPY command
+// It copies the value of a key, with its time to live, to another key
+// Supported options: DB destination-db and REPLACE
+// Returns 1 if the key was copied, and 0 if it does not exist or the destination exists
+func Copy(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("copy")
+	}
+
+	dst := s
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 == len(args) {
+				return syntaxError()
+			}
+			index, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return notInteger()
+			}
+			if dst = s.DB(index); dst == nil {
+				return dbOutOfRange()
+			}
+			i++
+		default:
+			return syntaxError()
+		}
+	}
+	if dst == s && args[0] == args[1] {
+		return resp.Err("ERR source and destination objects are the same")
+	}
+
+	if !s.Copy(args[0], dst, args[1], replace) {
+		return resp.Integer(0)
+	}
+	return resp.Integer(1)
+}
+
+// 8) -> https://redis.io/docs/latest/commands/touch
+// Touch handles the TOUCH command
+// Keys have no access time to update, so it only counts the keys that exist
+// Returns the number of keys that exist
+func Touch(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("touch")
+	}
+	return resp.Integer(int64(s.Exists(args...)))
+}
@@ -0,0 +1,447 @@
+package command
+
+import (
+	"errors"
+	"math"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bulkArray converts a slice of strings into a RESP array of bulk strings
+func bulkArray(items []string) resp.Value {
+	array := make([]resp.Value, len(items))
+	for i, item := range items {
+		array[i] = resp.Bulk(item)
+	}
+	return resp.Array(array...)
+}
+
+// parseDirection parses a LEFT or RIGHT argument
+// Returns true for LEFT and false if the argument is neither
+func parseDirection(arg string) (left bool, ok bool) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// parseTimeout parses the timeout of a blocking command, given in seconds with an optional fraction
+// A timeout of 0 blocks forever
+func parseTimeout(arg string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds*float64(time.Second) > math.MaxInt64 {
+		return 0, errors.New("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, errors.New("ERR timeout is negative")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// blmpopArgs holds the parsed arguments of BLMPOP
+type blmpopArgs struct {
+	timeout time.Duration
+	keys    []string
+	left    bool
+	count   int
+}
+
+// parseBLMPop parses BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func parseBLMPop(args []string) (blmpopArgs, resp.Value, bool) {
+	var a blmpopArgs
+	if len(args) < 4 {
+		return a, wrongArgs("blmpop"), false
+	}
+	timeout, err := parseTimeout(args[0])
+	if err != nil {
+		return a, resp.Err(err.Error()), false
+	}
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys <= 0 {
+		return a, resp.Err("ERR numkeys should be greater than 0"), false
+	}
+	if len(args) < numKeys+3 {
+		return a, syntaxError(), false
+	}
+	a.timeout, a.keys, a.count = timeout, args[2:2+numKeys], 1
+
+	rest := args[2+numKeys:]
+	left, ok := parseDirection(rest[0])
+	if !ok {
+		return a, syntaxError(), false
+	}
+	a.left = left
+	switch {
+	case len(rest) == 1:
+	case len(rest) == 3 && strings.ToUpper(rest[1]) == "COUNT":
+		count, err := strconv.Atoi(rest[2])
+		if err != nil || count <= 0 {
+			return a, resp.Err("ERR count should be greater than 0"), false
+		}
+		a.count = count
+	default:
+		return a, syntaxError(), false
+	}
+	return a, resp.Value{}, true
+}
+
+// pushGeneric implements LPUSH and RPUSH
+func pushGeneric(args []string, name string, push func(string, ...string) (int, error)) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs(name)
+	}
+	length, err := push(args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(length))
+}
+
+// popGeneric implements LPOP and RPOP
+func popGeneric(args []string, name string, pop func(string, int) ([]string, error)) resp.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongArgs(name)
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return resp.Err("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	values, err := pop(args[0], count)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if values == nil {
+		return resp.Null()
+	}
+	if len(args) == 2 {
+		return bulkArray(values)
+	}
+	return resp.Bulk(values[0])
+}
+
+// 1) -> https://redis.io/docs/latest/commands/lpush
+// LPush handles the LPUSH command
+// It inserts all the specified values at the head of the list stored at key
+// Returns the length of the list after the push operation
+func LPush(s *storage.Storage, args []string) resp.Value {
+	return pushGeneric(args, "lpush", s.LPush)
+}
+
+// 2) -> https://redis.io/docs/latest/commands/rpush
+// RPush handles the RPUSH command
+// It inserts all the specified values at the tail of the list stored at key
+// Returns the length of the list after the push operation
+func RPush(s *storage.Storage, args []string) resp.Value {
+	return pushGeneric(args, "rpush", s.RPush)
+}
+
+// 3) -> https://redis.io/docs/latest/commands/lpop
+// LPop handles the LPOP command
+// It removes and returns the first elements of the list stored at key
+// Without a count a single bulk string is returned, otherwise an array
+func LPop(s *storage.Storage, args []string) resp.Value {
+	return popGeneric(args, "lpop", s.LPop)
+}
+
+// 4) -> https://redis.io/docs/latest/commands/rpop
+// RPop handles the RPOP command
+// It removes and returns the last elements of the list stored at key
+// Without a count a single bulk string is returned, otherwise an array
+func RPop(s *storage.Storage, args []string) resp.Value {
+	return popGeneric(args, "rpop", s.RPop)
+}
+
+// 5) -> https://redis.io/docs/latest/commands/lrange
+// LRange handles the LRANGE command
+// It returns the elements of the list between start and stop, both inclusive
+func LRange(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("lrange")
+	}
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		return notInteger()
+	}
+	values, err := s.LRange(args[0], start, stop)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return bulkArray(values)
+}
+
+// 6) -> https://redis.io/docs/latest/commands/lindex
+// LIndex handles the LINDEX command
+// It returns the element at index in the list stored at key
+func LIndex(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("lindex")
+	}
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return notInteger()
+	}
+	value, ok, err := s.LIndex(args[0], index)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if !ok {
+		return resp.Null()
+	}
+	return resp.Bulk(value)
+}
+
+// 7) -> https://redis.io/docs/latest/commands/lset
+// LSet handles the LSET command
+// It sets the list element at index to value
+func LSet(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("lset")
+	}
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return notInteger()
+	}
+	if err := s.LSet(args[0], index, args[2]); err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.OK()
+}
+
+// 8) -> https://redis.io/docs/latest/commands/ltrim
+// LTrim handles the LTRIM command
+// It trims the list so that it only contains the specified range of elements
+func LTrim(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("ltrim")
+	}
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		return notInteger()
+	}
+	if err := s.LTrim(args[0], start, stop); err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.OK()
+}
+
+// 9) -> https://redis.io/docs/latest/commands/lrem
+// LRem handles the LREM command
+// It removes the first count occurrences of element from the list stored at key
+// Returns the number of removed elements
+func LRem(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("lrem")
+	}
+	count, err := strconv.Atoi(args[1])
+	if err != nil {
+		return notInteger()
+	}
+	removed, err := s.LRem(args[0], count, args[2])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(removed))
+}
+
+// 10) -> https://redis.io/docs/latest/commands/linsert
+// LInsert handles the LINSERT command
+// It inserts element before or after the pivot value in the list stored at key
+// Returns the list length after the insert, 0 if the key does not exist and -1 if pivot was not found
+func LInsert(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 4 {
+		return wrongArgs("linsert")
+	}
+	var before bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return syntaxError()
+	}
+	length, err := s.LInsert(args[0], before, args[2], args[3])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(length))
+}
+
+// 11) -> https://redis.io/docs/latest/commands/llen
+// LLen handles the LLEN command
+// It returns the length of the list stored at key
+func LLen(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("llen")
+	}
+	length, err := s.LLen(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(length))
+}
+
+// 12) -> https://redis.io/docs/latest/commands/lmove
+// LMove handles the LMOVE command
+// It atomically moves an element from one end of the source list to one end of the destination list
+// Returns the element being moved, or null if the source list does not exist
+func LMove(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 4 {
+		return wrongArgs("lmove")
+	}
+	fromLeft, ok1 := parseDirection(args[2])
+	toLeft, ok2 := parseDirection(args[3])
+	if !ok1 || !ok2 {
+		return syntaxError()
+	}
+	value, ok, err := s.LMove(args[0], args[1], fromLeft, toLeft)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if !ok {
+		return resp.Null()
+	}
+	return resp.Bulk(value)
+}
+
+// blockingPopGeneric implements BLPOP and BRPOP
+// The command never blocks here: when all lists are empty it returns null, and
+// the server parks the client until one of the keys is pushed to
+func blockingPopGeneric(s *storage.Storage, args []string, name string, left bool) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs(name)
+	}
+	if _, err := parseTimeout(args[len(args)-1]); err != nil {
+		return resp.Err(err.Error())
+	}
+
+	key, values, err := s.LMPop(args[:len(args)-1], 1, left)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if key == "" {
+		return resp.Null()
+	}
+	return bulkArray([]string{key, values[0]})
+}
+
+// 13) -> https://redis.io/docs/latest/commands/blpop
+// BLPop handles the BLPOP command
+// It pops an element from the head of the first non-empty list among the keys
+// Returns the key and the element, or null if all the lists are empty
+func BLPop(s *storage.Storage, args []string) resp.Value {
+	return blockingPopGeneric(s, args, "blpop", true)
+}
+
+// 14) -> https://redis.io/docs/latest/commands/brpop
+// BRPop handles the BRPOP command
+// It pops an element from the tail of the first non-empty list among the keys
+// Returns the key and the element, or null if all the lists are empty
+func BRPop(s *storage.Storage, args []string) resp.Value {
+	return blockingPopGeneric(s, args, "brpop", false)
+}
+
+// 15) -> https://redis.io/docs/latest/commands/blmove
+// BLMove handles the BLMOVE command
+// It is the blocking variant of LMOVE
+// Returns the element being moved, or null if the source list is empty
+func BLMove(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 5 {
+		return wrongArgs("blmove")
+	}
+	if _, err := parseTimeout(args[4]); err != nil {
+		return resp.Err(err.Error())
+	}
+	return LMove(s, args[:4])
+}
+
+// 16) -> https://redis.io/docs/latest/commands/blmpop
+// BLMPop handles the BLMPOP command
+// It pops up to count elements from one end of the first non-empty list among the keys
+// Returns the key and the elements, or null if all the lists are empty
+func BLMPop(s *storage.Storage, args []string) resp.Value {
+	a, errValue, ok := parseBLMPop(args)
+	if !ok {
+		return errValue
+	}
+
+	key, values, err := s.LMPop(a.keys, a.count, a.left)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if key == "" {
+		return resp.Null()
+	}
+	return resp.Array(resp.Bulk(key), bulkArray(values))
+}
+
+// BlockingKeys returns the keys a blocking command waits on, and for how long
+// A timeout of 0 means forever
+// Returns false if cmd is not a blocking command, or if its arguments are invalid
+func BlockingKeys(cmd string, args []string) ([]string, time.Duration, bool) {
+	switch strings.ToUpper(cmd) {
+	case "BLPOP", "BRPOP":
+		if len(args) < 2 {
+			return nil, 0, false
+		}
+		timeout, err := parseTimeout(args[len(args)-1])
+		return args[:len(args)-1], timeout, err == nil
+	case "BLMOVE":
+		if len(args) != 5 {
+			return nil, 0, false
+		}
+		timeout, err := parseTimeout(args[4])
+		return args[:1], timeout, err == nil
+	case "BLMPOP":
+		a, _, ok := parseBLMPop(args)
+		return a.keys, a.timeout, ok
+	case "XREAD":
+		return streamBlockingKeys(args, false)
+	case "XREADGROUP":
+		return streamBlockingKeys(args, true)
+	}
+	return nil, 0, false
+}
+
+// RewriteBlocking converts a blocking list command that popped elements into the
+// non-blocking command written to the AOF, as the pop must not block on replay
+// BLPOP and BRPOP become LPOP or RPOP of the key that was served, BLMPOP pops as
+// many elements as it returned, and BLMOVE becomes LMOVE
+// Any other command, or one that did not pop, is returned unchanged
+func RewriteBlocking(cmd string, args []string, result resp.Value) (string, []string) {
+	switch strings.ToUpper(cmd) {
+	case "BLPOP", "BRPOP":
+		if result.Kind == resp.KindArray && len(result.Array) == 2 {
+			return strings.ToUpper(cmd[1:]), []string{string(result.Array[0].Bulk)}
+		}
+	case "BLMPOP":
+		a, _, ok := parseBLMPop(args)
+		if ok && result.Kind == resp.KindArray && len(result.Array) == 2 {
+			name := "RPOP"
+			if a.left {
+				name = "LPOP"
+			}
+			return name, []string{string(result.Array[0].Bulk), strconv.Itoa(len(result.Array[1].Array))}
+		}
+	case "BLMOVE":
+		if result.Kind == resp.KindBulk && len(args) == 5 {
+			return "LMOVE", args[:4]
+		}
+	}
+	return cmd, args
+}
@@ -0,0 +1,55 @@
+package command
+
+import (
+	"redis/pubsub"
+	"redis/resp"
+	"strings"
+)
+
+// 1) -> https://redis.io/docs/latest/commands/publish
+// Publish handles the PUBLISH command
+// It posts a message to the given channel
+// Returns the number of clients that received the message
+func Publish(h *pubsub.Hub, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("publish")
+	}
+	return resp.Integer(int64(h.Publish(args[0], args[1])))
+}
+
+// 2) -> https://redis.io/docs/latest/commands/pubsub
+// PubSub handles the PUBSUB command and its subcommands:
+// CHANNELS [pattern] lists the active channels, NUMSUB [channel ...] returns the number of
+// subscribers of each channel and NUMPAT returns the number of subscribed patterns
+func PubSub(h *pubsub.Hub, args []string) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("pubsub")
+	}
+	switch strings.ToUpper(args[0]) {
+	case "CHANNELS":
+		if len(args) > 2 {
+			return wrongArgs("pubsub|channels")
+		}
+		pattern := ""
+		if len(args) == 2 {
+			pattern = args[1]
+		}
+		return bulkArray(h.Channels(pattern))
+	case "NUMSUB":
+		array := make([]resp.Value, 0, 2*(len(args)-1))
+		for _, channel := range args[1:] {
+			array = append(array,
+				resp.Bulk(channel),
+				resp.Integer(int64(h.NumSub(channel))),
+			)
+		}
+		return resp.Array(array...)
+	case "NUMPAT":
+		if len(args) != 1 {
+			return wrongArgs("pubsub|numpat")
+		}
+		return resp.Integer(int64(h.NumPat()))
+	default:
+		return resp.Err("ERR unknown subcommand '" + args[0] + "'. Try PUBSUB HELP.")
+	}
+}
@@ -0,0 +1,145 @@
+package command
+
+import (
+	"redis/resp"
+	"redis/storage"
+	"strings"
+)
+
+// groupCategories maps each documentation group to its ACL category
+var groupCategories = map[string]string{
+	"string":       "@string",
+	"generic":      "@keyspace",
+	"list":         "@list",
+	"hash":         "@hash",
+	"set":          "@set",
+	"sorted-set":   "@sortedset",
+	"stream":       "@stream",
+	"pubsub":       "@pubsub",
+	"transactions": "@transaction",
+	"connection":   "@connection",
+}
+
+// 1) -> https://redis.io/docs/latest/commands/command
+// Command handles the COMMAND command and its subcommands, which describe the commands
+// of the server from its table:
+// COMMAND and INFO [name ...] return the details of every or the given commands,
+// COUNT returns the number of commands and DOCS [name ...] returns their documentation
+func Command(s *storage.Storage, args []string) resp.Value {
+	if len(args) == 0 {
+		return commandInfo(commandNames)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "INFO":
+		if len(args) == 1 {
+			return commandInfo(commandNames)
+		}
+		return commandInfo(args[1:])
+	case "COUNT":
+		if len(args) != 1 {
+			return wrongArgs("command|count")
+		}
+		return resp.Integer(int64(len(commandNames)))
+	case "DOCS":
+		if len(args) == 1 {
+			return commandDocs(commandNames)
+		}
+		return commandDocs(args[1:])
+	default:
+		return resp.Err("ERR unknown subcommand '" + args[0] + "'. Try COMMAND HELP.")
+	}
+}
+
+// commandInfo returns the details of the given commands, with a null for the unknown ones:
+// name, arity, flags, first key, last key, step, ACL categories, tips, key specs and subcommands
+func commandInfo(names []string) resp.Value {
+	array := make([]resp.Value, len(names))
+	for i, name := range names {
+		d, ok := Lookup(name)
+		if !ok {
+			array[i] = resp.Null()
+			continue
+		}
+		array[i] = resp.Array(
+			resp.Bulk(strings.ToLower(d.Name)),
+			resp.Integer(int64(d.Arity)),
+			statusSet(d.flagNames()),
+			resp.Integer(int64(d.Keys.First)),
+			resp.Integer(int64(d.Keys.Last)),
+			resp.Integer(int64(d.Keys.Step)),
+			statusSet(d.categories()),
+			resp.Array(),
+			resp.Array(),
+			resp.Array(),
+		)
+	}
+	return resp.Array(array...)
+}
+
+// commandDocs returns a map from the name of the given commands to their documentation
+// Unknown commands are left out
+func commandDocs(names []string) resp.Value {
+	array := make([]resp.Value, 0, 2*len(names))
+	for _, name := range names {
+		d, ok := Lookup(name)
+		if !ok {
+			continue
+		}
+		array = append(array,
+			resp.Bulk(strings.ToLower(d.Name)),
+			resp.Map(
+				resp.Bulk("summary"), resp.Bulk(d.Summary),
+				resp.Bulk("since"), resp.Bulk(d.Since),
+				resp.Bulk("group"), resp.Bulk(d.Group),
+			),
+		)
+	}
+	return resp.Map(array...)
+}
+
+// statusSet returns a set of simple strings, as Redis sends flags and categories
+func statusSet(items []string) resp.Value {
+	array := make([]resp.Value, len(items))
+	for i, item := range items {
+		array[i] = resp.SimpleString(item)
+	}
+	return resp.Set(array...)
+}
+
+// flagNames returns the names of the flags of the command
+func (d *Descriptor) flagNames() []string {
+	var names []string
+	for _, f := range flagNames {
+		if d.Has(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// categories returns the ACL categories of the command, derived from its flags and group
+func (d *Descriptor) categories() []string {
+	var categories []string
+	if d.Has(FlagWrite) {
+		categories = append(categories, "@write")
+	}
+	if d.Has(FlagReadOnly) {
+		categories = append(categories, "@read")
+	}
+	if category, ok := groupCategories[d.Group]; ok {
+		categories = append(categories, category)
+	}
+	if d.Has(FlagAdmin) {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if d.Has(FlagBlocking) {
+		categories = append(categories, "@blocking")
+	}
+	if d.Has(FlagFast) {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	return categories
+}
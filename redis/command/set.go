@@ -0,0 +1,266 @@
+package command
+
+import (
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+)
+
+// 1) -> https://redis.io/docs/latest/commands/sadd
+// SAdd handles the SADD command
+// It adds the specified members to the set stored at key
+// Returns the number of members that were added
+func SAdd(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("sadd")
+	}
+	added, err := s.SAdd(args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(added))
+}
+
+// 2) -> https://redis.io/docs/latest/commands/srem
+// SRem handles the SREM command
+// It removes the specified members from the set stored at key
+// Returns the number of members that were removed
+func SRem(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("srem")
+	}
+	removed, err := s.SRem(args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(removed))
+}
+
+// 3) -> https://redis.io/docs/latest/commands/smembers
+// SMembers handles the SMEMBERS command
+// It returns all the members of the set stored at key
+func SMembers(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("smembers")
+	}
+	members, err := s.SMembers(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return setValue(members)
+}
+
+// 4) -> https://redis.io/docs/latest/commands/sismember
+// SIsMember handles the SISMEMBER command
+// It returns 1 if member is a member of the set stored at key, 0 otherwise
+func SIsMember(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("sismember")
+	}
+	ok, err := s.SIsMember(args[0], args[1])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if ok {
+		return resp.Integer(1)
+	}
+	return resp.Integer(0)
+}
+
+// 5) -> https://redis.io/docs/latest/commands/smismember
+// SMIsMember handles the SMISMEMBER command
+// It returns, for every member, 1 if it belongs to the set stored at key and 0 otherwise
+func SMIsMember(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("smismember")
+	}
+	found, err := s.SMIsMember(args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	array := make([]resp.Value, len(found))
+	for i, ok := range found {
+		array[i] = resp.Integer(0)
+		if ok {
+			array[i].Num = 1
+		}
+	}
+	return resp.Array(array...)
+}
+
+// 6) -> https://redis.io/docs/latest/commands/scard
+// SCard handles the SCARD command
+// It returns the number of members of the set stored at key
+func SCard(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("scard")
+	}
+	count, err := s.SCard(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(count))
+}
+
+// 7) -> https://redis.io/docs/latest/commands/spop
+// SPop handles the SPOP command
+// It removes and returns one or more random members from the set stored at key
+// Without a count a single bulk string is returned, otherwise an array
+func SPop(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongArgs("spop")
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return resp.Err("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	members, err := s.SPop(args[0], count)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if len(args) == 2 {
+		return bulkArray(members)
+	}
+	if len(members) == 0 {
+		return resp.Null()
+	}
+	return resp.Bulk(members[0])
+}
+
+// 8) -> https://redis.io/docs/latest/commands/srandmember
+// maxRandomCount is the most members SRANDMEMBER returns for a negative count
+// Redis refuses counts below -LONG_MAX/2, but the reply is built in memory before it is
+// sent, so like the number of arguments of a command it is bounded much lower
+const maxRandomCount = resp.DefaultMaxMultiBulkLen
+
+// SRandMember handles the SRANDMEMBER command
+// It returns one or more random members from the set stored at key without removing them
+// A negative count allows the same member to be returned multiple times
+func SRandMember(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongArgs("srandmember")
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return notInteger()
+		}
+		if n < -maxRandomCount {
+			return resp.Err("ERR value is out of range")
+		}
+		count = n
+	}
+
+	members, err := s.SRandMember(args[0], count)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if len(args) == 2 {
+		return bulkArray(members)
+	}
+	if len(members) == 0 {
+		return resp.Null()
+	}
+	return resp.Bulk(members[0])
+}
+
+// setValue builds the reply for the members of a set, a set for RESP3 clients and an array for RESP2
+func setValue(members []string) resp.Value {
+	value := bulkArray(members)
+	value.Kind = resp.KindSet
+	return value
+}
+
+// setOperationGeneric implements SINTER, SUNION and SDIFF
+func setOperationGeneric(s *storage.Storage, args []string, name string, op storage.SetOp) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs(name)
+	}
+	members, err := s.SetOperation(op, args...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return setValue(members)
+}
+
+// setOperationStoreGeneric implements SINTERSTORE, SUNIONSTORE and SDIFFSTORE
+func setOperationStoreGeneric(s *storage.Storage, args []string, name string, op storage.SetOp) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs(name)
+	}
+	count, err := s.SetOperationStore(op, args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(count))
+}
+
+// 9) -> https://redis.io/docs/latest/commands/sinter
+// SInter handles the SINTER command
+// It returns the members of the set resulting from the intersection of all the given sets
+func SInter(s *storage.Storage, args []string) resp.Value {
+	return setOperationGeneric(s, args, "sinter", storage.SetInter)
+}
+
+// 10) -> https://redis.io/docs/latest/commands/sunion
+// SUnion handles the SUNION command
+// It returns the members of the set resulting from the union of all the given sets
+func SUnion(s *storage.Storage, args []string) resp.Value {
+	return setOperationGeneric(s, args, "sunion", storage.SetUnion)
+}
+
+// 11) -> https://redis.io/docs/latest/commands/sdiff
+// SDiff handles the SDIFF command
+// It returns the members of the first set that are not in any of the following sets
+func SDiff(s *storage.Storage, args []string) resp.Value {
+	return setOperationGeneric(s, args, "sdiff", storage.SetDiff)
+}
+
+// 12) -> https://redis.io/docs/latest/commands/sinterstore
+// SInterStore handles the SINTERSTORE command
+// It works like SINTER but stores the result in destination
+// Returns the number of members in the resulting set
+func SInterStore(s *storage.Storage, args []string) resp.Value {
+	return setOperationStoreGeneric(s, args, "sinterstore", storage.SetInter)
+}
+
+// 13) -> https://redis.io/docs/latest/commands/sunionstore
+// SUnionStore handles the SUNIONSTORE command
+// It works like SUNION but stores the result in destination
+// Returns the number of members in the resulting set
+func SUnionStore(s *storage.Storage, args []string) resp.Value {
+	return setOperationStoreGeneric(s, args, "sunionstore", storage.SetUnion)
+}
+
+// 14) -> https://redis.io/docs/latest/commands/sdiffstore
+// SDiffStore handles the SDIFFSTORE command
+// It works like SDIFF but stores the result in destination
+// Returns the number of members in the resulting set
+func SDiffStore(s *storage.Storage, args []string) resp.Value {
+	return setOperationStoreGeneric(s, args, "sdiffstore", storage.SetDiff)
+}
+
+// RewriteSPop returns the command to write to the AOF for an executed SPOP
+// The members are popped at random, so the command is written as an SREM of the members
+// it returned, which replays the same way
+// Any other command is returned unchanged
+func RewriteSPop(cmd string, args []string, result resp.Value) (string, []string) {
+	if strings.ToUpper(cmd) != "SPOP" {
+		return cmd, args
+	}
+	rewritten := []string{args[0]}
+	if result.Kind == resp.KindBulk {
+		rewritten = append(rewritten, string(result.Bulk))
+	}
+	for _, member := range result.Array {
+		rewritten = append(rewritten, string(member.Bulk))
+	}
+	return "SREM", rewritten
+}
@@ -0,0 +1,944 @@
+package command
+
+import (
+	"errors"
+	"math"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// idValue builds the bulk string reply for a stream ID
+func idValue(id storage.StreamID) resp.Value {
+	return resp.Bulk(id.String())
+}
+
+// entryValue builds the reply for a stream entry: its ID followed by its field-value pairs
+// Entries that were deleted from the stream are reported with a null in place of the fields
+func entryValue(entry storage.StreamEntry) resp.Value {
+	fields := resp.Null()
+	if entry.Fields != nil {
+		fields = bulkArray(entry.Fields)
+	}
+	return resp.Array(idValue(entry.ID), fields)
+}
+
+// entriesValue builds the reply for a list of stream entries
+// With justID only the IDs are returned
+func entriesValue(entries []storage.StreamEntry, justID bool) resp.Value {
+	array := make([]resp.Value, len(entries))
+	for i, entry := range entries {
+		if justID {
+			array[i] = idValue(entry.ID)
+		} else {
+			array[i] = entryValue(entry)
+		}
+	}
+	return resp.Array(array...)
+}
+
+// streamsValue builds the reply of XREAD and XREADGROUP, which is null when nothing was read
+func streamsValue(streams []storage.StreamEntries) resp.Value {
+	if len(streams) == 0 {
+		return resp.Null()
+	}
+	array := make([]resp.Value, len(streams))
+	for i, st := range streams {
+		array[i] = resp.Array(
+			resp.Bulk(st.Key),
+			entriesValue(st.Entries, false),
+		)
+	}
+	return resp.Array(array...)
+}
+
+// parseRangeBound parses an XRANGE bound such as "-", "+", "1526985054069", "1526985054069-0"
+// or an exclusive one such as "(1526985054069-0"
+// A missing sequence part defaults to 0 for the start of the range and to the maximum for the end
+func parseRangeBound(arg string, start bool) (storage.StreamID, error) {
+	switch arg {
+	case "-":
+		return storage.StreamID{}, nil
+	case "+":
+		return storage.MaxStreamID, nil
+	}
+
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+	missingSeq := storage.MaxStreamID.Seq
+	if start {
+		missingSeq = 0
+	}
+	id, err := storage.ParseStreamID(arg, missingSeq)
+	if err != nil || !exclusive {
+		return id, err
+	}
+
+	ok := false
+	if start {
+		id, ok = id.Next()
+	} else {
+		id, ok = id.Prev()
+	}
+	if !ok {
+		if start {
+			return id, errors.New("ERR invalid start ID for the interval")
+		}
+		return id, errors.New("ERR invalid end ID for the interval")
+	}
+	return id, nil
+}
+
+// trimArgs collects the MAXLEN, MINID and LIMIT options of XADD and XTRIM
+type trimArgs struct {
+	opts   storage.TrimOptions
+	approx bool // The "~" modifier was given
+	limit  bool // The LIMIT option was given
+}
+
+// parse consumes the trimming option at args[i], if there is one
+// Returns the number of arguments consumed, which is 0 if args[i] is not a trimming option
+func (t *trimArgs) parse(args []string, i int) (int, error) {
+	option := strings.ToUpper(args[i])
+	switch option {
+	case "MAXLEN", "MINID":
+	case "LIMIT":
+		if i+1 >= len(args) {
+			return 0, storage.ErrSyntax
+		}
+		limit, err := strconv.Atoi(args[i+1])
+		if err != nil || limit < 0 {
+			return 0, errors.New("ERR The LIMIT argument must be >= 0.")
+		}
+		t.opts.Limit, t.limit = limit, true
+		return 2, nil
+	default:
+		return 0, nil
+	}
+
+	if t.opts.Strategy != storage.TrimNone {
+		return 0, errors.New("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+	}
+	consumed := 1
+	if i+1 < len(args) && (args[i+1] == "~" || args[i+1] == "=") {
+		t.approx = args[i+1] == "~"
+		consumed++
+	}
+	if i+consumed >= len(args) {
+		return 0, storage.ErrSyntax
+	}
+	threshold := args[i+consumed]
+	consumed++
+
+	if option == "MAXLEN" {
+		maxLen, err := strconv.Atoi(threshold)
+		if err != nil {
+			return 0, errors.New(notInteger().Str)
+		}
+		if maxLen < 0 {
+			return 0, errors.New("ERR The MAXLEN argument must be >= 0.")
+		}
+		t.opts.Strategy, t.opts.MaxLen = storage.TrimMaxLen, maxLen
+	} else {
+		minID, err := storage.ParseStreamID(threshold, 0)
+		if err != nil {
+			return 0, err
+		}
+		t.opts.Strategy, t.opts.MinID = storage.TrimMinID, minID
+	}
+	return consumed, nil
+}
+
+// options validates the combination of trimming options and returns them
+func (t *trimArgs) options() (storage.TrimOptions, error) {
+	if t.limit && !t.approx {
+		return t.opts, errors.New("ERR syntax error, LIMIT cannot be used without the special ~ option")
+	}
+	return t.opts, nil
+}
+
+// xaddArgs holds the parsed arguments of XADD
+type xaddArgs struct {
+	noMkStream bool
+	trim       storage.TrimOptions
+	idIndex    int // Index of the ID argument
+	id         storage.XAddID
+}
+
+// parseXAdd parses the arguments of XADD that come before the field-value pairs
+func parseXAdd(args []string) (xaddArgs, error) {
+	var parsed xaddArgs
+	var trim trimArgs
+	i := 1
+	for ; i < len(args); i++ {
+		if strings.ToUpper(args[i]) == "NOMKSTREAM" {
+			parsed.noMkStream = true
+			continue
+		}
+		consumed, err := trim.parse(args, i)
+		if err != nil {
+			return parsed, err
+		}
+		if consumed == 0 {
+			break
+		}
+		i += consumed - 1
+	}
+
+	var err error
+	if parsed.trim, err = trim.options(); err != nil {
+		return parsed, err
+	}
+	if i >= len(args) {
+		return parsed, storage.ErrSyntax
+	}
+
+	parsed.idIndex = i
+	switch arg := args[i]; {
+	case arg == "*":
+		parsed.id = storage.XAddID{AutoMs: true, AutoSeq: true}
+	case strings.HasSuffix(arg, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(arg, "-*"), 10, 64)
+		if err != nil {
+			return parsed, storage.ErrInvalidStreamID
+		}
+		parsed.id = storage.XAddID{ID: storage.StreamID{Ms: ms}, AutoSeq: true}
+	default:
+		id, err := storage.ParseStreamID(arg, 0)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.id = storage.XAddID{ID: id}
+	}
+	return parsed, nil
+}
+
+// 1) -> https://redis.io/docs/latest/commands/xadd
+// XAdd handles the XADD command
+// It appends a new entry with the given field-value pairs to the stream stored at key
+// The ID is generated with "*", gets an automatic sequence number with "<ms>-*", or is given explicitly
+// Supported options: NOMKSTREAM, MAXLEN | MINID [= | ~] threshold [LIMIT count]
+// Returns the ID of the added entry, or null if the stream does not exist and NOMKSTREAM is given
+func XAdd(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 4 {
+		return wrongArgs("xadd")
+	}
+	parsed, err := parseXAdd(args)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	fields := args[parsed.idIndex+1:]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return wrongArgs("xadd")
+	}
+
+	id, ok, err := s.XAdd(args[0], parsed.id, fields, parsed.noMkStream, parsed.trim)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if !ok {
+		return resp.Null()
+	}
+	return idValue(id)
+}
+
+// rangeGeneric implements XRANGE and XREVRANGE
+func rangeGeneric(s *storage.Storage, args []string, name string, reverse bool) resp.Value {
+	if len(args) != 3 && len(args) != 5 {
+		return wrongArgs(name)
+	}
+
+	startArg, endArg := args[1], args[2]
+	if reverse {
+		startArg, endArg = endArg, startArg
+	}
+	start, err := parseRangeBound(startArg, true)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	end, err := parseRangeBound(endArg, false)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+
+	count := 0
+	if len(args) == 5 {
+		if strings.ToUpper(args[3]) != "COUNT" {
+			return syntaxError()
+		}
+		if count, err = strconv.Atoi(args[4]); err != nil {
+			return notInteger()
+		}
+		if count <= 0 {
+			return resp.Array()
+		}
+	}
+
+	entries, err := s.XRange(args[0], start, end, count, reverse)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return entriesValue(entries, false)
+}
+
+// 2) -> https://redis.io/docs/latest/commands/xrange
+// XRange handles the XRANGE command
+// It returns the entries of the stream with an ID between start and end, both inclusive
+// "-" and "+" stand for the smallest and greatest IDs, and a "(" prefix makes a bound exclusive
+func XRange(s *storage.Storage, args []string) resp.Value {
+	return rangeGeneric(s, args, "xrange", false)
+}
+
+// 3) -> https://redis.io/docs/latest/commands/xrevrange
+// XRevRange handles the XREVRANGE command
+// It works like XRANGE, but returns the entries in reverse order and takes the end bound first
+func XRevRange(s *storage.Storage, args []string) resp.Value {
+	return rangeGeneric(s, args, "xrevrange", true)
+}
+
+// 4) -> https://redis.io/docs/latest/commands/xlen
+// XLen handles the XLEN command
+// It returns the number of entries in the stream stored at key
+func XLen(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("xlen")
+	}
+	length, err := s.XLen(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(length))
+}
+
+// 5) -> https://redis.io/docs/latest/commands/xtrim
+// XTrim handles the XTRIM command
+// It evicts the oldest entries of the stream stored at key: MAXLEN keeps the given number of
+// entries and MINID evicts entries with a lower ID
+// Returns the number of evicted entries
+func XTrim(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("xtrim")
+	}
+	var trim trimArgs
+	for i := 1; i < len(args); {
+		consumed, err := trim.parse(args, i)
+		if err != nil {
+			return resp.Err(err.Error())
+		}
+		if consumed == 0 {
+			return syntaxError()
+		}
+		i += consumed
+	}
+	opts, err := trim.options()
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if opts.Strategy == storage.TrimNone {
+		return syntaxError()
+	}
+
+	evicted, err := s.XTrim(args[0], opts)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(evicted))
+}
+
+// readArgs holds the parsed arguments of XREAD and XREADGROUP
+type readArgs struct {
+	group    string
+	consumer string
+	count    int
+	noAck    bool
+	block    bool          // Set by BLOCK, which the server handles: the command itself never blocks
+	timeout  time.Duration // Timeout given to BLOCK, 0 waits forever
+	reads    []storage.StreamRead
+}
+
+// parseRead parses the arguments of XREAD, or of XREADGROUP when group is set
+func parseRead(args []string, group bool) (readArgs, error) {
+	var parsed readArgs
+	name := "xread"
+	if group {
+		name = "xreadgroup"
+	}
+
+	i := 0
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		if option == "STREAMS" {
+			i++
+			break
+		}
+		switch {
+		case option == "COUNT" && i+1 < len(args):
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return parsed, errors.New(notInteger().Str)
+			}
+			parsed.count = count
+			i++
+		case option == "BLOCK" && i+1 < len(args):
+			timeout, err := parseBlockTimeout(args[i+1])
+			if err != nil {
+				return parsed, err
+			}
+			parsed.block, parsed.timeout = true, timeout
+			i++
+		case option == "GROUP" && group && i+2 < len(args):
+			parsed.group, parsed.consumer = args[i+1], args[i+2]
+			i += 2
+		case option == "NOACK" && group:
+			parsed.noAck = true
+		default:
+			return parsed, storage.ErrSyntax
+		}
+	}
+
+	if group && parsed.group == "" {
+		return parsed, errors.New("ERR Missing GROUP option for XREADGROUP")
+	}
+	streams := args[min(i, len(args)):]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return parsed, errors.New("ERR Unbalanced '" + name + "' list of streams: " +
+			"for each stream key an ID or '$' must be specified.")
+	}
+
+	keys, ids := streams[:len(streams)/2], streams[len(streams)/2:]
+	parsed.reads = make([]storage.StreamRead, len(keys))
+	for j, key := range keys {
+		read := storage.StreamRead{Key: key}
+		switch {
+		case ids[j] == "$" && !group:
+			read.Last = true
+		case ids[j] == "$":
+			return parsed, errors.New("ERR The $ ID is meaningless in the context of XREADGROUP: " +
+				"you want to read the history of this consumer by specifying a proper ID, " +
+				"or use the > ID to get new messages. The $ ID would just return an empty result set.")
+		case ids[j] == ">" && group:
+			read.New = true
+		case ids[j] == ">":
+			return parsed, errors.New("ERR The > ID can be specified only when calling XREADGROUP " +
+				"using the GROUP <group> <consumer> option.")
+		default:
+			id, err := storage.ParseStreamID(ids[j], 0)
+			if err != nil {
+				return parsed, err
+			}
+			read.ID = id
+		}
+		parsed.reads[j] = read
+	}
+	return parsed, nil
+}
+
+// parseBlockTimeout parses the timeout of BLOCK, in milliseconds
+func parseBlockTimeout(arg string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || ms > math.MaxInt64/int64(time.Millisecond) {
+		return 0, errors.New("ERR timeout is not an integer or out of range")
+	}
+	if ms < 0 {
+		return 0, errors.New("ERR timeout is negative")
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// streamBlockingKeys returns the streams XREAD or XREADGROUP waits on with BLOCK, and for how long
+// Returns false without BLOCK, or when XREADGROUP reads the pending entries of the consumer,
+// which like in Redis never blocks
+func streamBlockingKeys(args []string, group bool) ([]string, time.Duration, bool) {
+	parsed, err := parseRead(args, group)
+	if err != nil || !parsed.block {
+		return nil, 0, false
+	}
+	keys := make([]string, len(parsed.reads))
+	for i, read := range parsed.reads {
+		if group && !read.New {
+			return nil, 0, false
+		}
+		keys[i] = read.Key
+	}
+	return keys, parsed.timeout, true
+}
+
+// BlockedArgs returns the arguments a blocked command runs with whenever its keys are modified
+// The $ IDs of XREAD become the last ID of their stream at the time the client blocked, so
+// that only the entries added since are returned. Other commands keep their arguments
+func BlockedArgs(s *storage.Storage, cmd string, args []string) []string {
+	if strings.ToUpper(cmd) != "XREAD" {
+		return args
+	}
+	parsed, err := parseRead(args, false)
+	if err != nil {
+		return args
+	}
+	// The IDs are the last arguments, one for each stream
+	resolved := append([]string{}, args...)
+	ids := resolved[len(resolved)-len(parsed.reads):]
+	for i, read := range parsed.reads {
+		if read.Last {
+			id, _ := s.XLastID(read.Key)
+			ids[i] = id.String()
+		}
+	}
+	return resolved
+}
+
+// 6) -> https://redis.io/docs/latest/commands/xread
+// XRead handles the XREAD command
+// It returns, for each stream, up to COUNT entries with an ID greater than the given one
+// Streams with nothing to read are left out, and null is returned if nothing was read at all,
+// on which the server blocks the client when BLOCK is given
+func XRead(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("xread")
+	}
+	parsed, err := parseRead(args, false)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	streams, err := s.XRead(parsed.reads, parsed.count)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return streamsValue(streams)
+}
+
+// parseGroupID parses the ID argument of XGROUP CREATE and XGROUP SETID, where "$" is the last entry
+func parseGroupID(arg string) (storage.StreamID, bool, error) {
+	if arg == "$" {
+		return storage.StreamID{}, true, nil
+	}
+	id, err := storage.ParseStreamID(arg, 0)
+	return id, false, err
+}
+
+// 7) -> https://redis.io/docs/latest/commands/xgroup
+// XGroup handles the XGROUP command and its subcommands:
+// CREATE key group id|$ [MKSTREAM] [ENTRIESREAD n], SETID key group id|$ [ENTRIESREAD n],
+// DESTROY key group, CREATECONSUMER key group consumer and DELCONSUMER key group consumer
+func XGroup(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("xgroup")
+	}
+	subcommand := strings.ToUpper(args[0])
+	switch subcommand {
+	case "CREATE", "SETID":
+		if len(args) < 4 {
+			return wrongArgs("xgroup|" + strings.ToLower(subcommand))
+		}
+		id, last, err := parseGroupID(args[3])
+		if err != nil {
+			return resp.Err(err.Error())
+		}
+		mkStream := false
+		for i := 4; i < len(args); i++ {
+			switch option := strings.ToUpper(args[i]); {
+			case option == "MKSTREAM" && subcommand == "CREATE":
+				mkStream = true
+			case option == "ENTRIESREAD" && i+1 < len(args):
+				// Only used by Redis to compute the consumer group lag, which is not tracked here
+				if _, err := strconv.ParseInt(args[i+1], 10, 64); err != nil {
+					return notInteger()
+				}
+				i++
+			default:
+				return syntaxError()
+			}
+		}
+		if subcommand == "CREATE" {
+			err = s.XGroupCreate(args[1], args[2], id, last, mkStream)
+		} else {
+			err = s.XGroupSetID(args[1], args[2], id, last)
+		}
+		if err != nil {
+			return resp.Err(err.Error())
+		}
+		return resp.OK()
+	case "DESTROY":
+		if len(args) != 3 {
+			return wrongArgs("xgroup|destroy")
+		}
+		destroyed, err := s.XGroupDestroy(args[1], args[2])
+		if err != nil {
+			return resp.Err(err.Error())
+		}
+		if !destroyed {
+			return resp.Integer(0)
+		}
+		return resp.Integer(1)
+	case "CREATECONSUMER":
+		if len(args) != 4 {
+			return wrongArgs("xgroup|createconsumer")
+		}
+		created, err := s.XGroupCreateConsumer(args[1], args[2], args[3])
+		if err != nil {
+			return resp.Err(err.Error())
+		}
+		if !created {
+			return resp.Integer(0)
+		}
+		return resp.Integer(1)
+	case "DELCONSUMER":
+		if len(args) != 4 {
+			return wrongArgs("xgroup|delconsumer")
+		}
+		pending, err := s.XGroupDelConsumer(args[1], args[2], args[3])
+		if err != nil {
+			return resp.Err(err.Error())
+		}
+		return resp.Integer(int64(pending))
+	default:
+		return resp.Err("ERR unknown subcommand '" + args[0] + "'. Try XGROUP HELP.")
+	}
+}
+
+// 8) -> https://redis.io/docs/latest/commands/xreadgroup
+// XReadGroup handles the XREADGROUP command
+// It reads entries on behalf of a consumer of a consumer group: the ">" ID delivers entries
+// never delivered to the group and adds them to the consumer's pending entries list (unless
+// NOACK is given), while any other ID returns the consumer's pending entries after that ID
+func XReadGroup(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 6 {
+		return wrongArgs("xreadgroup")
+	}
+	parsed, err := parseRead(args, true)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	streams, err := s.XReadGroup(parsed.group, parsed.consumer, parsed.reads, parsed.count, parsed.noAck)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return streamsValue(streams)
+}
+
+// parseIDs parses a list of stream IDs
+func parseIDs(args []string) ([]storage.StreamID, error) {
+	ids := make([]storage.StreamID, len(args))
+	for i, arg := range args {
+		id, err := storage.ParseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// 9) -> https://redis.io/docs/latest/commands/xack
+// XAck handles the XACK command
+// It removes the given entries from the pending entries list of the consumer group
+// Returns the number of entries that were acknowledged
+func XAck(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("xack")
+	}
+	ids, err := parseIDs(args[2:])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	acked, err := s.XAck(args[0], args[1], ids...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(acked))
+}
+
+// 10) -> https://redis.io/docs/latest/commands/xpending
+// XPending handles the XPENDING command
+// Without a range it returns a summary: the number of pending entries, the smallest and greatest
+// pending IDs and the number of pending entries of every consumer
+// With [IDLE min-idle-time] start end count [consumer] it returns the details of each pending
+// entry: its ID, consumer, idle time in milliseconds and delivery count
+func XPending(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("xpending")
+	}
+	if len(args) == 2 {
+		summary, err := s.XPendingSummary(args[0], args[1])
+		if err != nil {
+			return resp.Err(err.Error())
+		}
+		if summary.Count == 0 {
+			null := resp.Null()
+			return resp.Array(resp.Integer(0), null, null, null)
+		}
+		consumers := make([]resp.Value, len(summary.Consumers))
+		for i, c := range summary.Consumers {
+			consumers[i] = bulkArray([]string{c.Name, strconv.Itoa(c.Count)})
+		}
+		return resp.Array(
+			resp.Integer(int64(summary.Count)),
+			idValue(summary.Smallest),
+			idValue(summary.Greatest),
+			resp.Array(consumers...),
+		)
+	}
+
+	rest := args[2:]
+	var minIdle int64
+	if strings.ToUpper(rest[0]) == "IDLE" {
+		if len(rest) < 2 {
+			return syntaxError()
+		}
+		var err error
+		if minIdle, err = strconv.ParseInt(rest[1], 10, 64); err != nil {
+			return notInteger()
+		}
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		return syntaxError()
+	}
+	start, err := parseRangeBound(rest[0], true)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	end, err := parseRangeBound(rest[1], false)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	count, err := strconv.Atoi(rest[2])
+	if err != nil {
+		return notInteger()
+	}
+	consumerName := ""
+	if len(rest) == 4 {
+		consumerName = rest[3]
+	}
+
+	pending, err := s.XPendingRange(args[0], args[1], start, end, max(count, 0), consumerName, minIdle)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	array := make([]resp.Value, len(pending))
+	for i, pe := range pending {
+		array[i] = resp.Array(
+			idValue(pe.ID),
+			resp.Bulk(pe.Consumer),
+			resp.Integer(pe.Idle),
+			resp.Integer(int64(pe.DeliveryCount)),
+		)
+	}
+	return resp.Array(array...)
+}
+
+// parseMinIdle parses the min-idle-time argument of XCLAIM and XAUTOCLAIM
+func parseMinIdle(arg, name string) (int64, error) {
+	minIdle, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errors.New("ERR Invalid min-idle-time argument for " + name)
+	}
+	return max(minIdle, 0), nil
+}
+
+// xclaimArgs holds the parsed arguments of XCLAIM
+type xclaimArgs struct {
+	minIdle int64
+	ids     []storage.StreamID
+	idCount int // Number of ID arguments, which follow min-idle-time
+	opts    storage.XClaimOptions
+}
+
+// parseXClaim parses the arguments of XCLAIM after the key, group and consumer
+func parseXClaim(args []string) (xclaimArgs, error) {
+	parsed := xclaimArgs{opts: storage.XClaimOptions{RetryCount: -1}}
+	var err error
+	if parsed.minIdle, err = parseMinIdle(args[0], "XCLAIM"); err != nil {
+		return parsed, err
+	}
+
+	// IDs come first, the options start at the first argument that is not an ID
+	i := 1
+	for ; i < len(args); i++ {
+		id, err := storage.ParseStreamID(args[i], 0)
+		if err != nil {
+			break
+		}
+		parsed.ids = append(parsed.ids, id)
+	}
+	parsed.idCount = len(parsed.ids)
+
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch {
+		case option == "FORCE":
+			parsed.opts.Force = true
+		case option == "JUSTID":
+			parsed.opts.JustID = true
+		case (option == "IDLE" || option == "TIME" || option == "RETRYCOUNT") && i+1 < len(args):
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return parsed, errors.New("ERR Invalid " + option + " option argument for XCLAIM")
+			}
+			switch option {
+			case "IDLE":
+				parsed.opts.DeliveryTime = storage.Now() - n
+			case "TIME":
+				parsed.opts.DeliveryTime = n
+			case "RETRYCOUNT":
+				parsed.opts.RetryCount = int(n)
+			}
+			i++
+		case option == "LASTID" && i+1 < len(args):
+			id, err := storage.ParseStreamID(args[i+1], 0)
+			if err != nil {
+				return parsed, err
+			}
+			parsed.opts.LastID = id
+			i++
+		default:
+			return parsed, errors.New("ERR Unrecognized XCLAIM option '" + args[i] + "'")
+		}
+	}
+	return parsed, nil
+}
+
+// 11) -> https://redis.io/docs/latest/commands/xclaim
+// XClaim handles the XCLAIM command
+// It transfers the given pending entries to a consumer, provided they have been idle
+// for at least min-idle-time milliseconds
+// Supported options: IDLE ms, TIME unix-time-ms, RETRYCOUNT count, FORCE, JUSTID and LASTID id
+// Returns the claimed entries, or only their IDs with JUSTID
+func XClaim(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 5 {
+		return wrongArgs("xclaim")
+	}
+	parsed, err := parseXClaim(args[3:])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	claimed, err := s.XClaim(args[0], args[1], args[2], parsed.minIdle, parsed.ids, parsed.opts)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return entriesValue(claimed, parsed.opts.JustID)
+}
+
+// 12) -> https://redis.io/docs/latest/commands/xautoclaim
+// XAutoClaim handles the XAUTOCLAIM command
+// It scans the pending entries list from start and claims up to COUNT (default 100) entries
+// idle for at least min-idle-time milliseconds, like XCLAIM would
+// Returns the ID to pass as start to continue the scan (0-0 when it is complete),
+// the claimed entries (or IDs with JUSTID) and the IDs of pending entries that no longer exist
+func XAutoClaim(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 5 {
+		return wrongArgs("xautoclaim")
+	}
+	minIdle, err := parseMinIdle(args[3], "XAUTOCLAIM")
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	start, err := parseRangeBound(args[4], true)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+
+	count, justID := 100, false
+	for i := 5; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "COUNT" && i+1 < len(args):
+			if count, err = strconv.Atoi(args[i+1]); err != nil {
+				return notInteger()
+			}
+			if count <= 0 {
+				return resp.Err("ERR COUNT must be > 0")
+			}
+			i++
+		case option == "JUSTID":
+			justID = true
+		default:
+			return syntaxError()
+		}
+	}
+
+	next, claimed, deleted, err := s.XAutoClaim(args[0], args[1], args[2], minIdle, start, count, justID)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	deletedIDs := make([]resp.Value, len(deleted))
+	for i, id := range deleted {
+		deletedIDs[i] = idValue(id)
+	}
+	return resp.Array(
+		idValue(next),
+		entriesValue(claimed, justID),
+		resp.Array(deletedIDs...),
+	)
+}
+
+// RewriteStream converts an executed stream command into the form that is written to the AOF,
+// using its reply, so that replaying it later yields the same state:
+//   - XADD with a generated ID gets the ID that was actually assigned
+//   - XCLAIM and XAUTOCLAIM depend on how long entries have been idle, which is not the same
+//     on replay, so they become an XCLAIM of exactly the entries that were claimed or found
+//     deleted, with a min-idle-time of 0
+//
+// Any other command, a failed one, or one with nothing to rewrite, is returned unchanged
+func RewriteStream(s *storage.Storage, cmd string, args []string, result resp.Value) (string, []string) {
+	if result.Kind == resp.KindError {
+		return cmd, args
+	}
+	switch strings.ToUpper(cmd) {
+	case "XADD":
+		parsed, err := parseXAdd(args)
+		if err != nil || result.Kind != resp.KindBulk {
+			return cmd, args
+		}
+		rewritten := append([]string{}, args...)
+		rewritten[parsed.idIndex] = string(result.Bulk)
+		return cmd, rewritten
+	case "XCLAIM":
+		parsed, err := parseXClaim(args[3:])
+		if err != nil {
+			return cmd, args
+		}
+		ids := claimedIDs(result.Array)
+		// Pending entries that no longer exist were removed from the pending entries list
+		for _, id := range parsed.ids {
+			if entries, _ := s.XRange(args[0], id, id, 1, false); len(entries) == 0 {
+				ids = append(ids, id.String())
+			}
+		}
+		if len(ids) == 0 {
+			return cmd, args
+		}
+		rewritten := append([]string{args[0], args[1], args[2], "0"}, ids...)
+		return cmd, append(rewritten, args[4+parsed.idCount:]...)
+	case "XAUTOCLAIM":
+		if len(result.Array) != 3 {
+			return cmd, args
+		}
+		ids := append(claimedIDs(result.Array[1].Array), claimedIDs(result.Array[2].Array)...)
+		if len(ids) == 0 {
+			return cmd, args
+		}
+		rewritten := append([]string{args[0], args[1], args[2], "0"}, ids...)
+		for _, arg := range args[5:] {
+			if strings.ToUpper(arg) == "JUSTID" {
+				rewritten = append(rewritten, "JUSTID")
+			}
+		}
+		return "XCLAIM", rewritten
+	}
+	return cmd, args
+}
+
+// claimedIDs extracts the IDs from a list of entries or IDs as returned by XCLAIM
+func claimedIDs(array []resp.Value) []string {
+	ids := make([]string, 0, len(array))
+	for _, v := range array {
+		if v.Kind == resp.KindArray && len(v.Array) > 0 {
+			v = v.Array[0]
+		}
+		ids = append(ids, string(v.Bulk))
+	}
+	return ids
+}
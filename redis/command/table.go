@@ -0,0 +1,374 @@
+package command
+
+import (
+	"redis/resp"
+	"redis/storage"
+	"sort"
+	"strings"
+)
+
+// Flag describes how a command behaves, as reported by COMMAND INFO
+type Flag uint16
+
+const (
+	FlagWrite       Flag = 1 << iota // Modifies the data
+	FlagReadOnly                     // Only reads the data
+	FlagAdmin                        // Administers the server
+	FlagPubSub                       // Related to pub/sub
+	FlagNoScript                     // Not allowed in scripts
+	FlagBlocking                     // May block the client
+	FlagFast                         // Runs in constant or logarithmic time
+	FlagMovableKeys                  // Keys are found from other arguments, not only the key positions
+)
+
+// flagNames are the names of the flags, in the order COMMAND INFO lists them
+var flagNames = []struct {
+	flag Flag
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadOnly, "readonly"},
+	{FlagAdmin, "admin"},
+	{FlagPubSub, "pubsub"},
+	{FlagNoScript, "noscript"},
+	{FlagBlocking, "blocking"},
+	{FlagFast, "fast"},
+	{FlagMovableKeys, "movablekeys"},
+}
+
+// KeyPositions are the positions of the keys in the arguments of a command, counting the
+// command name as 0: from First to Last, every Step arguments
+// A negative Last counts from the end, so -1 is the last argument
+type KeyPositions struct {
+	First, Last, Step int
+}
+
+var (
+	noKeys     = KeyPositions{}
+	oneKey     = KeyPositions{1, 1, 1}
+	twoKeys    = KeyPositions{1, 2, 1}
+	allKeys    = KeyPositions{1, -1, 1}
+	allButLast = KeyPositions{1, -2, 1} // Every argument but the last one, the timeout of BLPOP
+)
+
+// Descriptor describes a command: how it is called, how it behaves and who executes it
+type Descriptor struct {
+	Name    string // Name in uppercase
+	Arity   int    // Number of arguments including the name, or the minimum number when negative
+	Flags   Flag
+	Keys    KeyPositions
+	Group   string // Group of the documentation, such as "string" or "sorted-set"
+	Since   string // Version of Redis that introduced the command
+	Summary string
+
+	// Handler executes the command on the storage
+	// It is nil for the commands the server executes itself, such as MULTI or SUBSCRIBE
+	Handler func(*storage.Storage, []string) resp.Value
+}
+
+// Has returns true if the command has the flag
+func (d *Descriptor) Has(flag Flag) bool {
+	return d.Flags&flag != 0
+}
+
+// CheckArity returns true if the command accepts argc arguments, counting its name
+func (d *Descriptor) CheckArity(argc int) bool {
+	if d.Arity >= 0 {
+		return argc == d.Arity
+	}
+	return argc >= -d.Arity
+}
+
+// table lists every command of the server
+var table = []*Descriptor{
+	// Connection
+	{Name: "PING", Arity: -1, Flags: FlagFast, Keys: noKeys, Group: "connection", Since: "1.0.0",
+		Summary: "Returns the server's liveliness response.", Handler: Ping},
+	{Name: "HELLO", Arity: -1, Flags: FlagNoScript | FlagFast, Keys: noKeys, Group: "connection", Since: "6.0.0",
+		Summary: "Handshakes with the Redis server."},
+	{Name: "SELECT", Arity: 2, Flags: FlagFast, Keys: noKeys, Group: "connection", Since: "1.0.0",
+		Summary: "Changes the selected database."},
+
+	// Server
+	{Name: "COMMAND", Arity: -1, Keys: noKeys, Group: "server", Since: "2.8.13",
+		Summary: "Returns detailed information about all commands.", Handler: Command},
+	{Name: "INFO", Arity: -1, Keys: noKeys, Group: "server", Since: "1.0.0",
+		Summary: "Returns information and statistics about the server."},
+	{Name: "CONFIG", Arity: -2, Flags: FlagAdmin | FlagNoScript, Keys: noKeys, Group: "server", Since: "2.0.0",
+		Summary: "Gets, sets, rewrites or resets the parameters of the server."},
+	{Name: "BGREWRITEAOF", Arity: 1, Flags: FlagAdmin | FlagNoScript, Keys: noKeys, Group: "server", Since: "1.0.0",
+		Summary: "Asynchronously rewrites the append-only file to disk."},
+	{Name: "SAVE", Arity: 1, Flags: FlagAdmin | FlagNoScript, Keys: noKeys, Group: "server", Since: "1.0.0",
+		Summary: "Synchronously saves the database(s) to disk."},
+	{Name: "BGSAVE", Arity: -1, Flags: FlagAdmin | FlagNoScript, Keys: noKeys, Group: "server", Since: "1.0.0",
+		Summary: "Asynchronously saves the database(s) to disk."},
+	{Name: "LASTSAVE", Arity: 1, Flags: FlagFast, Keys: noKeys, Group: "server", Since: "1.0.0",
+		Summary: "Returns the Unix timestamp of the last successful save to disk."},
+	{Name: "DBSIZE", Arity: 1, Flags: FlagReadOnly | FlagFast, Keys: noKeys, Group: "server", Since: "1.0.0",
+		Summary: "Returns the number of keys in the database.", Handler: DBSize},
+	{Name: "FLUSHDB", Arity: -1, Flags: FlagWrite, Keys: noKeys, Group: "server", Since: "1.0.0",
+		Summary: "Removes all keys from the current database.", Handler: FlushDB},
+	{Name: "FLUSHALL", Arity: -1, Flags: FlagWrite, Keys: noKeys, Group: "server", Since: "1.0.0",
+		Summary: "Removes all keys from all databases.", Handler: FlushAll},
+	{Name: "SWAPDB", Arity: 3, Flags: FlagWrite | FlagFast, Keys: noKeys, Group: "server", Since: "4.0.0",
+		Summary: "Swaps two Redis databases.", Handler: SwapDB},
+
+	// Strings
+	{Name: "SET", Arity: -3, Flags: FlagWrite, Keys: oneKey, Group: "string", Since: "1.0.0",
+		Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", Handler: Set},
+	{Name: "GET", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "string", Since: "1.0.0",
+		Summary: "Returns the string value of a key.", Handler: Get},
+	{Name: "INCR", Arity: 2, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "string", Since: "1.0.0",
+		Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Handler: Incr},
+
+	// Keys
+	{Name: "DEL", Arity: -2, Flags: FlagWrite, Keys: allKeys, Group: "generic", Since: "1.0.0",
+		Summary: "Deletes one or more keys.", Handler: Del},
+	{Name: "EXISTS", Arity: -2, Flags: FlagReadOnly | FlagFast, Keys: allKeys, Group: "generic", Since: "1.0.0",
+		Summary: "Determines whether one or more keys exist.", Handler: Exists},
+	{Name: "EXPIRE", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "generic", Since: "1.0.0",
+		Summary: "Sets the expiration time of a key in seconds.", Handler: Expire},
+	{Name: "PEXPIRE", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "generic", Since: "2.6.0",
+		Summary: "Sets the expiration time of a key in milliseconds.", Handler: PExpire},
+	{Name: "EXPIREAT", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "generic", Since: "1.2.0",
+		Summary: "Sets the expiration time of a key to a Unix timestamp.", Handler: ExpireAt},
+	{Name: "PEXPIREAT", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "generic", Since: "2.6.0",
+		Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", Handler: PExpireAt},
+	{Name: "TTL", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "generic", Since: "1.0.0",
+		Summary: "Returns the expiration time in seconds of a key.", Handler: TTL},
+	{Name: "PTTL", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "generic", Since: "2.6.0",
+		Summary: "Returns the expiration time in milliseconds of a key.", Handler: PTTL},
+	{Name: "PERSIST", Arity: 2, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "generic", Since: "2.2.0",
+		Summary: "Removes the expiration time of a key.", Handler: Persist},
+	{Name: "MOVE", Arity: 3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "generic", Since: "1.0.0",
+		Summary: "Moves a key to another database.", Handler: Move},
+	{Name: "KEYS", Arity: 2, Flags: FlagReadOnly, Keys: noKeys, Group: "generic", Since: "1.0.0",
+		Summary: "Returns all key names that match a pattern.", Handler: Keys},
+	{Name: "SCAN", Arity: -2, Flags: FlagReadOnly, Keys: noKeys, Group: "generic", Since: "2.8.0",
+		Summary: "Iterates over the key names in the database.", Handler: Scan},
+	{Name: "RANDOMKEY", Arity: 1, Flags: FlagReadOnly, Keys: noKeys, Group: "generic", Since: "1.0.0",
+		Summary: "Returns a random key name from the database.", Handler: RandomKey},
+	{Name: "RENAME", Arity: 3, Flags: FlagWrite, Keys: twoKeys, Group: "generic", Since: "1.0.0",
+		Summary: "Renames a key and overwrites the destination.", Handler: Rename},
+	{Name: "RENAMENX", Arity: 3, Flags: FlagWrite | FlagFast, Keys: twoKeys, Group: "generic", Since: "1.0.0",
+		Summary: "Renames a key only when the target key name doesn't exist.", Handler: RenameNX},
+	{Name: "TYPE", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "generic", Since: "1.0.0",
+		Summary: "Determines the type of value stored at a key.", Handler: Type},
+	{Name: "COPY", Arity: -3, Flags: FlagWrite, Keys: twoKeys, Group: "generic", Since: "6.2.0",
+		Summary: "Copies the value of a key to a new key.", Handler: Copy},
+	{Name: "TOUCH", Arity: -2, Flags: FlagReadOnly | FlagFast, Keys: allKeys, Group: "generic", Since: "3.2.1",
+		Summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.", Handler: Touch},
+
+	// Lists
+	{Name: "LPUSH", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", Handler: LPush},
+	{Name: "RPUSH", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", Handler: RPush},
+	{Name: "LPOP", Arity: -2, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", Handler: LPop},
+	{Name: "RPOP", Arity: -2, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Returns and removes the last elements of the list. Deletes the list if the last element was popped.", Handler: RPop},
+	{Name: "LRANGE", Arity: 4, Flags: FlagReadOnly, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Returns a range of elements from a list.", Handler: LRange},
+	{Name: "LINDEX", Arity: 3, Flags: FlagReadOnly, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Returns an element from a list by its index.", Handler: LIndex},
+	{Name: "LSET", Arity: 4, Flags: FlagWrite, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Sets the value of an element in a list by its index.", Handler: LSet},
+	{Name: "LTRIM", Arity: 4, Flags: FlagWrite, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.", Handler: LTrim},
+	{Name: "LREM", Arity: 4, Flags: FlagWrite, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Removes elements from a list. Deletes the list if the last element was removed.", Handler: LRem},
+	{Name: "LINSERT", Arity: 5, Flags: FlagWrite, Keys: oneKey, Group: "list", Since: "2.2.0",
+		Summary: "Inserts an element before or after another element in a list.", Handler: LInsert},
+	{Name: "LLEN", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "list", Since: "1.0.0",
+		Summary: "Returns the length of a list.", Handler: LLen},
+	{Name: "LMOVE", Arity: 5, Flags: FlagWrite, Keys: twoKeys, Group: "list", Since: "6.2.0",
+		Summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.", Handler: LMove},
+	{Name: "BLPOP", Arity: -3, Flags: FlagWrite | FlagBlocking, Keys: allButLast, Group: "list", Since: "2.0.0",
+		Summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Handler: BLPop},
+	{Name: "BRPOP", Arity: -3, Flags: FlagWrite | FlagBlocking, Keys: allButLast, Group: "list", Since: "2.0.0",
+		Summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Handler: BRPop},
+	{Name: "BLMOVE", Arity: 6, Flags: FlagWrite | FlagBlocking, Keys: twoKeys, Group: "list", Since: "6.2.0",
+		Summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved.", Handler: BLMove},
+	{Name: "BLMPOP", Arity: -5, Flags: FlagWrite | FlagBlocking | FlagMovableKeys, Keys: noKeys, Group: "list", Since: "7.0.0",
+		Summary: "Pops the first element from one of multiple lists. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Handler: BLMPop},
+
+	// Hashes
+	{Name: "HSET", Arity: -4, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Creates or modifies the value of a field in a hash.", Handler: HSet},
+	{Name: "HSETNX", Arity: 4, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Sets the value of a field in a hash only when the field doesn't exist.", Handler: HSetNX},
+	{Name: "HGET", Arity: 3, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Returns the value of a field in a hash.", Handler: HGet},
+	{Name: "HMGET", Arity: -3, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Returns the values of all fields in a hash.", Handler: HMGet},
+	{Name: "HDEL", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.", Handler: HDel},
+	{Name: "HGETALL", Arity: 2, Flags: FlagReadOnly, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Returns all fields and values in a hash.", Handler: HGetAll},
+	{Name: "HKEYS", Arity: 2, Flags: FlagReadOnly, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Returns all fields in a hash.", Handler: HKeys},
+	{Name: "HVALS", Arity: 2, Flags: FlagReadOnly, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Returns all values in a hash.", Handler: HVals},
+	{Name: "HLEN", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Returns the number of fields in a hash.", Handler: HLen},
+	{Name: "HEXISTS", Arity: 3, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Determines whether a field exists in a hash.", Handler: HExists},
+	{Name: "HINCRBY", Arity: 4, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "hash", Since: "2.0.0",
+		Summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.", Handler: HIncrBy},
+	{Name: "HINCRBYFLOAT", Arity: 4, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "hash", Since: "2.6.0",
+		Summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.", Handler: HIncrByFloat},
+
+	// Sets
+	{Name: "SADD", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "set", Since: "1.0.0",
+		Summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", Handler: SAdd},
+	{Name: "SREM", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "set", Since: "1.0.0",
+		Summary: "Removes one or more members from a set. Deletes the set if the last member was removed.", Handler: SRem},
+	{Name: "SMEMBERS", Arity: 2, Flags: FlagReadOnly, Keys: oneKey, Group: "set", Since: "1.0.0",
+		Summary: "Returns all members of a set.", Handler: SMembers},
+	{Name: "SISMEMBER", Arity: 3, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "set", Since: "1.0.0",
+		Summary: "Determines whether a member belongs to a set.", Handler: SIsMember},
+	{Name: "SMISMEMBER", Arity: -3, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "set", Since: "6.2.0",
+		Summary: "Determines whether multiple members belong to a set.", Handler: SMIsMember},
+	{Name: "SCARD", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "set", Since: "1.0.0",
+		Summary: "Returns the number of members in a set.", Handler: SCard},
+	{Name: "SPOP", Arity: -2, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "set", Since: "1.0.0",
+		Summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.", Handler: SPop},
+	{Name: "SRANDMEMBER", Arity: -2, Flags: FlagReadOnly, Keys: oneKey, Group: "set", Since: "1.0.0",
+		Summary: "Get one or multiple random members from a set", Handler: SRandMember},
+	{Name: "SINTER", Arity: -2, Flags: FlagReadOnly, Keys: allKeys, Group: "set", Since: "1.0.0",
+		Summary: "Returns the intersect of multiple sets.", Handler: SInter},
+	{Name: "SUNION", Arity: -2, Flags: FlagReadOnly, Keys: allKeys, Group: "set", Since: "1.0.0",
+		Summary: "Returns the union of multiple sets.", Handler: SUnion},
+	{Name: "SDIFF", Arity: -2, Flags: FlagReadOnly, Keys: allKeys, Group: "set", Since: "1.0.0",
+		Summary: "Returns the difference of multiple sets.", Handler: SDiff},
+	{Name: "SINTERSTORE", Arity: -3, Flags: FlagWrite, Keys: allKeys, Group: "set", Since: "1.0.0",
+		Summary: "Stores the intersect of multiple sets in a key.", Handler: SInterStore},
+	{Name: "SUNIONSTORE", Arity: -3, Flags: FlagWrite, Keys: allKeys, Group: "set", Since: "1.0.0",
+		Summary: "Stores the union of multiple sets in a key.", Handler: SUnionStore},
+	{Name: "SDIFFSTORE", Arity: -3, Flags: FlagWrite, Keys: allKeys, Group: "set", Since: "1.0.0",
+		Summary: "Stores the difference of multiple sets in a key.", Handler: SDiffStore},
+
+	// Sorted sets
+	{Name: "ZADD", Arity: -4, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "1.2.0",
+		Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", Handler: ZAdd},
+	{Name: "ZINCRBY", Arity: 4, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "1.2.0",
+		Summary: "Increments the score of a member in a sorted set.", Handler: ZIncrBy},
+	{Name: "ZSCORE", Arity: 3, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "1.2.0",
+		Summary: "Returns the score of a member in a sorted set.", Handler: ZScore},
+	{Name: "ZCARD", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "1.2.0",
+		Summary: "Returns the number of members in a sorted set.", Handler: ZCard},
+	{Name: "ZRANGE", Arity: -4, Flags: FlagReadOnly, Keys: oneKey, Group: "sorted-set", Since: "1.2.0",
+		Summary: "Returns members in a sorted set within a range of indexes.", Handler: ZRange},
+	{Name: "ZRANK", Arity: 3, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "2.0.0",
+		Summary: "Returns the index of a member in a sorted set ordered by ascending scores.", Handler: ZRank},
+	{Name: "ZREVRANK", Arity: 3, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "2.0.0",
+		Summary: "Returns the index of a member in a sorted set ordered by descending scores.", Handler: ZRevRank},
+	{Name: "ZREM", Arity: -3, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "1.2.0",
+		Summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", Handler: ZRem},
+	{Name: "ZCOUNT", Arity: 4, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "2.0.0",
+		Summary: "Returns the count of members in a sorted set that have scores within a range.", Handler: ZCount},
+	{Name: "ZPOPMIN", Arity: -2, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "5.0.0",
+		Summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", Handler: ZPopMin},
+	{Name: "ZPOPMAX", Arity: -2, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "sorted-set", Since: "5.0.0",
+		Summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", Handler: ZPopMax},
+	{Name: "ZUNIONSTORE", Arity: -4, Flags: FlagWrite | FlagMovableKeys, Keys: oneKey, Group: "sorted-set", Since: "2.0.0",
+		Summary: "Stores the union of multiple sorted sets in a key.", Handler: ZUnionStore},
+	{Name: "ZINTERSTORE", Arity: -4, Flags: FlagWrite | FlagMovableKeys, Keys: oneKey, Group: "sorted-set", Since: "2.0.0",
+		Summary: "Stores the intersect of multiple sorted sets in a key.", Handler: ZInterStore},
+
+	// Streams
+	{Name: "XADD", Arity: -5, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "stream", Since: "5.0.0",
+		Summary: "Appends a new message to a stream. Creates the key if it doesn't exist.", Handler: XAdd},
+	{Name: "XRANGE", Arity: -4, Flags: FlagReadOnly, Keys: oneKey, Group: "stream", Since: "5.0.0",
+		Summary: "Returns the messages from a stream within a range of IDs.", Handler: XRange},
+	{Name: "XREVRANGE", Arity: -4, Flags: FlagReadOnly, Keys: oneKey, Group: "stream", Since: "5.0.0",
+		Summary: "Returns the messages from a stream within a range of IDs in reverse order.", Handler: XRevRange},
+	{Name: "XLEN", Arity: 2, Flags: FlagReadOnly | FlagFast, Keys: oneKey, Group: "stream", Since: "5.0.0",
+		Summary: "Return the number of messages in a stream.", Handler: XLen},
+	{Name: "XTRIM", Arity: -4, Flags: FlagWrite, Keys: oneKey, Group: "stream", Since: "5.0.0",
+		Summary: "Deletes messages from the beginning of a stream.", Handler: XTrim},
+	{Name: "XREAD", Arity: -4, Flags: FlagReadOnly | FlagBlocking | FlagMovableKeys, Keys: noKeys, Group: "stream", Since: "5.0.0",
+		Summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.", Handler: XRead},
+	{Name: "XGROUP", Arity: -2, Flags: FlagWrite, Keys: KeyPositions{2, 2, 1}, Group: "stream", Since: "5.0.0",
+		Summary: "A container for consumer groups commands.", Handler: XGroup},
+	{Name: "XREADGROUP", Arity: -7, Flags: FlagWrite | FlagBlocking | FlagMovableKeys, Keys: noKeys, Group: "stream", Since: "5.0.0",
+		Summary: "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise.", Handler: XReadGroup},
+	{Name: "XACK", Arity: -4, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "stream", Since: "5.0.0",
+		Summary: "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream.", Handler: XAck},
+	{Name: "XPENDING", Arity: -3, Flags: FlagReadOnly, Keys: oneKey, Group: "stream", Since: "5.0.0",
+		Summary: "Returns the information and entries from a stream consumer group's pending entries list.", Handler: XPending},
+	{Name: "XCLAIM", Arity: -6, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "stream", Since: "5.0.0",
+		Summary: "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member.", Handler: XClaim},
+	{Name: "XAUTOCLAIM", Arity: -6, Flags: FlagWrite | FlagFast, Keys: oneKey, Group: "stream", Since: "6.2.0",
+		Summary: "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member.", Handler: XAutoClaim},
+
+	// Pub/sub
+	{Name: "SUBSCRIBE", Arity: -2, Flags: FlagPubSub | FlagNoScript, Keys: noKeys, Group: "pubsub", Since: "2.0.0",
+		Summary: "Listens for messages published to channels."},
+	{Name: "PSUBSCRIBE", Arity: -2, Flags: FlagPubSub | FlagNoScript, Keys: noKeys, Group: "pubsub", Since: "2.0.0",
+		Summary: "Listens for messages published to channels that match one or more patterns."},
+	{Name: "UNSUBSCRIBE", Arity: -1, Flags: FlagPubSub | FlagNoScript, Keys: noKeys, Group: "pubsub", Since: "2.0.0",
+		Summary: "Stops listening to messages posted to channels."},
+	{Name: "PUNSUBSCRIBE", Arity: -1, Flags: FlagPubSub | FlagNoScript, Keys: noKeys, Group: "pubsub", Since: "2.0.0",
+		Summary: "Stops listening to messages published to channels that match one or more patterns."},
+	{Name: "PUBLISH", Arity: 3, Flags: FlagPubSub | FlagFast, Keys: noKeys, Group: "pubsub", Since: "2.0.0",
+		Summary: "Posts a message to a channel."},
+	{Name: "PUBSUB", Arity: -2, Flags: FlagPubSub, Keys: noKeys, Group: "pubsub", Since: "2.8.0",
+		Summary: "A container for Pub/Sub commands."},
+
+	// Transactions
+	{Name: "MULTI", Arity: 1, Flags: FlagNoScript | FlagFast, Keys: noKeys, Group: "transactions", Since: "1.2.0",
+		Summary: "Starts a transaction."},
+	{Name: "EXEC", Arity: 1, Flags: FlagNoScript, Keys: noKeys, Group: "transactions", Since: "1.2.0",
+		Summary: "Executes all commands in a transaction."},
+	{Name: "DISCARD", Arity: 1, Flags: FlagNoScript | FlagFast, Keys: noKeys, Group: "transactions", Since: "2.0.0",
+		Summary: "Discards a transaction."},
+	{Name: "WATCH", Arity: -2, Flags: FlagNoScript | FlagFast, Keys: allKeys, Group: "transactions", Since: "2.2.0",
+		Summary: "Monitors changes to keys to determine the execution of a transaction."},
+	{Name: "UNWATCH", Arity: 1, Flags: FlagNoScript | FlagFast, Keys: noKeys, Group: "transactions", Since: "2.2.0",
+		Summary: "Forgets about watched keys of a transaction."},
+}
+
+// commands indexes the table by name, and commandNames lists the names in alphabetical order
+var (
+	commands     = make(map[string]*Descriptor)
+	commandNames []string
+)
+
+func init() {
+	for _, d := range table {
+		commands[d.Name] = d
+		commandNames = append(commandNames, d.Name)
+	}
+	sort.Strings(commandNames)
+}
+
+// Lookup returns the descriptor of a command, whatever the case of its name
+// Returns false if the command does not exist
+func Lookup(name string) (*Descriptor, bool) {
+	d, ok := commands[strings.ToUpper(name)]
+	return d, ok
+}
+
+// UnknownCommand returns the error reply for a command that does not exist
+// Like Redis, it quotes the beginning of the arguments to help find the mistake
+func UnknownCommand(name string, args []string) resp.Value {
+	var b strings.Builder
+	b.WriteString("ERR unknown command '" + name + "', with args beginning with: ")
+	for _, arg := range args {
+		if b.Len() > 128 {
+			break
+		}
+		if len(arg) > 128 {
+			arg = arg[:128]
+		}
+		b.WriteString("'" + arg + "' ")
+	}
+	return resp.Err(b.String())
+}
+
+// WrongArity returns the error reply for a command called with the wrong number of arguments
+func WrongArity(d *Descriptor) resp.Value {
+	return wrongArgs(strings.ToLower(d.Name))
+}
@@ -0,0 +1,459 @@
+package command
+
+import (
+	"math"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+)
+
+// parseScore parses a score argument, rejecting NaN
+func parseScore(arg string) (float64, bool) {
+	score, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false
+	}
+	return score, true
+}
+
+// notFloat returns the error reply for an argument that is not a valid float
+func notFloat() resp.Value {
+	return resp.Err("ERR value is not a valid float")
+}
+
+// scoreValue builds the bulk string reply for a score
+func scoreValue(score float64) resp.Value {
+	return resp.Bulk(resp.FormatFloat(score))
+}
+
+// parseScoreBound parses a ZRANGE BYSCORE or ZCOUNT bound such as "1.5", "(1.5" or "-inf"
+func parseScoreBound(arg string) (float64, bool, bool) {
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+	score, ok := parseScore(arg)
+	return score, exclusive, ok
+}
+
+// parseScoreRange parses the min and max arguments of a score range
+func parseScoreRange(minArg, maxArg string) (storage.ScoreRange, bool) {
+	var r storage.ScoreRange
+	var ok1, ok2 bool
+	r.Min, r.MinEx, ok1 = parseScoreBound(minArg)
+	r.Max, r.MaxEx, ok2 = parseScoreBound(maxArg)
+	return r, ok1 && ok2
+}
+
+// parseLexBound parses a ZRANGE BYLEX bound such as "[a", "(a", "-" or "+"
+func parseLexBound(arg string) (storage.LexBound, bool) {
+	switch {
+	case arg == "-":
+		return storage.LexBound{Inf: -1}, true
+	case arg == "+":
+		return storage.LexBound{Inf: 1}, true
+	case strings.HasPrefix(arg, "["):
+		return storage.LexBound{Value: arg[1:]}, true
+	case strings.HasPrefix(arg, "("):
+		return storage.LexBound{Value: arg[1:], Exclusive: true}, true
+	default:
+		return storage.LexBound{}, false
+	}
+}
+
+// zmemberArray builds the reply for a list of sorted set members,
+// interleaving the scores when withScores is set
+func zmemberArray(members []storage.ZMember, withScores bool) resp.Value {
+	array := make([]resp.Value, 0, len(members)*2)
+	for _, m := range members {
+		array = append(array, resp.Bulk(m.Member))
+		if withScores {
+			array = append(array, scoreValue(m.Score))
+		}
+	}
+	return resp.Array(array...)
+}
+
+// 1) -> https://redis.io/docs/latest/commands/zadd
+// ZAdd handles the ZADD command
+// It adds all the specified members with the specified scores to the sorted set stored at key
+// Supported options: NX | XX, GT | LT, CH and INCR
+// Returns the number of added members (or added and updated members with CH),
+// or the new score of the member with INCR
+func ZAdd(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("zadd")
+	}
+
+	var opts storage.ZAddOptions
+	incr := false
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		case "CH":
+			opts.CH = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return syntaxError()
+	}
+	if opts.NX && opts.XX {
+		return resp.Err("ERR XX and NX options at the same time are not compatible")
+	}
+	if (opts.GT && opts.LT) || (opts.NX && (opts.GT || opts.LT)) {
+		return resp.Err("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if incr && len(pairs) != 2 {
+		return resp.Err("ERR INCR option supports a single increment-element pair")
+	}
+
+	members := make([]storage.ZMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, ok := parseScore(pairs[j])
+		if !ok {
+			return notFloat()
+		}
+		members = append(members, storage.ZMember{Member: pairs[j+1], Score: score})
+	}
+
+	if incr {
+		score, ok, err := s.ZIncrBy(args[0], members[0].Member, members[0].Score, opts)
+		if err != nil {
+			return resp.Err(err.Error())
+		}
+		if !ok {
+			return resp.Null()
+		}
+		return scoreValue(score)
+	}
+
+	count, err := s.ZAdd(args[0], opts, members...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(count))
+}
+
+// 2) -> https://redis.io/docs/latest/commands/zincrby
+// ZIncrBy handles the ZINCRBY command
+// It increments the score of member in the sorted set stored at key
+// Returns the new score of the member
+func ZIncrBy(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("zincrby")
+	}
+	increment, ok := parseScore(args[1])
+	if !ok {
+		return notFloat()
+	}
+	score, _, err := s.ZIncrBy(args[0], args[2], increment, storage.ZAddOptions{})
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return scoreValue(score)
+}
+
+// 3) -> https://redis.io/docs/latest/commands/zscore
+// ZScore handles the ZSCORE command
+// It returns the score of member in the sorted set stored at key
+func ZScore(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("zscore")
+	}
+	score, ok, err := s.ZScore(args[0], args[1])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if !ok {
+		return resp.Null()
+	}
+	return scoreValue(score)
+}
+
+// 4) -> https://redis.io/docs/latest/commands/zcard
+// ZCard handles the ZCARD command
+// It returns the number of members of the sorted set stored at key
+func ZCard(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("zcard")
+	}
+	count, err := s.ZCard(args[0])
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(count))
+}
+
+// 5) -> https://redis.io/docs/latest/commands/zrange
+// ZRange handles the ZRANGE command
+// It returns the specified range of members of the sorted set stored at key
+// The range is by rank by default, or by score with BYSCORE and by member with BYLEX
+// Supported options: REV, LIMIT offset count and WITHSCORES
+func ZRange(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("zrange")
+	}
+
+	byScore, byLex, reverse, withScores, hasLimit := false, false, false, false, false
+	offset, count := 0, -1
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "BYSCORE":
+			byScore = true
+		case "BYLEX":
+			byLex = true
+		case "REV":
+			reverse = true
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return syntaxError()
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(args[i+1])
+			count, err2 = strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				return notInteger()
+			}
+			hasLimit = true
+			i += 2
+		default:
+			return syntaxError()
+		}
+	}
+	if byScore && byLex {
+		return syntaxError()
+	}
+	if hasLimit && !byScore && !byLex {
+		return resp.Err("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if withScores && byLex {
+		return resp.Err("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	// With REV the range is given from the highest to the lowest bound
+	minArg, maxArg := args[1], args[2]
+	if reverse && (byScore || byLex) {
+		minArg, maxArg = maxArg, minArg
+	}
+
+	var members []storage.ZMember
+	var err error
+	switch {
+	case byScore:
+		r, ok := parseScoreRange(minArg, maxArg)
+		if !ok {
+			return resp.Err("ERR min or max is not a float")
+		}
+		members, err = s.ZRangeByScore(args[0], r, reverse, offset, count)
+	case byLex:
+		minBound, ok1 := parseLexBound(minArg)
+		maxBound, ok2 := parseLexBound(maxArg)
+		if !ok1 || !ok2 {
+			return resp.Err("ERR min or max not valid string range item")
+		}
+		members, err = s.ZRangeByLex(args[0], storage.LexRange{Min: minBound, Max: maxBound}, reverse, offset, count)
+	default:
+		start, err1 := strconv.Atoi(args[1])
+		stop, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			return notInteger()
+		}
+		members, err = s.ZRangeByRank(args[0], start, stop, reverse)
+	}
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return zmemberArray(members, withScores)
+}
+
+// rankGeneric implements ZRANK and ZREVRANK
+func rankGeneric(s *storage.Storage, args []string, name string, reverse bool) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs(name)
+	}
+	rank, ok, err := s.ZRank(args[0], args[1], reverse)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	if !ok {
+		return resp.Null()
+	}
+	return resp.Integer(int64(rank))
+}
+
+// 6) -> https://redis.io/docs/latest/commands/zrank
+// ZRank handles the ZRANK command
+// It returns the rank of member in the sorted set stored at key, with the scores ordered from low to high
+func ZRank(s *storage.Storage, args []string) resp.Value {
+	return rankGeneric(s, args, "zrank", false)
+}
+
+// 7) -> https://redis.io/docs/latest/commands/zrevrank
+// ZRevRank handles the ZREVRANK command
+// It returns the rank of member in the sorted set stored at key, with the scores ordered from high to low
+func ZRevRank(s *storage.Storage, args []string) resp.Value {
+	return rankGeneric(s, args, "zrevrank", true)
+}
+
+// 8) -> https://redis.io/docs/latest/commands/zrem
+// ZRem handles the ZREM command
+// It removes the specified members from the sorted set stored at key
+// Returns the number of members removed
+func ZRem(s *storage.Storage, args []string) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("zrem")
+	}
+	removed, err := s.ZRem(args[0], args[1:]...)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(removed))
+}
+
+// 9) -> https://redis.io/docs/latest/commands/zcount
+// ZCount handles the ZCOUNT command
+// It returns the number of members in the sorted set at key with a score between min and max
+func ZCount(s *storage.Storage, args []string) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("zcount")
+	}
+	r, ok := parseScoreRange(args[1], args[2])
+	if !ok {
+		return resp.Err("ERR min or max is not a float")
+	}
+	count, err := s.ZCount(args[0], r)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(count))
+}
+
+// zpopGeneric implements ZPOPMIN and ZPOPMAX
+func zpopGeneric(s *storage.Storage, args []string, name string, highest bool) resp.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongArgs(name)
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return resp.Err("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+	members, err := s.ZPop(args[0], count, highest)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return zmemberArray(members, true)
+}
+
+// 10) -> https://redis.io/docs/latest/commands/zpopmin
+// ZPopMin handles the ZPOPMIN command
+// It removes and returns up to count members with the lowest scores in the sorted set stored at key
+func ZPopMin(s *storage.Storage, args []string) resp.Value {
+	return zpopGeneric(s, args, "zpopmin", false)
+}
+
+// 11) -> https://redis.io/docs/latest/commands/zpopmax
+// ZPopMax handles the ZPOPMAX command
+// It removes and returns up to count members with the highest scores in the sorted set stored at key
+func ZPopMax(s *storage.Storage, args []string) resp.Value {
+	return zpopGeneric(s, args, "zpopmax", true)
+}
+
+// zstoreGeneric implements ZUNIONSTORE and ZINTERSTORE
+// The arguments are: destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+func zstoreGeneric(s *storage.Storage, args []string, name string, op storage.SetOp) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs(name)
+	}
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil {
+		return notInteger()
+	}
+	if numKeys < 1 {
+		return resp.Err("ERR at least 1 input key is needed for '" + name + "' command")
+	}
+	if numKeys > len(args)-2 {
+		return syntaxError()
+	}
+	keys := args[2 : 2+numKeys]
+
+	var weights []float64
+	aggregate := storage.AggregateSum
+	for i := 2 + numKeys; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WEIGHTS":
+			if i+numKeys >= len(args) {
+				return syntaxError()
+			}
+			weights = make([]float64, numKeys)
+			for j := range weights {
+				weight, ok := parseScore(args[i+1+j])
+				if !ok {
+					return resp.Err("ERR weight value is not a float")
+				}
+				weights[j] = weight
+			}
+			i += numKeys
+		case "AGGREGATE":
+			if i+1 >= len(args) {
+				return syntaxError()
+			}
+			switch strings.ToUpper(args[i+1]) {
+			case "SUM":
+				aggregate = storage.AggregateSum
+			case "MIN":
+				aggregate = storage.AggregateMin
+			case "MAX":
+				aggregate = storage.AggregateMax
+			default:
+				return syntaxError()
+			}
+			i++
+		default:
+			return syntaxError()
+		}
+	}
+
+	count, err := s.ZStore(op, args[0], keys, weights, aggregate)
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.Integer(int64(count))
+}
+
+// 12) -> https://redis.io/docs/latest/commands/zunionstore
+// ZUnionStore handles the ZUNIONSTORE command
+// It computes the union of the given sorted sets and stores the result in destination
+// Returns the number of members in the resulting sorted set
+func ZUnionStore(s *storage.Storage, args []string) resp.Value {
+	return zstoreGeneric(s, args, "zunionstore", storage.SetUnion)
+}
+
+// 13) -> https://redis.io/docs/latest/commands/zinterstore
+// ZInterStore handles the ZINTERSTORE command
+// It computes the intersection of the given sorted sets and stores the result in destination
+// Returns the number of members in the resulting sorted set
+func ZInterStore(s *storage.Storage, args []string) resp.Value {
+	return zstoreGeneric(s, args, "zinterstore", storage.SetInter)
+}
@@ -0,0 +1,194 @@
+// https://redis.io/docs/latest/operate/oss_and_stack/management/config/
+// Package config reads the parameters of the server from a redis.conf file and the command line
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"redis/aof"
+	"redis/glob"
+	"redis/resp"
+	"strings"
+)
+
+// SaveParam is a save point: the data is saved in the background once at least
+// Changes writes were made and Seconds elapsed since the last save
+type SaveParam struct {
+	Seconds int
+	Changes int
+}
+
+// Config holds the parameters of the server
+type Config struct {
+	Port int      // TCP port to listen on
+	Bind []string // Addresses to listen on, "*" for every address; with a "-" prefix, an address that is not available is skipped
+	Dir  string   // Working directory, where the RDB file and the AOF directory are
+
+	Databases int // Number of databases, selected by index with SELECT
+
+	DBFilename string      // Name of the RDB file
+	Save       []SaveParam // When the data is saved to the RDB file in the background, none to disable
+
+	AppendOnly               bool            // Whether the AOF is enabled
+	AppendFilename           string          // Prefix of the names of the files of the AOF
+	AppendDirname            string          // Directory holding the files of the AOF
+	AppendFsync              aof.FsyncPolicy // When the AOF is flushed to disk
+	AOFLoadTruncated         bool            // Whether an incomplete command at the end of the AOF is dropped on startup
+	AutoAOFRewritePercentage int             // Growth of the AOF since the last rewrite that triggers a new one, 0 to disable
+	AutoAOFRewriteMinSize    int64           // Smallest AOF that is rewritten automatically
+
+	MaxClients      int   // Most clients connected at the same time
+	ProtoMaxBulkLen int64 // Longest bulk string accepted from clients
+
+	File string // Path of the configuration file, empty if the server was started without one
+}
+
+// Default returns the default configuration
+// It matches the defaults of Redis, except that the AOF is enabled and keeps the
+// database.aof name of the previous versions of this server
+func Default() *Config {
+	return &Config{
+		Port: 6379,
+		Bind: []string{"*", "-::*"},
+		Dir:  "./",
+
+		Databases: 16,
+
+		DBFilename: "dump.rdb",
+		Save:       []SaveParam{{Seconds: 3600, Changes: 1}, {Seconds: 300, Changes: 100}, {Seconds: 60, Changes: 10000}},
+
+		AppendOnly:               true,
+		AppendFilename:           "database.aof",
+		AppendDirname:            "appendonlydir",
+		AppendFsync:              aof.FsyncEverySec,
+		AOFLoadTruncated:         true,
+		AutoAOFRewritePercentage: 100,
+		AutoAOFRewriteMinSize:    64 * 1024 * 1024,
+
+		MaxClients:      10000,
+		ProtoMaxBulkLen: resp.DefaultMaxBulkLen,
+	}
+}
+
+// Clone returns a copy of the configuration, which can be modified without changing c
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Bind = append([]string(nil), c.Bind...)
+	clone.Save = append([]SaveParam(nil), c.Save...)
+	return &clone
+}
+
+// Load returns the configuration given by the command line arguments of the server, like
+// redis-server: the path of a configuration file, then parameters as --name value ...,
+// which override the ones of the file
+func Load(args []string) (*Config, error) {
+	c := Default()
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		// The server changes to dir on startup, so CONFIG REWRITE needs the absolute path
+		file, err := filepath.Abs(args[0])
+		if err != nil {
+			return nil, err
+		}
+		c.File = file
+		data, err := os.ReadFile(c.File)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Parse(string(data)); err != nil {
+			return nil, err
+		}
+		args = args[1:]
+	}
+
+	// Each --name starts a directive, followed by its arguments
+	var directives [][]string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--") {
+			directives = append(directives, []string{arg[2:]})
+			continue
+		}
+		if len(directives) == 0 {
+			return nil, fmt.Errorf("invalid argument '%s', parameters must start with --", arg)
+		}
+		directives[len(directives)-1] = append(directives[len(directives)-1], arg)
+	}
+	for _, directive := range directives {
+		if err := c.apply(directive, false); err != nil {
+			return nil, fmt.Errorf("invalid option '--%s': %v", strings.Join(directive, " "), err)
+		}
+	}
+	return c, nil
+}
+
+// Parse applies the directives of a configuration file: one parameter per line with its
+// arguments, quoted like the arguments of inline commands, and comments starting with #
+// As in Redis, the first save directive replaces the default save points, and the
+// following ones add to it
+func (c *Config) Parse(text string) error {
+	saved := false
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		args, err := resp.SplitArgs(line)
+		if errors.Is(err, resp.ErrUnbalancedQuotes) {
+			err = errors.New("Unbalanced quotes in configuration line")
+		} else if err == nil && len(args) > 0 {
+			err = c.apply(args, saved)
+			saved = saved || strings.EqualFold(args[0], "save")
+		}
+		if err != nil {
+			return fmt.Errorf("reading the configuration file, at line %d\n>>> '%s'\n%v", i+1, line, err)
+		}
+	}
+	return nil
+}
+
+// apply applies a directive: the name of a parameter followed by its arguments
+// If add is set, save points are added to the current ones instead of replacing them
+func (c *Config) apply(directive []string, add bool) error {
+	p, ok := lookup(directive[0])
+	if !ok || len(directive) < 2 || (!p.multiArg && len(directive) != 2) {
+		return errors.New("Bad directive or wrong number of arguments")
+	}
+
+	value := strings.Join(directive[1:], " ")
+	if p.name == "save" && add && value != "" {
+		value = strings.TrimSpace(p.get(c) + " " + value)
+	}
+	return p.set(c, value)
+}
+
+// Set sets a parameter from its value as given to CONFIG SET
+// The arguments of a parameter that takes several, such as save, are separated by spaces
+func (c *Config) Set(name, value string) error {
+	p, ok := lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown parameter '%s'", name)
+	}
+	return p.set(c, value)
+}
+
+// Get returns the parameters whose name matches the glob-style pattern, with their values
+// as returned by CONFIG GET, in the order of the parameter table
+func (c *Config) Get(pattern string) [][2]string {
+	pattern = strings.ToLower(pattern)
+	var result [][2]string
+	for _, p := range params {
+		if glob.Match(pattern, p.name) {
+			result = append(result, [2]string{p.name, p.get(c)})
+		}
+	}
+	return result
+}
+
+// Exists reports whether there is a parameter with the given name, and whether it can be
+// changed while the server runs
+func Exists(name string) (exists, mutable bool) {
+	p, ok := lookup(name)
+	return ok, ok && p.mutable
+}
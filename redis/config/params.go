@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"redis/aof"
+	"strconv"
+	"strings"
+)
+
+// param describes a parameter: how it is read from its value and written back
+type param struct {
+	name     string
+	mutable  bool                            // Can be changed at runtime by CONFIG SET
+	multiArg bool                            // Takes several arguments in the configuration file, joined with spaces
+	get      func(c *Config) string          // Returns the value as reported by CONFIG GET
+	set      func(c *Config, v string) error // Validates the value and stores it
+}
+
+// params are the parameters of the server, in the order CONFIG GET and CONFIG REWRITE list them
+var params = []param{
+	intParam("port", false, 0, 65535, func(c *Config) *int { return &c.Port }),
+	{name: "bind", multiArg: true,
+		get: func(c *Config) string { return strings.Join(c.Bind, " ") },
+		set: func(c *Config, v string) error {
+			bind := strings.Fields(v)
+			if len(bind) == 0 {
+				return errors.New("Too few bind addresses")
+			}
+			c.Bind = bind
+			return nil
+		}},
+	{name: "dir", mutable: true,
+		get: func(c *Config) string { return c.Dir },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				return errors.New("dir can't be empty")
+			}
+			c.Dir = v
+			return nil
+		}},
+	intParam("databases", false, 1, math.MaxInt32, func(c *Config) *int { return &c.Databases }),
+	filenameParam("dbfilename", true, "dbfilename can't be a path, just a filename", func(c *Config) *string { return &c.DBFilename }),
+	{name: "save", mutable: true, multiArg: true,
+		get: func(c *Config) string {
+			values := make([]string, 0, 2*len(c.Save))
+			for _, sp := range c.Save {
+				values = append(values, strconv.Itoa(sp.Seconds), strconv.Itoa(sp.Changes))
+			}
+			return strings.Join(values, " ")
+		},
+		set: func(c *Config, v string) error {
+			fields := strings.Fields(v)
+			if len(fields)%2 != 0 {
+				return errors.New("Invalid save parameters")
+			}
+			save := make([]SaveParam, 0, len(fields)/2)
+			for i := 0; i < len(fields); i += 2 {
+				seconds, err1 := strconv.Atoi(fields[i])
+				changes, err2 := strconv.Atoi(fields[i+1])
+				if err1 != nil || err2 != nil || seconds < 1 || changes < 0 {
+					return errors.New("Invalid save parameters")
+				}
+				save = append(save, SaveParam{Seconds: seconds, Changes: changes})
+			}
+			c.Save = save
+			return nil
+		}},
+	boolParam("appendonly", false, func(c *Config) *bool { return &c.AppendOnly }),
+	filenameParam("appendfilename", false, "appendfilename can't be a path, just a filename", func(c *Config) *string { return &c.AppendFilename }),
+	filenameParam("appenddirname", false, "appenddirname can't be a path, just a dir name", func(c *Config) *string { return &c.AppendDirname }),
+	{name: "appendfsync", mutable: true,
+		get: func(c *Config) string { return c.AppendFsync.String() },
+		set: func(c *Config, v string) error {
+			policy, err := aof.ParseFsyncPolicy(v)
+			if err != nil {
+				return errors.New("argument(s) must be one of the following: always, everysec, no")
+			}
+			c.AppendFsync = policy
+			return nil
+		}},
+	boolParam("aof-load-truncated", true, func(c *Config) *bool { return &c.AOFLoadTruncated }),
+	intParam("auto-aof-rewrite-percentage", true, 0, math.MaxInt32, func(c *Config) *int { return &c.AutoAOFRewritePercentage }),
+	memoryParam("auto-aof-rewrite-min-size", true, 0, math.MaxInt64, func(c *Config) *int64 { return &c.AutoAOFRewriteMinSize }),
+	intParam("maxclients", true, 1, math.MaxInt32, func(c *Config) *int { return &c.MaxClients }),
+	memoryParam("proto-max-bulk-len", true, 1024*1024, math.MaxInt64, func(c *Config) *int64 { return &c.ProtoMaxBulkLen }),
+}
+
+// lookup returns the parameter with the given name, which is case-insensitive
+func lookup(name string) (param, bool) {
+	for _, p := range params {
+		if strings.EqualFold(p.name, name) {
+			return p, true
+		}
+	}
+	return param{}, false
+}
+
+// boolParam returns a parameter set by yes or no
+func boolParam(name string, mutable bool, field func(c *Config) *bool) param {
+	return param{name: name, mutable: mutable,
+		get: func(c *Config) string {
+			if *field(c) {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(c *Config, v string) error {
+			switch strings.ToLower(v) {
+			case "yes":
+				*field(c) = true
+			case "no":
+				*field(c) = false
+			default:
+				return errors.New("argument must be 'yes' or 'no'")
+			}
+			return nil
+		}}
+}
+
+// intParam returns a parameter holding an integer between min and max
+func intParam(name string, mutable bool, min, max int, field func(c *Config) *int) param {
+	return param{name: name, mutable: mutable,
+		get: func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an integer")
+			}
+			if n < min || n > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*field(c) = n
+			return nil
+		}}
+}
+
+// memoryParam returns a parameter holding a number of bytes between min and max,
+// which may be given with a unit: 1k is 1000 bytes, 1kb is 1024, and so on with m, mb, g and gb
+func memoryParam(name string, mutable bool, min, max int64, field func(c *Config) *int64) param {
+	return param{name: name, mutable: mutable,
+		get: func(c *Config) string { return strconv.FormatInt(*field(c), 10) },
+		set: func(c *Config, v string) error {
+			n, err := parseMemory(v)
+			if err != nil {
+				return err
+			}
+			if n < min || n > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*field(c) = n
+			return nil
+		}}
+}
+
+// memoryUnits are the multipliers of the units of memory values
+var memoryUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"kb", 1024}, {"mb", 1024 * 1024}, {"gb", 1024 * 1024 * 1024},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseMemory parses a memory value, such as 64mb
+func parseMemory(v string) (int64, error) {
+	lower := strings.ToLower(v)
+	multiplier := int64(1)
+	for _, unit := range memoryUnits {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower = strings.TrimSuffix(lower, unit.suffix)
+			multiplier = unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n > math.MaxInt64/multiplier || n < math.MinInt64/multiplier {
+		return 0, errors.New("argument must be a memory value")
+	}
+	return n * multiplier, nil
+}
+
+// filenameParam returns a parameter holding the name of a file, which cannot be a path
+func filenameParam(name string, mutable bool, pathErr string, field func(c *Config) *string) param {
+	return param{name: name, mutable: mutable,
+		get: func(c *Config) string { return *field(c) },
+		set: func(c *Config, v string) error {
+			if v == "" || strings.ContainsAny(v, "/\\") || v == "." || v == ".." {
+				return errors.New(pathErr)
+			}
+			*field(c) = v
+			return nil
+		}}
+}
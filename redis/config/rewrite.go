@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"redis/resp"
+	"strings"
+)
+
+// rewriteSignature is the comment before the parameters CONFIG REWRITE appends to the file
+const rewriteSignature = "# Generated by CONFIG REWRITE"
+
+// ErrNoConfigFile is returned by Rewrite when the server was started without a configuration file
+var ErrNoConfigFile = errors.New("ERR The server is running without a config file")
+
+// Rewrite writes the configuration back to its file, with the minimal changes:
+// the line of each parameter is replaced in place, comments and unknown lines are kept,
+// and the parameters missing from the file that differ from their default are appended
+// The file is replaced atomically, so a failed rewrite leaves the previous one intact
+func (c *Config) Rewrite() error {
+	if c.File == "" {
+		return ErrNoConfigFile
+	}
+	data, err := os.ReadFile(c.File)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	defaults := Default()
+	written := make(map[string]bool)
+	signed := false
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		signed = signed || trimmed == rewriteSignature
+		args, err := resp.SplitArgs(trimmed)
+		if trimmed == "" || trimmed[0] == '#' || err != nil || len(args) == 0 {
+			lines = append(lines, line)
+			continue
+		}
+		p, ok := lookup(args[0])
+		if !ok {
+			lines = append(lines, line)
+			continue
+		}
+		// The first line of a parameter is replaced by its current value, the others are dropped
+		if !written[p.name] {
+			written[p.name] = true
+			lines = append(lines, p.lines(c)...)
+		}
+	}
+
+	var appended []string
+	for _, p := range params {
+		if written[p.name] || p.get(c) == p.get(defaults) {
+			continue
+		}
+		appended = append(appended, p.lines(c)...)
+	}
+	// A previous rewrite already appended parameters after the signature
+	if len(appended) > 0 && !signed {
+		lines = append(lines, rewriteSignature)
+	}
+	if len(appended) > 0 {
+		lines = append(lines, appended...)
+	}
+	return writeFile(c.File, strings.Join(lines, "\n")+"\n")
+}
+
+// lines returns the lines of the configuration file that set the parameter to its value in c
+func (p param) lines(c *Config) []string {
+	value := p.get(c)
+	if p.name == "save" {
+		// Each save point is on its own line, and an empty value disables saving
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return []string{`save ""`}
+		}
+		lines := make([]string, 0, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			lines = append(lines, fmt.Sprintf("save %s %s", fields[i], fields[i+1]))
+		}
+		return lines
+	}
+	if p.multiArg {
+		fields := strings.Fields(value)
+		for i, field := range fields {
+			fields[i] = quote(field)
+		}
+		return []string{p.name + " " + strings.Join(fields, " ")}
+	}
+	return []string{p.name + " " + quote(value)}
+}
+
+// quote returns the value as an argument of a configuration line,
+// in double quotes with escapes if it is empty or holds spaces, quotes or control characters
+func quote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n\"'\\") && strings.IndexFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) < 0 {
+		return value
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; ch {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if ch < 0x20 || ch == 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, ch)
+			} else {
+				b.WriteByte(ch)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// writeFile replaces the file at path with the content, through a temporary file
+// that is flushed to disk and renamed over it
+func writeFile(path, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "temp-config-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	return os.Rename(tmp.Name(), path)
+}
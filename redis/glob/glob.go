@@ -0,0 +1,103 @@
+// Package glob implements the glob-style patterns used by Redis in PSUBSCRIBE, KEYS, SCAN and CONFIG GET
+package glob
+
+// Match reports whether str matches pattern, following the rules of Redis (util.c, stringmatchlen):
+//   - * matches any sequence of characters, including an empty one
+//   - ? matches any single character
+//   - [abc] matches one of the characters in the brackets, [^abc] any other character,
+//     and [a-z] a range of characters
+//   - \ escapes the next character, so it is matched literally
+func Match(pattern, str string) bool {
+	skipLongerMatches := false
+	return match(pattern, str, &skipLongerMatches)
+}
+
+// match is the recursive implementation of Match
+// skipLongerMatches is set when a * failed to match any suffix of the string, in which case
+// trying a longer match for an enclosing * cannot succeed either
+func match(p, s string, skipLongerMatches *bool) bool {
+	for len(p) > 0 && len(s) > 0 {
+		switch p[0] {
+		case '*':
+			for len(p) > 1 && p[1] == '*' {
+				p = p[1:]
+			}
+			if len(p) == 1 {
+				return true
+			}
+			for len(s) > 0 {
+				if match(p[1:], s, skipLongerMatches) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+				s = s[1:]
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			s = s[1:]
+		case '[':
+			p = p[1:]
+			not := len(p) > 0 && p[0] == '^'
+			if not {
+				p = p[1:]
+			}
+			matched := false
+			for {
+				if len(p) == 0 {
+					// Unterminated set: keep the last character so the outer loop consumes it
+					p = " "
+					break
+				}
+				if p[0] == '\\' && len(p) >= 2 {
+					p = p[1:]
+					if p[0] == s[0] {
+						matched = true
+					}
+				} else if p[0] == ']' {
+					break
+				} else if len(p) >= 3 && p[1] == '-' {
+					start, end := p[0], p[2]
+					if start > end {
+						start, end = end, start
+					}
+					p = p[2:]
+					if s[0] >= start && s[0] <= end {
+						matched = true
+					}
+				} else if p[0] == s[0] {
+					matched = true
+				}
+				p = p[1:]
+			}
+			if not {
+				matched = !matched
+			}
+			if !matched {
+				return false
+			}
+			s = s[1:]
+		case '\\':
+			if len(p) >= 2 {
+				p = p[1:]
+			}
+			fallthrough
+		default:
+			if p[0] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		p = p[1:]
+	}
+
+	// Trailing stars match the empty rest of the string
+	if len(s) == 0 {
+		for len(p) > 0 && p[0] == '*' {
+			p = p[1:]
+		}
+	}
+	return len(p) == 0 && len(s) == 0
+}
@@ -3,18 +3,23 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"redis/config"
 	"redis/server"
 )
 
 func main() {
-	// Define the port on which the server will listen
-	const port = ":6379"
+	// Read the configuration: an optional redis.conf-style file, then --name value overrides
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Print a startup message
-	fmt.Printf("Starting Redis server on port %s\n", port)
+	fmt.Printf("Starting Redis server on port %d\n", cfg.Port)
 
 	// Create a new server instance
-	server, err := server.NewServer(port)
+	server, err := server.NewServer(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
@@ -0,0 +1,286 @@
+// https://redis.io/docs/latest/develop/interact/pubsub/
+package pubsub
+
+import (
+	"redis/glob"
+	"redis/resp"
+	"sort"
+	"sync"
+)
+
+// queueSize is the number of messages that can wait to be written to a subscriber
+// A subscriber that falls further behind is disconnected, like Redis does when a client
+// exceeds its pubsub output buffer limit, so publishers never wait for slow readers
+const queueSize = 1024
+
+// Subscriber is a connection that subscribed to channels or patterns
+// Everything written to the connection goes through its queue, so replies and
+// published messages reach the client in order
+type Subscriber struct {
+	queue     chan resp.Value
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// Guarded by the hub lock
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+// NewSubscriber creates a subscriber with an empty queue
+func NewSubscriber() *Subscriber {
+	return &Subscriber{
+		queue:    make(chan resp.Value, queueSize),
+		done:     make(chan struct{}),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Queue returns the values waiting to be written to the connection
+func (sub *Subscriber) Queue() <-chan resp.Value {
+	return sub.queue
+}
+
+// Done is closed when the subscriber is closed, either by the connection or because it fell behind
+func (sub *Subscriber) Done() <-chan struct{} {
+	return sub.done
+}
+
+// Close marks the subscriber as closed, it is safe to call more than once
+func (sub *Subscriber) Close() {
+	sub.closeOnce.Do(func() { close(sub.done) })
+}
+
+// Send queues a value for the connection, waiting for room in the queue
+// Returns false if the subscriber is closed
+func (sub *Subscriber) Send(v resp.Value) bool {
+	select {
+	case sub.queue <- v:
+		return true
+	case <-sub.done:
+		return false
+	}
+}
+
+// sendAll queues values for the connection with Send, stopping if the subscriber is closed
+func (sub *Subscriber) sendAll(values []resp.Value) {
+	for _, v := range values {
+		if !sub.Send(v) {
+			return
+		}
+	}
+}
+
+// deliver queues a value without waiting, closing the subscriber if its queue is full
+func (sub *Subscriber) deliver(v resp.Value) {
+	select {
+	case sub.queue <- v:
+	default:
+		sub.Close()
+	}
+}
+
+// count returns the number of channels and patterns the subscriber is subscribed to
+func (sub *Subscriber) count() int {
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// Hub keeps track of subscriptions and routes published messages to subscribers
+type Hub struct {
+	channels map[string]map[*Subscriber]struct{} // Subscribers of each channel
+	patterns map[string]map[*Subscriber]struct{} // Subscribers of each pattern
+	mu       sync.RWMutex
+}
+
+// NewHub creates a hub without subscriptions
+func NewHub() *Hub {
+	return &Hub{
+		channels: make(map[string]map[*Subscriber]struct{}),
+		patterns: make(map[string]map[*Subscriber]struct{}),
+	}
+}
+
+// confirmation builds the reply sent for each (un)subscribed channel or pattern
+// Like messages, it is pushed to RESP3 clients
+func confirmation(kind string, name *string, count int) resp.Value {
+	nameValue := resp.Null()
+	if name != nil {
+		nameValue = resp.Bulk(*name)
+	}
+	return resp.Push(
+		resp.Bulk(kind),
+		nameValue,
+		resp.Integer(int64(count)),
+	)
+}
+
+// subscribe adds the subscriber to every name in the index
+// Returns the confirmation of each one, to be sent once the lock is released
+// The caller must hold the write lock
+func (h *Hub) subscribe(sub *Subscriber, index map[string]map[*Subscriber]struct{}, own map[string]struct{}, kind string, names []string) []resp.Value {
+	confirmations := make([]resp.Value, 0, len(names))
+	for _, name := range names {
+		if _, ok := own[name]; !ok {
+			own[name] = struct{}{}
+			if index[name] == nil {
+				index[name] = make(map[*Subscriber]struct{})
+			}
+			index[name][sub] = struct{}{}
+		}
+		confirmations = append(confirmations, confirmation(kind, &name, sub.count()))
+	}
+	return confirmations
+}
+
+// unsubscribe removes the subscriber from the given names, or from all of its own if none are given
+// Returns the confirmation of each one, to be sent once the lock is released
+// The caller must hold the write lock
+func (h *Hub) unsubscribe(sub *Subscriber, index map[string]map[*Subscriber]struct{}, own map[string]struct{}, kind string, names []string) []resp.Value {
+	if len(names) == 0 {
+		for name := range own {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return []resp.Value{confirmation(kind, nil, sub.count())}
+		}
+	}
+	confirmations := make([]resp.Value, 0, len(names))
+	for _, name := range names {
+		if _, ok := own[name]; ok {
+			delete(own, name)
+			delete(index[name], sub)
+			if len(index[name]) == 0 {
+				delete(index, name)
+			}
+		}
+		confirmations = append(confirmations, confirmation(kind, &name, sub.count()))
+	}
+	return confirmations
+}
+
+// Subscribe subscribes to channels
+// A confirmation with the number of active subscriptions is queued for each channel
+// Confirmations are replies rather than published messages, so they wait for room in the
+// queue instead of counting towards the limit of a slow subscriber
+func (h *Hub) Subscribe(sub *Subscriber, channels ...string) {
+	h.mu.Lock()
+	confirmations := h.subscribe(sub, h.channels, sub.channels, "subscribe", channels)
+	h.mu.Unlock()
+	sub.sendAll(confirmations)
+}
+
+// Unsubscribe unsubscribes from channels, or from all channels if none are given
+func (h *Hub) Unsubscribe(sub *Subscriber, channels ...string) {
+	h.mu.Lock()
+	confirmations := h.unsubscribe(sub, h.channels, sub.channels, "unsubscribe", channels)
+	h.mu.Unlock()
+	sub.sendAll(confirmations)
+}
+
+// PSubscribe subscribes to glob-style patterns
+func (h *Hub) PSubscribe(sub *Subscriber, patterns ...string) {
+	h.mu.Lock()
+	confirmations := h.subscribe(sub, h.patterns, sub.patterns, "psubscribe", patterns)
+	h.mu.Unlock()
+	sub.sendAll(confirmations)
+}
+
+// PUnsubscribe unsubscribes from patterns, or from all patterns if none are given
+func (h *Hub) PUnsubscribe(sub *Subscriber, patterns ...string) {
+	h.mu.Lock()
+	confirmations := h.unsubscribe(sub, h.patterns, sub.patterns, "punsubscribe", patterns)
+	h.mu.Unlock()
+	sub.sendAll(confirmations)
+}
+
+// Remove drops all subscriptions of a subscriber without confirming them, when its connection is closed
+func (h *Hub) Remove(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for channel := range sub.channels {
+		delete(h.channels[channel], sub)
+		if len(h.channels[channel]) == 0 {
+			delete(h.channels, channel)
+		}
+	}
+	for pattern := range sub.patterns {
+		delete(h.patterns[pattern], sub)
+		if len(h.patterns[pattern]) == 0 {
+			delete(h.patterns, pattern)
+		}
+	}
+	sub.channels = make(map[string]struct{})
+	sub.patterns = make(map[string]struct{})
+}
+
+// Subscribed reports whether the subscriber has any active subscription
+func (h *Hub) Subscribed(sub *Subscriber) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return sub.count() > 0
+}
+
+// Publish sends a message to the subscribers of the channel and of every matching pattern
+// Messages are queued without waiting, so a slow subscriber cannot stall the publisher
+// Returns the number of subscribers that received the message
+func (h *Hub) Publish(channel, message string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	receivers := 0
+	for sub := range h.channels[channel] {
+		sub.deliver(resp.Push(
+			resp.Bulk("message"),
+			resp.Bulk(channel),
+			resp.Bulk(message),
+		))
+		receivers++
+	}
+	for pattern, subs := range h.patterns {
+		if !glob.Match(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			sub.deliver(resp.Push(
+				resp.Bulk("pmessage"),
+				resp.Bulk(pattern),
+				resp.Bulk(channel),
+				resp.Bulk(message),
+			))
+			receivers++
+		}
+	}
+	return receivers
+}
+
+// Channels returns the active channels (those with at least one subscriber) matching the pattern,
+// or all of them if the pattern is empty
+func (h *Hub) Channels(pattern string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	channels := []string{}
+	for channel := range h.channels {
+		if pattern == "" || glob.Match(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// NumSub returns the number of subscribers of a channel, not counting pattern subscribers
+func (h *Hub) NumSub(channel string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.channels[channel])
+}
+
+// NumPat returns the number of distinct patterns subscribed to by any client
+func (h *Hub) NumPat() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.patterns)
+}
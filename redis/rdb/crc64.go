@@ -0,0 +1,19 @@
+package rdb
+
+import "hash/crc64"
+
+// crcTable is the table of the CRC-64-Jones checksum Redis appends to RDB files,
+// from the reflected form of the polynomial 0xad93d23594c935a9
+var crcTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// updateCRC adds p to the checksum crc
+// The crc64 package inverts the checksum before and after the update, while Redis starts
+// from 0 and does not invert it, so the inversions are undone
+func updateCRC(crc uint64, p []byte) uint64 {
+	return ^crc64.Update(^crc, crcTable, p)
+}
+
+// Checksum returns the CRC-64-Jones checksum of p, as computed by Redis
+func Checksum(p []byte) uint64 {
+	return updateCRC(0, p)
+}
@@ -0,0 +1,203 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// listpackHeaderLen is the size of the header of a listpack: its size in bytes
+// and its number of elements
+const listpackHeaderLen = 6
+
+// listpackEnd terminates a listpack
+const listpackEnd = 0xFF
+
+// Listpack builds a listpack, the compact list of strings and integers Redis uses
+// to store the entries of streams
+type Listpack struct {
+	buf   []byte
+	count int
+}
+
+// NewListpack creates an empty listpack
+func NewListpack() *Listpack {
+	return &Listpack{buf: make([]byte, listpackHeaderLen)}
+}
+
+// AppendInt appends an integer, in the smallest encoding that holds it
+func (lp *Listpack) AppendInt(n int64) {
+	start := len(lp.buf)
+	switch {
+	case n >= 0 && n <= 127:
+		lp.buf = append(lp.buf, byte(n))
+	case n >= -4096 && n <= 4095:
+		v := uint64(n) & (1<<13 - 1)
+		lp.buf = append(lp.buf, byte(v>>8)|0xC0, byte(v))
+	case n >= -1<<15 && n < 1<<15:
+		lp.buf = binary.LittleEndian.AppendUint16(append(lp.buf, 0xF1), uint16(n))
+	case n >= -1<<23 && n < 1<<23:
+		lp.buf = append(lp.buf, 0xF2, byte(n), byte(n>>8), byte(n>>16))
+	case n >= -1<<31 && n < 1<<31:
+		lp.buf = binary.LittleEndian.AppendUint32(append(lp.buf, 0xF3), uint32(n))
+	default:
+		lp.buf = binary.LittleEndian.AppendUint64(append(lp.buf, 0xF4), uint64(n))
+	}
+	lp.appendBacklen(len(lp.buf) - start)
+}
+
+// AppendString appends a string
+func (lp *Listpack) AppendString(s string) {
+	start := len(lp.buf)
+	switch n := len(s); {
+	case n < 64:
+		lp.buf = append(lp.buf, 0x80|byte(n))
+	case n < 4096:
+		lp.buf = append(lp.buf, 0xE0|byte(n>>8), byte(n))
+	default:
+		lp.buf = binary.LittleEndian.AppendUint32(append(lp.buf, 0xF0), uint32(n))
+	}
+	lp.buf = append(lp.buf, s...)
+	lp.appendBacklen(len(lp.buf) - start)
+}
+
+// appendBacklen appends the length of the element that was just appended, which lets
+// Redis walk the listpack backwards: 7 bits per byte, the first byte holding the high bits
+// and the others having their high bit set
+func (lp *Listpack) appendBacklen(n int) {
+	size := backlenSize(n)
+	for i := size - 1; i >= 0; i-- {
+		b := byte(n>>(7*i)) & 0x7F
+		if i < size-1 {
+			b |= 0x80
+		}
+		lp.buf = append(lp.buf, b)
+	}
+	lp.count++
+}
+
+// backlenSize returns the number of bytes of the backlen of an element of n bytes
+func backlenSize(n int) int {
+	switch {
+	case n < 1<<7:
+		return 1
+	case n < 1<<14-1:
+		return 2
+	case n < 1<<21-1:
+		return 3
+	case n < 1<<28-1:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// Len returns the number of elements of the listpack
+func (lp *Listpack) Len() int {
+	return lp.count
+}
+
+// Size returns the size of the listpack in bytes, once terminated
+func (lp *Listpack) Size() int {
+	return len(lp.buf) + 1
+}
+
+// Bytes terminates the listpack and returns it
+func (lp *Listpack) Bytes() []byte {
+	b := append(lp.buf[:len(lp.buf):len(lp.buf)], listpackEnd)
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	binary.LittleEndian.PutUint16(b[4:], uint16(min(lp.count, 0xFFFF)))
+	return b
+}
+
+// errListpack is returned for a listpack that cannot be decoded
+var errListpack = errors.New("invalid listpack")
+
+// DecodeListpack returns the elements of a listpack, with integers formatted as strings
+func DecodeListpack(b []byte) ([]string, error) {
+	if len(b) < listpackHeaderLen+1 || int(binary.LittleEndian.Uint32(b)) != len(b) {
+		return nil, errListpack
+	}
+
+	var elements []string
+	for i := listpackHeaderLen; b[i] != listpackEnd; {
+		element, size, err := decodeElement(b[i:])
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+		i += size + backlenSize(size)
+		if i >= len(b) {
+			return nil, errListpack
+		}
+	}
+	return elements, nil
+}
+
+// decodeElement decodes the element at the start of b
+// Returns the element and the size of its encoding, not counting its backlen
+func decodeElement(b []byte) (string, int, error) {
+	// need returns false if b is shorter than n bytes
+	need := func(n int) bool { return len(b) >= n }
+
+	switch first := b[0]; {
+	case first&0x80 == 0:
+		return strconv.Itoa(int(first)), 1, nil
+	case first&0xC0 == 0x80:
+		n := int(first & 0x3F)
+		if !need(1 + n) {
+			return "", 0, errListpack
+		}
+		return string(b[1 : 1+n]), 1 + n, nil
+	case first&0xE0 == 0xC0:
+		if !need(2) {
+			return "", 0, errListpack
+		}
+		v := int64(first&0x1F)<<8 | int64(b[1])
+		if v >= 1<<12 {
+			v -= 1 << 13
+		}
+		return strconv.FormatInt(v, 10), 2, nil
+	case first&0xF0 == 0xE0:
+		if !need(2) {
+			return "", 0, errListpack
+		}
+		n := int(first&0x0F)<<8 | int(b[1])
+		if !need(2 + n) {
+			return "", 0, errListpack
+		}
+		return string(b[2 : 2+n]), 2 + n, nil
+	case first == 0xF0:
+		if !need(5) {
+			return "", 0, errListpack
+		}
+		n := int(binary.LittleEndian.Uint32(b[1:]))
+		if n < 0 || !need(5+n) {
+			return "", 0, errListpack
+		}
+		return string(b[5 : 5+n]), 5 + n, nil
+	case first == 0xF1:
+		if !need(3) {
+			return "", 0, errListpack
+		}
+		return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(b[1:]))), 10), 3, nil
+	case first == 0xF2:
+		if !need(4) {
+			return "", 0, errListpack
+		}
+		v := int32(uint32(b[1])<<8|uint32(b[2])<<16|uint32(b[3])<<24) >> 8
+		return strconv.FormatInt(int64(v), 10), 4, nil
+	case first == 0xF3:
+		if !need(5) {
+			return "", 0, errListpack
+		}
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(b[1:]))), 10), 5, nil
+	case first == 0xF4:
+		if !need(9) {
+			return "", 0, errListpack
+		}
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(b[1:])), 10), 9, nil
+	default:
+		return "", 0, errListpack
+	}
+}
@@ -0,0 +1,399 @@
+// https://rdb.fnordig.de/file_format.html
+// Package rdb reads and writes the binary snapshot format of Redis
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Version is the version of the format written, which Redis 5 and later can load
+const Version = 9
+
+// Types of the values, written before each key
+const (
+	TypeString = 0
+	TypeList   = 1
+	TypeSet    = 2
+	TypeHash   = 4
+	TypeZSet2  = 5 // Sorted set with binary scores
+	TypeStream = 15
+)
+
+// Opcodes that come in place of a type, for what is not a key
+const (
+	OpFunction     = 0xF5
+	OpModuleAux    = 0xF7
+	OpIdle         = 0xF8
+	OpFreq         = 0xF9
+	OpAux          = 0xFA
+	OpResizeDB     = 0xFB
+	OpExpireTimeMs = 0xFC
+	OpExpireTime   = 0xFD
+	OpSelectDB     = 0xFE
+	OpEOF          = 0xFF
+)
+
+// Encodings of the length of a string or a collection, in the two high bits of the first byte
+const (
+	len6Bit  = 0    // Length in the 6 other bits
+	len14Bit = 1    // Length in the 6 other bits and the next byte
+	len32Bit = 0x80 // Length in the next 4 bytes, big endian
+	len64Bit = 0x81 // Length in the next 8 bytes, big endian
+	encoded  = 3    // Not a length, but a special encoding of a string
+)
+
+// Special encodings of strings
+const (
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+)
+
+// ErrChecksum is returned when the checksum at the end of a file does not match its content
+var ErrChecksum = errors.New("wrong RDB checksum")
+
+// Encoder writes an RDB file
+// Errors are sticky: once a write fails, the following ones do nothing and Close returns the error
+type Encoder struct {
+	w   *bufio.Writer
+	crc uint64
+	err error
+}
+
+// NewEncoder creates an Encoder writing to w, and writes the header of the file
+func NewEncoder(w io.Writer) *Encoder {
+	e := &Encoder{w: bufio.NewWriter(w)}
+	e.write([]byte(fmt.Sprintf("REDIS%04d", Version)))
+	return e
+}
+
+// write writes raw bytes, adding them to the checksum
+func (e *Encoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	e.crc = updateCRC(e.crc, p)
+	_, e.err = e.w.Write(p)
+}
+
+// WriteByte writes a single byte, such as a type or an opcode
+func (e *Encoder) WriteByte(b byte) error {
+	e.write([]byte{b})
+	return e.err
+}
+
+// WriteLen writes a length
+func (e *Encoder) WriteLen(n uint64) {
+	switch {
+	case n < 1<<6:
+		e.write([]byte{byte(n)})
+	case n < 1<<14:
+		e.write([]byte{byte(n>>8) | len14Bit<<6, byte(n)})
+	case n <= math.MaxUint32:
+		e.write(binary.BigEndian.AppendUint32([]byte{len32Bit}, uint32(n)))
+	default:
+		e.write(binary.BigEndian.AppendUint64([]byte{len64Bit}, n))
+	}
+}
+
+// WriteString writes a string, encoded as an integer when it is the canonical
+// representation of one that fits in 32 bits, like Redis does
+func (e *Encoder) WriteString(s string) {
+	if len(s) <= 11 {
+		if n, err := strconv.ParseInt(s, 10, 32); err == nil && strconv.FormatInt(n, 10) == s {
+			e.writeInt(n)
+			return
+		}
+	}
+	e.WriteLen(uint64(len(s)))
+	e.write([]byte(s))
+}
+
+// writeInt writes an integer encoded string, in the smallest encoding that holds it
+func (e *Encoder) writeInt(n int64) {
+	switch {
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		e.write([]byte{encoded<<6 | encInt8, byte(n)})
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		e.write(binary.LittleEndian.AppendUint16([]byte{encoded<<6 | encInt16}, uint16(n)))
+	default:
+		e.write(binary.LittleEndian.AppendUint32([]byte{encoded<<6 | encInt32}, uint32(n)))
+	}
+}
+
+// WriteBytes writes a string holding binary data, which is never encoded as an integer
+func (e *Encoder) WriteBytes(p []byte) {
+	e.WriteLen(uint64(len(p)))
+	e.write(p)
+}
+
+// WriteDouble writes a double as 8 bytes, as in the scores of sorted sets
+func (e *Encoder) WriteDouble(f float64) {
+	e.write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+// WriteMillis writes a time in unix milliseconds as 8 bytes
+func (e *Encoder) WriteMillis(ms int64) {
+	e.write(binary.LittleEndian.AppendUint64(nil, uint64(ms)))
+}
+
+// WriteRaw writes bytes as they are, as the stream IDs of pending entries lists
+func (e *Encoder) WriteRaw(p []byte) {
+	e.write(p)
+}
+
+// WriteAux writes an auxiliary field, which describes the file or the server that wrote it
+func (e *Encoder) WriteAux(key, value string) {
+	e.WriteByte(OpAux)
+	e.WriteString(key)
+	e.WriteString(value)
+}
+
+// Close writes the end of the file and its checksum, and flushes it
+// Returns the first error that occurred while writing the file
+func (e *Encoder) Close() error {
+	e.WriteByte(OpEOF)
+	if e.err != nil {
+		return e.err
+	}
+	// The checksum is not part of itself
+	if _, err := e.w.Write(binary.LittleEndian.AppendUint64(nil, e.crc)); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// Decoder reads an RDB file
+// Errors are sticky: once a read fails, the following ones return zero values and Err returns the error
+type Decoder struct {
+	r   *bufio.Reader
+	crc uint64
+	err error
+}
+
+// NewDecoder creates a Decoder reading from r, and reads the header of the file
+// Returns an error if it is not an RDB file of a version up to Version
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	d := &Decoder{r: bufio.NewReader(r)}
+	header := d.read(9)
+	if d.err != nil {
+		return nil, d.err
+	}
+	if string(header[:5]) != "REDIS" {
+		return nil, errors.New("not an RDB file")
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil || version < 1 || version > Version {
+		return nil, fmt.Errorf("unsupported RDB version %q", header[5:])
+	}
+	return d, nil
+}
+
+// Err returns the first error that occurred while reading
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// fail records an error, unless there was one already
+func (d *Decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// read reads n raw bytes, adding them to the checksum
+func (d *Decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(d.r, p); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.fail(err)
+		return nil
+	}
+	d.crc = updateCRC(d.crc, p)
+	return p
+}
+
+// ReadByte reads a single byte, such as a type or an opcode
+func (d *Decoder) ReadByte() (byte, error) {
+	p := d.read(1)
+	if p == nil {
+		return 0, d.err
+	}
+	return p[0], nil
+}
+
+// readLen reads a length, or the special encoding of a string
+// Returns the length, and whether it is a special encoding instead
+func (d *Decoder) readLen() (uint64, bool) {
+	first, err := d.ReadByte()
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case first>>6 == len6Bit:
+		return uint64(first & 0x3F), false
+	case first>>6 == len14Bit:
+		next, _ := d.ReadByte()
+		return uint64(first&0x3F)<<8 | uint64(next), false
+	case first == len32Bit:
+		if p := d.read(4); p != nil {
+			return uint64(binary.BigEndian.Uint32(p)), false
+		}
+	case first == len64Bit:
+		if p := d.read(8); p != nil {
+			return binary.BigEndian.Uint64(p), false
+		}
+	case first>>6 == encoded:
+		return uint64(first & 0x3F), true
+	default:
+		d.fail(fmt.Errorf("invalid length encoding 0x%02x", first))
+	}
+	return 0, false
+}
+
+// ReadLen reads a length
+func (d *Decoder) ReadLen() uint64 {
+	n, special := d.readLen()
+	if special {
+		d.fail(errors.New("unexpected string encoding in place of a length"))
+		return 0
+	}
+	return n
+}
+
+// ReadString reads a string, in any of its encodings
+func (d *Decoder) ReadString() string {
+	n, special := d.readLen()
+	if d.err != nil {
+		return ""
+	}
+	if !special {
+		return string(d.read(int(n)))
+	}
+
+	switch n {
+	case encInt8:
+		if p := d.read(1); p != nil {
+			return strconv.Itoa(int(int8(p[0])))
+		}
+	case encInt16:
+		if p := d.read(2); p != nil {
+			return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(p))))
+		}
+	case encInt32:
+		if p := d.read(4); p != nil {
+			return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(p))))
+		}
+	case encLZF:
+		compressed, length := d.ReadLen(), d.ReadLen()
+		if p := d.read(int(compressed)); p != nil {
+			s, err := decompressLZF(p, int(length))
+			d.fail(err)
+			return string(s)
+		}
+	default:
+		d.fail(fmt.Errorf("invalid string encoding %d", n))
+	}
+	return ""
+}
+
+// ReadDouble reads a double written as 8 bytes
+func (d *Decoder) ReadDouble() float64 {
+	if p := d.read(8); p != nil {
+		return math.Float64frombits(binary.LittleEndian.Uint64(p))
+	}
+	return 0
+}
+
+// ReadMillis reads a time in unix milliseconds written as 8 bytes
+func (d *Decoder) ReadMillis() int64 {
+	if p := d.read(8); p != nil {
+		return int64(binary.LittleEndian.Uint64(p))
+	}
+	return 0
+}
+
+// ReadSeconds reads a time in unix seconds written as 4 bytes, as in old expiry opcodes
+func (d *Decoder) ReadSeconds() int64 {
+	if p := d.read(4); p != nil {
+		return int64(int32(binary.LittleEndian.Uint32(p)))
+	}
+	return 0
+}
+
+// ReadRaw reads n bytes as they are
+func (d *Decoder) ReadRaw(n int) []byte {
+	return d.read(n)
+}
+
+// VerifyChecksum reads the checksum that follows the EOF opcode and checks it
+// A checksum of 0 means the file was written without one, and is always accepted
+func (d *Decoder) VerifyChecksum() error {
+	if d.err != nil {
+		return d.err
+	}
+	expected := d.crc
+	p := make([]byte, 8)
+	if _, err := io.ReadFull(d.r, p); err != nil {
+		return fmt.Errorf("missing RDB checksum: %v", err)
+	}
+	if checksum := binary.LittleEndian.Uint64(p); checksum != 0 && checksum != expected {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// decompressLZF decompresses a string compressed by Redis with LZF
+func decompressLZF(in []byte, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+		if ctrl < 32 {
+			// Literal run of ctrl+1 bytes
+			if i+ctrl+1 > len(in) {
+				return nil, errors.New("invalid LZF data")
+			}
+			out = append(out, in[i:i+ctrl+1]...)
+			i += ctrl + 1
+			continue
+		}
+
+		// Back reference
+		n := ctrl >> 5
+		if n == 7 {
+			if i >= len(in) {
+				return nil, errors.New("invalid LZF data")
+			}
+			n += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, errors.New("invalid LZF data")
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 {
+			return nil, errors.New("invalid LZF data")
+		}
+		for j := 0; j < n+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+	if len(out) != length {
+		return nil, errors.New("invalid LZF length")
+	}
+	return out, nil
+}
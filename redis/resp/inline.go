@@ -0,0 +1,178 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// MaxInlineLen is the longest inline command accepted, like PROTO_INLINE_MAX_SIZE in Redis
+const MaxInlineLen = 64 * 1024
+
+var (
+	// ErrInlineTooLong is returned when an inline command has no newline within MaxInlineLen bytes
+	ErrInlineTooLong = fmt.Errorf("%w: too big inline request", ErrProtocol)
+	// ErrUnbalancedQuotes is returned when a quoted argument of an inline command is not closed
+	ErrUnbalancedQuotes = fmt.Errorf("%w: unbalanced quotes in request", ErrProtocol)
+)
+
+// readInline reads an inline command: arguments separated by spaces on a single line
+// It returns the same array of bulk strings as a command sent in RESP, or an empty
+// array for a blank line
+func (r *Resp) readInline() (Value, error) {
+	var line []byte
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		if len(line)+len(chunk) > MaxInlineLen {
+			return Value{}, ErrInlineTooLong
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return Value{}, err
+		}
+	}
+
+	args, err := splitArgs(line)
+	if err != nil {
+		return Value{}, err
+	}
+
+	array := make([]Value, len(args))
+	for i, arg := range args {
+		array[i] = BulkBytes(arg)
+	}
+	return Array(array...), nil
+}
+
+// SplitArgs splits a line into arguments following the same rules as inline commands,
+// as Redis does for the lines of redis.conf
+func SplitArgs(line string) ([]string, error) {
+	split, err := splitArgs([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, len(split))
+	for i, arg := range split {
+		args[i] = string(arg)
+	}
+	return args, nil
+}
+
+// splitArgs splits an inline command into arguments, following the rules of Redis (sds.c, sdssplitargs):
+//   - arguments are separated by whitespace
+//   - "double quoted" arguments may contain spaces and the escapes \n, \r, \t, \b, \a, \xHH,
+//     and a backslash before any other character
+//   - 'single quoted' arguments may contain spaces, and \' for a quote
+//   - a closing quote must be followed by whitespace or the end of the line
+func splitArgs(line []byte) ([][]byte, error) {
+	var args [][]byte
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var current []byte
+		inDouble, inSingle := false, false
+		for done := false; !done; {
+			switch {
+			case inDouble:
+				if i == len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+				c := line[i]
+				switch {
+				case c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+					current = append(current, hexValue(line[i+2])<<4|hexValue(line[i+3]))
+					i += 3
+				case c == '\\' && i+1 < len(line):
+					i++
+					switch line[i] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, line[i])
+					}
+				case c == '"':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					current = append(current, c)
+				}
+			case inSingle:
+				if i == len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+				c := line[i]
+				switch {
+				case c == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					current = append(current, '\'')
+					i++
+				case c == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					current = append(current, c)
+				}
+			default:
+				if i == len(line) {
+					done = true
+					break
+				}
+				switch c := line[i]; {
+				case isSpace(c) || c == 0:
+					done = true
+				case c == '"':
+					inDouble = true
+				case c == '\'':
+					inSingle = true
+				default:
+					current = append(current, c)
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, current)
+	}
+}
+
+// isSpace reports whether c separates inline arguments
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// hexValue returns the value of a hexadecimal digit
+func hexValue(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	default:
+		return c - '0'
+	}
+}
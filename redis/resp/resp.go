@@ -2,8 +2,11 @@ package resp
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -15,25 +18,69 @@ const (
 	INTEGER = ':'
 	BULK    = '$'
 	ARRAY   = '*'
+
+	// RESP3 only
+	MAP       = '%'
+	SET       = '~'
+	DOUBLE    = ','
+	BOOLEAN   = '#'
+	BIGNUMBER = '('
+	VERBATIM  = '='
+	NULL      = '_'
+	ATTRIBUTE = '|'
+	PUSH      = '>'
+	BLOBERROR = '!'
 )
 
-// Value represents a RESP (Redis Serialization Protocol) value
-type Value struct {
-	Type  string
-	Str   string
-	Num   int
-	Bulk  string
-	Array []Value
+// Default limits of a RESP reader, like proto-max-bulk-len in Redis and the longest
+// multibulk it accepts
+const (
+	DefaultMaxBulkLen      = 512 * 1024 * 1024
+	DefaultMaxMultiBulkLen = 1024 * 1024
+)
+
+// preallocLen is the most elements or bytes allocated ahead from a length sent by the peer
+// Longer aggregates and bulk strings grow as they arrive, so a header alone cannot make
+// the reader allocate a huge slice
+const preallocLen = 16 * 1024
+
+// ErrProtocol is wrapped by every error caused by invalid RESP, as opposed to I/O errors
+// The server replies to these errors before closing the connection
+var ErrProtocol = errors.New("Protocol error")
+
+// protocolError returns an error wrapping ErrProtocol, such as "Protocol error: invalid bulk length"
+func protocolError(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrProtocol}, args...)...)
 }
 
 // Resp represents a RESP reader
 type Resp struct {
 	reader *bufio.Reader
+
+	MaxBulkLen      int // Longest bulk string accepted, in bytes
+	MaxMultiBulkLen int // Most elements accepted in an array, or pairs in a map
 }
 
-// NewResp creates a new RESP reader
+// NewResp creates a new RESP reader with the default limits
 func NewResp(rd io.Reader) *Resp {
-	return &Resp{reader: bufio.NewReader(rd)}
+	return &Resp{
+		reader:          bufio.NewReader(rd),
+		MaxBulkLen:      DefaultMaxBulkLen,
+		MaxMultiBulkLen: DefaultMaxMultiBulkLen,
+	}
+}
+
+// Buffered returns the number of bytes received but not read yet
+// When it is 0, the client sent no more pipelined commands
+func (r *Resp) Buffered() int {
+	return r.reader.Buffered()
+}
+
+// Peek waits until the next value starts to arrive, without consuming it
+// Returns an error if the connection is closed before that
+func (r *Resp) Peek() error {
+	_, err := r.reader.Peek(1)
+	return err
 }
 
 // Read reads and parses a RESP value
@@ -54,42 +101,193 @@ func (r *Resp) Read() (Value, error) {
 		return r.readInteger()
 	case ERROR:
 		return r.readError()
+	case MAP:
+		return r.readAggregate(KindMap, 2)
+	case SET:
+		return r.readAggregate(KindSet, 1)
+	case PUSH:
+		return r.readAggregate(KindPush, 1)
+	case DOUBLE:
+		return r.readDouble()
+	case BOOLEAN:
+		return r.readBoolean()
+	case BIGNUMBER:
+		return r.readBigNumber()
+	case VERBATIM:
+		return r.readVerbatim()
+	case NULL:
+		_, err := r.readLine()
+		return Null(), err
+	case ATTRIBUTE:
+		return r.readAttribute()
+	case BLOBERROR:
+		return r.readBlobError()
 	default:
-		return Value{}, fmt.Errorf("unknown type: %v", string(typeChar))
+		// Anything else is an inline command, typed by hand in telnet or nc
+		if err := r.reader.UnreadByte(); err != nil {
+			return Value{}, err
+		}
+		return r.readInline()
+	}
+}
+
+// ReadCommand reads a command sent by a client: an array of bulk strings, or an inline command
+// Unlike Read, it accepts nothing else, so clients cannot send nested aggregates
+// Blank inline lines are skipped, like Redis does
+func (r *Resp) ReadCommand() (Value, error) {
+	for {
+		typeChar, err := r.reader.ReadByte()
+		if err != nil {
+			return Value{}, err
+		}
+		if typeChar == ARRAY {
+			return r.readCommandArray()
+		}
+
+		if err := r.reader.UnreadByte(); err != nil {
+			return Value{}, err
+		}
+		value, err := r.readInline()
+		if err != nil || len(value.Array) > 0 {
+			return value, err
+		}
+	}
+}
+
+// readCommandArray reads the array of bulk strings of a command
+// A null or empty array is returned as an empty command
+func (r *Resp) readCommandArray() (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+
+	count, err := parseLength(line)
+	if err != nil || count > r.MaxMultiBulkLen {
+		return Value{}, protocolError("invalid multibulk length")
+	}
+
+	array := make([]Value, 0, min(max(count, 0), preallocLen))
+	for i := 0; i < count; i++ {
+		typeChar, err := r.reader.ReadByte()
+		if err != nil {
+			return Value{}, err
+		}
+		if typeChar != BULK {
+			return Value{}, protocolError("expected '$', got '%c'", typeChar)
+		}
+		value, err := r.readBulk()
+		if err != nil {
+			return Value{}, err
+		}
+		if value.Kind == KindNull {
+			return Value{}, protocolError("invalid bulk length")
+		}
+		array = append(array, value)
 	}
+
+	return Array(array...), nil
 }
 
-// readLine reads a line from the RESP stream
+// readLine reads a line from the RESP stream, without the trailing CRLF
+// The line points into the buffer of the reader, so it is only valid until the next read
 func (r *Resp) readLine() ([]byte, error) {
-	line, err := r.reader.ReadString('\n')
+	line, err := r.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// Lines longer than the buffer are rare, and only then copied
+		long := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			// Headers are short, so a longer line can only come from a broken or hostile peer
+			if len(long) > MaxInlineLen {
+				return nil, protocolError("too big line")
+			}
+			line, err = r.reader.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		line = long
+	}
 	if err != nil {
 		return nil, err
 	}
-	return []byte(strings.TrimRight(line, "\r\n")), nil
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// parseInt parses the decimal integer of a RESP integer or header, without converting it to a string
+func parseInt(b []byte) (int64, error) {
+	if len(b) == 0 {
+		return 0, protocolError("invalid integer: empty")
+	}
+
+	negative := b[0] == '-'
+	digits := b
+	if negative || b[0] == '+' {
+		digits = b[1:]
+	}
+	if len(digits) == 0 || len(digits) > 18 {
+		// Too long to parse without overflowing, which strconv reports properly
+		n, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return 0, protocolError("invalid integer: %q", b)
+		}
+		return n, nil
+	}
+
+	var n int64
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, protocolError("invalid integer: %q", b)
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if negative {
+		n = -n
+	}
+	return n, nil
+}
+
+// parseLength parses the length in the header of a bulk string or an aggregate
+func parseLength(b []byte) (int, error) {
+	n, err := parseInt(b)
+	if err != nil {
+		return 0, err
+	}
+	if n != int64(int(n)) {
+		return 0, protocolError("invalid length: %q", b)
+	}
+	return int(n), nil
 }
 
 // readArray reads a RESP array
+// The null array of RESP2, *-1, is returned as a null
 func (r *Resp) readArray() (Value, error) {
 	line, err := r.readLine()
 	if err != nil {
 		return Value{}, err
 	}
 
-	count, err := strconv.Atoi(string(line))
-	if err != nil {
-		return Value{}, err
+	count, err := parseLength(line)
+	if err != nil || count < -1 || count > r.MaxMultiBulkLen {
+		return Value{}, protocolError("invalid multibulk length")
+	}
+	if count == -1 {
+		return Null(), nil
 	}
 
-	array := make([]Value, count)
+	return r.readElements(KindArray, count)
+}
+
+// readElements reads the count values of an aggregate
+func (r *Resp) readElements(kind Kind, count int) (Value, error) {
+	array := make([]Value, 0, min(count, preallocLen))
 	for i := 0; i < count; i++ {
 		value, err := r.Read()
 		if err != nil {
 			return Value{}, err
 		}
-		array[i] = value
+		array = append(array, value)
 	}
 
-	return Value{Type: "array", Array: array}, nil
+	return Value{Kind: kind, Array: array}, nil
 }
 
 // readBulk reads a RESP bulk string
@@ -99,28 +297,50 @@ func (r *Resp) readBulk() (Value, error) {
 		return Value{}, err
 	}
 
-	size, err := strconv.Atoi(string(line))
-	if err != nil {
-		return Value{}, err
+	size, err := parseLength(line)
+	if err != nil || size < -1 || size > r.MaxBulkLen {
+		return Value{}, protocolError("invalid bulk length")
 	}
 
 	if size == -1 {
-		return Value{Type: "null"}, nil
+		return Null(), nil
 	}
 
-	bulk := make([]byte, size)
-	_, err = io.ReadFull(r.reader, bulk)
+	bulk, err := r.readPayload(size)
 	if err != nil {
 		return Value{}, err
 	}
 
 	// Read the trailing CRLF
-	_, err = r.readLine()
-	if err != nil {
+	var crlf [2]byte
+	if _, err := io.ReadFull(r.reader, crlf[:]); err != nil {
 		return Value{}, err
 	}
+	if crlf != [2]byte{'\r', '\n'} {
+		return Value{}, protocolError("bulk string not terminated by CRLF")
+	}
+
+	return BulkBytes(bulk), nil
+}
+
+// readPayload reads the size bytes of a bulk string
+// Large payloads grow as the bytes arrive, rather than being allocated at once
+func (r *Resp) readPayload(size int) ([]byte, error) {
+	if size <= preallocLen {
+		bulk := make([]byte, size)
+		_, err := io.ReadFull(r.reader, bulk)
+		return bulk, err
+	}
 
-	return Value{Type: "bulk", Bulk: string(bulk)}, nil
+	var buf bytes.Buffer
+	buf.Grow(preallocLen)
+	if _, err := io.CopyN(&buf, r.reader, int64(size)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // readString reads a RESP simple string
@@ -129,7 +349,7 @@ func (r *Resp) readString() (Value, error) {
 	if err != nil {
 		return Value{}, err
 	}
-	return Value{Type: "string", Str: string(line)}, nil
+	return SimpleString(string(line)), nil
 }
 
 // readInteger reads a RESP integer
@@ -139,12 +359,12 @@ func (r *Resp) readInteger() (Value, error) {
 		return Value{}, err
 	}
 
-	num, err := strconv.Atoi(string(line))
+	num, err := parseInt(line)
 	if err != nil {
 		return Value{}, err
 	}
 
-	return Value{Type: "integer", Num: num}, nil
+	return Integer(num), nil
 }
 
 // readError reads a RESP error
@@ -153,80 +373,269 @@ func (r *Resp) readError() (Value, error) {
 	if err != nil {
 		return Value{}, err
 	}
-	return Value{Type: "error", Str: string(line)}, nil
+	return Err(string(line)), nil
 }
 
-// Marshal converts a Value to its RESP byte representation
+// readAggregate reads a RESP3 map, set or push
+// A map header counts pairs, so it is followed by twice as many values
+func (r *Resp) readAggregate(kind Kind, valuesPerItem int) (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+
+	count, err := parseLength(line)
+	if err != nil || count < 0 || count > r.MaxMultiBulkLen {
+		return Value{}, protocolError("invalid multibulk length")
+	}
+
+	return r.readElements(kind, count*valuesPerItem)
+}
+
+// readDouble reads a RESP3 double
+func (r *Resp) readDouble() (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+
+	// strconv accepts "inf", "-inf" and "nan" as sent by Redis
+	f, err := strconv.ParseFloat(string(line), 64)
+	if err != nil {
+		return Value{}, protocolError("invalid double: %q", line)
+	}
+
+	return Double(f), nil
+}
+
+// readBoolean reads a RESP3 boolean
+func (r *Resp) readBoolean() (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+
+	switch string(line) {
+	case "t":
+		return Boolean(true), nil
+	case "f":
+		return Boolean(false), nil
+	default:
+		return Value{}, protocolError("invalid boolean: %q", line)
+	}
+}
+
+// readBigNumber reads a RESP3 big number, kept as its decimal representation
+func (r *Resp) readBigNumber() (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+	return BigNumber(string(line)), nil
+}
+
+// readVerbatim reads a RESP3 verbatim string, a bulk string prefixed with a three letter format
+func (r *Resp) readVerbatim() (Value, error) {
+	bulk, err := r.readBulk()
+	if err != nil {
+		return Value{}, err
+	}
+	if len(bulk.Bulk) < 4 || bulk.Bulk[3] != ':' {
+		return Value{}, protocolError("invalid verbatim string: %q", bulk.Bulk)
+	}
+	return Value{Kind: KindVerbatim, Str: string(bulk.Bulk[:3]), Bulk: bulk.Bulk[4:]}, nil
+}
+
+// readAttribute reads a RESP3 attribute and the value it describes
+// The attribute is returned in the Attributes of that value
+func (r *Resp) readAttribute() (Value, error) {
+	attributes, err := r.readAggregate(KindMap, 2)
+	if err != nil {
+		return Value{}, err
+	}
+
+	value, err := r.Read()
+	if err != nil {
+		return Value{}, err
+	}
+
+	value.Attributes = attributes.Array
+	return value, nil
+}
+
+// readBlobError reads a RESP3 blob error, returned like a simple error
+func (r *Resp) readBlobError() (Value, error) {
+	bulk, err := r.readBulk()
+	if err != nil {
+		return Value{}, err
+	}
+	return Err(string(bulk.Bulk)), nil
+}
+
+// Marshal converts a Value to its RESP2 byte representation
+// RESP3 types are sent as their closest RESP2 equivalent, like Redis does for RESP2 clients:
+// maps, sets and pushes become arrays, booleans integers and the other types bulk strings
 func (v Value) Marshal() []byte {
-	switch v.Type {
-	case "array":
-		return v.marshalArray()
-	case "bulk":
-		return v.marshalBulk()
-	case "string":
-		return v.marshalString()
-	case "integer":
-		return v.marshalInteger()
-	case "error":
-		return v.marshalError()
-	case "null":
-		return v.marshalNull()
+	return v.AppendRESP2(nil)
+}
+
+// MarshalRESP3 converts a Value to its RESP3 byte representation
+func (v Value) MarshalRESP3() []byte {
+	return v.AppendRESP3(nil)
+}
+
+// AppendRESP2 appends the RESP2 byte representation of a Value to b, like Marshal
+// Reusing b avoids allocating a new slice for every value
+func (v Value) AppendRESP2(b []byte) []byte {
+	return v.appendTo(b, 2)
+}
+
+// AppendRESP3 appends the RESP3 byte representation of a Value to b, like MarshalRESP3
+func (v Value) AppendRESP3(b []byte) []byte {
+	return v.appendTo(b, 3)
+}
+
+// appendTo appends the byte representation of a Value in the given protocol version to b
+func (v Value) appendTo(b []byte, protocol int) []byte {
+	if protocol == 3 && len(v.Attributes) > 0 {
+		b = appendAggregate(b, ATTRIBUTE, v.Attributes, len(v.Attributes)/2, protocol)
+	}
+
+	switch v.Kind {
+	case KindArray:
+		return appendAggregate(b, ARRAY, v.Array, len(v.Array), protocol)
+	case KindBulk:
+		return appendBulk(b, v.Bulk)
+	case KindString:
+		return appendLine(b, STRING, v.Str)
+	case KindInteger:
+		return appendInteger(b, INTEGER, v.Num)
+	case KindError:
+		return appendLine(b, ERROR, v.Str)
+	case KindNull:
+		// RESP2 has no null type, so a null bulk string is sent instead
+		if protocol == 2 {
+			return append(b, "$-1\r\n"...)
+		}
+		return append(b, "_\r\n"...)
+	case KindMap:
+		if protocol == 2 {
+			return appendAggregate(b, ARRAY, v.Array, len(v.Array), protocol)
+		}
+		return appendAggregate(b, MAP, v.Array, len(v.Array)/2, protocol)
+	case KindSet:
+		if protocol == 2 {
+			return appendAggregate(b, ARRAY, v.Array, len(v.Array), protocol)
+		}
+		return appendAggregate(b, SET, v.Array, len(v.Array), protocol)
+	case KindPush:
+		if protocol == 2 {
+			return appendAggregate(b, ARRAY, v.Array, len(v.Array), protocol)
+		}
+		return appendAggregate(b, PUSH, v.Array, len(v.Array), protocol)
+	case KindDouble:
+		if protocol == 2 {
+			return appendBulkString(b, FormatFloat(v.Double))
+		}
+		return appendLine(b, DOUBLE, FormatFloat(v.Double))
+	case KindBoolean:
+		if protocol == 2 {
+			var num int64
+			if v.Bool {
+				num = 1
+			}
+			return appendInteger(b, INTEGER, num)
+		}
+		if v.Bool {
+			return append(b, "#t\r\n"...)
+		}
+		return append(b, "#f\r\n"...)
+	case KindBigNumber:
+		if protocol == 2 {
+			return appendBulkString(b, v.Str)
+		}
+		return appendLine(b, BIGNUMBER, v.Str)
+	case KindVerbatim:
+		if protocol == 2 {
+			return appendBulk(b, v.Bulk)
+		}
+		return v.appendVerbatim(b)
 	default:
-		return []byte{}
+		return b
 	}
 }
 
-// marshalArray converts an array Value to its RESP byte representation
-func (v Value) marshalArray() []byte {
-	var bytes []byte
-	bytes = append(bytes, ARRAY)
-	bytes = append(bytes, strconv.Itoa(len(v.Array))...)
-	bytes = append(bytes, '\r', '\n')
-	for _, item := range v.Array {
-		bytes = append(bytes, item.Marshal()...)
+// appendAggregate appends the elements of an array, map, set, push or attribute,
+// preceded by the header with the given count
+func appendAggregate(b []byte, prefix byte, items []Value, count int, protocol int) []byte {
+	b = appendInteger(b, prefix, int64(count))
+	for _, item := range items {
+		b = item.appendTo(b, protocol)
 	}
-	return bytes
+	return b
 }
 
-// marshalBulk converts a bulk string Value to its RESP byte representation
-func (v Value) marshalBulk() []byte {
-	var bytes []byte
-	bytes = append(bytes, BULK)
-	bytes = append(bytes, strconv.Itoa(len(v.Bulk))...)
-	bytes = append(bytes, '\r', '\n')
-	bytes = append(bytes, v.Bulk...)
-	bytes = append(bytes, '\r', '\n')
-	return bytes
+// appendBulk appends a bulk string
+func appendBulk(b []byte, bulk []byte) []byte {
+	b = appendInteger(b, BULK, int64(len(bulk)))
+	b = append(b, bulk...)
+	return append(b, '\r', '\n')
 }
 
-// marshalString converts a simple string Value to its RESP byte representation
-func (v Value) marshalString() []byte {
-	var bytes []byte
-	bytes = append(bytes, STRING)
-	bytes = append(bytes, v.Str...)
-	bytes = append(bytes, '\r', '\n')
-	return bytes
+// appendBulkString appends a bulk string holding s
+func appendBulkString(b []byte, s string) []byte {
+	b = appendInteger(b, BULK, int64(len(s)))
+	b = append(b, s...)
+	return append(b, '\r', '\n')
 }
 
-// marshalInteger converts an integer Value to its RESP byte representation
-func (v Value) marshalInteger() []byte {
-	var bytes []byte
-	bytes = append(bytes, INTEGER)
-	bytes = append(bytes, strconv.Itoa(v.Num)...)
-	bytes = append(bytes, '\r', '\n')
-	return bytes
+// appendLine appends a type identifier followed by a line, as in simple strings and errors
+func appendLine(b []byte, prefix byte, line string) []byte {
+	b = append(b, prefix)
+	b = append(b, line...)
+	return append(b, '\r', '\n')
 }
 
-// marshalError converts an error Value to its RESP byte representation
-func (v Value) marshalError() []byte {
-	var bytes []byte
-	bytes = append(bytes, ERROR)
-	bytes = append(bytes, v.Str...)
-	bytes = append(bytes, '\r', '\n')
-	return bytes
+// appendInteger appends a type identifier followed by an integer, as in integers and the
+// headers of bulk strings and aggregates
+func appendInteger(b []byte, prefix byte, n int64) []byte {
+	b = append(b, prefix)
+	b = strconv.AppendInt(b, n, 10)
+	return append(b, '\r', '\n')
 }
 
-// marshalNull converts a null Value to its RESP byte representation
-func (v Value) marshalNull() []byte {
-	return []byte("$-1\r\n")
+// appendVerbatim appends a RESP3 verbatim string
+func (v Value) appendVerbatim(b []byte) []byte {
+	// The format is always three characters long, plain text by default
+	format := v.Str
+	if len(format) != 3 {
+		format = "txt"
+	}
+	b = appendInteger(b, VERBATIM, int64(len(v.Bulk)+4))
+	b = append(b, format...)
+	b = append(b, ':')
+	b = append(b, v.Bulk...)
+	return append(b, '\r', '\n')
+}
+
+// FormatFloat formats a float the way Redis does in its replies
+// Integral values are printed without a fractional part, infinities as "inf" and "-inf",
+// and any other value with the shortest representation that parses back to the same float
+// Like %.17g, the exponent form is used once the exponent is below -4 or at least 17
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	case f == math.Trunc(f) && math.Abs(f) < 1e17:
+		return strconv.FormatInt(int64(f), 10)
+	}
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	if exp, _ := strconv.Atoi(e[strings.IndexByte(e, 'e')+1:]); exp < -4 || exp >= 17 {
+		return e
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
 }
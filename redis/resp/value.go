@@ -0,0 +1,171 @@
+package resp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Kind identifies the RESP type of a Value
+type Kind uint8
+
+// RESP2 kinds, followed by the kinds only RESP3 has
+const (
+	KindInvalid Kind = iota
+	KindString
+	KindError
+	KindInteger
+	KindBulk
+	KindArray
+	KindNull
+
+	KindMap
+	KindSet
+	KindDouble
+	KindBoolean
+	KindBigNumber
+	KindVerbatim
+	KindPush
+)
+
+// kindNames are the names of the kinds, used in String
+var kindNames = map[Kind]string{
+	KindInvalid:   "invalid",
+	KindString:    "string",
+	KindError:     "error",
+	KindInteger:   "integer",
+	KindBulk:      "bulk",
+	KindArray:     "array",
+	KindNull:      "null",
+	KindMap:       "map",
+	KindSet:       "set",
+	KindDouble:    "double",
+	KindBoolean:   "boolean",
+	KindBigNumber: "bignumber",
+	KindVerbatim:  "verbatim",
+	KindPush:      "push",
+}
+
+// String returns the name of the kind, such as "bulk"
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Value represents a RESP (Redis Serialization Protocol) value
+// Values are built with the constructors below, such as OK, Err and Bulk, which fill in
+// the field that matches the kind
+type Value struct {
+	Kind   Kind
+	Str    string  // Simple string, error, big number, or format of a verbatim string
+	Num    int64   // Integer
+	Bulk   []byte  // Bulk string, or text of a verbatim string
+	Array  []Value // Elements of an array, set or push, or keys and values alternating in a map
+	Double float64
+	Bool   bool
+
+	// Attributes holds the keys and values of a RESP3 attribute sent before the value,
+	// alternating like in a map
+	Attributes []Value
+}
+
+// OK returns the +OK simple string
+func OK() Value {
+	return Value{Kind: KindString, Str: "OK"}
+}
+
+// SimpleString returns a simple string, which cannot contain CR or LF
+func SimpleString(s string) Value {
+	return Value{Kind: KindString, Str: s}
+}
+
+// Err returns an error, whose message starts with its code such as "ERR" or "WRONGTYPE"
+func Err(message string) Value {
+	return Value{Kind: KindError, Str: message}
+}
+
+// Integer returns a 64-bit integer
+func Integer(n int64) Value {
+	return Value{Kind: KindInteger, Num: n}
+}
+
+// Bulk returns a bulk string holding s
+func Bulk(s string) Value {
+	return Value{Kind: KindBulk, Bulk: []byte(s)}
+}
+
+// BulkBytes returns a bulk string holding b, without copying it
+func BulkBytes(b []byte) Value {
+	return Value{Kind: KindBulk, Bulk: b}
+}
+
+// Null returns the null value, sent as a null bulk string to RESP2 clients
+func Null() Value {
+	return Value{Kind: KindNull}
+}
+
+// Array returns an array of the given values
+func Array(items ...Value) Value {
+	return Value{Kind: KindArray, Array: items}
+}
+
+// Map returns a map of the given keys and values, alternating
+// RESP2 clients receive them as an array
+func Map(pairs ...Value) Value {
+	return Value{Kind: KindMap, Array: pairs}
+}
+
+// Set returns a set of the given values, received as an array by RESP2 clients
+func Set(items ...Value) Value {
+	return Value{Kind: KindSet, Array: items}
+}
+
+// Push returns out-of-band data such as a pub/sub message, received as an array by RESP2 clients
+func Push(items ...Value) Value {
+	return Value{Kind: KindPush, Array: items}
+}
+
+// Double returns a floating point number, received as a bulk string by RESP2 clients
+func Double(f float64) Value {
+	return Value{Kind: KindDouble, Double: f}
+}
+
+// Boolean returns a boolean, received as the integer 1 or 0 by RESP2 clients
+func Boolean(b bool) Value {
+	return Value{Kind: KindBoolean, Bool: b}
+}
+
+// BigNumber returns an integer of any size given in decimal, received as a bulk string by RESP2 clients
+func BigNumber(digits string) Value {
+	return Value{Kind: KindBigNumber, Str: digits}
+}
+
+// Verbatim returns a string with a three letter format such as "txt" or "mkd",
+// received as a bulk string by RESP2 clients
+func Verbatim(format, text string) Value {
+	return Value{Kind: KindVerbatim, Str: format, Bulk: []byte(text)}
+}
+
+// String formats a Value for debugging and test failures, such as bulk("hello")
+func (v Value) String() string {
+	switch v.Kind {
+	case KindString, KindError, KindBigNumber:
+		return v.Kind.String() + "(" + strconv.Quote(v.Str) + ")"
+	case KindInteger:
+		return "integer(" + strconv.FormatInt(v.Num, 10) + ")"
+	case KindBulk:
+		return "bulk(" + strconv.Quote(string(v.Bulk)) + ")"
+	case KindVerbatim:
+		return "verbatim(" + v.Str + ":" + strconv.Quote(string(v.Bulk)) + ")"
+	case KindDouble:
+		return "double(" + FormatFloat(v.Double) + ")"
+	case KindBoolean:
+		return "boolean(" + strconv.FormatBool(v.Bool) + ")"
+	case KindArray, KindMap, KindSet, KindPush:
+		items := make([]string, len(v.Array))
+		for i, item := range v.Array {
+			items[i] = item.String()
+		}
+		return v.Kind.String() + "[" + strings.Join(items, " ") + "]"
+	default:
+		return v.Kind.String()
+	}
+}
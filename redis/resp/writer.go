@@ -0,0 +1,44 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+)
+
+// Writer buffers the RESP values written to a connection, so that the replies
+// to pipelined commands are sent together with a single system call
+type Writer struct {
+	writer *bufio.Writer
+}
+
+// NewWriter creates a new RESP writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{writer: bufio.NewWriter(w)}
+}
+
+// Write buffers the RESP2 representation of a Value
+// It is only sent once the buffer is full or Flush is called
+func (w *Writer) Write(v Value) error {
+	return w.write(v, 2)
+}
+
+// WriteRESP3 buffers the RESP3 representation of a Value
+func (w *Writer) WriteRESP3(v Value) error {
+	return w.write(v, 3)
+}
+
+// write marshals a Value straight into the free space of the buffer
+func (w *Writer) write(v Value, protocol int) error {
+	_, err := w.writer.Write(v.appendTo(w.writer.AvailableBuffer(), protocol))
+	return err
+}
+
+// Flush sends the buffered values
+func (w *Writer) Flush() error {
+	return w.writer.Flush()
+}
+
+// Buffered returns the number of bytes waiting to be sent
+func (w *Writer) Buffered() int {
+	return w.writer.Buffered()
+}
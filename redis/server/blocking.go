@@ -0,0 +1,148 @@
+// https://redis.io/docs/latest/develop/data-types/lists/#blocking-commands
+package server
+
+import (
+	"redis/command"
+	"redis/resp"
+	"time"
+)
+
+// blockedClient is a client waiting in BLPOP, BRPOP, BLMOVE or BLMPOP for one of its keys to be
+// pushed to, or in XREAD or XREADGROUP with BLOCK for an entry to be added to one of its streams
+type blockedClient struct {
+	db      int // Database the command runs in
+	cmd     string
+	args    []string
+	keys    []string
+	timeout time.Duration   // 0 waits forever
+	result  chan resp.Value // Receives the reply once the client is served
+	blocked bool            // Set while the client is in the queues of its keys
+}
+
+// blockedKey is a key of a database that clients are blocked on
+type blockedKey struct {
+	db  int
+	key string
+}
+
+// callBlocking executes a blocking command, and blocks the client on its keys if they are all empty
+// The keys are marked as blocked before the command runs, so that a push right after it cannot be missed
+// Returns the reply, the command as it must be written to the AOF, and the blocked client if any
+// The caller must hold writeMu in DBs.Shared
+func (s *Server) callBlocking(db int, cmd string, args, keys []string, timeout time.Duration) (resp.Value, loggedCommand, *blockedClient) {
+	s.blockMu.Lock()
+	defer s.blockMu.Unlock()
+
+	s.DBs.DB(db).Block(keys...)
+	result, logged := s.call(db, cmd, args)
+	if result.Kind != resp.KindNull {
+		s.DBs.DB(db).Unblock(keys...)
+		return result, logged, nil
+	}
+
+	w := &blockedClient{
+		db:      db,
+		cmd:     cmd,
+		args:    command.BlockedArgs(s.DBs.DB(db), cmd, args),
+		keys:    keys,
+		timeout: timeout,
+		result:  make(chan resp.Value, 1),
+		blocked: true,
+	}
+	for _, key := range keys {
+		bk := blockedKey{db: db, key: key}
+		s.blocked[bk] = append(s.blocked[bk], w)
+	}
+	return result, logged, w
+}
+
+// unblock removes a client from the queues of its keys
+// Returns false if it was already served
+// The caller must hold blockMu
+func (s *Server) unblock(w *blockedClient) bool {
+	if !w.blocked {
+		return false
+	}
+	w.blocked = false
+
+	for _, key := range w.keys {
+		bk := blockedKey{db: w.db, key: key}
+		queue := s.blocked[bk][:0]
+		for _, other := range s.blocked[bk] {
+			if other != w {
+				queue = append(queue, other)
+			}
+		}
+		if len(queue) == 0 {
+			delete(s.blocked, bk)
+		} else {
+			s.blocked[bk] = queue
+		}
+	}
+	s.DBs.DB(w.db).Unblock(w.keys...)
+	return true
+}
+
+// serveBlocked serves the clients blocked on keys that were modified, in the order they blocked
+// Each client runs its command again, which may make more keys ready, as BLMOVE pushes to
+// its destination, so this repeats until no key of its database is ready
+// The caller must hold writeMu in DBs.Shared, or run in DBs.Exclusive
+func (s *Server) serveBlocked() {
+	for db := 0; db < s.DBs.Len(); db++ {
+		s.serveBlockedDB(db)
+	}
+}
+
+// serveBlockedDB serves the clients blocked on keys of a database that were modified
+// The caller must hold writeMu in DBs.Shared, or run in DBs.Exclusive
+func (s *Server) serveBlockedDB(db int) {
+	database := s.DBs.DB(db)
+	for keys := database.ReadyKeys(); keys != nil; keys = database.ReadyKeys() {
+		s.blockMu.Lock()
+		for _, key := range keys {
+			bk := blockedKey{db: db, key: key}
+			for len(s.blocked[bk]) > 0 {
+				w := s.blocked[bk][0]
+				result, logged := s.call(db, w.cmd, w.args)
+				// The list is empty again, the next clients keep waiting
+				if result.Kind == resp.KindNull {
+					break
+				}
+				s.propagate(logged)
+				s.unblock(w)
+				w.result <- result
+			}
+		}
+		s.blockMu.Unlock()
+	}
+}
+
+// waitBlocked parks the connection of a blocked client until it is served, its timeout
+// expires or it disconnects
+// Returns the reply of the command, null if it timed out
+func (s *Server) waitBlocked(c *client, reader *resp.Resp, w *blockedClient) resp.Value {
+	var timeout <-chan time.Time
+	if w.timeout > 0 {
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	closed, stop := c.watchClosed(reader)
+	defer stop()
+
+	select {
+	case result := <-w.result:
+		return result
+	case <-timeout:
+	case <-closed:
+	}
+
+	s.blockMu.Lock()
+	removed := s.unblock(w)
+	s.blockMu.Unlock()
+	if !removed {
+		// Served while timing out
+		return <-w.result
+	}
+	return resp.Null()
+}
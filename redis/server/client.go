@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"redis/aof"
+	"redis/pubsub"
+	"redis/resp"
+	"redis/storage"
+	"sync/atomic"
+	"time"
+)
+
+// errSubscriberClosed is returned when writing to a client whose subscriber was closed
+var errSubscriberClosed = errors.New("subscriber closed")
+
+// client holds the state of a connection
+type client struct {
+	conn       net.Conn
+	out        replyWriter
+	writer     *resp.Writer // Buffers replies until the pipelined commands were all executed
+	id         int64
+	name       string               // Set with HELLO SETNAME
+	db         int                  // Index of the database selected with SELECT
+	protocol   atomic.Int32         // RESP version negotiated with HELLO, read by the subscriber queue writer
+	subscriber *pubsub.Subscriber   // Set once the client uses a subscription command
+	multi      bool                 // Set between MULTI and EXEC or DISCARD
+	multiError bool                 // Set when a command failed to queue, so EXEC aborts
+	queue      []queuedCommand      // Commands queued since MULTI
+	watched    []storage.WatchedKey // Keys watched with WATCH
+}
+
+// newClient creates the state of a new connection, which speaks RESP2 until it sends HELLO 3
+func newClient(conn net.Conn, id int64, file *aof.AOF) *client {
+	c := &client{conn: conn, out: replyWriter{conn: conn, aof: file}, id: id}
+	c.writer = resp.NewWriter(c.out)
+	c.protocol.Store(2)
+	return c
+}
+
+// replyWriter sends the replies of a client to its connection
+// With appendfsync always, the writes they acknowledge are fsynced first, including when
+// the buffer fills up before flush and for the replies sent by the subscriber queue.
+// Clients sending at the same time share the fsync, which groups the writes of concurrent clients
+type replyWriter struct {
+	conn net.Conn
+	aof  *aof.AOF
+}
+
+// Write commits the AOF, then sends the bytes to the connection
+func (w replyWriter) Write(p []byte) (int, error) {
+	if w.aof != nil {
+		if err := w.aof.Commit(); err != nil {
+			fmt.Printf("Error syncing AOF: %v\n", err)
+		}
+	}
+	return w.conn.Write(p)
+}
+
+// encode buffers a reply in the protocol negotiated by the client
+func (c *client) encode(w *resp.Writer, v resp.Value) error {
+	if c.protocol.Load() == 3 {
+		return w.WriteRESP3(v)
+	}
+	return w.Write(v)
+}
+
+// write buffers a reply to the client, which is sent by flush
+// Once the client has a subscriber, replies go through its queue so they stay
+// ordered with the messages published to it
+func (c *client) write(v resp.Value) error {
+	if c.subscriber != nil {
+		if !c.subscriber.Send(v) {
+			return errSubscriberClosed
+		}
+		return nil
+	}
+	return c.encode(c.writer, v)
+}
+
+// flush sends the buffered replies
+func (c *client) flush() error {
+	return c.writer.Flush()
+}
+
+// startSubscriber returns the subscriber of the client, creating it and the goroutine
+// that writes its queue to the connection on first use
+func (c *client) startSubscriber() *pubsub.Subscriber {
+	if c.subscriber == nil {
+		// The replies buffered so far are sent before any queued value.
+		// A failure shows up again on the next write
+		c.flush()
+		c.subscriber = pubsub.NewSubscriber()
+		go c.writeQueue(c.subscriber)
+	}
+	return c.subscriber
+}
+
+// writeQueue writes the values queued for the subscriber to the connection until it is closed
+// The connection is closed when the subscriber is, so a client that fell behind is disconnected
+func (c *client) writeQueue(sub *pubsub.Subscriber) {
+	// Closing the connection also interrupts a write blocked on a client that stopped reading
+	go func() {
+		<-sub.Done()
+		c.conn.Close()
+	}()
+
+	writer := resp.NewWriter(c.out)
+	for {
+		select {
+		case v := <-sub.Queue():
+			err := c.encode(writer, v)
+			// Flushed once the queue is drained, so a burst of messages is sent at once
+			if err == nil && len(sub.Queue()) == 0 {
+				err = writer.Flush()
+			}
+			if err != nil {
+				sub.Close()
+				return
+			}
+		case <-sub.Done():
+			return
+		}
+	}
+}
+
+// watchClosed detects the connection being closed while the client is blocked and
+// does not read its commands
+// Returns a channel closed on disconnection, and a function that stops watching, which
+// must be called before reading from the connection again
+func (c *client) watchClosed(reader *resp.Resp) (<-chan struct{}, func()) {
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// A pipelined command also ends the wait, without being consumed
+		if err := reader.Peek(); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			close(closed)
+		}
+	}()
+
+	return closed, func() {
+		// An expired deadline interrupts the peek
+		c.conn.SetReadDeadline(time.Now())
+		<-done
+		c.conn.SetReadDeadline(time.Time{})
+	}
+}
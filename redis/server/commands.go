@@ -0,0 +1,56 @@
+package server
+
+import (
+	"redis/command"
+	"redis/resp"
+)
+
+// serverHandler executes a command that needs the state of the server or of the connection
+// exclusive is s.DBs.Exclusive, or inExclusive in EXEC, which already holds it
+type serverHandler func(s *Server, c *client, args []string, exclusive func(func())) resp.Value
+
+// serverCommands are the commands of the table without a Handler that run like any other
+// command, directly or queued in a transaction. The other ones are the transaction and
+// subscription commands, handled by handleTransaction and handlePubSub
+var serverCommands = map[string]serverHandler{
+	"HELLO": func(s *Server, c *client, args []string, _ func(func())) resp.Value {
+		return s.hello(c, args)
+	},
+	"SELECT": func(s *Server, c *client, args []string, _ func(func())) resp.Value {
+		return s.selectDB(c, args)
+	},
+	"INFO": func(s *Server, _ *client, args []string, _ func(func())) resp.Value {
+		return s.info(args)
+	},
+	"CONFIG": func(s *Server, _ *client, args []string, exclusive func(func())) resp.Value {
+		return s.configCommand(args, exclusive)
+	},
+	"SAVE": func(s *Server, _ *client, _ []string, exclusive func(func())) resp.Value {
+		return s.save(exclusive)
+	},
+	"BGSAVE": func(s *Server, _ *client, args []string, exclusive func(func())) resp.Value {
+		return s.bgSaveCommand(args, exclusive)
+	},
+	"LASTSAVE": func(s *Server, _ *client, _ []string, _ func(func())) resp.Value {
+		return s.lastSave()
+	},
+	"BGREWRITEAOF": func(s *Server, _ *client, _ []string, exclusive func(func())) resp.Value {
+		return s.bgRewriteAOF(exclusive)
+	},
+	"PUBLISH": func(s *Server, _ *client, args []string, _ func(func())) resp.Value {
+		return command.Publish(s.PubSub, args)
+	},
+	"PUBSUB": func(s *Server, _ *client, args []string, _ func(func())) resp.Value {
+		return command.PubSub(s.PubSub, args)
+	},
+}
+
+// serverCommand executes a command of the table without a Handler, for a client running it
+// directly or in EXEC
+func (s *Server) serverCommand(c *client, cmd string, args []string, exclusive func(func())) resp.Value {
+	handler, ok := serverCommands[cmd]
+	if !ok {
+		return command.UnknownCommand(cmd, args)
+	}
+	return handler(s, c, args, exclusive)
+}
@@ -0,0 +1,128 @@
+// https://redis.io/docs/latest/commands/config-get/
+package server
+
+import (
+	"fmt"
+	"os"
+	"redis/config"
+	"redis/resp"
+	"strings"
+)
+
+// configCommand handles CONFIG GET, CONFIG SET, CONFIG REWRITE and CONFIG RESETSTAT
+// exclusive is s.DBs.Exclusive, or inExclusive when CONFIG is queued in a transaction
+func (s *Server) configCommand(args []string, exclusive func(func())) resp.Value {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if len(args) < 2 {
+			return resp.Err("ERR wrong number of arguments for 'config|get' command")
+		}
+		return s.configGet(args[1:])
+	case "SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return resp.Err("ERR wrong number of arguments for 'config|set' command")
+		}
+		return s.configSet(args[1:], exclusive)
+	case "REWRITE":
+		if len(args) != 1 {
+			return resp.Err("ERR wrong number of arguments for 'config|rewrite' command")
+		}
+		// Held while writing, so the file has the parameters of a single CONFIG SET
+		s.configMu.RLock()
+		err := s.Config.Rewrite()
+		s.configMu.RUnlock()
+		if err == config.ErrNoConfigFile {
+			return resp.Err(err.Error())
+		}
+		if err != nil {
+			return resp.Err("ERR Rewriting config file: " + err.Error())
+		}
+		return resp.SimpleString("OK")
+	case "RESETSTAT":
+		if len(args) != 1 {
+			return resp.Err("ERR wrong number of arguments for 'config|resetstat' command")
+		}
+		s.stats.reset()
+		return resp.SimpleString("OK")
+	default:
+		return resp.Err("ERR unknown subcommand '" + args[0] + "'. Try CONFIG HELP.")
+	}
+}
+
+// 1) -> https://redis.io/docs/latest/commands/config-get
+// configGet returns the parameters matching any of the glob-style patterns, with their values
+func (s *Server) configGet(patterns []string) resp.Value {
+	cfg := s.config()
+	seen := make(map[string]bool)
+	var pairs []resp.Value
+	for _, pattern := range patterns {
+		for _, param := range cfg.Get(pattern) {
+			if seen[param[0]] {
+				continue
+			}
+			seen[param[0]] = true
+			pairs = append(pairs, resp.Bulk(param[0]), resp.Bulk(param[1]))
+		}
+	}
+	return resp.Map(pairs...)
+}
+
+// 2) -> https://redis.io/docs/latest/commands/config-set
+// configSet sets parameters from name value pairs
+// The parameters are all validated before any of them is applied, so either all of them
+// change or none does. It runs while no other command does, since changing the working
+// directory affects the commands saving files: exclusive runs it as s.DBs.Exclusive does
+func (s *Server) configSet(args []string, exclusive func(func())) resp.Value {
+	var reply resp.Value
+	exclusive(func() {
+		s.configMu.Lock()
+		defer s.configMu.Unlock()
+
+		failed := func(name, reason string) resp.Value {
+			return resp.Err(fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", name, reason))
+		}
+
+		cfg := s.Config.Clone()
+		set := make(map[string]bool)
+		for i := 0; i < len(args); i += 2 {
+			name := strings.ToLower(args[i])
+			exists, mutable := config.Exists(name)
+			switch {
+			case !exists:
+				reply = resp.Err("ERR Unknown option or number of arguments for CONFIG SET - '" + args[i] + "'")
+				return
+			case !mutable:
+				reply = failed(args[i], "can't set immutable config")
+				return
+			case set[name]:
+				reply = failed(args[i], "duplicate parameter")
+				return
+			}
+			set[name] = true
+			if err := cfg.Set(name, args[i+1]); err != nil {
+				reply = failed(args[i], err.Error())
+				return
+			}
+		}
+
+		// Applying the parameters that change the state of the server, only dir can fail
+		if set["dir"] {
+			if err := os.Chdir(cfg.Dir); err != nil {
+				reply = failed("dir", err.Error())
+				return
+			}
+			if dir, err := os.Getwd(); err == nil {
+				cfg.Dir = dir
+			}
+		}
+		if set["appendfsync"] && s.AOF != nil {
+			s.AOF.SetFsyncPolicy(cfg.AppendFsync)
+		}
+		if set["aof-load-truncated"] && s.AOF != nil {
+			s.AOF.LoadTruncated = cfg.AOFLoadTruncated
+		}
+		s.Config = cfg
+		reply = resp.SimpleString("OK")
+	})
+	return reply
+}
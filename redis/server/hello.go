@@ -0,0 +1,64 @@
+// https://redis.io/docs/latest/commands/hello/
+package server
+
+import (
+	"redis/resp"
+	"strconv"
+	"strings"
+)
+
+// serverVersion is the Redis version reported to clients
+const serverVersion = "7.2.0"
+
+// hello handles HELLO [protover [AUTH username password] [SETNAME clientname]]
+// It switches the connection to the requested protocol version
+// Returns the properties of the server and the connection, as a map in RESP3
+func (s *Server) hello(c *client, args []string) resp.Value {
+	protocol := int(c.protocol.Load())
+	if len(args) > 0 {
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return resp.Err("ERR Protocol version is not an integer or out of range")
+		}
+		if version != 2 && version != 3 {
+			return resp.Err("NOPROTO unsupported protocol version")
+		}
+		protocol = version
+	}
+
+	// Options are all validated before any of them is applied
+	name, setName := "", false
+	for i := 1; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "AUTH" && i+2 < len(args):
+			// There are no passwords, so the default user is the only valid one
+			if args[i+1] != "default" {
+				return resp.Err("WRONGPASS invalid username-password pair or user is disabled.")
+			}
+			i += 2
+		case option == "SETNAME" && i+1 < len(args):
+			if strings.ContainsFunc(args[i+1], func(r rune) bool { return r <= ' ' || r > '~' }) {
+				return resp.Err("ERR Client names cannot contain spaces, newlines or special characters.")
+			}
+			name, setName = args[i+1], true
+			i++
+		default:
+			return resp.Err("ERR Syntax error in HELLO option '" + args[i] + "'")
+		}
+	}
+
+	if setName {
+		c.name = name
+	}
+	c.protocol.Store(int32(protocol))
+
+	return resp.Map(
+		resp.Bulk("server"), resp.Bulk("redis"),
+		resp.Bulk("version"), resp.Bulk(serverVersion),
+		resp.Bulk("proto"), resp.Integer(int64(protocol)),
+		resp.Bulk("id"), resp.Integer(c.id),
+		resp.Bulk("mode"), resp.Bulk("standalone"),
+		resp.Bulk("role"), resp.Bulk("master"),
+		resp.Bulk("modules"), resp.Array(),
+	)
+}
@@ -0,0 +1,140 @@
+// https://redis.io/docs/latest/commands/info/
+package server
+
+import (
+	"redis/aof"
+	"redis/resp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// serverStats counts the events reported by INFO clients and INFO stats
+// The counters of INFO stats are reset by CONFIG RESETSTAT
+type serverStats struct {
+	clients     atomic.Int64 // Clients connected
+	connections atomic.Int64 // Connections accepted, including the rejected ones
+	commands    atomic.Int64 // Commands processed
+	rejected    atomic.Int64 // Connections rejected because of maxclients
+}
+
+// reset resets the counters of INFO stats
+func (st *serverStats) reset() {
+	st.connections.Store(0)
+	st.commands.Store(0)
+	st.rejected.Store(0)
+}
+
+// infoSection is a section of the INFO reply
+type infoSection struct {
+	name   string                    // Name of the section, in lowercase
+	fields func(*Server) [][2]string // Returns the fields of the section and their values
+}
+
+// infoSections are the sections of INFO, in the order they are listed
+var infoSections = []infoSection{
+	{name: "clients", fields: (*Server).clientsInfo},
+	{name: "persistence", fields: (*Server).persistenceInfo},
+	{name: "stats", fields: (*Server).statsInfo},
+}
+
+// info handles INFO [section ...]
+// It returns the requested sections, or all of them when none, "default", "all" or
+// "everything" is given, as lines of "field:value" under a "# Section" header
+func (s *Server) info(args []string) resp.Value {
+	requested := make(map[string]bool, len(args))
+	all := len(args) == 0
+	for _, arg := range args {
+		section := strings.ToLower(arg)
+		requested[section] = true
+		if section == "default" || section == "all" || section == "everything" {
+			all = true
+		}
+	}
+
+	var b strings.Builder
+	for _, section := range infoSections {
+		if !all && !requested[section.name] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.ToUpper(section.name[:1]) + section.name[1:] + "\r\n")
+		for _, field := range section.fields(s) {
+			b.WriteString(field[0] + ":" + field[1] + "\r\n")
+		}
+	}
+	return resp.Verbatim("txt", b.String())
+}
+
+// clientsInfo returns the fields of the clients section
+func (s *Server) clientsInfo() [][2]string {
+	return [][2]string{
+		{"connected_clients", strconv.FormatInt(s.stats.clients.Load(), 10)},
+		{"maxclients", strconv.Itoa(s.config().MaxClients)},
+	}
+}
+
+// statsInfo returns the fields of the stats section
+func (s *Server) statsInfo() [][2]string {
+	return [][2]string{
+		{"total_connections_received", strconv.FormatInt(s.stats.connections.Load(), 10)},
+		{"total_commands_processed", strconv.FormatInt(s.stats.commands.Load(), 10)},
+		{"rejected_connections", strconv.FormatInt(s.stats.rejected.Load(), 10)},
+	}
+}
+
+// persistenceInfo returns the fields of the persistence section, which describe the RDB file and the AOF
+func (s *Server) persistenceInfo() [][2]string {
+	s.snapshot.mu.Lock()
+	changes := s.DBs.Dirty() - s.snapshot.dirty
+	saving := s.snapshot.saving
+	lastSave := s.snapshot.lastSave.Unix()
+	lastSaveErr := s.snapshot.lastErr
+	s.snapshot.mu.Unlock()
+
+	stats := aof.Stats{Policy: s.config().AppendFsync}
+	if s.AOF != nil {
+		stats = s.AOF.Stats()
+	}
+	lastFsync := int64(-1)
+	if !stats.LastFsync.IsZero() {
+		lastFsync = stats.LastFsync.Unix()
+	}
+
+	return [][2]string{
+		{"loading", "0"},
+		{"rdb_changes_since_last_save", strconv.FormatUint(changes, 10)},
+		{"rdb_bgsave_in_progress", flag(saving)},
+		{"rdb_last_save_time", strconv.FormatInt(lastSave, 10)},
+		{"rdb_last_bgsave_status", status(lastSaveErr)},
+		{"aof_enabled", flag(s.AOF != nil)},
+		{"aof_fsync_policy", stats.Policy.String()},
+		{"aof_last_write_status", status(stats.WriteErr)},
+		{"aof_last_fsync_status", status(stats.FsyncErr)},
+		{"aof_last_fsync_time", strconv.FormatInt(lastFsync, 10)},
+		{"aof_pending_fsync_bytes", strconv.FormatInt(stats.PendingBytes, 10)},
+		{"aof_fsyncs", strconv.FormatInt(stats.Fsyncs, 10)},
+		{"aof_current_size", strconv.FormatInt(stats.Size, 10)},
+		{"aof_base_size", strconv.FormatInt(stats.BaseSize, 10)},
+		{"aof_rewrite_in_progress", flag(stats.RewriteInProgress)},
+		{"aof_last_bgrewrite_status", status(stats.RewriteErr)},
+	}
+}
+
+// status returns "ok" if err is nil, and "err" otherwise, as INFO reports the last operations
+func status(err error) string {
+	if err != nil {
+		return "err"
+	}
+	return "ok"
+}
+
+// flag returns "1" if b is set, and "0" otherwise
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
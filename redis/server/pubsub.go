@@ -0,0 +1,62 @@
+package server
+
+import "redis/resp"
+
+// subscribedModeCommands are the only commands a client can run while it has subscriptions
+var subscribedModeCommands = map[string]bool{
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"PING":         true,
+}
+
+// handlePubSub executes the subscription commands, which need the state of the connection
+// and are never written to the AOF, and restricts RESP2 clients to them while subscribed
+// PUBLISH and PUBSUB run like the other commands executed by the server, in serverCommand
+// Returns false if cmd must be executed normally
+func (s *Server) handlePubSub(c *client, cmd string, args []string) (bool, error) {
+	// RESP3 clients can tell pushed messages from replies, so only RESP2 clients
+	// are restricted to the subscription commands while subscribed
+	subscribed := c.protocol.Load() == 2 && c.subscriber != nil && s.PubSub.Subscribed(c.subscriber)
+	if subscribed && !subscribedModeCommands[cmd] {
+		return true, c.write(resp.Err("ERR Can't execute '" + cmd +
+			"': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING are allowed in this context"))
+	}
+	// Inside MULTI the pub/sub commands are queued by handleTransaction
+	if c.multi {
+		return false, nil
+	}
+
+	switch cmd {
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		// The confirmations are queued by the hub, in order with the messages
+		if cmd == "SUBSCRIBE" {
+			s.PubSub.Subscribe(c.startSubscriber(), args...)
+		} else {
+			s.PubSub.PSubscribe(c.startSubscriber(), args...)
+		}
+		return true, nil
+	case "UNSUBSCRIBE":
+		s.PubSub.Unsubscribe(c.startSubscriber(), args...)
+		return true, nil
+	case "PUNSUBSCRIBE":
+		s.PubSub.PUnsubscribe(c.startSubscriber(), args...)
+		return true, nil
+	case "PING":
+		if !subscribed {
+			return false, nil
+		}
+		// In subscribed mode PING replies with a message-like array
+		message := ""
+		if len(args) > 0 {
+			message = args[0]
+		}
+		return true, c.write(resp.Array(
+			resp.Bulk("pong"),
+			resp.Bulk(message),
+		))
+	default:
+		return false, nil
+	}
+}
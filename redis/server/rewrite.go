@@ -0,0 +1,78 @@
+// https://redis.io/docs/latest/commands/bgrewriteaof/
+package server
+
+import (
+	"errors"
+	"fmt"
+	"redis/resp"
+	"redis/storage"
+)
+
+// errAOFDisabled is returned when the AOF is rewritten while appendonly is disabled
+var errAOFDisabled = errors.New("ERR Background append only file rewriting is not possible while appendonly is disabled")
+
+// bgRewriteAOF handles BGREWRITEAOF, which compacts the AOF in the background
+func (s *Server) bgRewriteAOF(exclusive func(func())) resp.Value {
+	if err := s.rewriteAOF(exclusive); err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.SimpleString("Background append only file rewriting started")
+}
+
+// rewriteAOF starts rewriting the AOF in the background
+// A snapshot of the data is taken while no other command runs, and the writes that follow
+// are kept by the AOF until the file rewritten from the snapshot replaces it
+// exclusive is s.DBs.Exclusive, or inExclusive when the caller already runs in it
+// Returns aof.ErrRewriteInProgress if a rewrite already runs
+func (s *Server) rewriteAOF(exclusive func(func())) error {
+	if s.AOF == nil {
+		return errAOFDisabled
+	}
+	var snap *storage.Snapshot
+	var err error
+	exclusive(func() {
+		if err = s.AOF.StartRewrite(); err != nil {
+			return
+		}
+		// The new incremental file is replayed after the base file, which may end in any database
+		s.aofDB = -1
+		snap = s.DBs.Snapshot()
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer snap.Release()
+		if err := s.AOF.Rewrite(rewriteCommands(snap)); err != nil {
+			fmt.Printf("Error rewriting AOF: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// rewriteCommands returns a generator passing the commands rebuilding the data of the
+// snapshot to write, one at a time, for AOF.Rewrite
+func rewriteCommands(snap *storage.Snapshot) func(write func(resp.Value)) {
+	return func(write func(resp.Value)) {
+		snap.Rewrite(func(args []string) {
+			write(commandValue(args[0], args[1:]))
+		})
+	}
+}
+
+// autoRewriteAOF starts a rewrite when the AOF grew past the thresholds since the last one
+// It is called after writing to the AOF, where the storage cannot be locked exclusively,
+// so the rewrite starts once the command that triggered it is done
+func (s *Server) autoRewriteAOF() {
+	cfg := s.config()
+	if !s.AOF.NeedsRewrite(cfg.AutoAOFRewritePercentage, cfg.AutoAOFRewriteMinSize) {
+		return
+	}
+	go func() {
+		// Another write may have triggered it first
+		if err := s.rewriteAOF(s.DBs.Exclusive); err == nil {
+			fmt.Println("Starting automatic rewriting of AOF")
+		}
+	}()
+}
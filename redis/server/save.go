@@ -0,0 +1,223 @@
+// https://redis.io/docs/latest/operate/oss_and_stack/management/persistence/#snapshotting
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"redis/config"
+	"redis/rdb"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bgsaveRetryDelay is how long the save points wait after a background save failed
+const bgsaveRetryDelay = 5 * time.Second
+
+// errBgsaveInProgress is returned when a save starts while a background save runs
+var errBgsaveInProgress = errors.New("ERR Background save already in progress")
+
+// snapshotState tracks the saves of the data to the RDB file
+type snapshotState struct {
+	mu       sync.Mutex
+	saving   bool      // Set while a background save runs
+	lastSave time.Time // Time of the last successful save, or of the startup
+	lastTry  time.Time // Time the last background save started
+	dirty    uint64    // DBs.Dirty when the data of the last successful save was taken
+	lastErr  error     // Error of the last background save, if it failed
+}
+
+// save handles SAVE, which writes the data to the RDB file while no other command runs
+// exclusive is s.DBs.Exclusive, or inExclusive when SAVE is queued in a transaction
+func (s *Server) save(exclusive func(func())) resp.Value {
+	var err error
+	exclusive(func() {
+		s.snapshot.mu.Lock()
+		defer s.snapshot.mu.Unlock()
+		if s.snapshot.saving {
+			err = errBgsaveInProgress
+			return
+		}
+
+		dirty := s.DBs.Dirty()
+		snap := s.DBs.Snapshot()
+		defer snap.Release()
+		if err = writeRDB(snap, s.config().DBFilename); err != nil {
+			err = fmt.Errorf("ERR %v", err)
+			return
+		}
+		s.snapshot.lastSave = time.Now()
+		s.snapshot.dirty = dirty
+	})
+	if err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.SimpleString("OK")
+}
+
+// bgSaveCommand handles BGSAVE [SCHEDULE]
+// SCHEDULE is accepted for compatibility: a background save never waits for an AOF
+// rewrite, so it always starts at once
+func (s *Server) bgSaveCommand(args []string, exclusive func(func())) resp.Value {
+	if len(args) > 1 || (len(args) == 1 && !strings.EqualFold(args[0], "SCHEDULE")) {
+		return resp.Err("ERR syntax error")
+	}
+	if err := s.bgSave(exclusive); err != nil {
+		return resp.Err(err.Error())
+	}
+	return resp.SimpleString("Background saving started")
+}
+
+// bgSave starts saving the data to the RDB file in the background
+// A snapshot of the data is taken while no other command runs, and written while commands keep running
+// exclusive is s.DBs.Exclusive, or inExclusive when the caller already runs in it
+// Returns errBgsaveInProgress if a background save already runs
+func (s *Server) bgSave(exclusive func(func())) error {
+	var snap *storage.Snapshot
+	var dirty uint64
+	var err error
+	exclusive(func() {
+		s.snapshot.mu.Lock()
+		defer s.snapshot.mu.Unlock()
+		if s.snapshot.saving {
+			err = errBgsaveInProgress
+			return
+		}
+		s.snapshot.saving = true
+		s.snapshot.lastTry = time.Now()
+		dirty = s.DBs.Dirty()
+		snap = s.DBs.Snapshot()
+	})
+	if err != nil {
+		return err
+	}
+
+	filename := s.config().DBFilename
+	go func() {
+		err := writeRDB(snap, filename)
+		snap.Release()
+		if err != nil {
+			fmt.Printf("Error saving RDB: %v\n", err)
+		} else {
+			fmt.Println("Background saving terminated with success")
+		}
+
+		s.snapshot.mu.Lock()
+		defer s.snapshot.mu.Unlock()
+		s.snapshot.saving = false
+		s.snapshot.lastErr = err
+		if err == nil {
+			s.snapshot.lastSave = time.Now()
+			s.snapshot.dirty = dirty
+		}
+	}()
+	return nil
+}
+
+// lastSave handles LASTSAVE, which returns the unix time of the last successful save
+func (s *Server) lastSave() resp.Value {
+	s.snapshot.mu.Lock()
+	defer s.snapshot.mu.Unlock()
+	return resp.Integer(s.snapshot.lastSave.Unix())
+}
+
+// writeRDB saves the data of the snapshot to the RDB file with the given name
+// It is written to a temporary file which replaces the RDB file once it is complete on disk,
+// so the previous snapshot is kept if the save fails
+func writeRDB(snap *storage.Snapshot, filename string) error {
+	temp := fmt.Sprintf("temp-%d.rdb", os.Getpid())
+	file, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	e := rdb.NewEncoder(file)
+	e.WriteAux("redis-ver", serverVersion)
+	e.WriteAux("redis-bits", strconv.Itoa(strconv.IntSize))
+	e.WriteAux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	snap.SaveRDB(e)
+	err = e.Close()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, filename)
+}
+
+// loadRDB loads the RDB file, if there is one
+// If the AOF is enabled, it is then rewritten from the loaded data, since it is the file
+// loaded on the next startup
+func (s *Server) loadRDB() error {
+	filename := s.config().DBFilename
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	d, err := rdb.NewDecoder(file)
+	if err != nil {
+		return err
+	}
+	if err := s.DBs.LoadRDB(d); err != nil {
+		return err
+	}
+	fmt.Printf("DB loaded from %s\n", filename)
+
+	if s.AOF == nil {
+		return nil
+	}
+	if err := s.AOF.StartRewrite(); err != nil {
+		return err
+	}
+	snap := s.DBs.Snapshot()
+	defer snap.Release()
+	return s.AOF.Rewrite(rewriteCommands(snap))
+}
+
+// saveCron checks the save points once per second, and starts a background save
+// when one of them is reached
+func (s *Server) saveCron() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if param, changes, ok := s.reachedSavePoint(); ok {
+			if err := s.bgSave(s.DBs.Exclusive); err == nil {
+				fmt.Printf("%d changes in %d seconds. Saving...\n", changes, param.Seconds)
+			}
+		}
+	}
+}
+
+// reachedSavePoint returns the first save point that is reached, with the number of
+// changes since the last save
+// After a failed background save, no save point is reached for bgsaveRetryDelay
+func (s *Server) reachedSavePoint() (config.SaveParam, uint64, bool) {
+	s.snapshot.mu.Lock()
+	defer s.snapshot.mu.Unlock()
+
+	if s.snapshot.saving || (s.snapshot.lastErr != nil && time.Since(s.snapshot.lastTry) < bgsaveRetryDelay) {
+		return config.SaveParam{}, 0, false
+	}
+	changes := s.DBs.Dirty() - s.snapshot.dirty
+	for _, param := range s.config().Save {
+		if changes >= uint64(param.Changes) && time.Since(s.snapshot.lastSave) >= time.Duration(param.Seconds)*time.Second {
+			return param, changes, true
+		}
+	}
+	return config.SaveParam{}, 0, false
+}
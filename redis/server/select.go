@@ -0,0 +1,20 @@
+// https://redis.io/docs/latest/commands/select/
+package server
+
+import (
+	"redis/resp"
+	"strconv"
+)
+
+// selectDB handles SELECT index, which changes the database the commands of the client run in
+func (s *Server) selectDB(c *client, args []string) resp.Value {
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return resp.Err("ERR value is not an integer or out of range")
+	}
+	if s.DBs.DB(index) == nil {
+		return resp.Err("ERR DB index is out of range")
+	}
+	c.db = index
+	return resp.OK()
+}
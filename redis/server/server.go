@@ -1,133 +1,468 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"redis/aof"
 	"redis/command"
+	"redis/config"
+	"redis/pubsub"
 	"redis/resp"
 	"redis/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// activeExpireInterval is how often the server samples keys for expiry (10 times per second, like Redis)
+const activeExpireInterval = 100 * time.Millisecond
+
 // Server represents the Redis-like server
 type Server struct {
-	Addr    string           // Address to listen on
-	Storage *storage.Storage // In-memory storage
-	AOF     *aof.AOF         // Append-Only File for persistence
+	DBs    *storage.Databases // In-memory databases, selected by index with SELECT
+	AOF    *aof.AOF           // Append-Only File for persistence, nil if appendonly is disabled
+	PubSub *pubsub.Hub        // Channel and pattern subscriptions
+
+	// Config holds the parameters of the server. Once the server runs, it is replaced by
+	// CONFIG SET rather than modified, and read with config()
+	Config   *config.Config
+	configMu sync.RWMutex
+
+	MaxMultiBulkLen int // Most arguments accepted in a command
+
+	snapshot snapshotState
+	stats    serverStats
+	crons    sync.Once // Starts the background tasks with the first listener served
+
+	writeMu sync.Mutex                      // Serializes write commands, so the AOF has them in the order they were applied
+	aofDB   int                             // Database selected by the last SELECT written to the AOF, -1 if it must be written again
+	blockMu sync.Mutex                      // Serializes blocking and serving blocked clients
+	blocked map[blockedKey][]*blockedClient // Clients blocked on each key, oldest first
+
+	lastClientID atomic.Int64 // ID of the last client that connected
 }
 
-// NewServer creates a new Server instance
-func NewServer(addr string) (*Server, error) {
-	storage := storage.NewStorage()
-	aofHandler, err := aof.NewAOF("database.aof")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create AOF handler: %v", err)
+// NewServer creates a new Server instance with the given configuration
+// The working directory is changed to the dir parameter, where the RDB file and the AOF are
+func NewServer(cfg *config.Config) (*Server, error) {
+	if err := os.Chdir(cfg.Dir); err != nil {
+		return nil, fmt.Errorf("can't chdir to '%s': %v", cfg.Dir, err)
+	}
+	// Like Redis, CONFIG GET dir returns the absolute path
+	if dir, err := os.Getwd(); err == nil {
+		cfg.Dir = dir
 	}
 
 	server := &Server{
-		Addr:    addr,
-		Storage: storage,
-		AOF:     aofHandler,
+		DBs:     storage.NewDatabases(cfg.Databases),
+		PubSub:  pubsub.NewHub(),
+		Config:  cfg,
+		aofDB:   -1,
+		blocked: make(map[blockedKey][]*blockedClient),
+
+		MaxMultiBulkLen: resp.DefaultMaxMultiBulkLen,
+	}
+
+	// Like Redis with appendonly enabled, the RDB file is only loaded when there is no AOF
+	loadRDB := true
+	if cfg.AppendOnly {
+		// The directory of the AOF stays the same if CONFIG SET dir changes the working directory
+		dir, err := filepath.Abs(cfg.AppendDirname)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AOF handler: %v", err)
+		}
+		loadRDB = !aof.Exists(dir, cfg.AppendFilename)
+		if server.AOF, err = aof.NewAOF(dir, cfg.AppendFilename); err != nil {
+			return nil, fmt.Errorf("failed to create AOF handler: %v", err)
+		}
+		server.AOF.LoadTruncated = cfg.AOFLoadTruncated
+		server.AOF.SetFsyncPolicy(cfg.AppendFsync)
 	}
 
-	if err := server.loadAOF(); err != nil {
+	if loadRDB {
+		if err := server.loadRDB(); err != nil {
+			return nil, fmt.Errorf("failed to load RDB: %v", err)
+		}
+	} else if err := server.loadAOF(); err != nil {
 		return nil, fmt.Errorf("failed to load AOF: %v", err)
 	}
+	// The loaded data counts as saved
+	server.snapshot.lastSave = time.Now()
+	server.snapshot.dirty = server.DBs.Dirty()
 
 	return server, nil
 }
 
 // loadAOF loads the Append-Only File and executes all commands
+// The commands of a MULTI ... EXEC block are only executed once EXEC is read, so a
+// transaction cut short at the end of the file is not applied
+// Commands run in database 0 until a SELECT selects another one
 func (s *Server) loadAOF() error {
-	return s.AOF.Load(func(value resp.Value) {
-		if value.Type == "array" && len(value.Array) > 0 {
-			cmd := value.Array[0].Bulk
+	var queue []queuedCommand
+	multi := false
+	db := 0
+	var selectErr error
+
+	replay := func(cmd string, args []string) {
+		if cmd != "SELECT" {
+			s.executeCommand(db, cmd, args)
+			return
+		}
+		if len(args) != 1 {
+			selectErr = fmt.Errorf("SELECT with %d arguments instead of 1", len(args))
+			return
+		}
+		index, err := strconv.Atoi(args[0])
+		if err != nil || s.DBs.DB(index) == nil {
+			selectErr = fmt.Errorf("SELECT %s: there are only %d databases", args[0], s.DBs.Len())
+			return
+		}
+		db = index
+	}
+
+	err := s.AOF.Load(func(value resp.Value) {
+		if selectErr == nil && value.Kind == resp.KindArray && len(value.Array) > 0 {
+			cmd := strings.ToUpper(string(value.Array[0].Bulk))
 			args := make([]string, len(value.Array)-1)
 			for i, v := range value.Array[1:] {
-				args[i] = v.Bulk
+				args[i] = string(v.Bulk)
+			}
+
+			switch {
+			case cmd == "MULTI":
+				multi = true
+			case cmd == "EXEC":
+				for _, q := range queue {
+					replay(q.cmd, q.args)
+				}
+				queue = nil
+				multi = false
+			case multi:
+				queue = append(queue, queuedCommand{cmd: cmd, args: args})
+			default:
+				replay(cmd, args)
 			}
-			s.executeCommand(cmd, args)
 		}
 	})
+	if err != nil {
+		return err
+	}
+	return selectErr
+}
+
+// config returns the current configuration, which must not be modified
+func (s *Server) config() *config.Config {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.Config
 }
 
-// Run starts the server and listens for connections
+// Run starts the server and listens for connections on the port, at each address of bind
+// "*" stands for every IPv4 address and "::*" for every IPv6 address. An address with a "-"
+// prefix is skipped if it is not available, such as the IPv6 addresses once the IPv4
+// listener took the port on both
 func (s *Server) Run() error {
-	listener, err := net.Listen("tcp", s.Addr)
-	if err != nil {
-		return fmt.Errorf("failed to start server: %v", err)
+	cfg := s.config()
+	var listeners []net.Listener
+	defer func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+
+	for _, addr := range cfg.Bind {
+		optional := strings.HasPrefix(addr, "-")
+		host := strings.TrimPrefix(addr, "-")
+		switch host {
+		case "*":
+			host = ""
+		case "::*":
+			host = "::"
+		}
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(cfg.Port)))
+		if err != nil {
+			if optional {
+				continue
+			}
+			return fmt.Errorf("failed to start server: %v", err)
+		}
+		fmt.Printf("Server listening on %s\n", listener.Addr())
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return errors.New("failed to start server: no address to listen on")
+	}
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) { errs <- s.Serve(listener) }(listener)
 	}
-	defer listener.Close()
+	return <-errs
+}
 
-	fmt.Printf("Server listening on %s\n", s.Addr)
+// Serve accepts connections on the listener until it is closed
+// Beyond maxclients connections, new clients are sent an error and disconnected
+func (s *Server) Serve(listener net.Listener) error {
+	s.crons.Do(func() {
+		go s.activeExpireCycle()
+		go s.saveCron()
+	})
 
 	for {
 		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
 		if err != nil {
 			fmt.Printf("Error accepting connection: %v\n", err)
 			continue
 		}
+
+		s.stats.connections.Add(1)
+		if s.stats.clients.Add(1) > int64(s.config().MaxClients) {
+			s.stats.clients.Add(-1)
+			s.stats.rejected.Add(1)
+			conn.Write([]byte("-ERR max number of clients reached\r\n"))
+			conn.Close()
+			continue
+		}
 		go s.handleConnection(conn)
 	}
 }
 
 // handleConnection processes client connections
 func (s *Server) handleConnection(conn net.Conn) {
+	defer s.stats.clients.Add(-1)
 	defer conn.Close()
 	respReader := resp.NewResp(conn)
+	respReader.MaxMultiBulkLen = s.MaxMultiBulkLen
+	c := newClient(conn, s.lastClientID.Add(1), s.AOF)
+	defer s.closeClient(c)
 
 	for {
-		value, err := respReader.Read()
+		// proto-max-bulk-len may have been changed by CONFIG SET
+		respReader.MaxBulkLen = int(s.config().ProtoMaxBulkLen)
+
+		// Replies are sent once every command pipelined so far was executed
+		if respReader.Buffered() == 0 {
+			if err := c.flush(); err != nil {
+				fmt.Printf("Error writing response: %v\n", err)
+				return
+			}
+		}
+
+		value, err := respReader.ReadCommand()
 		if err != nil {
 			fmt.Printf("Error reading command: %v\n", err)
+			// The stream cannot be resynchronized after invalid RESP, so like Redis
+			// the client is told why before the connection is closed
+			if errors.Is(err, resp.ErrProtocol) {
+				if c.write(resp.Err("ERR "+err.Error())) == nil {
+					c.flush()
+				}
+			}
 			return
 		}
 
-		if value.Type != "array" {
-			fmt.Println("Invalid command format")
+		if len(value.Array) == 0 {
+			if err := c.write(resp.Err("ERR empty command")); err != nil {
+				fmt.Printf("Error writing response: %v\n", err)
+				return
+			}
 			continue
 		}
 
-		if len(value.Array) == 0 {
-			fmt.Println("Empty command")
+		name := string(value.Array[0].Bulk)
+		args := make([]string, len(value.Array)-1)
+		for i, v := range value.Array[1:] {
+			args[i] = string(v.Bulk)
+		}
+
+		d, ok := command.Lookup(name)
+		if !ok || !d.CheckArity(len(value.Array)) {
+			reply := command.UnknownCommand(name, args)
+			if ok {
+				reply = command.WrongArity(d)
+			}
+			// Like Redis, a command rejected while queuing makes EXEC fail
+			if c.multi {
+				c.multiError = true
+			}
+			if err := c.write(reply); err != nil {
+				fmt.Printf("Error writing response: %v\n", err)
+				return
+			}
 			continue
 		}
+		cmd := d.Name
+		s.stats.commands.Add(1)
 
-		// Write command to AOF for persistence
-		if err := s.AOF.Write(value); err != nil {
-			fmt.Printf("Error writing to AOF: %v\n", err)
+		if handled, err := s.handlePubSub(c, cmd, args); handled {
+			if err != nil {
+				fmt.Printf("Error writing response: %v\n", err)
+				return
+			}
+			continue
 		}
 
-		cmd := value.Array[0].Bulk
-		args := make([]string, len(value.Array)-1)
-		for i, v := range value.Array[1:] {
-			args[i] = v.Bulk
+		if handled, err := s.handleTransaction(c, cmd, args); handled {
+			if err != nil {
+				fmt.Printf("Error writing response: %v\n", err)
+				return
+			}
+			continue
+		}
+
+		// The commands without a handler in the table are executed by the server itself
+		if d.Handler == nil {
+			if err := c.write(s.serverCommand(c, cmd, args, s.DBs.Exclusive)); err != nil {
+				fmt.Printf("Error writing response: %v\n", err)
+				return
+			}
+			continue
+		}
+
+		// Commands run concurrently with each other, but never during a transaction
+		var result resp.Value
+		var waiter *blockedClient
+		s.DBs.Shared(func() {
+			keys, timeout, blocking := command.BlockingKeys(cmd, args)
+			if !d.Has(command.FlagWrite) && !blocking {
+				result, _ = s.call(c.db, cmd, args)
+				return
+			}
+
+			// Write commands run one at a time, and are written to the AOF before the next
+			// one is applied, so that replaying the AOF applies them in the same order.
+			// A blocking read such as XREAD takes the lock too, so a write cannot slip in
+			// between the read and the client blocking
+			s.writeMu.Lock()
+			defer s.writeMu.Unlock()
+
+			var logged loggedCommand
+			if blocking {
+				result, logged, waiter = s.callBlocking(c.db, cmd, args, keys, timeout)
+			} else {
+				result, logged = s.call(c.db, cmd, args)
+			}
+			s.propagate(logged)
+
+			s.serveBlocked()
+		})
+
+		// The keys of a blocking command were all empty, so wait until it is served
+		if waiter != nil {
+			// The replies to the commands pipelined before are not held back while waiting.
+			// A failure means the client is gone, which waitBlocked notices
+			c.flush()
+			result = s.waitBlocked(c, respReader, waiter)
 		}
 
-		result := s.executeCommand(cmd, args)
-		if _, err := conn.Write(result.Marshal()); err != nil {
+		if err := c.write(result); err != nil {
 			fmt.Printf("Error writing response: %v\n", err)
 			return
 		}
 	}
 }
 
-// executeCommand executes the given command with its arguments
-func (s *Server) executeCommand(cmd string, args []string) resp.Value {
-	switch cmd {
-	case "PING":
-		return command.Ping(s.Storage, args)
-	case "SET":
-		return command.Set(s.Storage, args)
-	case "GET":
-		return command.Get(s.Storage, args)
-	case "DEL":
-		return command.Del(s.Storage, args)
-	case "EXISTS":
-		return command.Exists(s.Storage, args)
-	case "INCR":
-		return command.Incr(s.Storage, args)
-	default:
-		return resp.Value{Type: "error", Str: "ERR unknown command '" + cmd + "'"}
+// closeClient releases the resources of a client whose connection is closing
+func (s *Server) closeClient(c *client) {
+	if c.subscriber != nil {
+		s.PubSub.Remove(c.subscriber)
+		c.subscriber.Close()
+	}
+	s.resetTransaction(c)
+}
+
+// loggedCommand is a command to write to the AOF, with the database it runs in
+type loggedCommand struct {
+	db    int        // -1 for a command that runs in any database, such as EXEC
+	value resp.Value // The zero Value if nothing is written
+}
+
+// call executes a storage command in the given database
+// Returns the reply and the command as it must be written to the AOF
+// Only the write commands that succeeded and modified the data are written: for any other
+// command, the second value holds the zero Value
+func (s *Server) call(db int, cmd string, args []string) (resp.Value, loggedCommand) {
+	// Relative expiries are made absolute so the command is replayed from the AOF with the
+	// same deadline, while it runs with its own arguments so they are validated as given
+	logCmd, logArgs := command.RewriteExpire(cmd, args)
+
+	dirty := s.DBs.Dirty()
+	result := s.executeCommand(db, cmd, args)
+	if d, ok := command.Lookup(cmd); !ok || !d.Has(command.FlagWrite) ||
+		result.Kind == resp.KindError || s.DBs.Dirty() == dirty {
+		return result, loggedCommand{db: db}
+	}
+
+	// Logged as executed, so that stream commands are written with the IDs they actually used,
+	// blocking pops as the pops they performed and SPOP as the members it removed
+	logCmd, logArgs = command.RewriteBlocking(logCmd, logArgs, result)
+	logCmd, logArgs = command.RewriteSPop(logCmd, logArgs, result)
+	return result, loggedCommand{db: db, value: commandValue(command.RewriteStream(s.DBs.DB(db), logCmd, logArgs, result))}
+}
+
+// propagate writes commands returned by call to the AOF, skipping the zero Values
+// Like Redis, a SELECT is written first whenever a command runs in another database than
+// the previous one
+// The caller must hold writeMu, or run in DBs.Exclusive
+func (s *Server) propagate(logged ...loggedCommand) {
+	var values []resp.Value
+	for _, l := range logged {
+		if l.value.Kind == resp.KindInvalid {
+			continue
+		}
+		if l.db >= 0 && l.db != s.aofDB {
+			values = append(values, commandValue("SELECT", []string{strconv.Itoa(l.db)}))
+			s.aofDB = l.db
+		}
+		values = append(values, l.value)
+	}
+	if len(values) == 0 || s.AOF == nil {
+		return
+	}
+	if err := s.AOF.Write(values...); err != nil {
+		fmt.Printf("Error writing to AOF: %v\n", err)
+	}
+	s.autoRewriteAOF()
+}
+
+// executeCommand executes the given command with its arguments in the given database
+func (s *Server) executeCommand(db int, cmd string, args []string) resp.Value {
+	d, ok := command.Lookup(cmd)
+	if !ok || d.Handler == nil {
+		return command.UnknownCommand(cmd, args)
+	}
+	return d.Handler(s.DBs.DB(db), args)
+}
+
+// activeExpireCycle periodically removes expired keys that are never accessed again
+func (s *Server) activeExpireCycle() {
+	ticker := time.NewTicker(activeExpireInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Keys never expire in the middle of a transaction
+		s.DBs.Shared(func() {
+			for db := 0; db < s.DBs.Len(); db++ {
+				s.DBs.DB(db).ActiveExpireCycle()
+			}
+		})
+	}
+}
+
+// commandValue builds the RESP array for a command and its arguments
+func commandValue(cmd string, args []string) resp.Value {
+	array := make([]resp.Value, len(args)+1)
+	array[0] = resp.Bulk(cmd)
+	for i, arg := range args {
+		array[i+1] = resp.Bulk(arg)
 	}
+	return resp.Array(array...)
 }
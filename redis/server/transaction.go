@@ -0,0 +1,155 @@
+// https://redis.io/docs/latest/develop/interact/transactions/
+package server
+
+import (
+	"redis/command"
+	"redis/resp"
+)
+
+// queuedCommand is a command queued between MULTI and EXEC
+type queuedCommand struct {
+	cmd  string
+	args []string
+}
+
+// transactionCommands are the commands that control a transaction, and are never queued
+var transactionCommands = map[string]bool{
+	"MULTI":   true,
+	"EXEC":    true,
+	"DISCARD": true,
+	"WATCH":   true,
+	"UNWATCH": true,
+}
+
+// handleTransaction executes the transaction commands, and queues every other command
+// while the client is inside MULTI
+// Returns false if cmd must be executed normally
+func (s *Server) handleTransaction(c *client, cmd string, args []string) (bool, error) {
+	if c.multi && !transactionCommands[cmd] {
+		return true, c.write(s.queueCommand(c, cmd, args))
+	}
+
+	switch cmd {
+	case "MULTI":
+		if c.multi {
+			return true, c.write(resp.Err("ERR MULTI calls can not be nested"))
+		}
+		c.multi = true
+		return true, c.write(resp.OK())
+	case "EXEC":
+		if !c.multi {
+			return true, c.write(resp.Err("ERR EXEC without MULTI"))
+		}
+		return true, c.write(s.exec(c))
+	case "DISCARD":
+		if !c.multi {
+			return true, c.write(resp.Err("ERR DISCARD without MULTI"))
+		}
+		s.resetTransaction(c)
+		return true, c.write(resp.OK())
+	case "WATCH":
+		if c.multi {
+			// Like Redis, the error also aborts the transaction
+			c.multiError = true
+			return true, c.write(resp.Err("ERR WATCH inside MULTI is not allowed"))
+		}
+		c.watched = append(c.watched, s.DBs.DB(c.db).Watch(args...)...)
+		return true, c.write(resp.OK())
+	case "UNWATCH":
+		if c.multi {
+			// Queued like any other command, it has no effect as EXEC unwatches anyway
+			return true, c.write(s.queueCommand(c, cmd, args))
+		}
+		s.DBs.Unwatch(c.watched)
+		c.watched = nil
+		return true, c.write(resp.OK())
+	default:
+		return false, nil
+	}
+}
+
+// queueCommand queues a command until EXEC
+// Unknown commands and wrong numbers of arguments were already rejected, making EXEC fail
+func (s *Server) queueCommand(c *client, cmd string, args []string) resp.Value {
+	switch cmd {
+	case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		c.multiError = true
+		return resp.Err("ERR Command not allowed inside a transaction")
+	}
+
+	c.queue = append(c.queue, queuedCommand{cmd: cmd, args: args})
+	return resp.SimpleString("QUEUED")
+}
+
+// exec runs the queued commands of a client as a single atomic step
+// The commands that change the data are written to the AOF as one MULTI ... EXEC block
+func (s *Server) exec(c *client) resp.Value {
+	defer s.resetTransaction(c)
+
+	if c.multiError {
+		return resp.Err("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	var result resp.Value
+	s.DBs.Exclusive(func() {
+		// A watched key was modified, so the transaction is aborted with a null reply
+		if s.DBs.Changed(c.watched) {
+			result = resp.Null()
+			return
+		}
+
+		results := make([]resp.Value, len(c.queue))
+		var logged []loggedCommand
+		var rewrites []int
+		for i, q := range c.queue {
+			switch d, _ := command.Lookup(q.cmd); {
+			case q.cmd == "UNWATCH":
+				results[i] = resp.OK()
+			case q.cmd == "BGREWRITEAOF":
+				// Started once the transaction is in the AOF, or the snapshot would hold the
+				// writes queued before it, which would also be replayed from the AOF
+				rewrites = append(rewrites, i)
+			case d.Handler == nil:
+				// A SELECT applies to the commands queued after it, as they run in c.db
+				results[i] = s.serverCommand(c, q.cmd, q.args, inExclusive)
+			default:
+				var l loggedCommand
+				results[i], l = s.call(c.db, q.cmd, q.args)
+				if l.value.Kind != resp.KindInvalid {
+					logged = append(logged, l)
+				}
+			}
+		}
+
+		// A single command needs no MULTI ... EXEC block to be replayed atomically
+		if len(logged) > 1 {
+			// Any SELECT the first command needs is written before MULTI
+			logged = append([]loggedCommand{{db: logged[0].db, value: commandValue("MULTI", nil)}}, logged...)
+			logged = append(logged, loggedCommand{db: -1, value: commandValue("EXEC", nil)})
+		}
+		s.propagate(logged...)
+		for _, i := range rewrites {
+			results[i] = s.bgRewriteAOF(inExclusive)
+		}
+		result = resp.Array(results...)
+
+		// Clients blocked on keys pushed to by the transaction are served once it completed
+		s.serveBlocked()
+	})
+	return result
+}
+
+// inExclusive runs fn directly, standing for s.DBs.Exclusive in the commands EXEC runs,
+// as it already holds it
+func inExclusive(fn func()) {
+	fn()
+}
+
+// resetTransaction leaves MULTI and stops watching keys
+func (s *Server) resetTransaction(c *client) {
+	s.DBs.Unwatch(c.watched)
+	c.multi = false
+	c.multiError = false
+	c.queue = nil
+	c.watched = nil
+}
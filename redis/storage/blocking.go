@@ -0,0 +1,52 @@
+// https://redis.io/docs/latest/develop/data-types/lists/#blocking-commands
+package storage
+
+// Block records that a client is blocked on keys, so that modifying them makes them ready
+// Every call must be matched by a call to Unblock with the same keys
+func (s *Storage) Block(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		s.blocked[key]++
+	}
+}
+
+// Unblock records that a client is no longer blocked on keys
+func (s *Storage) Unblock(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		s.blocked[key]--
+		if s.blocked[key] <= 0 {
+			delete(s.blocked, key)
+		}
+	}
+}
+
+// ReadyKeys returns the keys with blocked clients that were modified since the last call,
+// in the order they were first modified
+func (s *Storage) ReadyKeys() []string {
+	// Checked first with the read lock, as this runs after every command
+	s.mu.RLock()
+	empty := len(s.ready) == 0
+	s.mu.RUnlock()
+	if empty {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(s.ready))
+	keys := make([]string, 0, len(s.ready))
+	for _, key := range s.ready {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	s.ready = nil
+	return keys
+}
@@ -0,0 +1,172 @@
+// https://redis.io/docs/latest/commands/select/
+package storage
+
+import (
+	"sync"
+)
+
+// Databases are the numbered databases of a server
+// They share their locks, so that a command can move keys between them atomically, and their
+// count of modifications and snapshot epoch, so that a snapshot covers all of them at once
+type Databases struct {
+	dbs       []*Storage
+	epoch     uint64       // Incremented by every snapshot, see Snapshot
+	snapshots int          // Number of snapshots not released yet
+	dirty     uint64       // Number of modifications since the databases were created
+	mu        sync.RWMutex // Read-Write mutex for thread-safe operations
+	txMu      sync.RWMutex // Held exclusively while a transaction runs, shared by any other command
+}
+
+// NewDatabases creates n empty databases
+func NewDatabases(n int) *Databases {
+	d := &Databases{dbs: make([]*Storage, n)}
+	for i := range d.dbs {
+		db := &Storage{
+			Databases: d,
+			index:     i,
+			watched:   make(map[string]*watchedKey),
+			blocked:   make(map[string]int),
+		}
+		for j := range db.shards {
+			db.shards[j] = newShard(0)
+		}
+		d.dbs[i] = db
+	}
+	return d
+}
+
+// DB returns the database with the given index, or nil if there is none
+func (d *Databases) DB(index int) *Storage {
+	if index < 0 || index >= len(d.dbs) {
+		return nil
+	}
+	return d.dbs[index]
+}
+
+// Len returns the number of databases
+func (d *Databases) Len() int {
+	return len(d.dbs)
+}
+
+// Index returns the index of the database
+func (s *Storage) Index() int {
+	return s.index
+}
+
+// DBSize returns the number of keys in the database, including the ones that expired
+// but were not removed yet
+func (s *Storage) DBSize() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	size := 0
+	for _, sh := range s.shards {
+		size += len(sh.data)
+	}
+	return size
+}
+
+// FlushDB removes every key of the database
+// The shards are replaced by empty ones, and the previous ones are left to the garbage
+// collector, so the keys are freed in the background whether ASYNC is given or not
+// Returns the number of keys removed
+func (s *Storage) FlushDB() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// FlushAll removes every key of every database
+// Returns the number of keys removed
+func (d *Databases) FlushAll() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	removed := 0
+	for _, db := range d.dbs {
+		removed += db.flush()
+	}
+	return removed
+}
+
+// flush removes every key of the database, invalidating the transactions watching them
+// The caller must hold the write lock
+func (s *Storage) flush() int {
+	for key, w := range s.watched {
+		if s.object(key) != nil {
+			w.version++
+		}
+	}
+
+	removed := 0
+	for i, sh := range s.shards {
+		removed += len(sh.data)
+		s.shards[i] = newShard(s.epoch)
+	}
+	s.dirty += uint64(removed)
+	return removed
+}
+
+// SwapDB swaps the keys of two databases, so that the clients connected to one of them see
+// the keys of the other
+// The transactions watching keys of either database are invalidated, and the clients blocked
+// on keys that now exist are served, since these stay with the database index like in Redis
+func (d *Databases) SwapDB(i, j int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if i == j {
+		return
+	}
+	a, b := d.dbs[i], d.dbs[j]
+	a.touchSwapped(b)
+	b.touchSwapped(a)
+	a.shards, b.shards = b.shards, a.shards
+	a.readySwapped()
+	b.readySwapped()
+	d.dirty++
+}
+
+// touchSwapped invalidates the transactions watching keys of the database that exist in it
+// or in the database it is swapped with
+// The caller must hold the write lock
+func (s *Storage) touchSwapped(other *Storage) {
+	for key, w := range s.watched {
+		if s.object(key) != nil || other.object(key) != nil {
+			w.version++
+		}
+	}
+}
+
+// readySwapped makes the keys with blocked clients ready if they exist after a swap
+// The caller must hold the write lock
+func (s *Storage) readySwapped() {
+	for key := range s.blocked {
+		if s.object(key) != nil {
+			s.ready = append(s.ready, key)
+		}
+	}
+}
+
+// Move moves a key, with its value and expiry, to another database
+// Returns false if the key does not exist or already exists in the destination
+func (s *Storage) Move(key string, dst *Storage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj := s.lookup(key)
+	if obj == nil || dst.lookup(key) != nil {
+		return false
+	}
+	at, expires := s.expiry(key)
+	s.delete(key)
+
+	// The value keeps its epoch, since a snapshot may still share it
+	sh := dst.writableShard(key)
+	sh.data[key] = obj
+	if expires {
+		sh.expires[key] = at
+	}
+	dst.touch(key)
+	return true
+}
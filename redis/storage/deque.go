@@ -0,0 +1,113 @@
+package storage
+
+// deque is a double-ended queue of strings backed by a growable ring buffer
+// Pushing and popping at either end is O(1), and so is access by index,
+// which makes it a good fit for the Redis list commands
+type deque struct {
+	buf   []string // Ring buffer holding the elements
+	head  int      // Index in buf of the first element
+	count int      // Number of elements in the deque
+}
+
+// newDeque creates an empty deque
+func newDeque() *deque {
+	return &deque{}
+}
+
+// Len returns the number of elements in the deque
+func (d *deque) Len() int {
+	return d.count
+}
+
+// grow doubles the capacity of the ring buffer when it is full
+func (d *deque) grow() {
+	if d.count < len(d.buf) {
+		return
+	}
+	buf := make([]string, max(8, 2*len(d.buf)))
+	d.copyTo(buf)
+	d.buf = buf
+	d.head = 0
+}
+
+// copyTo copies the elements in order into dst, which must be large enough
+func (d *deque) copyTo(dst []string) {
+	n := copy(dst, d.buf[d.head:min(d.head+d.count, len(d.buf))])
+	copy(dst[n:], d.buf[:d.count-n])
+}
+
+// index converts a logical position into an index in the ring buffer
+func (d *deque) index(i int) int {
+	return (d.head + i) % len(d.buf)
+}
+
+// PushFront inserts an element at the head of the deque
+func (d *deque) PushFront(value string) {
+	d.grow()
+	d.head = (d.head - 1 + len(d.buf)) % len(d.buf)
+	d.buf[d.head] = value
+	d.count++
+}
+
+// PushBack inserts an element at the tail of the deque
+func (d *deque) PushBack(value string) {
+	d.grow()
+	d.buf[d.index(d.count)] = value
+	d.count++
+}
+
+// PopFront removes and returns the element at the head of the deque
+// The deque must not be empty
+func (d *deque) PopFront() string {
+	value := d.buf[d.head]
+	d.buf[d.head] = ""
+	d.head = d.index(1)
+	d.count--
+	return value
+}
+
+// PopBack removes and returns the element at the tail of the deque
+// The deque must not be empty
+func (d *deque) PopBack() string {
+	i := d.index(d.count - 1)
+	value := d.buf[i]
+	d.buf[i] = ""
+	d.count--
+	return value
+}
+
+// At returns the element at position i, counting from the head
+func (d *deque) At(i int) string {
+	return d.buf[d.index(i)]
+}
+
+// Set replaces the element at position i, counting from the head
+func (d *deque) Set(i int, value string) {
+	d.buf[d.index(i)] = value
+}
+
+// Range returns the elements between positions start and stop, both inclusive
+func (d *deque) Range(start, stop int) []string {
+	items := make([]string, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		items = append(items, d.At(i))
+	}
+	return items
+}
+
+// Items returns all elements in order
+func (d *deque) Items() []string {
+	items := make([]string, d.count)
+	if d.count > 0 {
+		d.copyTo(items)
+	}
+	return items
+}
+
+// Reset replaces the content of the deque with the given elements
+// It is used by operations that rewrite the middle of a list, such as LREM and LINSERT
+func (d *deque) Reset(items []string) {
+	d.buf = items
+	d.head = 0
+	d.count = len(items)
+}
@@ -0,0 +1,120 @@
+// https://redis.io/docs/latest/commands/expire/#how-redis-expires-keys
+package storage
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// activeExpireSamples is the number of keys with a TTL sampled per loop
+	activeExpireSamples = 20
+	// activeExpireTimeLimit bounds the time a single cycle may hold the lock
+	activeExpireTimeLimit = 25 * time.Millisecond
+)
+
+// Now returns the current time in unix milliseconds
+func Now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// expireIfNeeded deletes the key if its time to live has elapsed
+// Returns true if the key was expired
+// The caller must hold the write lock
+func (s *Storage) expireIfNeeded(key string) bool {
+	when, ok := s.expiry(key)
+	if !ok || when > Now() {
+		return false
+	}
+	s.delete(key)
+	return true
+}
+
+// Expire sets the absolute expiry time (unix milliseconds) of a key
+// A time in the past deletes the key immediately
+// Returns false if the key does not exist
+func (s *Storage) Expire(key string, at int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookup(key) == nil {
+		return false
+	}
+
+	if at <= Now() {
+		s.delete(key)
+		return true
+	}
+	s.setExpiry(key, at)
+	s.touch(key)
+	return true
+}
+
+// TTL returns the remaining time to live of a key in milliseconds
+// Returns -2 if the key does not exist and -1 if it has no expiry
+func (s *Storage) TTL(key string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookup(key) == nil {
+		return -2
+	}
+	when, ok := s.expiry(key)
+	if !ok {
+		return -1
+	}
+	return max(when-Now(), 0)
+}
+
+// Persist removes the expiry from a key
+// Returns true if the key existed and had a time to live
+func (s *Storage) Persist(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookup(key) == nil {
+		return false
+	}
+	if _, ok := s.expiry(key); !ok {
+		return false
+	}
+	s.clearExpiry(key)
+	s.touch(key)
+	return true
+}
+
+// ActiveExpireCycle samples keys with a time to live and deletes the expired ones
+// Like Redis, it keeps sampling while more than a quarter of the sample was expired,
+// but never for longer than activeExpireTimeLimit
+// Returns the number of keys that were deleted
+func (s *Storage) ActiveExpireCycle() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := time.Now()
+	deleted := 0
+	for {
+		now := Now()
+		sampled, expired := 0, 0
+		// Go randomises map iteration order, which gives us a cheap random sample
+		// once the shards are walked from a random one
+		first := rand.Intn(shardCount)
+		for i := 0; i < shardCount && sampled < activeExpireSamples; i++ {
+			for key, when := range s.shards[(first+i)%shardCount].expires {
+				if sampled == activeExpireSamples {
+					break
+				}
+				sampled++
+				if when <= now {
+					s.delete(key)
+					expired++
+				}
+			}
+		}
+		deleted += expired
+
+		if expired*4 <= sampled || time.Since(start) > activeExpireTimeLimit {
+			return deleted
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// https://redis.io/docs/latest/develop/data-types/hashes/
+package storage
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+var (
+	// ErrHashNotInteger is returned by HINCRBY when the field does not hold an integer
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	// ErrHashNotFloat is returned by HINCRBYFLOAT when the field does not hold a float
+	ErrHashNotFloat = errors.New("ERR hash value is not a float")
+	// ErrOverflow is returned when an increment would overflow a 64 bit integer
+	ErrOverflow = errors.New("ERR increment or decrement would overflow")
+	// ErrNaNOrInfinity is returned when a float increment would produce NaN or Infinity
+	ErrNaNOrInfinity = errors.New("ERR increment would produce NaN or Infinity")
+)
+
+// lookupHash returns the hash stored under key
+// If the key does not exist, nil is returned unless mode is accessCreate, in which case an empty hash is stored
+// Unless mode is accessRead, the value is copied first if a snapshot shares it
+// Returns ErrWrongType if the key holds another data type
+// The caller must hold the write lock
+func (s *Storage) lookupHash(key string, mode access) (map[string]string, error) {
+	obj := s.lookup(key)
+	if obj == nil {
+		if mode != accessCreate {
+			return nil, nil
+		}
+		obj = &object{kind: KindHash, hash: make(map[string]string)}
+		s.store(key, obj)
+	}
+	if obj.kind != KindHash {
+		return nil, ErrWrongType
+	}
+	if mode != accessRead {
+		obj = s.writable(key, obj)
+	}
+	return obj.hash, nil
+}
+
+// HSet sets the given field-value pairs in the hash stored at key
+// fieldValues must contain an even number of elements
+// Returns the number of fields that were added
+func (s *Storage) HSet(key string, fieldValues ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessCreate)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for i := 0; i+1 < len(fieldValues); i += 2 {
+		if _, ok := hash[fieldValues[i]]; !ok {
+			added++
+		}
+		hash[fieldValues[i]] = fieldValues[i+1]
+	}
+	s.touch(key)
+	return added, nil
+}
+
+// HSetNX sets a field in the hash stored at key only if the field does not exist yet
+// Returns true if the field was set
+func (s *Storage) HSetNX(key, field, value string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessCreate)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := hash[field]; ok {
+		return false, nil
+	}
+	hash[field] = value
+	s.touch(key)
+	return true, nil
+}
+
+// HGet returns the value of a field in the hash stored at key
+// Returns false if the key or the field does not exist
+func (s *Storage) HGet(key, field string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessRead)
+	if hash == nil {
+		return "", false, err
+	}
+	value, ok := hash[field]
+	return value, ok, nil
+}
+
+// HMGet returns the values of the given fields in the hash stored at key
+// The second slice reports, for every field, whether it exists
+func (s *Storage) HMGet(key string, fields ...string) ([]string, []bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessRead)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]string, len(fields))
+	found := make([]bool, len(fields))
+	for i, field := range fields {
+		values[i], found[i] = hash[field]
+	}
+	return values, found, nil
+}
+
+// HDel removes the given fields from the hash stored at key
+// The key is deleted once the hash is empty
+// Returns the number of fields that were removed
+func (s *Storage) HDel(key string, fields ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessWrite)
+	if hash == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, field := range fields {
+		if _, ok := hash[field]; ok {
+			delete(hash, field)
+			removed++
+		}
+	}
+	if len(hash) == 0 {
+		s.delete(key)
+	}
+	if removed > 0 {
+		s.touch(key)
+	}
+	return removed, nil
+}
+
+// HGetAll returns all fields and values of the hash stored at key,
+// as a flat list of field-value pairs
+func (s *Storage) HGetAll(key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessRead)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]string, 0, 2*len(hash))
+	for field, value := range hash {
+		pairs = append(pairs, field, value)
+	}
+	return pairs, nil
+}
+
+// HKeys returns all field names of the hash stored at key
+func (s *Storage) HKeys(key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessRead)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(hash))
+	for field := range hash {
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// HVals returns all values of the hash stored at key
+func (s *Storage) HVals(key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessRead)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(hash))
+	for _, value := range hash {
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// HLen returns the number of fields in the hash stored at key
+func (s *Storage) HLen(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessRead)
+	return len(hash), err
+}
+
+// HExists reports whether field exists in the hash stored at key
+func (s *Storage) HExists(key, field string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessRead)
+	if hash == nil {
+		return false, err
+	}
+	_, ok := hash[field]
+	return ok, nil
+}
+
+// HIncrBy increments the integer value of a field in the hash stored at key
+// A missing field is set to 0 before performing the operation
+// Returns the new value
+func (s *Storage) HIncrBy(key, field string, amount int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessCreate)
+	if err != nil {
+		return 0, err
+	}
+	var current int64
+	if value, ok := hash[field]; ok {
+		current, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, ErrHashNotInteger
+		}
+	}
+	if (amount > 0 && current > math.MaxInt64-amount) || (amount < 0 && current < math.MinInt64-amount) {
+		return 0, ErrOverflow
+	}
+	current += amount
+	hash[field] = strconv.FormatInt(current, 10)
+	s.touch(key)
+	return current, nil
+}
+
+// HIncrByFloat increments the float value of a field in the hash stored at key
+// A missing field is set to 0 before performing the operation
+// Returns the new value
+func (s *Storage) HIncrByFloat(key, field string, amount float64) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.lookupHash(key, accessCreate)
+	if err != nil {
+		return 0, err
+	}
+	var current float64
+	if value, ok := hash[field]; ok {
+		current, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return 0, ErrHashNotFloat
+		}
+	}
+	current += amount
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		if len(hash) == 0 {
+			// Don't leave behind the empty hash created above
+			s.delete(key)
+		}
+		return 0, ErrNaNOrInfinity
+	}
+	hash[field] = strconv.FormatFloat(current, 'f', -1, 64)
+	s.touch(key)
+	return current, nil
+}
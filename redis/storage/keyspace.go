@@ -0,0 +1,155 @@
+// https://redis.io/docs/latest/develop/use/keyspace/
+package storage
+
+import (
+	"math/rand"
+	"redis/glob"
+)
+
+// ScanOptions holds the optional arguments of the SCAN command
+type ScanOptions struct {
+	Match string // Glob-style pattern the keys must match, empty for every key
+	Count int    // Number of keys to visit, more may be visited to finish a shard
+	Type  string // Type the keys must hold, as reported by TYPE, empty for every type
+}
+
+// live reports whether a key of the shard has not expired at the given time
+// The caller must hold the lock
+func (sh *shard) live(key string, now int64) bool {
+	at, ok := sh.expires[key]
+	return !ok || at > now
+}
+
+// Keys returns the keys matching the glob-style pattern, in no particular order
+func (s *Storage) Keys(pattern string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := Now()
+	all := pattern == "*"
+	keys := []string{}
+	for _, sh := range s.shards {
+		for key := range sh.data {
+			if sh.live(key, now) && (all || glob.Match(pattern, key)) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// Scan returns the keys of the shards from cursor on, until at least opts.Count keys
+// were visited, and the cursor to pass to the next call, which is 0 once every shard was
+// visited
+// The cursor is the index of the next shard: since a key always hashes to the same shard,
+// a key that exists for the whole iteration is returned exactly once, however the keys
+// change in between
+func (s *Storage) Scan(cursor int, opts ScanOptions) ([]string, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := Now()
+	keys := []string{}
+	visited := 0
+	for ; cursor < shardCount && visited < opts.Count; cursor++ {
+		sh := s.shards[cursor]
+		for key, obj := range sh.data {
+			visited++
+			if !sh.live(key, now) ||
+				(opts.Match != "" && !glob.Match(opts.Match, key)) ||
+				(opts.Type != "" && obj.kind.String() != opts.Type) {
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	if cursor >= shardCount {
+		cursor = 0
+	}
+	return keys, cursor
+}
+
+// RandomKey returns a key of the database that has not expired, and false if there is none
+// The key is taken from a random shard, in the random iteration order of its map
+func (s *Storage) RandomKey() (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := Now()
+	first := rand.Intn(shardCount)
+	for i := 0; i < shardCount; i++ {
+		sh := s.shards[(first+i)%shardCount]
+		for key := range sh.data {
+			if sh.live(key, now) {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Type returns the data type held by a key, and false if it does not exist
+func (s *Storage) Type(key string) (Kind, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj := s.lookup(key)
+	if obj == nil {
+		return 0, false
+	}
+	return obj.kind, true
+}
+
+// Rename renames a key, with its value and expiry, overwriting newKey if it exists
+// With nx, newKey is only written if it does not exist
+// Returns false if nothing was renamed, and ErrNoSuchKey if the key does not exist
+func (s *Storage) Rename(key, newKey string, nx bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj := s.lookup(key)
+	if obj == nil {
+		return false, ErrNoSuchKey
+	}
+	if key == newKey {
+		return !nx, nil
+	}
+	if nx && s.lookup(newKey) != nil {
+		return false, nil
+	}
+	at, expires := s.expiry(key)
+	s.delete(key)
+
+	// The value keeps its epoch, since a snapshot may still share it
+	s.writableShard(newKey).data[newKey] = obj
+	if expires {
+		s.setExpiry(newKey, at)
+	} else {
+		s.clearExpiry(newKey)
+	}
+	s.touch(newKey)
+	return true, nil
+}
+
+// Copy copies the value of a key, with its expiry, to dstKey in the database dst
+// With replace, dstKey is overwritten if it exists
+// Returns false if the key does not exist, or dstKey exists and replace is not set
+func (s *Storage) Copy(key string, dst *Storage, dstKey string, replace bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj := s.lookup(key)
+	if obj == nil || (!replace && dst.lookup(dstKey) != nil) {
+		return false
+	}
+	at, expires := s.expiry(key)
+
+	dst.store(dstKey, obj.clone())
+	if expires {
+		dst.setExpiry(dstKey, at)
+	} else {
+		dst.clearExpiry(dstKey)
+	}
+	dst.touch(dstKey)
+	return true
+}
@@ -0,0 +1,359 @@
+// https://redis.io/docs/latest/develop/data-types/lists/
+package storage
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNoSuchKey is returned by LSET and RENAME when the key does not exist
+	ErrNoSuchKey = errors.New("ERR no such key")
+	// ErrIndexOutOfRange is returned by LSET when the index is outside the list
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+)
+
+// lookupList returns the list stored under key
+// If the key does not exist, nil is returned unless mode is accessCreate, in which case an empty list is stored
+// Unless mode is accessRead, the value is copied first if a snapshot shares it
+// Returns ErrWrongType if the key holds another data type
+// The caller must hold the write lock
+func (s *Storage) lookupList(key string, mode access) (*deque, error) {
+	obj := s.lookup(key)
+	if obj == nil {
+		if mode != accessCreate {
+			return nil, nil
+		}
+		obj = &object{kind: KindList, list: newDeque()}
+		s.store(key, obj)
+	}
+	if obj.kind != KindList {
+		return nil, ErrWrongType
+	}
+	if mode != accessRead {
+		obj = s.writable(key, obj)
+	}
+	return obj.list, nil
+}
+
+// deleteIfEmpty removes a list key once its last element is gone, like Redis does
+// The caller must hold the write lock
+func (s *Storage) deleteIfEmpty(key string, list *deque) {
+	if list.Len() == 0 {
+		s.delete(key)
+	}
+}
+
+// normalizeRange converts Redis style start/stop indexes, which may be negative,
+// into an inclusive range within a sequence of the given length
+// Returns false if the range is empty
+func normalizeRange(start, stop, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+// LPush inserts the values at the head of the list stored at key
+// Returns the length of the list after the operation
+func (s *Storage) LPush(key string, values ...string) (int, error) {
+	return s.push(key, values, true)
+}
+
+// RPush inserts the values at the tail of the list stored at key
+// Returns the length of the list after the operation
+func (s *Storage) RPush(key string, values ...string) (int, error) {
+	return s.push(key, values, false)
+}
+
+// push implements LPUSH and RPUSH
+func (s *Storage) push(key string, values []string, left bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessCreate)
+	if err != nil {
+		return 0, err
+	}
+	for _, value := range values {
+		if left {
+			list.PushFront(value)
+		} else {
+			list.PushBack(value)
+		}
+	}
+	s.touch(key)
+	return list.Len(), nil
+}
+
+// LPop removes and returns up to count elements from the head of the list
+// Returns nil if the key does not exist
+func (s *Storage) LPop(key string, count int) ([]string, error) {
+	return s.pop(key, count, true)
+}
+
+// RPop removes and returns up to count elements from the tail of the list
+// Returns nil if the key does not exist
+func (s *Storage) RPop(key string, count int) ([]string, error) {
+	return s.pop(key, count, false)
+}
+
+// pop implements LPOP and RPOP
+func (s *Storage) pop(key string, count int, left bool) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessWrite)
+	if list == nil {
+		return nil, err
+	}
+	return s.popList(key, list, count, left), nil
+}
+
+// popList removes up to count elements from one end of the list stored at key
+// The caller must hold the write lock
+func (s *Storage) popList(key string, list *deque, count int, left bool) []string {
+	values := make([]string, 0, min(count, list.Len()))
+	for len(values) < count && list.Len() > 0 {
+		if left {
+			values = append(values, list.PopFront())
+		} else {
+			values = append(values, list.PopBack())
+		}
+	}
+	s.deleteIfEmpty(key, list)
+	if len(values) > 0 {
+		s.touch(key)
+	}
+	return values
+}
+
+// LMPop removes up to count elements from one end of the first non-empty list among keys
+// Returns the key the elements were popped from, or an empty key if no list has elements
+func (s *Storage) LMPop(keys []string, count int, left bool) (string, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		list, err := s.lookupList(key, accessWrite)
+		if err != nil {
+			return "", nil, err
+		}
+		if list != nil {
+			return key, s.popList(key, list, count, left), nil
+		}
+	}
+	return "", nil, nil
+}
+
+// LLen returns the length of the list stored at key, 0 if the key does not exist
+func (s *Storage) LLen(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessRead)
+	if list == nil {
+		return 0, err
+	}
+	return list.Len(), nil
+}
+
+// LRange returns the elements of the list between start and stop, both inclusive
+// Negative indexes count from the end of the list
+func (s *Storage) LRange(key string, start, stop int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessRead)
+	if list == nil {
+		return []string{}, err
+	}
+	start, stop, ok := normalizeRange(start, stop, list.Len())
+	if !ok {
+		return []string{}, nil
+	}
+	return list.Range(start, stop), nil
+}
+
+// LIndex returns the element at index in the list stored at key
+// Returns false if the key does not exist or the index is out of range
+func (s *Storage) LIndex(key string, index int) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessRead)
+	if list == nil {
+		return "", false, err
+	}
+	if index < 0 {
+		index += list.Len()
+	}
+	if index < 0 || index >= list.Len() {
+		return "", false, nil
+	}
+	return list.At(index), true, nil
+}
+
+// LSet replaces the element at index in the list stored at key
+func (s *Storage) LSet(key string, index int, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessWrite)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return ErrNoSuchKey
+	}
+	if index < 0 {
+		index += list.Len()
+	}
+	if index < 0 || index >= list.Len() {
+		return ErrIndexOutOfRange
+	}
+	list.Set(index, value)
+	s.touch(key)
+	return nil
+}
+
+// LTrim trims the list so that it only contains the elements between start and stop
+func (s *Storage) LTrim(key string, start, stop int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessWrite)
+	if list == nil {
+		return err
+	}
+	start, stop, ok := normalizeRange(start, stop, list.Len())
+	if !ok {
+		s.delete(key)
+		return nil
+	}
+	list.Reset(list.Range(start, stop))
+	s.touch(key)
+	return nil
+}
+
+// LRem removes occurrences of value from the list stored at key
+// count > 0 removes up to count elements moving from head to tail,
+// count < 0 removes up to -count elements moving from tail to head,
+// and count == 0 removes all of them
+// Returns the number of removed elements
+func (s *Storage) LRem(key string, count int, value string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessWrite)
+	if list == nil {
+		return 0, err
+	}
+
+	items := list.Items()
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	removed := 0
+	keep := make([]bool, len(items))
+	for n := 0; n < len(items); n++ {
+		i := n
+		if count < 0 {
+			i = len(items) - 1 - n
+		}
+		if items[i] == value && (limit == 0 || removed < limit) {
+			removed++
+			continue
+		}
+		keep[i] = true
+	}
+
+	if removed > 0 {
+		kept := make([]string, 0, len(items)-removed)
+		for i, item := range items {
+			if keep[i] {
+				kept = append(kept, item)
+			}
+		}
+		list.Reset(kept)
+		s.deleteIfEmpty(key, list)
+		s.touch(key)
+	}
+	return removed, nil
+}
+
+// LInsert inserts value before or after the first occurrence of pivot
+// Returns the new length of the list, 0 if the key does not exist and -1 if pivot was not found
+func (s *Storage) LInsert(key string, before bool, pivot, value string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key, accessWrite)
+	if list == nil {
+		return 0, err
+	}
+
+	items := list.Items()
+	for i, item := range items {
+		if item != pivot {
+			continue
+		}
+		if !before {
+			i++
+		}
+		items = append(items, "")
+		copy(items[i+1:], items[i:])
+		items[i] = value
+		list.Reset(items)
+		s.touch(key)
+		return list.Len(), nil
+	}
+	return -1, nil
+}
+
+// LMove atomically pops an element from one end of the source list and
+// pushes it to one end of the destination list
+// Returns false if the source list does not exist
+func (s *Storage) LMove(source, destination string, fromLeft, toLeft bool) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := s.lookupList(source, accessWrite)
+	if src == nil {
+		return "", false, err
+	}
+	// Check the destination type before modifying the source
+	if obj := s.lookup(destination); obj != nil && obj.kind != KindList {
+		return "", false, ErrWrongType
+	}
+
+	var value string
+	if fromLeft {
+		value = src.PopFront()
+	} else {
+		value = src.PopBack()
+	}
+
+	dst, _ := s.lookupList(destination, accessCreate)
+	if toLeft {
+		dst.PushFront(value)
+	} else {
+		dst.PushBack(value)
+	}
+	s.deleteIfEmpty(source, src)
+	s.touch(source)
+	s.touch(destination)
+	return value, true, nil
+}
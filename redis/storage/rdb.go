@@ -0,0 +1,415 @@
+// https://rdb.fnordig.de/file_format.html
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"redis/rdb"
+	"strconv"
+)
+
+// Flags of the entries in the listpacks of a stream
+const (
+	streamItemDeleted    = 1 // The entry was deleted
+	streamItemSameFields = 2 // The entry has the fields of the master entry, so only its values are stored
+)
+
+// streamNodeMaxEntries is the most entries saved in one listpack, like stream-node-max-entries
+const streamNodeMaxEntries = 100
+
+// SaveRDB writes the keys of the snapshot to an RDB file, each database that has keys
+// after a SELECTDB opcode
+// Expired keys are left out
+func (snap *Snapshot) SaveRDB(e *rdb.Encoder) {
+	for db := range snap.dbs {
+		keys, expires := snap.dbLen(db)
+		if keys == 0 {
+			continue
+		}
+		e.WriteByte(rdb.OpSelectDB)
+		e.WriteLen(uint64(db))
+		e.WriteByte(rdb.OpResizeDB)
+		e.WriteLen(uint64(keys))
+		e.WriteLen(uint64(expires))
+		snap.saveDB(db, e)
+	}
+}
+
+// saveDB writes the keys of a database of the snapshot
+func (snap *Snapshot) saveDB(db int, e *rdb.Encoder) {
+	snap.forEach(db, func(key string, obj *object, expireAt int64) {
+		if expireAt > 0 {
+			e.WriteByte(rdb.OpExpireTimeMs)
+			e.WriteMillis(expireAt)
+		}
+
+		switch obj.kind {
+		case KindString:
+			e.WriteByte(rdb.TypeString)
+			e.WriteString(key)
+			e.WriteString(obj.str)
+		case KindList:
+			e.WriteByte(rdb.TypeList)
+			e.WriteString(key)
+			e.WriteLen(uint64(obj.list.Len()))
+			for _, item := range obj.list.Items() {
+				e.WriteString(item)
+			}
+		case KindHash:
+			e.WriteByte(rdb.TypeHash)
+			e.WriteString(key)
+			e.WriteLen(uint64(len(obj.hash)))
+			for field, value := range obj.hash {
+				e.WriteString(field)
+				e.WriteString(value)
+			}
+		case KindSet:
+			e.WriteByte(rdb.TypeSet)
+			e.WriteString(key)
+			e.WriteLen(uint64(len(obj.set)))
+			for member := range obj.set {
+				e.WriteString(member)
+			}
+		case KindZSet:
+			e.WriteByte(rdb.TypeZSet2)
+			e.WriteString(key)
+			e.WriteLen(uint64(obj.zset.zsl.length))
+			for x := obj.zset.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+				e.WriteString(x.member)
+				e.WriteDouble(x.score)
+			}
+		case KindStream:
+			e.WriteByte(rdb.TypeStream)
+			e.WriteString(key)
+			saveStream(e, obj.stream)
+		}
+	})
+}
+
+// encodeStreamID encodes an ID as 16 big endian bytes, as in the keys of the nodes
+// of a stream and its pending entries lists
+func encodeStreamID(id StreamID) []byte {
+	return binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, id.Ms), id.Seq)
+}
+
+// saveStream writes a stream like Redis does: its entries in listpacks of up to
+// streamNodeMaxEntries entries, keyed by the ID of their first entry, followed by
+// its consumer groups
+func saveStream(e *rdb.Encoder, st *stream) {
+	nodes := (len(st.entries) + streamNodeMaxEntries - 1) / streamNodeMaxEntries
+	e.WriteLen(uint64(nodes))
+	for i := 0; i < len(st.entries); i += streamNodeMaxEntries {
+		entries := st.entries[i:min(i+streamNodeMaxEntries, len(st.entries))]
+		master := entries[0].ID
+
+		// The master entry has no fields, so every entry stores its own
+		lp := rdb.NewListpack()
+		lp.AppendInt(int64(len(entries)))
+		lp.AppendInt(0)
+		lp.AppendInt(0)
+		lp.AppendInt(0)
+		for _, entry := range entries {
+			lp.AppendInt(0)
+			lp.AppendInt(int64(entry.ID.Ms - master.Ms))
+			lp.AppendInt(int64(entry.ID.Seq - master.Seq))
+			lp.AppendInt(int64(len(entry.Fields) / 2))
+			for _, field := range entry.Fields {
+				lp.AppendString(field)
+			}
+			lp.AppendInt(int64(len(entry.Fields) + 4))
+		}
+
+		e.WriteBytes(encodeStreamID(master))
+		e.WriteBytes(lp.Bytes())
+	}
+
+	e.WriteLen(uint64(len(st.entries)))
+	e.WriteLen(st.lastID.Ms)
+	e.WriteLen(st.lastID.Seq)
+
+	e.WriteLen(uint64(len(st.groups)))
+	for name, cg := range st.groups {
+		e.WriteString(name)
+		e.WriteLen(cg.lastID.Ms)
+		e.WriteLen(cg.lastID.Seq)
+
+		e.WriteLen(uint64(len(cg.pending)))
+		for _, id := range cg.sortedPending() {
+			pe := cg.pending[id]
+			e.WriteRaw(encodeStreamID(id))
+			e.WriteMillis(pe.deliveryTime)
+			e.WriteLen(uint64(pe.deliveryCount))
+		}
+
+		e.WriteLen(uint64(len(cg.consumers)))
+		for consumerName, c := range cg.consumers {
+			e.WriteString(consumerName)
+			e.WriteMillis(c.seenTime)
+			e.WriteLen(uint64(len(c.pending)))
+			for id := range c.pending {
+				e.WriteRaw(encodeStreamID(id))
+			}
+		}
+	}
+}
+
+// LoadRDB adds the keys of an RDB file to the databases, reading until the end of the file
+// and checking its checksum
+// Keys that already expired are skipped
+func (d *Databases) LoadRDB(dec *rdb.Decoder) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	db := d.dbs[0]
+	now := Now()
+	expireAt := int64(0)
+	for {
+		opcode, err := dec.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch opcode {
+		case rdb.OpEOF:
+			return dec.VerifyChecksum()
+		case rdb.OpAux:
+			dec.ReadString()
+			dec.ReadString()
+		case rdb.OpSelectDB:
+			index := dec.ReadLen()
+			if index >= uint64(len(d.dbs)) {
+				return fmt.Errorf("RDB has keys in database %d, but there are only %d databases", index, len(d.dbs))
+			}
+			db = d.dbs[index]
+		case rdb.OpResizeDB:
+			dec.ReadLen()
+			dec.ReadLen()
+		case rdb.OpExpireTimeMs:
+			expireAt = dec.ReadMillis()
+		case rdb.OpExpireTime:
+			expireAt = dec.ReadSeconds() * 1000
+		case rdb.OpIdle:
+			dec.ReadLen()
+		case rdb.OpFreq:
+			dec.ReadByte()
+		default:
+			key := dec.ReadString()
+			obj, err := loadObject(dec, opcode)
+			if err != nil {
+				return fmt.Errorf("key %q: %v", key, err)
+			}
+			if expireAt == 0 || expireAt > now {
+				db.store(key, obj)
+				if expireAt > 0 {
+					db.setExpiry(key, expireAt)
+				}
+				d.dirty++
+			}
+			expireAt = 0
+		}
+
+		if err := dec.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// loadObject reads a value of the given type
+func loadObject(d *rdb.Decoder, kind byte) (*object, error) {
+	switch kind {
+	case rdb.TypeString:
+		return &object{kind: KindString, str: d.ReadString()}, d.Err()
+	case rdb.TypeList:
+		n := d.ReadLen()
+		list := newDeque()
+		for i := uint64(0); i < n && d.Err() == nil; i++ {
+			list.PushBack(d.ReadString())
+		}
+		return &object{kind: KindList, list: list}, d.Err()
+	case rdb.TypeHash:
+		n := d.ReadLen()
+		hash := make(map[string]string)
+		for i := uint64(0); i < n && d.Err() == nil; i++ {
+			field := d.ReadString()
+			hash[field] = d.ReadString()
+		}
+		return &object{kind: KindHash, hash: hash}, d.Err()
+	case rdb.TypeSet:
+		n := d.ReadLen()
+		set := make(map[string]struct{})
+		for i := uint64(0); i < n && d.Err() == nil; i++ {
+			set[d.ReadString()] = struct{}{}
+		}
+		return &object{kind: KindSet, set: set}, d.Err()
+	case rdb.TypeZSet2:
+		n := d.ReadLen()
+		z := newZset()
+		for i := uint64(0); i < n && d.Err() == nil; i++ {
+			member := d.ReadString()
+			z.set(member, d.ReadDouble())
+		}
+		return &object{kind: KindZSet, zset: z}, d.Err()
+	case rdb.TypeStream:
+		st, err := loadStream(d)
+		return &object{kind: KindStream, stream: st}, err
+	default:
+		return nil, fmt.Errorf("unsupported RDB type %d", kind)
+	}
+}
+
+// errStreamFormat is returned for a stream that cannot be decoded
+var errStreamFormat = errors.New("invalid stream encoding")
+
+// decodeStreamID decodes an ID encoded by encodeStreamID
+func decodeStreamID(b []byte) (StreamID, error) {
+	if len(b) != 16 {
+		return StreamID{}, errStreamFormat
+	}
+	return StreamID{Ms: binary.BigEndian.Uint64(b), Seq: binary.BigEndian.Uint64(b[8:])}, nil
+}
+
+// loadStream reads a stream written by saveStream, or by Redis with the same type
+func loadStream(d *rdb.Decoder) (*stream, error) {
+	st := newStream()
+	nodes := d.ReadLen()
+	for i := uint64(0); i < nodes && d.Err() == nil; i++ {
+		master, err := decodeStreamID([]byte(d.ReadString()))
+		if err != nil {
+			return nil, err
+		}
+		elements, err := rdb.DecodeListpack([]byte(d.ReadString()))
+		if err != nil {
+			return nil, err
+		}
+		if err := st.loadNode(master, elements); err != nil {
+			return nil, err
+		}
+	}
+
+	d.ReadLen() // Number of entries, known from the listpacks
+	st.lastID = StreamID{Ms: d.ReadLen(), Seq: d.ReadLen()}
+
+	groups := d.ReadLen()
+	for i := uint64(0); i < groups && d.Err() == nil; i++ {
+		name := d.ReadString()
+		cg := &consumerGroup{
+			lastID:    StreamID{Ms: d.ReadLen(), Seq: d.ReadLen()},
+			pending:   make(map[StreamID]*pendingEntry),
+			consumers: make(map[string]*consumer),
+		}
+		st.groups[name] = cg
+
+		pending := d.ReadLen()
+		for j := uint64(0); j < pending && d.Err() == nil; j++ {
+			id, err := decodeStreamID(d.ReadRaw(16))
+			if err != nil {
+				return nil, err
+			}
+			cg.pending[id] = &pendingEntry{deliveryTime: d.ReadMillis(), deliveryCount: int(d.ReadLen())}
+		}
+
+		consumers := d.ReadLen()
+		for j := uint64(0); j < consumers && d.Err() == nil; j++ {
+			consumerName := d.ReadString()
+			c := &consumer{seenTime: d.ReadMillis(), pending: make(map[StreamID]struct{})}
+			cg.consumers[consumerName] = c
+			n := d.ReadLen()
+			for k := uint64(0); k < n && d.Err() == nil; k++ {
+				id, err := decodeStreamID(d.ReadRaw(16))
+				if err != nil {
+					return nil, err
+				}
+				pe, ok := cg.pending[id]
+				if !ok {
+					return nil, errors.New("consumer pending entry missing from the group")
+				}
+				pe.consumer = consumerName
+				c.pending[id] = struct{}{}
+			}
+		}
+	}
+	return st, d.Err()
+}
+
+// loadNode adds the entries of a listpack of a stream, whose master entry has the given ID
+func (st *stream) loadNode(master StreamID, elements []string) error {
+	ints := func(s string) int64 {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	}
+	// next returns the next element, or fails when there are no more
+	pos := 0
+	next := func() (string, error) {
+		if pos >= len(elements) {
+			return "", errStreamFormat
+		}
+		pos++
+		return elements[pos-1], nil
+	}
+
+	// Master entry: count, deleted count, number of fields, fields, 0
+	for i := 0; i < 2; i++ {
+		if _, err := next(); err != nil {
+			return err
+		}
+	}
+	n, err := next()
+	if err != nil {
+		return err
+	}
+	masterFields := make([]string, ints(n))
+	for i := range masterFields {
+		if masterFields[i], err = next(); err != nil {
+			return err
+		}
+	}
+	if _, err := next(); err != nil {
+		return err
+	}
+
+	for pos < len(elements) {
+		values := make([]string, 0, 4)
+		for i := 0; i < 3; i++ {
+			v, err := next()
+			if err != nil {
+				return err
+			}
+			values = append(values, v)
+		}
+		flags := ints(values[0])
+		id := StreamID{Ms: master.Ms + uint64(ints(values[1])), Seq: master.Seq + uint64(ints(values[2]))}
+
+		var fields []string
+		if flags&streamItemSameFields != 0 {
+			for _, field := range masterFields {
+				value, err := next()
+				if err != nil {
+					return err
+				}
+				fields = append(fields, field, value)
+			}
+		} else {
+			n, err := next()
+			if err != nil {
+				return err
+			}
+			for i := int64(0); i < 2*ints(n); i++ {
+				v, err := next()
+				if err != nil {
+					return err
+				}
+				fields = append(fields, v)
+			}
+		}
+		// Number of elements of the entry, to walk the listpack backwards
+		if _, err := next(); err != nil {
+			return err
+		}
+
+		if flags&streamItemDeleted == 0 {
+			st.entries = append(st.entries, StreamEntry{ID: id, Fields: fields})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// https://redis.io/docs/latest/operate/oss_and_stack/management/persistence/#log-rewriting
+package storage
+
+import (
+	"sort"
+	"strconv"
+)
+
+// rewriteItemsPerCommand is the most elements added by one command of a rewrite, like Redis
+const rewriteItemsPerCommand = 64
+
+// Rewrite calls emit with the shortest list of commands that rebuilds the data of the snapshot:
+// for each database that has keys, a SELECT followed by one or a few commands per key and
+// the expiry of the key as an absolute time
+// Expired keys are left out
+func (snap *Snapshot) Rewrite(emit func(args []string)) {
+	for db := range snap.dbs {
+		if keys, _ := snap.dbLen(db); keys > 0 {
+			emit([]string{"SELECT", strconv.Itoa(db)})
+			snap.rewriteDB(db, emit)
+		}
+	}
+}
+
+// rewriteDB emits the commands that rebuild the keys of a database of the snapshot
+func (snap *Snapshot) rewriteDB(db int, emit func(args []string)) {
+	snap.forEach(db, func(key string, obj *object, expireAt int64) {
+		switch obj.kind {
+		case KindString:
+			emit([]string{"SET", key, obj.str})
+		case KindList:
+			emitBatches(emit, []string{"RPUSH", key}, obj.list.Items(), 1)
+		case KindHash:
+			items := make([]string, 0, 2*len(obj.hash))
+			for field, value := range obj.hash {
+				items = append(items, field, value)
+			}
+			emitBatches(emit, []string{"HSET", key}, items, 2)
+		case KindSet:
+			emitBatches(emit, []string{"SADD", key}, setMembers(obj.set), 1)
+		case KindZSet:
+			items := make([]string, 0, 2*obj.zset.zsl.length)
+			for x := obj.zset.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+				items = append(items, strconv.FormatFloat(x.score, 'g', -1, 64), x.member)
+			}
+			emitBatches(emit, []string{"ZADD", key}, items, 2)
+		case KindStream:
+			rewriteStream(emit, key, obj.stream)
+		}
+
+		if expireAt > 0 {
+			emit([]string{"PEXPIREAT", key, strconv.FormatInt(expireAt, 10)})
+		}
+	})
+}
+
+// emitBatches emits the command with the items appended, split into several commands
+// when there are many of them
+// Items go by groups of size, such as the field and value of a hash, which are never split
+func emitBatches(emit func(args []string), command []string, items []string, size int) {
+	for len(items) > 0 {
+		n := min(len(items), rewriteItemsPerCommand*size)
+		emit(append(command[:len(command):len(command)], items[:n]...))
+		items = items[n:]
+	}
+}
+
+// rewriteStream emits the commands that rebuild a stream: its entries, its last ID,
+// and its consumer groups with their consumers and pending entries
+func rewriteStream(emit func(args []string), key string, st *stream) {
+	for _, entry := range st.entries {
+		emit(append([]string{"XADD", key, entry.ID.String()}, entry.Fields...))
+	}
+
+	// A stream emptied by XTRIM keeps its last ID, so IDs generated after a restart still grow
+	mkStream := false
+	if len(st.entries) == 0 {
+		if st.lastID == (StreamID{}) {
+			mkStream = true
+		} else {
+			emit([]string{"XADD", key, "MAXLEN", "0", st.lastID.String(), "", ""})
+		}
+	}
+	if mkStream && len(st.groups) == 0 {
+		// An empty stream that never had an entry only exists through XGROUP CREATE MKSTREAM
+		emit([]string{"XGROUP", "CREATE", key, "rewrite", "0", "MKSTREAM"})
+		emit([]string{"XGROUP", "DESTROY", key, "rewrite"})
+		return
+	}
+
+	for name, cg := range st.groups {
+		create := []string{"XGROUP", "CREATE", key, name, cg.lastID.String()}
+		if mkStream {
+			create = append(create, "MKSTREAM")
+			mkStream = false
+		}
+		emit(create)
+
+		// Consumers without pending entries are created explicitly, the others by XCLAIM
+		for consumerName, c := range cg.consumers {
+			if len(c.pending) == 0 {
+				emit([]string{"XGROUP", "CREATECONSUMER", key, name, consumerName})
+			}
+		}
+		ids := make([]StreamID, 0, len(cg.pending))
+		for id := range cg.pending {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+		for _, id := range ids {
+			p := cg.pending[id]
+			emit([]string{"XCLAIM", key, name, p.consumer, "0", id.String(),
+				"TIME", strconv.FormatInt(p.deliveryTime, 10),
+				"RETRYCOUNT", strconv.Itoa(p.deliveryCount), "FORCE", "JUSTID"})
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// https://redis.io/docs/latest/develop/data-types/sets/
+package storage
+
+import (
+	"math/rand"
+)
+
+// SetOp identifies a multi-key set operation
+type SetOp int
+
+const (
+	SetInter SetOp = iota
+	SetUnion
+	SetDiff
+)
+
+// lookupSet returns the set stored under key
+// If the key does not exist, nil is returned unless mode is accessCreate, in which case an empty set is stored
+// Unless mode is accessRead, the value is copied first if a snapshot shares it
+// Returns ErrWrongType if the key holds another data type
+// The caller must hold the write lock
+func (s *Storage) lookupSet(key string, mode access) (map[string]struct{}, error) {
+	obj := s.lookup(key)
+	if obj == nil {
+		if mode != accessCreate {
+			return nil, nil
+		}
+		obj = &object{kind: KindSet, set: make(map[string]struct{})}
+		s.store(key, obj)
+	}
+	if obj.kind != KindSet {
+		return nil, ErrWrongType
+	}
+	if mode != accessRead {
+		obj = s.writable(key, obj)
+	}
+	return obj.set, nil
+}
+
+// setMembers returns the members of a set as a slice
+func setMembers(set map[string]struct{}) []string {
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	return members
+}
+
+// SAdd adds the members to the set stored at key
+// Returns the number of members that were added
+func (s *Storage) SAdd(key string, members ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key, accessCreate)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, member := range members {
+		if _, ok := set[member]; !ok {
+			set[member] = struct{}{}
+			added++
+		}
+	}
+	if added > 0 {
+		s.touch(key)
+	}
+	return added, nil
+}
+
+// SRem removes the members from the set stored at key
+// The key is deleted once the set is empty
+// Returns the number of members that were removed
+func (s *Storage) SRem(key string, members ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key, accessWrite)
+	if set == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if _, ok := set[member]; ok {
+			delete(set, member)
+			removed++
+		}
+	}
+	if len(set) == 0 {
+		s.delete(key)
+	}
+	if removed > 0 {
+		s.touch(key)
+	}
+	return removed, nil
+}
+
+// SMembers returns all members of the set stored at key
+func (s *Storage) SMembers(key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key, accessRead)
+	if err != nil {
+		return nil, err
+	}
+	return setMembers(set), nil
+}
+
+// SIsMember reports whether member belongs to the set stored at key
+func (s *Storage) SIsMember(key, member string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key, accessRead)
+	if set == nil {
+		return false, err
+	}
+	_, ok := set[member]
+	return ok, nil
+}
+
+// SMIsMember reports, for every member, whether it belongs to the set stored at key
+func (s *Storage) SMIsMember(key string, members ...string) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key, accessRead)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(members))
+	for i, member := range members {
+		_, found[i] = set[member]
+	}
+	return found, nil
+}
+
+// SCard returns the number of members of the set stored at key
+func (s *Storage) SCard(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key, accessRead)
+	return len(set), err
+}
+
+// SPop removes and returns up to count random members from the set stored at key
+// Returns nil if the key does not exist
+func (s *Storage) SPop(key string, count int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key, accessWrite)
+	if set == nil {
+		return nil, err
+	}
+	members := randomMembers(set, count)
+	for _, member := range members {
+		delete(set, member)
+	}
+	if len(set) == 0 {
+		s.delete(key)
+	}
+	if len(members) > 0 {
+		s.touch(key)
+	}
+	return members, nil
+}
+
+// SRandMember returns random members from the set stored at key without removing them
+// A positive count returns up to count distinct members, while a negative count
+// returns exactly -count members that may repeat
+// Returns nil if the key does not exist
+func (s *Storage) SRandMember(key string, count int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key, accessRead)
+	if set == nil {
+		return nil, err
+	}
+	if count >= 0 {
+		return randomMembers(set, count), nil
+	}
+
+	all := setMembers(set)
+	members := make([]string, -count)
+	for i := range members {
+		members[i] = all[rand.Intn(len(all))]
+	}
+	return members, nil
+}
+
+// randomMembers picks up to count distinct random members of a set
+func randomMembers(set map[string]struct{}, count int) []string {
+	members := setMembers(set)
+	if count >= len(members) {
+		return members
+	}
+	// Partial Fisher-Yates shuffle: only the first count positions are needed
+	for i := 0; i < count; i++ {
+		j := i + rand.Intn(len(members)-i)
+		members[i], members[j] = members[j], members[i]
+	}
+	return members[:count]
+}
+
+// setOperation computes the intersection, union or difference of the sets stored at keys
+// Missing keys are treated as empty sets
+// The caller must hold the write lock
+func (s *Storage) setOperation(op SetOp, keys []string) (map[string]struct{}, error) {
+	sets := make([]map[string]struct{}, len(keys))
+	for i, key := range keys {
+		set, err := s.lookupSet(key, accessRead)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+
+	result := make(map[string]struct{})
+	switch op {
+	case SetInter:
+		// Iterate the smallest set and probe the others
+		smallest := sets[0]
+		for _, set := range sets[1:] {
+			if len(set) < len(smallest) {
+				smallest = set
+			}
+		}
+	members:
+		for member := range smallest {
+			for _, set := range sets {
+				if _, ok := set[member]; !ok {
+					continue members
+				}
+			}
+			result[member] = struct{}{}
+		}
+	case SetUnion:
+		for _, set := range sets {
+			for member := range set {
+				result[member] = struct{}{}
+			}
+		}
+	case SetDiff:
+		for member := range sets[0] {
+			result[member] = struct{}{}
+		}
+		for _, set := range sets[1:] {
+			for member := range set {
+				delete(result, member)
+			}
+		}
+	}
+	return result, nil
+}
+
+// SetOperation returns the intersection, union or difference of the sets stored at keys
+// All sets are read under a single lock, so the result is consistent
+func (s *Storage) SetOperation(op SetOp, keys ...string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.setOperation(op, keys)
+	if err != nil {
+		return nil, err
+	}
+	return setMembers(result), nil
+}
+
+// SetOperationStore computes the intersection, union or difference of the sets stored at keys
+// and atomically stores it in destination, replacing any existing value
+// An empty result deletes destination
+// Returns the number of members in the resulting set
+func (s *Storage) SetOperationStore(op SetOp, destination string, keys ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.setOperation(op, keys)
+	if err != nil {
+		return 0, err
+	}
+	s.delete(destination)
+	if len(result) > 0 {
+		s.store(destination, &object{kind: KindSet, set: result})
+	}
+	return len(result), nil
+}
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"math/rand"
+)
+
+// The skiplist is a port of the one Redis uses for sorted sets (t_zset.c)
+// Elements are ordered by score, then by member, and every forward link stores
+// its span (the number of nodes it skips), which makes rank queries O(log n)
+const (
+	skiplistMaxLevel = 32   // Enough for 2^64 elements
+	skiplistP        = 0.25 // Probability of a node having one more level
+)
+
+// skiplistLevel is a forward link of a node at a given level
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int
+}
+
+// skiplistNode is a member of a sorted set with its score
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+// skiplist is an ordered collection of (score, member) pairs
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+// newSkiplist creates an empty skiplist
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skiplistNode{level: make([]skiplistLevel, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+// randomLevel returns a level for a new node, with a powerlaw-alike distribution
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// before reports whether the node sorts before the given score and member
+func (n *skiplistNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// insert adds a new node, the member must not already be in the skiplist
+func (zsl *skiplist) insert(score float64, member string) *skiplistNode {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		// Store the rank that is crossed to reach the insert position
+		if i < zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+
+		// Update the span covered by update[i] now that x is inserted after it
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+
+	// Increment the span of untouched levels
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+	return x
+}
+
+// deleteNode unlinks x, given the nodes that precede it at every level
+func (zsl *skiplist) deleteNode(x *skiplistNode, update []*skiplistNode) {
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+// delete removes the node with the given score and member
+// Returns false if it was not found
+func (zsl *skiplist) delete(score float64, member string) bool {
+	update := make([]*skiplistNode, skiplistMaxLevel)
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	zsl.deleteNode(x, update)
+	return true
+}
+
+// rank returns the 1-based rank of the node with the given score and member,
+// or 0 if it was not found
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.before(score, member) || (x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node at the given 1-based rank, or nil if it is out of range
+func (zsl *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstMatching returns the first node that is not below the lower bound of the range,
+// provided it is also within the upper bound
+func (zsl *skiplist) firstMatching(aboveMin, belowMax func(*skiplistNode) bool) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !aboveMin(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !belowMax(x) {
+		return nil
+	}
+	return x
+}
+
+// lastMatching returns the last node that is not above the upper bound of the range,
+// provided it is also within the lower bound
+func (zsl *skiplist) lastMatching(aboveMin, belowMax func(*skiplistNode) bool) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && belowMax(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	if x == zsl.header || !aboveMin(x) {
+		return nil
+	}
+	return x
+}
+
+// firstInScoreRange returns the first node with a score in the range
+func (zsl *skiplist) firstInScoreRange(r ScoreRange) *skiplistNode {
+	return zsl.firstMatching(
+		func(n *skiplistNode) bool { return r.aboveMin(n.score) },
+		func(n *skiplistNode) bool { return r.belowMax(n.score) },
+	)
+}
+
+// lastInScoreRange returns the last node with a score in the range
+func (zsl *skiplist) lastInScoreRange(r ScoreRange) *skiplistNode {
+	return zsl.lastMatching(
+		func(n *skiplistNode) bool { return r.aboveMin(n.score) },
+		func(n *skiplistNode) bool { return r.belowMax(n.score) },
+	)
+}
+
+// firstInLexRange returns the first node with a member in the range
+func (zsl *skiplist) firstInLexRange(r LexRange) *skiplistNode {
+	return zsl.firstMatching(
+		func(n *skiplistNode) bool { return r.Min.aboveMin(n.member) },
+		func(n *skiplistNode) bool { return r.Max.belowMax(n.member) },
+	)
+}
+
+// lastInLexRange returns the last node with a member in the range
+func (zsl *skiplist) lastInLexRange(r LexRange) *skiplistNode {
+	return zsl.lastMatching(
+		func(n *skiplistNode) bool { return r.Min.aboveMin(n.member) },
+		func(n *skiplistNode) bool { return r.Max.belowMax(n.member) },
+	)
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"sync"
+)
+
+// The keys are spread over shards, so that a snapshot only has to copy the list of shards
+// Like the pages of a forked Redis process, a shard or a value shared with a snapshot is
+// copied the first time it is modified, and the snapshot keeps reading the original
+const shardCount = 256
+
+// shard holds the keys whose hash falls into it
+type shard struct {
+	data    map[string]*object // Internal map to store key-value pairs
+	expires map[string]int64   // Absolute expiry time (unix milliseconds) of keys that have a TTL
+	epoch   uint64             // Epoch of the storage when the shard was created or copied
+}
+
+// newShard creates an empty shard
+func newShard(epoch uint64) *shard {
+	return &shard{data: make(map[string]*object), expires: make(map[string]int64), epoch: epoch}
+}
+
+// shardIndex returns the shard of a key, using the FNV-1a hash of the key
+func shardIndex(key string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % shardCount)
+}
+
+// access tells the typed lookups what the caller does with the value
+type access int
+
+const (
+	accessRead   access = iota // The value is only read
+	accessWrite                // The value is modified, so it is copied first if a snapshot shares it
+	accessCreate               // Like accessWrite, and an empty value is stored if the key does not exist
+)
+
+// shared reports whether something created or copied at the given epoch may be read by
+// a snapshot, so it must be copied before being modified
+// The caller must hold the lock
+func (s *Storage) shared(epoch uint64) bool {
+	return s.snapshots > 0 && epoch < s.epoch
+}
+
+// object returns the value stored under key, even if it expired, or nil
+// The value must not be modified, see writable
+// The caller must hold the lock
+func (s *Storage) object(key string) *object {
+	return s.shards[shardIndex(key)].data[key]
+}
+
+// expiry returns the absolute expiry time of a key, and whether it has one
+// The caller must hold the lock
+func (s *Storage) expiry(key string) (int64, bool) {
+	at, ok := s.shards[shardIndex(key)].expires[key]
+	return at, ok
+}
+
+// writableShard returns the shard of a key, after copying it if a snapshot shares it
+// The caller must hold the write lock
+func (s *Storage) writableShard(key string) *shard {
+	i := shardIndex(key)
+	sh := s.shards[i]
+	if !s.shared(sh.epoch) {
+		return sh
+	}
+
+	copied := &shard{
+		data:    make(map[string]*object, len(sh.data)),
+		expires: make(map[string]int64, len(sh.expires)),
+		epoch:   s.epoch,
+	}
+	for key, obj := range sh.data {
+		copied.data[key] = obj
+	}
+	for key, at := range sh.expires {
+		copied.expires[key] = at
+	}
+	s.shards[i] = copied
+	return copied
+}
+
+// writable returns the value stored under key, which is obj, in a state it can be modified:
+// if a snapshot shares it, it is replaced by a copy which is returned
+// The caller must hold the write lock
+func (s *Storage) writable(key string, obj *object) *object {
+	if !s.shared(obj.epoch) {
+		return obj
+	}
+	copied := obj.clone()
+	copied.epoch = s.epoch
+	s.writableShard(key).data[key] = copied
+	return copied
+}
+
+// store stores a new value under key, keeping its expiry
+// The caller must hold the write lock
+func (s *Storage) store(key string, obj *object) {
+	obj.epoch = s.epoch
+	s.writableShard(key).data[key] = obj
+}
+
+// setExpiry sets the absolute expiry time of a key
+// The caller must hold the write lock
+func (s *Storage) setExpiry(key string, at int64) {
+	s.writableShard(key).expires[key] = at
+}
+
+// clearExpiry removes the expiry of a key
+// The caller must hold the write lock
+func (s *Storage) clearExpiry(key string) {
+	if _, ok := s.expiry(key); ok {
+		delete(s.writableShard(key).expires, key)
+	}
+}
+
+// clone returns a deep copy of the object
+func (obj *object) clone() *object {
+	c := &object{kind: obj.kind, str: obj.str}
+	switch obj.kind {
+	case KindList:
+		c.list = newDeque()
+		c.list.Reset(obj.list.Items())
+	case KindHash:
+		c.hash = make(map[string]string, len(obj.hash))
+		for field, value := range obj.hash {
+			c.hash[field] = value
+		}
+	case KindSet:
+		c.set = make(map[string]struct{}, len(obj.set))
+		for member := range obj.set {
+			c.set[member] = struct{}{}
+		}
+	case KindZSet:
+		c.zset = newZset()
+		for x := obj.zset.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+			c.zset.set(x.member, x.score)
+		}
+	case KindStream:
+		c.stream = obj.stream.clone()
+	}
+	return c
+}
+
+// clone returns a deep copy of the stream
+// The fields of the entries are shared, since entries are never modified once added
+func (st *stream) clone() *stream {
+	c := &stream{entries: append([]StreamEntry(nil), st.entries...), lastID: st.lastID, groups: make(map[string]*consumerGroup, len(st.groups))}
+	for name, cg := range st.groups {
+		group := &consumerGroup{
+			lastID:    cg.lastID,
+			pending:   make(map[StreamID]*pendingEntry, len(cg.pending)),
+			consumers: make(map[string]*consumer, len(cg.consumers)),
+		}
+		for id, pe := range cg.pending {
+			copied := *pe
+			group.pending[id] = &copied
+		}
+		for consumerName, cons := range cg.consumers {
+			copied := &consumer{seenTime: cons.seenTime, pending: make(map[StreamID]struct{}, len(cons.pending))}
+			for id := range cons.pending {
+				copied.pending[id] = struct{}{}
+			}
+			group.consumers[consumerName] = copied
+		}
+		c.groups[name] = group
+	}
+	return c
+}
+
+// Snapshot is a point-in-time view of the keys of every database
+// It is read without holding any lock, while the databases keep changing
+type Snapshot struct {
+	databases *Databases
+	dbs       [][shardCount]*shard // Shards of each database
+	now       int64                // Time the snapshot was taken, the keys that expired by then are left out
+	release   sync.Once
+}
+
+// Snapshot takes a point-in-time view of the keys, which only copies the lists of shards
+// Release must be called once the snapshot is no longer read: until then, the databases
+// copy each shard and value before modifying it for the first time
+func (d *Databases) Snapshot() *Snapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	snap := &Snapshot{databases: d, dbs: make([][shardCount]*shard, len(d.dbs)), now: Now()}
+	for i, db := range d.dbs {
+		snap.dbs[i] = db.shards
+	}
+	// Everything created before the snapshot now has an older epoch, so it is shared
+	d.epoch++
+	d.snapshots++
+	return snap
+}
+
+// Release lets the databases modify the shards and values the snapshot shares in place again
+// Releasing it again does nothing
+func (snap *Snapshot) Release() {
+	snap.release.Do(func() {
+		snap.databases.mu.Lock()
+		defer snap.databases.mu.Unlock()
+		snap.databases.snapshots--
+	})
+}
+
+// Len returns the number of keys in the snapshot, including the ones that expired
+func (snap *Snapshot) Len() (keys, expires int) {
+	for db := range snap.dbs {
+		k, e := snap.dbLen(db)
+		keys += k
+		expires += e
+	}
+	return keys, expires
+}
+
+// dbLen returns the number of keys of a database in the snapshot, including the ones that expired
+func (snap *Snapshot) dbLen(db int) (keys, expires int) {
+	for _, sh := range snap.dbs[db] {
+		keys += len(sh.data)
+		expires += len(sh.expires)
+	}
+	return keys, expires
+}
+
+// forEach calls fn for every key of a database in the snapshot that was not expired,
+// with its value and its expiry time, which is 0 if it has none
+func (snap *Snapshot) forEach(db int, fn func(key string, obj *object, expireAt int64)) {
+	for _, sh := range snap.dbs[db] {
+		for key, obj := range sh.data {
+			at := sh.expires[key]
+			if at != 0 && at <= snap.now {
+				continue
+			}
+			fn(key, obj, at)
+		}
+	}
+}
@@ -1,38 +1,146 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
-	"sync"
 )
 
-// Storage represents the in-memory key-value store
+var (
+	// ErrSyntax is returned when a combination of options is not valid
+	ErrSyntax = errors.New("ERR syntax error")
+	// ErrWrongType is returned when an operation is applied to a key holding another data type
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
+// Kind identifies the data type held by a key
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindList
+	KindHash
+	KindSet
+	KindZSet
+	KindStream
+)
+
+// String returns the name of the kind as reported by the TYPE command
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindList:
+		return "list"
+	case KindHash:
+		return "hash"
+	case KindSet:
+		return "set"
+	case KindZSet:
+		return "zset"
+	case KindStream:
+		return "stream"
+	default:
+		return "none"
+	}
+}
+
+// object is the value stored under a key, tagged with its data type
+// Only the field matching kind is used
+type object struct {
+	kind   Kind
+	str    string              // KindString
+	list   *deque              // KindList
+	hash   map[string]string   // KindHash
+	set    map[string]struct{} // KindSet
+	zset   *zset               // KindZSet
+	stream *stream             // KindStream
+	epoch  uint64              // Epoch of the storage when the object was created or copied
+}
+
+// Storage represents a database of the in-memory key-value store
+// The databases of a server share their locks through Databases, which they embed
 type Storage struct {
-	data map[string]string // Internal map to store key-value pairs
-	mu   sync.RWMutex      // Read-Write mutex for thread-safe operations
+	*Databases
+	index   int                    // Index of the database, as given to SELECT
+	shards  [shardCount]*shard     // Keys with their values and expiries, spread by the hash of the key
+	watched map[string]*watchedKey // Modification versions of the keys watched by transactions
+	blocked map[string]int         // Number of clients blocked on each key
+	ready   []string               // Blocked keys modified since the last call to ReadyKeys
 }
 
-// NewStorage creates and returns a new Storage instance
+// NewStorage creates and returns a new Storage instance, as the only database of its Databases
 func NewStorage() *Storage {
-	return &Storage{
-		data: make(map[string]string),
-	}
+	return NewDatabases(1).DB(0)
+}
+
+// SetOptions holds the optional arguments of the SET command
+type SetOptions struct {
+	NX       bool  // Only set the key if it does not already exist
+	XX       bool  // Only set the key if it already exists
+	Get      bool  // The previous value is returned, so it must be a string
+	KeepTTL  bool  // Retain the time to live associated with the key
+	ExpireAt int64 // Absolute expiry time in unix milliseconds, 0 means no expiry
 }
 
 // Set stores a key-value pair in the storage
+// Any existing time to live on the key is discarded
 func (s *Storage) Set(key, value string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.data[key] = value
+	s.store(key, &object{kind: KindString, str: value})
+	s.clearExpiry(key)
+	s.touch(key)
+}
+
+// SetWithOptions stores a key-value pair honouring the SET command options
+// Returns the previous value, whether the key existed and whether the value was stored
+func (s *Storage) SetWithOptions(key, value string, opts SetOptions) (string, bool, bool, error) {
+	if opts.NX && opts.XX {
+		return "", false, false, ErrSyntax
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.lookup(key)
+	exists := current != nil
+	old := ""
+	if exists {
+		if opts.Get && current.kind != KindString {
+			return "", false, false, ErrWrongType
+		}
+		old = current.str
+	}
+	if (opts.NX && exists) || (opts.XX && !exists) {
+		return old, exists, false, nil
+	}
+
+	s.store(key, &object{kind: KindString, str: value})
+	switch {
+	case opts.ExpireAt > 0:
+		s.setExpiry(key, opts.ExpireAt)
+	case !opts.KeepTTL:
+		s.clearExpiry(key)
+	}
+	s.touch(key)
+	return old, exists, true, nil
 }
 
-// Get retrieves the value associated with the given key
-// Returns the value and a boolean indicating if the key exists
-func (s *Storage) Get(key string) (string, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	value, ok := s.data[key]
-	return value, ok
+// Get retrieves the string value associated with the given key
+// Returns the value, a boolean indicating if the key exists and
+// ErrWrongType if the key holds another data type
+func (s *Storage) Get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj := s.lookup(key)
+	if obj == nil {
+		return "", false, nil
+	}
+	if obj.kind != KindString {
+		return "", false, ErrWrongType
+	}
+	return obj.str, true, nil
 }
 
 // Del removes the specified key from the storage
@@ -40,21 +148,21 @@ func (s *Storage) Get(key string) (string, bool) {
 func (s *Storage) Del(key string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, ok := s.data[key]
-	if ok {
-		delete(s.data, key)
+	if s.lookup(key) == nil {
+		return false
 	}
-	return ok
+	s.delete(key)
+	return true
 }
 
 // Exists checks if the specified keys exist in the storage
 // Returns the count of existing keys
 func (s *Storage) Exists(keys ...string) int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	count := 0
 	for _, key := range keys {
-		if _, ok := s.data[key]; ok {
+		if s.lookup(key) != nil {
 			count++
 		}
 	}
@@ -68,10 +176,12 @@ func (s *Storage) IncrBy(key string, amount int) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	value, ok := s.data[key]
-	if !ok {
-		s.data[key] = "0"
-		value = "0"
+	value := "0"
+	if obj := s.lookup(key); obj != nil {
+		if obj.kind != KindString {
+			return 0, ErrWrongType
+		}
+		value = obj.str
 	}
 
 	intValue, err := strconv.Atoi(value)
@@ -80,7 +190,37 @@ func (s *Storage) IncrBy(key string, amount int) (int, error) {
 	}
 
 	newValue := intValue + amount
-	s.data[key] = strconv.Itoa(newValue)
+	s.setString(key, strconv.Itoa(newValue))
 
 	return newValue, nil
 }
+
+// lookup returns the object stored under key, or nil if the key does not exist
+// Expired keys are deleted on access
+// The caller must hold the write lock
+func (s *Storage) lookup(key string) *object {
+	if s.expireIfNeeded(key) {
+		return nil
+	}
+	return s.object(key)
+}
+
+// setString overwrites the value of a string key, keeping its time to live
+// The caller must hold the write lock
+func (s *Storage) setString(key, value string) {
+	s.touch(key)
+	if obj := s.object(key); obj != nil && obj.kind == KindString {
+		s.writable(key, obj).str = value
+		return
+	}
+	s.store(key, &object{kind: KindString, str: value})
+}
+
+// delete removes a key together with its expiry
+// The caller must hold the write lock
+func (s *Storage) delete(key string) {
+	sh := s.writableShard(key)
+	delete(sh.data, key)
+	delete(sh.expires, key)
+	s.touch(key)
+}
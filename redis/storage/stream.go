@@ -0,0 +1,858 @@
+// https://redis.io/docs/latest/develop/data-types/streams/
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidStreamID is returned when an argument is not a valid stream ID
+	ErrInvalidStreamID = errors.New("ERR Invalid stream ID specified as stream command argument")
+	// ErrStreamIDTooSmall is returned by XADD when the ID is not greater than the last one
+	ErrStreamIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	// ErrStreamIDZero is returned by XADD when the ID is 0-0
+	ErrStreamIDZero = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	// ErrStreamExhausted is returned by XADD when no greater ID can be generated
+	ErrStreamExhausted = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+	// ErrBusyGroup is returned by XGROUP CREATE when the group already exists
+	ErrBusyGroup = errors.New("BUSYGROUP Consumer Group name already exists")
+	// ErrXGroupNoKey is returned by XGROUP when the stream does not exist
+	ErrXGroupNoKey = errors.New("ERR The XGROUP subcommand requires the key to exist. " +
+		"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+)
+
+// StreamID identifies an entry of a stream, as milliseconds and a sequence number
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// MaxStreamID is the greatest possible stream ID
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// String formats the ID as "ms-seq"
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Less reports whether id sorts before other
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+
+// Next returns the smallest ID greater than id
+// Returns false if id is already the greatest possible ID
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	default:
+		return id, false
+	}
+}
+
+// Prev returns the greatest ID smaller than id
+// Returns false if id is 0-0
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+// ParseStreamID parses an ID in the "ms-seq" or "ms" form
+// When the sequence part is missing, missingSeq is used instead
+func ParseStreamID(arg string, missingSeq uint64) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(arg, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// StreamEntry is an entry of a stream
+// Fields holds the field-value pairs, it is nil for an entry that was deleted
+// but is still referenced by a pending entries list
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// StreamEntries are the entries read from one stream by XREAD or XREADGROUP
+type StreamEntries struct {
+	Key     string
+	Entries []StreamEntry
+}
+
+// pendingEntry is an entry that was delivered to a consumer but not acknowledged yet
+type pendingEntry struct {
+	consumer      string
+	deliveryTime  int64 // Unix milliseconds of the last delivery
+	deliveryCount int
+}
+
+// consumer is a member of a consumer group
+type consumer struct {
+	seenTime int64                 // Unix milliseconds of the last interaction
+	pending  map[StreamID]struct{} // IDs of the entries pending for this consumer
+}
+
+// consumerGroup tracks what was delivered to the consumers reading a stream together
+type consumerGroup struct {
+	lastID    StreamID                   // Last ID delivered to the group
+	pending   map[StreamID]*pendingEntry // Pending entries list (PEL)
+	consumers map[string]*consumer
+}
+
+// stream is an append-only log of entries ordered by ID
+type stream struct {
+	entries []StreamEntry
+	lastID  StreamID
+	groups  map[string]*consumerGroup
+}
+
+// newStream creates an empty stream
+func newStream() *stream {
+	return &stream{groups: make(map[string]*consumerGroup)}
+}
+
+// search returns the index of the first entry with an ID not less than id
+func (st *stream) search(id StreamID) int {
+	return sort.Search(len(st.entries), func(i int) bool { return !st.entries[i].ID.Less(id) })
+}
+
+// entry returns the entry with the given ID
+func (st *stream) entry(id StreamID) (StreamEntry, bool) {
+	i := st.search(id)
+	if i < len(st.entries) && st.entries[i].ID == id {
+		return st.entries[i], true
+	}
+	return StreamEntry{}, false
+}
+
+// rangeEntries returns up to count entries between start and end, both inclusive
+// A count of 0 or less means no limit
+func (st *stream) rangeEntries(start, end StreamID, count int, reverse bool) []StreamEntry {
+	entries := []StreamEntry{}
+	if end.Less(start) {
+		return entries
+	}
+	from, to := st.search(start), st.search(end)
+	if to < len(st.entries) && st.entries[to].ID == end {
+		to++
+	}
+	for i := from; i < to && (count <= 0 || len(entries) < count); i++ {
+		j := i
+		if reverse {
+			j = to - 1 - (i - from)
+		}
+		entries = append(entries, st.entries[j])
+	}
+	return entries
+}
+
+// TrimStrategy selects how a stream is trimmed
+type TrimStrategy int
+
+const (
+	TrimNone   TrimStrategy = iota
+	TrimMaxLen              // Keep at most MaxLen entries
+	TrimMinID               // Evict entries with an ID lower than MinID
+)
+
+// TrimOptions holds the MAXLEN and MINID arguments of XADD and XTRIM
+// The approximate "~" form is accepted but trimming is always exact,
+// Limit (when positive) caps the number of evicted entries
+type TrimOptions struct {
+	Strategy TrimStrategy
+	MaxLen   int
+	MinID    StreamID
+	Limit    int
+}
+
+// trim evicts entries from the head of the stream according to opts
+// Returns the number of evicted entries
+func (st *stream) trim(opts TrimOptions) int {
+	n := 0
+	switch opts.Strategy {
+	case TrimMaxLen:
+		n = max(len(st.entries)-opts.MaxLen, 0)
+	case TrimMinID:
+		n = st.search(opts.MinID)
+	}
+	if opts.Limit > 0 {
+		n = min(n, opts.Limit)
+	}
+	if n > 0 {
+		// Copy the remaining entries so the evicted ones can be garbage collected
+		st.entries = append([]StreamEntry(nil), st.entries[n:]...)
+	}
+	return n
+}
+
+// XAddID is the ID argument of XADD: fully explicit, "ms-*" (AutoSeq) or "*" (AutoMs and AutoSeq)
+type XAddID struct {
+	ID      StreamID
+	AutoMs  bool
+	AutoSeq bool
+}
+
+// nextID computes the ID of a new entry, which must be greater than the last one
+func (st *stream) nextID(spec XAddID) (StreamID, error) {
+	last := st.lastID
+	switch {
+	case spec.AutoMs:
+		ms := uint64(Now())
+		if ms > last.Ms {
+			return StreamID{Ms: ms}, nil
+		}
+		id, ok := last.Next()
+		if !ok {
+			return StreamID{}, ErrStreamExhausted
+		}
+		return id, nil
+	case spec.AutoSeq:
+		if spec.ID.Ms > last.Ms {
+			return StreamID{Ms: spec.ID.Ms}, nil
+		}
+		if spec.ID.Ms < last.Ms || last.Seq == math.MaxUint64 {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return StreamID{Ms: last.Ms, Seq: last.Seq + 1}, nil
+	default:
+		if spec.ID == (StreamID{}) {
+			return StreamID{}, ErrStreamIDZero
+		}
+		if !last.Less(spec.ID) {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return spec.ID, nil
+	}
+}
+
+// lookupStream returns the stream stored under key
+// If the key does not exist, nil is returned unless mode is accessCreate, in which case an empty stream is stored
+// Unless mode is accessRead, the value is copied first if a snapshot shares it
+// Returns ErrWrongType if the key holds another data type
+// The caller must hold the write lock
+func (s *Storage) lookupStream(key string, mode access) (*stream, error) {
+	obj := s.lookup(key)
+	if obj == nil {
+		if mode != accessCreate {
+			return nil, nil
+		}
+		obj = &object{kind: KindStream, stream: newStream()}
+		s.store(key, obj)
+	}
+	if obj.kind != KindStream {
+		return nil, ErrWrongType
+	}
+	if mode != accessRead {
+		obj = s.writable(key, obj)
+	}
+	return obj.stream, nil
+}
+
+// lookupGroup returns a consumer group of the stream stored under key
+// Unless mode is accessRead, the stream is copied first if a snapshot shares it
+// Returns a NOGROUP error if the stream or the group does not exist
+// The caller must hold the write lock
+func (s *Storage) lookupGroup(key, group, command string, mode access) (*stream, *consumerGroup, error) {
+	st, err := s.lookupStream(key, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if st != nil {
+		if cg, ok := st.groups[group]; ok {
+			return st, cg, nil
+		}
+	}
+	if command == "XREADGROUP" {
+		return nil, nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
+	}
+	return nil, nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+// XAdd appends an entry to the stream stored at key, creating the stream unless noMkStream is set
+// The stream is then trimmed according to trim
+// Returns the ID of the new entry, or false if the stream does not exist and noMkStream is set
+func (s *Storage) XAdd(key string, spec XAddID, fields []string, noMkStream bool, trim TrimOptions) (StreamID, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.lookupStream(key, accessWrite)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	created := st == nil
+	if created {
+		if noMkStream {
+			return StreamID{}, false, nil
+		}
+		st = newStream()
+	}
+
+	id, err := st.nextID(spec)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if created {
+		s.store(key, &object{kind: KindStream, stream: st})
+	}
+	st.entries = append(st.entries, StreamEntry{ID: id, Fields: append([]string(nil), fields...)})
+	st.lastID = id
+	st.trim(trim)
+	s.touch(key)
+	return id, true, nil
+}
+
+// XLen returns the number of entries in the stream stored at key
+func (s *Storage) XLen(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.lookupStream(key, accessRead)
+	if st == nil {
+		return 0, err
+	}
+	return len(st.entries), nil
+}
+
+// XLastID returns the ID of the last entry added to the stream stored at key, which
+// trimming and deleting entries do not change, and 0-0 if the key does not exist
+func (s *Storage) XLastID(key string) (StreamID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.lookupStream(key, accessRead)
+	if st == nil {
+		return StreamID{}, err
+	}
+	return st.lastID, nil
+}
+
+// XRange returns up to count entries with an ID between start and end, both inclusive
+// With reverse, entries are returned from the greatest ID
+func (s *Storage) XRange(key string, start, end StreamID, count int, reverse bool) ([]StreamEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.lookupStream(key, accessRead)
+	if st == nil {
+		return []StreamEntry{}, err
+	}
+	return st.rangeEntries(start, end, count, reverse), nil
+}
+
+// XTrim trims the stream stored at key according to opts
+// Returns the number of evicted entries
+func (s *Storage) XTrim(key string, opts TrimOptions) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.lookupStream(key, accessWrite)
+	if st == nil {
+		return 0, err
+	}
+	evicted := st.trim(opts)
+	if evicted > 0 {
+		s.touch(key)
+	}
+	return evicted, nil
+}
+
+// StreamRead is a stream and the ID after which XREAD or XREADGROUP reads it
+type StreamRead struct {
+	Key  string
+	ID   StreamID
+	Last bool // "$": only entries added after the call
+	New  bool // ">": entries never delivered to the group
+}
+
+// XRead returns up to count entries with an ID greater than the given one from each stream
+// Streams without new entries are left out of the result
+func (s *Storage) XRead(reads []StreamRead, count int) ([]StreamEntries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []StreamEntries
+	for _, read := range reads {
+		st, err := s.lookupStream(read.Key, accessRead)
+		if err != nil {
+			return nil, err
+		}
+		if st == nil || read.Last {
+			continue
+		}
+		start, ok := read.ID.Next()
+		if !ok {
+			continue
+		}
+		if entries := st.rangeEntries(start, MaxStreamID, count, false); len(entries) > 0 {
+			result = append(result, StreamEntries{Key: read.Key, Entries: entries})
+		}
+	}
+	return result, nil
+}
+
+// XGroupCreate creates a consumer group that will deliver entries after id,
+// or after the last entry if last is set
+// With mkStream an empty stream is created when the key does not exist
+func (s *Storage) XGroupCreate(key, group string, id StreamID, last, mkStream bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mode := accessWrite
+	if mkStream {
+		mode = accessCreate
+	}
+	st, err := s.lookupStream(key, mode)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		return ErrXGroupNoKey
+	}
+	if _, ok := st.groups[group]; ok {
+		return ErrBusyGroup
+	}
+	if last {
+		id = st.lastID
+	}
+	st.groups[group] = &consumerGroup{
+		lastID:    id,
+		pending:   make(map[StreamID]*pendingEntry),
+		consumers: make(map[string]*consumer),
+	}
+	s.touch(key)
+	return nil
+}
+
+// XGroupSetID sets the last delivered ID of a consumer group
+func (s *Storage) XGroupSetID(key, group string, id StreamID, last bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.lookupStream(key, accessWrite)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		return ErrXGroupNoKey
+	}
+	cg, ok := st.groups[group]
+	if !ok {
+		return fmt.Errorf("NOGROUP No such consumer group '%s' for key name '%s'", group, key)
+	}
+	if last {
+		id = st.lastID
+	}
+	cg.lastID = id
+	s.touch(key)
+	return nil
+}
+
+// XGroupDestroy deletes a consumer group
+// Returns false if the group did not exist
+func (s *Storage) XGroupDestroy(key, group string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.lookupStream(key, accessWrite)
+	if err != nil {
+		return false, err
+	}
+	if st == nil {
+		return false, ErrXGroupNoKey
+	}
+	if _, ok := st.groups[group]; !ok {
+		return false, nil
+	}
+	delete(st.groups, group)
+	s.touch(key)
+	return true, nil
+}
+
+// XGroupCreateConsumer adds a consumer to a consumer group
+// Returns false if the consumer already existed
+func (s *Storage) XGroupCreateConsumer(key, group, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, cg, err := s.lookupGroup(key, group, "XGROUP", accessWrite)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := cg.consumers[name]; ok {
+		return false, nil
+	}
+	cg.consumer(name)
+	s.touch(key)
+	return true, nil
+}
+
+// XGroupDelConsumer removes a consumer from a consumer group, together with its pending entries
+// Returns the number of pending entries the consumer had
+func (s *Storage) XGroupDelConsumer(key, group, name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, cg, err := s.lookupGroup(key, group, "XGROUP", accessWrite)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := cg.consumers[name]
+	if !ok {
+		return 0, nil
+	}
+	for id := range c.pending {
+		delete(cg.pending, id)
+	}
+	delete(cg.consumers, name)
+	s.touch(key)
+	return len(c.pending), nil
+}
+
+// consumer returns the named consumer, creating it if needed
+func (cg *consumerGroup) consumer(name string) *consumer {
+	c, ok := cg.consumers[name]
+	if !ok {
+		c = &consumer{pending: make(map[StreamID]struct{})}
+		cg.consumers[name] = c
+	}
+	c.seenTime = Now()
+	return c
+}
+
+// deliver records that the entry was delivered to the consumer
+func (cg *consumerGroup) deliver(id StreamID, name string, c *consumer, incrementCount bool) *pendingEntry {
+	pe, ok := cg.pending[id]
+	if !ok {
+		pe = &pendingEntry{}
+		cg.pending[id] = pe
+	} else if pe.consumer != name {
+		if previous, ok := cg.consumers[pe.consumer]; ok {
+			delete(previous.pending, id)
+		}
+	}
+	pe.consumer = name
+	pe.deliveryTime = Now()
+	if incrementCount {
+		pe.deliveryCount++
+	}
+	c.pending[id] = struct{}{}
+	return pe
+}
+
+// ack removes an entry from the pending entries list
+func (cg *consumerGroup) ack(id StreamID) bool {
+	pe, ok := cg.pending[id]
+	if !ok {
+		return false
+	}
+	if c, ok := cg.consumers[pe.consumer]; ok {
+		delete(c.pending, id)
+	}
+	delete(cg.pending, id)
+	return true
+}
+
+// sortedPending returns the IDs of the pending entries list in order
+func (cg *consumerGroup) sortedPending() []StreamID {
+	ids := make([]StreamID, 0, len(cg.pending))
+	for id := range cg.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+	return ids
+}
+
+// XReadGroup reads entries from streams on behalf of a consumer of a consumer group
+// With the ">" ID, entries never delivered to the group are returned and added to the
+// pending entries list of the consumer (unless noAck is set)
+// With any other ID, the consumer's own pending entries after that ID are returned again
+func (s *Storage) XReadGroup(group, name string, reads []StreamRead, count int, noAck bool) ([]StreamEntries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check every stream before delivering anything
+	groups := make([]*consumerGroup, len(reads))
+	streams := make([]*stream, len(reads))
+	for i, read := range reads {
+		st, cg, err := s.lookupGroup(read.Key, group, "XREADGROUP", accessWrite)
+		if err != nil {
+			return nil, err
+		}
+		streams[i], groups[i] = st, cg
+	}
+
+	var result []StreamEntries
+	for i, read := range reads {
+		st, cg := streams[i], groups[i]
+		c := cg.consumer(name)
+
+		var entries []StreamEntry
+		if read.New {
+			start, ok := cg.lastID.Next()
+			if !ok {
+				continue
+			}
+			entries = st.rangeEntries(start, MaxStreamID, count, false)
+			for _, entry := range entries {
+				cg.lastID = entry.ID
+				if !noAck {
+					cg.deliver(entry.ID, name, c, true)
+				}
+			}
+			if len(entries) == 0 {
+				continue
+			}
+		} else {
+			// History is returned even when empty, so the client knows it caught up
+			entries = []StreamEntry{}
+			for _, id := range cg.sortedPending() {
+				if count > 0 && len(entries) == count {
+					break
+				}
+				if !read.ID.Less(id) || cg.pending[id].consumer != name {
+					continue
+				}
+				entry, ok := st.entry(id)
+				if !ok {
+					entry = StreamEntry{ID: id}
+				}
+				cg.deliver(id, name, c, true)
+				entries = append(entries, entry)
+			}
+		}
+		result = append(result, StreamEntries{Key: read.Key, Entries: entries})
+		s.touch(read.Key)
+	}
+	return result, nil
+}
+
+// XAck acknowledges entries, removing them from the pending entries list of the group
+// Returns the number of acknowledged entries
+func (s *Storage) XAck(key, group string, ids ...StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.lookupStream(key, accessWrite)
+	if st == nil {
+		return 0, err
+	}
+	cg, ok := st.groups[group]
+	if !ok {
+		return 0, nil
+	}
+	acked := 0
+	for _, id := range ids {
+		if cg.ack(id) {
+			acked++
+		}
+	}
+	if acked > 0 {
+		s.touch(key)
+	}
+	return acked, nil
+}
+
+// PendingSummary is the summary form of XPENDING
+type PendingSummary struct {
+	Count     int
+	Smallest  StreamID
+	Greatest  StreamID
+	Consumers []ConsumerPending
+}
+
+// ConsumerPending is the number of pending entries of a consumer
+type ConsumerPending struct {
+	Name  string
+	Count int
+}
+
+// PendingEntry is an entry of the pending entries list, as reported by XPENDING
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	Idle          int64 // Milliseconds since the last delivery
+	DeliveryCount int
+}
+
+// XPendingSummary returns the number of pending entries of a group, their ID range
+// and how many are pending for each consumer
+func (s *Storage) XPendingSummary(key, group string) (PendingSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, cg, err := s.lookupGroup(key, group, "XPENDING", accessRead)
+	if err != nil {
+		return PendingSummary{}, err
+	}
+	ids := cg.sortedPending()
+	summary := PendingSummary{Count: len(ids)}
+	if len(ids) == 0 {
+		return summary, nil
+	}
+	summary.Smallest, summary.Greatest = ids[0], ids[len(ids)-1]
+	for name, c := range cg.consumers {
+		if len(c.pending) > 0 {
+			summary.Consumers = append(summary.Consumers, ConsumerPending{Name: name, Count: len(c.pending)})
+		}
+	}
+	sort.Slice(summary.Consumers, func(i, j int) bool { return summary.Consumers[i].Name < summary.Consumers[j].Name })
+	return summary, nil
+}
+
+// XPendingRange returns up to count pending entries with an ID between start and end,
+// optionally only those of one consumer and those idle for at least minIdle milliseconds
+func (s *Storage) XPendingRange(key, group string, start, end StreamID, count int, consumerName string, minIdle int64) ([]PendingEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, cg, err := s.lookupGroup(key, group, "XPENDING", accessRead)
+	if err != nil {
+		return nil, err
+	}
+	now := Now()
+	entries := []PendingEntry{}
+	for _, id := range cg.sortedPending() {
+		if len(entries) >= count {
+			break
+		}
+		pe := cg.pending[id]
+		if id.Less(start) || end.Less(id) || (consumerName != "" && pe.consumer != consumerName) {
+			continue
+		}
+		idle := now - pe.deliveryTime
+		if idle < minIdle {
+			continue
+		}
+		entries = append(entries, PendingEntry{ID: id, Consumer: pe.consumer, Idle: idle, DeliveryCount: pe.deliveryCount})
+	}
+	return entries, nil
+}
+
+// XClaimOptions holds the optional arguments of XCLAIM
+type XClaimOptions struct {
+	DeliveryTime int64    // Unix milliseconds to set as last delivery time, 0 for now
+	RetryCount   int      // Delivery count to set, negative to increment it
+	Force        bool     // Create pending entries for IDs that are not pending yet
+	JustID       bool     // Don't increment the delivery count
+	LastID       StreamID // Raise the last delivered ID of the group to this ID
+}
+
+// claim transfers a pending entry to a consumer if it has been idle long enough
+// Entries deleted from the stream are removed from the pending entries list instead
+// Returns the claimed entry and whether it was claimed, plus whether it was deleted
+func (st *stream) claim(cg *consumerGroup, name string, c *consumer, id StreamID, minIdle int64, opts XClaimOptions) (StreamEntry, bool, bool) {
+	entry, exists := st.entry(id)
+	pe, pending := cg.pending[id]
+	if !exists {
+		if pending {
+			cg.ack(id)
+		}
+		return StreamEntry{}, false, pending
+	}
+	if !pending && !opts.Force {
+		return StreamEntry{}, false, false
+	}
+	if pending && minIdle > 0 && Now()-pe.deliveryTime < minIdle {
+		return StreamEntry{}, false, false
+	}
+
+	pe = cg.deliver(id, name, c, !opts.JustID)
+	if opts.DeliveryTime > 0 {
+		pe.deliveryTime = opts.DeliveryTime
+	}
+	if opts.RetryCount >= 0 {
+		pe.deliveryCount = opts.RetryCount
+	}
+	return entry, true, false
+}
+
+// XClaim changes the ownership of pending entries idle for at least minIdle milliseconds
+// Returns the entries that were claimed
+func (s *Storage) XClaim(key, group, name string, minIdle int64, ids []StreamID, opts XClaimOptions) ([]StreamEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, cg, err := s.lookupGroup(key, group, "XCLAIM", accessWrite)
+	if err != nil {
+		return nil, err
+	}
+	if cg.lastID.Less(opts.LastID) {
+		cg.lastID = opts.LastID
+	}
+	c := cg.consumer(name)
+	claimed := []StreamEntry{}
+	changed := false
+	for _, id := range ids {
+		entry, ok, removed := st.claim(cg, name, c, id, minIdle, opts)
+		if ok {
+			claimed = append(claimed, entry)
+		}
+		changed = changed || ok || removed
+	}
+	if changed {
+		s.touch(key)
+	}
+	return claimed, nil
+}
+
+// XAutoClaim scans up to count pending entries starting at start and claims
+// those idle for at least minIdle milliseconds
+// Returns the ID to continue the scan from (0-0 when done), the claimed entries
+// and the IDs of pending entries that no longer exist in the stream
+func (s *Storage) XAutoClaim(key, group, name string, minIdle int64, start StreamID, count int, justID bool) (StreamID, []StreamEntry, []StreamID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, cg, err := s.lookupGroup(key, group, "XAUTOCLAIM", accessWrite)
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+	c := cg.consumer(name)
+	opts := XClaimOptions{RetryCount: -1, JustID: justID}
+
+	claimed, deleted := []StreamEntry{}, []StreamID{}
+	ids := cg.sortedPending()
+	i := sort.Search(len(ids), func(i int) bool { return !ids[i].Less(start) })
+	for scanned := 0; i < len(ids) && scanned < count; i, scanned = i+1, scanned+1 {
+		entry, ok, removed := st.claim(cg, name, c, ids[i], minIdle, opts)
+		if ok {
+			claimed = append(claimed, entry)
+		} else if removed {
+			deleted = append(deleted, ids[i])
+		}
+	}
+
+	if len(claimed) > 0 || len(deleted) > 0 {
+		s.touch(key)
+	}
+
+	next := StreamID{}
+	if i < len(ids) {
+		next = ids[i]
+	}
+	return next, claimed, deleted, nil
+}
@@ -0,0 +1,106 @@
+// https://redis.io/docs/latest/develop/interact/transactions/
+package storage
+
+// watchedKey is the modification version of a key watched by at least one transaction
+// Versions are only kept for watched keys, so memory does not grow with every key ever written
+type watchedKey struct {
+	version  uint64
+	watchers int
+}
+
+// WatchedKey is a key watched by a client together with its version at the time of WATCH
+type WatchedKey struct {
+	Key     string
+	db      *Storage // Database the key was watched in
+	version uint64
+}
+
+// touch records that a key was modified, invalidating the transactions watching it
+// and making it ready for the clients blocked on it
+// The caller must hold the write lock
+func (s *Storage) touch(key string) {
+	s.dirty++
+	if w, ok := s.watched[key]; ok {
+		w.version++
+	}
+	if s.blocked[key] > 0 {
+		s.ready = append(s.ready, key)
+	}
+}
+
+// Dirty returns the number of modifications made to the databases
+// A command that leaves it unchanged did not modify anything
+func (d *Databases) Dirty() uint64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.dirty
+}
+
+// Watch starts watching keys for modifications
+// Returns the keys with their current versions, to be passed to Changed and Unwatch
+func (s *Storage) Watch(keys ...string) []WatchedKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watched := make([]WatchedKey, len(keys))
+	for i, key := range keys {
+		// Keys that already expired are deleted now, so expiring later is always a change
+		s.expireIfNeeded(key)
+		w, ok := s.watched[key]
+		if !ok {
+			w = &watchedKey{}
+			s.watched[key] = w
+		}
+		w.watchers++
+		watched[i] = WatchedKey{Key: key, db: s, version: w.version}
+	}
+	return watched
+}
+
+// Unwatch stops watching keys returned by Watch, in any of the databases
+func (d *Databases) Unwatch(watched []WatchedKey) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, wk := range watched {
+		if w, ok := wk.db.watched[wk.Key]; ok {
+			w.watchers--
+			if w.watchers == 0 {
+				delete(wk.db.watched, wk.Key)
+			}
+		}
+	}
+}
+
+// Changed reports whether any of the watched keys was modified, or expired, since Watch
+// The keys may have been watched in any of the databases
+func (d *Databases) Changed(watched []WatchedKey) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := Now()
+	for _, wk := range watched {
+		if w, ok := wk.db.watched[wk.Key]; ok && w.version != wk.version {
+			return true
+		}
+		if when, ok := wk.db.expiry(wk.Key); ok && when <= now {
+			return true
+		}
+	}
+	return false
+}
+
+// Exclusive runs fn while no other command runs, so that a transaction is applied atomically
+// fn still calls the regular Storage methods, which take the data lock as usual
+func (d *Databases) Exclusive(fn func()) {
+	d.txMu.Lock()
+	defer d.txMu.Unlock()
+	fn()
+}
+
+// Shared runs fn, which may run concurrently with other commands but never during a transaction
+func (d *Databases) Shared(fn func()) {
+	d.txMu.RLock()
+	defer d.txMu.RUnlock()
+	fn()
+}
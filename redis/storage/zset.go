@@ -0,0 +1,550 @@
+// https://redis.io/docs/latest/develop/data-types/sorted-sets/
+package storage
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// ErrScoreNaN is returned when an increment would make a score NaN
+	ErrScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+)
+
+// zset is a sorted set, encoded like Redis does: a dict mapping members to
+// scores for O(1) lookups, plus a skiplist keeping them ordered for ranges and ranks
+type zset struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+// newZset creates an empty sorted set
+func newZset() *zset {
+	return &zset{dict: make(map[string]float64), zsl: newSkiplist()}
+}
+
+// set adds a member or updates its score
+func (z *zset) set(member string, score float64) {
+	if current, ok := z.dict[member]; ok {
+		if current == score {
+			return
+		}
+		z.zsl.delete(current, member)
+	}
+	z.dict[member] = score
+	z.zsl.insert(score, member)
+}
+
+// remove deletes a member, returning false if it was not in the set
+func (z *zset) remove(member string) bool {
+	score, ok := z.dict[member]
+	if !ok {
+		return false
+	}
+	delete(z.dict, member)
+	z.zsl.delete(score, member)
+	return true
+}
+
+// ZMember is a member of a sorted set together with its score
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAddOptions holds the flags of the ZADD command
+type ZAddOptions struct {
+	NX bool // Only add new members
+	XX bool // Only update existing members
+	GT bool // Only update a score if the new one is greater
+	LT bool // Only update a score if the new one is less
+	CH bool // Count changed members as well as added ones
+}
+
+// allows reports whether the options permit giving member the new score
+func (opts ZAddOptions) allows(current float64, exists bool, score float64) bool {
+	if (opts.NX && exists) || (opts.XX && !exists) {
+		return false
+	}
+	if exists && ((opts.GT && score <= current) || (opts.LT && score >= current)) {
+		return false
+	}
+	return true
+}
+
+// ScoreRange is an interval of scores, as used by ZRANGE BYSCORE and ZCOUNT
+type ScoreRange struct {
+	Min, Max     float64
+	MinEx, MaxEx bool // Whether the bounds are exclusive
+}
+
+// aboveMin reports whether the score satisfies the lower bound
+func (r ScoreRange) aboveMin(score float64) bool {
+	if r.MinEx {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+// belowMax reports whether the score satisfies the upper bound
+func (r ScoreRange) belowMax(score float64) bool {
+	if r.MaxEx {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+// LexBound is one end of a lexicographical range, as used by ZRANGE BYLEX
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int // -1 for "-" (negative infinity), 1 for "+" (positive infinity), 0 otherwise
+}
+
+// aboveMin reports whether the member satisfies the bound used as a minimum
+func (b LexBound) aboveMin(member string) bool {
+	switch {
+	case b.Inf < 0:
+		return true
+	case b.Inf > 0:
+		return false
+	case b.Exclusive:
+		return member > b.Value
+	default:
+		return member >= b.Value
+	}
+}
+
+// belowMax reports whether the member satisfies the bound used as a maximum
+func (b LexBound) belowMax(member string) bool {
+	switch {
+	case b.Inf > 0:
+		return true
+	case b.Inf < 0:
+		return false
+	case b.Exclusive:
+		return member < b.Value
+	default:
+		return member <= b.Value
+	}
+}
+
+// LexRange is an interval of members, compared byte by byte
+type LexRange struct {
+	Min, Max LexBound
+}
+
+// Aggregate selects how ZUNIONSTORE and ZINTERSTORE combine the scores of a member
+type Aggregate int
+
+const (
+	AggregateSum Aggregate = iota
+	AggregateMin
+	AggregateMax
+)
+
+// lookupZset returns the sorted set stored under key
+// If the key does not exist, nil is returned unless mode is accessCreate, in which case an empty sorted set is stored
+// Unless mode is accessRead, the value is copied first if a snapshot shares it
+// Returns ErrWrongType if the key holds another data type
+// The caller must hold the write lock
+func (s *Storage) lookupZset(key string, mode access) (*zset, error) {
+	obj := s.lookup(key)
+	if obj == nil {
+		if mode != accessCreate {
+			return nil, nil
+		}
+		obj = &object{kind: KindZSet, zset: newZset()}
+		s.store(key, obj)
+	}
+	if obj.kind != KindZSet {
+		return nil, ErrWrongType
+	}
+	if mode != accessRead {
+		obj = s.writable(key, obj)
+	}
+	return obj.zset, nil
+}
+
+// ZAdd adds members with their scores to the sorted set stored at key, or updates their scores
+// Returns the number of added members, plus the number of updated ones if opts.CH is set
+func (s *Storage) ZAdd(key string, opts ZAddOptions, members ...ZMember) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessWrite)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range members {
+		current, exists := 0.0, false
+		if z != nil {
+			current, exists = z.dict[m.Member]
+		}
+		if !opts.allows(current, exists, m.Score) {
+			continue
+		}
+		if z == nil {
+			// Only create the key once a member is actually written
+			z, _ = s.lookupZset(key, accessCreate)
+		}
+		if !exists {
+			count++
+		} else if opts.CH && current != m.Score {
+			count++
+		}
+		z.set(m.Member, m.Score)
+		s.touch(key)
+	}
+	return count, nil
+}
+
+// ZIncrBy increments the score of member in the sorted set stored at key
+// A missing member is added with the increment as its score
+// Returns the new score, or false if the options prevented the update
+func (s *Storage) ZIncrBy(key, member string, increment float64, opts ZAddOptions) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessWrite)
+	if err != nil {
+		return 0, false, err
+	}
+	current, exists := 0.0, false
+	if z != nil {
+		current, exists = z.dict[member]
+	}
+	score := current + increment
+	if math.IsNaN(score) {
+		return 0, false, ErrScoreNaN
+	}
+	if !opts.allows(current, exists, score) {
+		return 0, false, nil
+	}
+	if z == nil {
+		z, _ = s.lookupZset(key, accessCreate)
+	}
+	z.set(member, score)
+	s.touch(key)
+	return score, true, nil
+}
+
+// ZScore returns the score of member in the sorted set stored at key
+func (s *Storage) ZScore(key, member string) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessRead)
+	if z == nil {
+		return 0, false, err
+	}
+	score, ok := z.dict[member]
+	return score, ok, nil
+}
+
+// ZCard returns the number of members in the sorted set stored at key
+func (s *Storage) ZCard(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessRead)
+	if z == nil {
+		return 0, err
+	}
+	return z.zsl.length, nil
+}
+
+// ZRem removes members from the sorted set stored at key
+// The key is deleted once the sorted set is empty
+// Returns the number of removed members
+func (s *Storage) ZRem(key string, members ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessWrite)
+	if z == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if z.remove(member) {
+			removed++
+		}
+	}
+	if z.zsl.length == 0 {
+		s.delete(key)
+	}
+	if removed > 0 {
+		s.touch(key)
+	}
+	return removed, nil
+}
+
+// ZRank returns the 0-based rank of member in the sorted set stored at key,
+// ordered from the lowest score, or from the highest if reverse is set
+func (s *Storage) ZRank(key, member string, reverse bool) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessRead)
+	if z == nil {
+		return 0, false, err
+	}
+	score, ok := z.dict[member]
+	if !ok {
+		return 0, false, nil
+	}
+	rank := z.zsl.rank(score, member)
+	if reverse {
+		return z.zsl.length - rank, true, nil
+	}
+	return rank - 1, true, nil
+}
+
+// ZCount returns the number of members with a score within the range
+func (s *Storage) ZCount(key string, r ScoreRange) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessRead)
+	if z == nil {
+		return 0, err
+	}
+	first := z.zsl.firstInScoreRange(r)
+	if first == nil {
+		return 0, nil
+	}
+	last := z.zsl.lastInScoreRange(r)
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1, nil
+}
+
+// walk collects up to count nodes starting from x, skipping the first offset ones
+// A negative count means no limit
+func walk(x *skiplistNode, reverse bool, offset, count int, inRange func(*skiplistNode) bool) []ZMember {
+	members := []ZMember{}
+	if offset < 0 {
+		return members
+	}
+	for ; x != nil && offset > 0 && inRange(x); offset-- {
+		x = next(x, reverse)
+	}
+	for ; x != nil && count != 0 && inRange(x); count-- {
+		members = append(members, ZMember{Member: x.member, Score: x.score})
+		x = next(x, reverse)
+	}
+	return members
+}
+
+// next returns the following node in the given direction
+func next(x *skiplistNode, reverse bool) *skiplistNode {
+	if reverse {
+		return x.backward
+	}
+	return x.level[0].forward
+}
+
+// ZRangeByRank returns the members between the start and stop ranks, both inclusive
+// Negative ranks count from the end
+func (s *Storage) ZRangeByRank(key string, start, stop int, reverse bool) ([]ZMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessRead)
+	if z == nil {
+		return []ZMember{}, err
+	}
+	start, stop, ok := normalizeRange(start, stop, z.zsl.length)
+	if !ok {
+		return []ZMember{}, nil
+	}
+	rank := start + 1
+	if reverse {
+		rank = z.zsl.length - start
+	}
+	x := z.zsl.byRank(rank)
+	return walk(x, reverse, 0, stop-start+1, func(*skiplistNode) bool { return true }), nil
+}
+
+// ZRangeByScore returns the members with a score within the range, skipping the
+// first offset ones and returning at most count (a negative count means all)
+// With reverse, members are returned from the highest score
+func (s *Storage) ZRangeByScore(key string, r ScoreRange, reverse bool, offset, count int) ([]ZMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessRead)
+	if z == nil {
+		return []ZMember{}, err
+	}
+	if reverse {
+		x := z.zsl.lastInScoreRange(r)
+		return walk(x, true, offset, count, func(n *skiplistNode) bool { return r.aboveMin(n.score) }), nil
+	}
+	x := z.zsl.firstInScoreRange(r)
+	return walk(x, false, offset, count, func(n *skiplistNode) bool { return r.belowMax(n.score) }), nil
+}
+
+// ZRangeByLex returns the members within the lexicographical range, which is only
+// meaningful when all members have the same score
+// offset, count and reverse work like in ZRangeByScore
+func (s *Storage) ZRangeByLex(key string, r LexRange, reverse bool, offset, count int) ([]ZMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessRead)
+	if z == nil {
+		return []ZMember{}, err
+	}
+	if reverse {
+		x := z.zsl.lastInLexRange(r)
+		return walk(x, true, offset, count, func(n *skiplistNode) bool { return r.Min.aboveMin(n.member) }), nil
+	}
+	x := z.zsl.firstInLexRange(r)
+	return walk(x, false, offset, count, func(n *skiplistNode) bool { return r.Max.belowMax(n.member) }), nil
+}
+
+// ZPop removes and returns up to count members with the lowest scores,
+// or with the highest scores if highest is set
+func (s *Storage) ZPop(key string, count int, highest bool) ([]ZMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, err := s.lookupZset(key, accessWrite)
+	if z == nil {
+		return []ZMember{}, err
+	}
+	members := []ZMember{}
+	for len(members) < count && z.zsl.length > 0 {
+		x := z.zsl.header.level[0].forward
+		if highest {
+			x = z.zsl.tail
+		}
+		members = append(members, ZMember{Member: x.member, Score: x.score})
+		z.remove(x.member)
+	}
+	if z.zsl.length == 0 {
+		s.delete(key)
+	}
+	if len(members) > 0 {
+		s.touch(key)
+	}
+	return members, nil
+}
+
+// zsetSource reads a ZUNIONSTORE/ZINTERSTORE input, which may be a sorted set or a set
+// whose members all have a score of 1
+// The caller must hold the write lock
+func (s *Storage) zsetSource(key string) (map[string]float64, error) {
+	obj := s.lookup(key)
+	if obj == nil {
+		return nil, nil
+	}
+	switch obj.kind {
+	case KindZSet:
+		return obj.zset.dict, nil
+	case KindSet:
+		scores := make(map[string]float64, len(obj.set))
+		for member := range obj.set {
+			scores[member] = 1
+		}
+		return scores, nil
+	default:
+		return nil, ErrWrongType
+	}
+}
+
+// combine merges two scores of the same member
+func (a Aggregate) combine(x, y float64) float64 {
+	switch a {
+	case AggregateMin:
+		return math.Min(x, y)
+	case AggregateMax:
+		return math.Max(x, y)
+	default:
+		sum := x + y
+		// Adding +inf to -inf gives NaN, which Redis turns into 0
+		if math.IsNaN(sum) {
+			return 0
+		}
+		return sum
+	}
+}
+
+// ZStore computes the union or intersection of the sorted sets stored at keys and
+// atomically stores it in destination, replacing any existing value
+// Each input score is multiplied by its weight (1 when weights is nil) and the scores
+// of a member are combined with the aggregate function
+// Returns the number of members in the resulting sorted set
+func (s *Storage) ZStore(op SetOp, destination string, keys []string, weights []float64, aggregate Aggregate) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sources := make([]map[string]float64, len(keys))
+	for i, key := range keys {
+		source, err := s.zsetSource(key)
+		if err != nil {
+			return 0, err
+		}
+		sources[i] = source
+	}
+
+	weight := func(i int, score float64) float64 {
+		if weights == nil {
+			return score
+		}
+		product := score * weights[i]
+		// 0 * inf gives NaN, which Redis turns into 0
+		if math.IsNaN(product) {
+			return 0
+		}
+		return product
+	}
+
+	result := make(map[string]float64)
+	switch op {
+	case SetUnion:
+		for i, source := range sources {
+			for member, score := range source {
+				if current, ok := result[member]; ok {
+					result[member] = aggregate.combine(current, weight(i, score))
+				} else {
+					result[member] = weight(i, score)
+				}
+			}
+		}
+	case SetInter:
+	members:
+		for member, score := range sources[0] {
+			combined := weight(0, score)
+			for i, source := range sources[1:] {
+				other, ok := source[member]
+				if !ok {
+					continue members
+				}
+				combined = aggregate.combine(combined, weight(i+1, other))
+			}
+			result[member] = combined
+		}
+	case SetDiff:
+		for member, score := range sources[0] {
+			result[member] = score
+		}
+		for _, source := range sources[1:] {
+			for member := range source {
+				delete(result, member)
+			}
+		}
+	}
+
+	s.delete(destination)
+	if len(result) > 0 {
+		z := newZset()
+		for member, score := range result {
+			z.set(member, score)
+		}
+		s.store(destination, &object{kind: KindZSet, zset: z})
+	}
+	return len(result), nil
+}
@@ -0,0 +1,787 @@
+package tests
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"redis/aof"
+	"redis/command"
+	"redis/config"
+	"redis/resp"
+	"redis/server"
+	"redis/storage"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// commandValue builds the RESP array of a command, the way clients send it
+func commandValue(args ...string) resp.Value {
+	array := make([]resp.Value, len(args))
+	for i, arg := range args {
+		array[i] = resp.Bulk(arg)
+	}
+	return resp.Array(array...)
+}
+
+// loadServer writes the given commands to database.aof in a temporary directory,
+// then creates a server that converts it to an AOF directory and replays it
+func loadServer(t testing.TB, commands ...[]string) *server.Server {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	var data []byte
+	for _, args := range commands {
+		data = append(data, commandValue(args...).Marshal()...)
+	}
+	if err := os.WriteFile("database.aof", data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.AOF.Close() })
+	return srv
+}
+
+// readAOF returns the commands written to the AOF of a server created by loadServer,
+// which are in its first incremental file until it is rewritten
+func readAOF(t testing.TB) []byte {
+	t.Helper()
+	data, err := os.ReadFile("appendonlydir/database.aof.1.incr.aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestAOFReplaySetStore tests that the STORE variants of the set operations are replayed from the AOF
+func TestAOFReplaySetStore(t *testing.T) {
+	srv := loadServer(t,
+		[]string{"SADD", "s1", "a", "b", "c"},
+		[]string{"SADD", "s2", "b", "c", "d"},
+		[]string{"SINTERSTORE", "inter", "s1", "s2"},
+		[]string{"SREM", "s1", "b"},
+		[]string{"SUNIONSTORE", "union", "s1", "s2"},
+	)
+
+	if result := command.SMembers(srv.DBs.DB(0), []string{"inter"}); sortedBulks(result) != "b c" {
+		t.Errorf("SINTERSTORE replay: Expected b c, got %v", result)
+	}
+	if result := command.SMembers(srv.DBs.DB(0), []string{"union"}); sortedBulks(result) != "a b c d" {
+		t.Errorf("SUNIONSTORE replay: Expected a b c d, got %v", result)
+	}
+}
+
+// TestAOFReplayStreams tests that streams and consumer groups are restored from the AOF,
+// including generated IDs and claims that depend on idle times
+func TestAOFReplayStreams(t *testing.T) {
+	s := storage.NewStorage()
+	handlers := map[string]func(*storage.Storage, []string) resp.Value{
+		"XADD":       command.XAdd,
+		"XGROUP":     command.XGroup,
+		"XREADGROUP": command.XReadGroup,
+		"XACK":       command.XAck,
+		"XCLAIM":     command.XClaim,
+		"XAUTOCLAIM": command.XAutoClaim,
+	}
+
+	// Execute the commands and log them the way the server does
+	var logged [][]string
+	run := func(args ...string) resp.Value {
+		result := handlers[args[0]](s, args[1:])
+		cmd, rewritten := command.RewriteStream(s, args[0], args[1:], result)
+		logged = append(logged, append([]string{cmd}, rewritten...))
+		return result
+	}
+
+	first := string(run("XADD", "stream", "*", "n", "1").Bulk)
+	second := string(run("XADD", "stream", "*", "n", "2").Bulk)
+	third := string(run("XADD", "stream", "*", "n", "3").Bulk)
+	run("XGROUP", "CREATE", "stream", "group", "0")
+	run("XREADGROUP", "GROUP", "group", "alice", "STREAMS", "stream", ">")
+	run("XACK", "stream", "group", first)
+	time.Sleep(5 * time.Millisecond)
+	run("XCLAIM", "stream", "group", "bob", "5", first, second)
+	run("XAUTOCLAIM", "stream", "group", "carol", "5", third, "COUNT", "1")
+
+	srv := loadServer(t, logged...)
+	for _, args := range [][]string{
+		{"stream", "group", "-", "+", "10", "bob"},
+		{"stream", "group", "-", "+", "10", "carol"},
+		{"stream", "group", "-", "+", "10", "alice"},
+	} {
+		expected := command.XPending(s, args)
+		if result := command.XPending(srv.DBs.DB(0), args); entryIDs(result) != entryIDs(expected) {
+			t.Errorf("XPENDING %v after replay: Expected %v, got %v", args, expected, result)
+		}
+	}
+	if expected, result := command.XRange(s, []string{"stream", "-", "+"}), command.XRange(srv.DBs.DB(0), []string{"stream", "-", "+"}); entryIDs(result) != entryIDs(expected) {
+		t.Errorf("XRANGE after replay: Expected %v, got %v", expected, result)
+	}
+}
+
+// TestAOFLogsOnlyWrites tests that reads, unknown commands, failed commands and writes that
+// changed nothing are not written to the AOF, and that SPOP is written as the SREM it performed
+func TestAOFLogsOnlyWrites(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	client.do("SET", "key", "value")
+	client.do("GET", "key")
+	client.do("PING")
+	client.do("NOPE")
+	client.do("INCR", "key")
+	client.do("DEL", "missing")
+	client.do("SET", "key", "other", "NX")
+	client.do("LPOP", "missing")
+	client.do("SADD", "set", "only")
+	client.do("SPOP", "set")
+	client.do("SPOP", "set")
+
+	data := readAOF(t)
+	// The first command written to a new AOF selects its database
+	expected := string(commandValue("SELECT", "0").Marshal()) +
+		string(commandValue("SET", "key", "value").Marshal()) +
+		string(commandValue("SADD", "set", "only").Marshal()) +
+		string(commandValue("SREM", "set", "only").Marshal())
+	if string(data) != expected {
+		t.Errorf("AOF: Expected %q, got %q", expected, data)
+	}
+}
+
+// TestAOFReplayEquivalence tests that replaying the AOF written while clients concurrently
+// modify the same keys rebuilds exactly the same data
+func TestAOFReplayEquivalence(t *testing.T) {
+	srv, connect := startServer(t)
+
+	const clients, commands, keys = 8, 300, 4
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		client := connect()
+		rng := rand.New(rand.NewSource(int64(i)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < commands; j++ {
+				client.do(randomWrite(rng, keys)...)
+			}
+		}()
+	}
+	wg.Wait()
+
+	replayed, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.AOF.Close()
+
+	for _, key := range dumpKeys(keys) {
+		if expected, result := dump(srv.DBs.DB(0), key), dump(replayed.DBs.DB(0), key); result != expected {
+			t.Errorf("%s after replay: Expected %s, got %s", key, expected, result)
+		}
+	}
+}
+
+// randomWrite returns a random command modifying one of a few keys of each type,
+// so that concurrent clients often modify the same keys
+func randomWrite(rng *rand.Rand, keys int) []string {
+	n := func(max int) string { return strconv.Itoa(rng.Intn(max)) }
+	str, list, other := "str:"+n(keys), "list:"+n(keys), "list:"+n(keys)
+	hash, set, zset, stream := "hash:"+n(keys), "set:"+n(keys), "zset:"+n(keys), "stream:"+n(keys)
+
+	switch rng.Intn(20) {
+	case 0:
+		return []string{"SET", str, n(100)}
+	case 1:
+		return []string{"INCR", str}
+	case 2:
+		return []string{"DEL", str, list}
+	case 3:
+		return []string{"EXPIRE", str, "1000"}
+	case 4:
+		return []string{"RPUSH", list, n(10), n(10)}
+	case 5:
+		return []string{"LPOP", list}
+	case 6:
+		return []string{"LMOVE", list, other, "LEFT", "RIGHT"}
+	case 7:
+		return []string{"LTRIM", list, "0", "4"}
+	case 8:
+		return []string{"HSET", hash, "f" + n(5), n(100)}
+	case 9:
+		return []string{"HINCRBY", hash, "f" + n(5), "3"}
+	case 10:
+		return []string{"HDEL", hash, "f" + n(5)}
+	case 11:
+		return []string{"SADD", set, n(10), n(10)}
+	case 12:
+		return []string{"SPOP", set}
+	case 13:
+		return []string{"SUNIONSTORE", set, set, "set:" + n(keys)}
+	case 14:
+		return []string{"ZADD", zset, n(10), "m" + n(10)}
+	case 15:
+		return []string{"ZINCRBY", zset, "2", "m" + n(10)}
+	case 16:
+		return []string{"ZPOPMIN", zset}
+	case 17:
+		return []string{"XADD", stream, "*", "f", n(10)}
+	case 18:
+		return []string{"XTRIM", stream, "MAXLEN", "3"}
+	default:
+		return []string{"BLPOP", list, "0.01"}
+	}
+}
+
+// dumpKeys returns the keys randomWrite may modify
+func dumpKeys(keys int) []string {
+	var names []string
+	for _, prefix := range []string{"str", "list", "hash", "set", "zset", "stream"} {
+		for i := 0; i < keys; i++ {
+			names = append(names, prefix+":"+strconv.Itoa(i))
+		}
+	}
+	return names
+}
+
+// dump returns the content of a key written by randomWrite, and whether it has a TTL
+func dump(s *storage.Storage, key string) string {
+	var content string
+	switch key[:strings.Index(key, ":")] {
+	case "str":
+		content = command.Get(s, []string{key}).String()
+	case "list":
+		content = bulks(command.LRange(s, []string{key, "0", "-1"}))
+	case "hash":
+		pairs := command.HGetAll(s, []string{key}).Array
+		fields := make([]string, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			fields = append(fields, string(pairs[i].Bulk)+"="+string(pairs[i+1].Bulk))
+		}
+		sort.Strings(fields)
+		content = strings.Join(fields, " ")
+	case "set":
+		content = sortedBulks(command.SMembers(s, []string{key}))
+	case "zset":
+		content = bulks(command.ZRange(s, []string{key, "0", "-1", "WITHSCORES"}))
+	case "stream":
+		content = command.XRange(s, []string{key, "-", "+"}).String()
+	}
+	return content + " ttl=" + strconv.FormatBool(command.PTTL(s, []string{key}).Num > 0)
+}
+
+// TestAOFGroupCommit tests that with appendfsync always, writers committing at the same time
+// share a single fsync that covers all of their writes
+func TestAOFGroupCommit(t *testing.T) {
+	file, err := aof.NewAOF(t.TempDir(), "database.aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.SetFsyncPolicy(aof.FsyncAlways)
+
+	const writers = 50
+	for i := 0; i < writers; i++ {
+		file.Write(commandValue("SET", "key", strconv.Itoa(i)))
+	}
+	if stats := file.Stats(); stats.PendingBytes != stats.Size || stats.Fsyncs != 0 {
+		t.Fatalf("Stats before commit: Expected %d pending bytes and no fsync, got %+v", stats.Size, stats)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := file.Commit(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := file.Stats(); stats.PendingBytes != 0 || stats.Fsyncs != 1 || stats.LastFsync.IsZero() {
+		t.Errorf("Stats after commit: Expected no pending bytes after one fsync, got %+v", stats)
+	}
+}
+
+// TestAOFFsyncBeforeReply tests that with appendfsync always, a write is fsynced before its
+// reply is sent, even when the reply goes through the subscriber queue or fills the buffer
+func TestAOFFsyncBeforeReply(t *testing.T) {
+	srv, connect := startServer(t)
+	srv.AOF.SetFsyncPolicy(aof.FsyncAlways)
+	client := connect()
+
+	// The command after GET is incomplete, so the replies are not flushed yet: the reply to SET
+	// is only sent because the reply to GET does not fit in the buffer
+	client.do("SET", "big", strings.Repeat("x", 16*1024))
+	ping := commandValue("PING").Marshal()
+	pipeline := append(commandValue("SET", "key", "value").Marshal(), commandValue("GET", "big").Marshal()...)
+	if _, err := client.conn.Write(append(pipeline, ping[:len(ping)-2]...)); err != nil {
+		t.Fatal(err)
+	}
+	if result := client.read(); result.Str != "OK" {
+		t.Fatalf("SET: Expected OK, got %v", result)
+	}
+	if stats := srv.AOF.Stats(); stats.PendingBytes != 0 {
+		t.Errorf("Stats after a reply sent before flush: Expected no pending bytes, got %+v", stats)
+	}
+	client.conn.Write(ping[len(ping)-2:])
+	client.read()
+	client.read()
+
+	subscribed := connect()
+	subscribed.do("HELLO", "3")
+	subscribed.do("SUBSCRIBE", "news")
+	subscribed.do("SET", "key", "other")
+	if stats := srv.AOF.Stats(); stats.PendingBytes != 0 {
+		t.Errorf("Stats after a reply sent by the subscriber queue: Expected no pending bytes, got %+v", stats)
+	}
+}
+
+// TestAOFFsyncPolicies tests that everysec fsyncs in the background and no never fsyncs
+func TestAOFFsyncPolicies(t *testing.T) {
+	dir := t.TempDir()
+	files := map[aof.FsyncPolicy]*aof.AOF{}
+	for _, policy := range []aof.FsyncPolicy{aof.FsyncEverySec, aof.FsyncNo} {
+		file, err := aof.NewAOF(dir, policy.String()+".aof")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		file.SetFsyncPolicy(policy)
+		file.Write(commandValue("SET", "key", "value"))
+		file.Commit()
+		files[policy] = file
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	for policy, file := range files {
+		stats := file.Stats()
+		if fsynced := stats.PendingBytes == 0; fsynced != (policy == aof.FsyncEverySec) {
+			t.Errorf("appendfsync %s: Unexpected stats %+v", policy, stats)
+		}
+	}
+
+	if _, err := aof.ParseFsyncPolicy("sometimes"); err == nil {
+		t.Error("ParseFsyncPolicy sometimes: Expected error")
+	}
+}
+
+// TestInfoPersistence tests that INFO reports the fsync state of the AOF
+func TestInfoPersistence(t *testing.T) {
+	srv, connect := startServer(t)
+	srv.AOF.SetFsyncPolicy(aof.FsyncAlways)
+	client := connect()
+
+	client.do("SET", "key", "value")
+	info := string(client.do("INFO", "persistence").Bulk)
+	for _, field := range []string{"# Persistence\r\n", "aof_fsync_policy:always\r\n", "aof_pending_fsync_bytes:0\r\n", "aof_last_fsync_status:ok\r\n"} {
+		if !strings.Contains(info, field) {
+			t.Errorf("INFO persistence: Expected %q in %q", field, info)
+		}
+	}
+	if strings.Contains(info, "aof_last_fsync_time:-1") {
+		t.Errorf("INFO persistence: Expected an fsync time in %q", info)
+	}
+	if info := client.do("INFO", "keyspace"); len(info.Bulk) != 0 {
+		t.Errorf("INFO keyspace: Expected nothing, got %v", info)
+	}
+
+	// INFO can be queued in a transaction like any other command
+	client.do("MULTI")
+	client.do("INFO", "persistence")
+	if result := client.do("EXEC"); len(result.Array) != 1 || !strings.Contains(string(result.Array[0].Bulk), "# Persistence") {
+		t.Errorf("INFO persistence in EXEC: Expected the persistence section, got %v", result)
+	}
+}
+
+// waitRewrite waits until the AOF rewrite in progress is done
+func waitRewrite(t testing.TB, srv *server.Server) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); srv.AOF.Stats().RewriteInProgress; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("AOF rewrite did not complete")
+		}
+	}
+	if err := srv.AOF.Stats().RewriteErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBGRewriteAOF tests that a rewritten AOF is smaller, and replays to the same data,
+// including the writes made while the rewrite ran
+func TestBGRewriteAOF(t *testing.T) {
+	srv, connect := startServer(t)
+	client := connect()
+
+	for i := 0; i < 100; i++ {
+		client.do("INCR", "str:0")
+		client.do("RPUSH", "list:0", strconv.Itoa(i))
+		client.do("HSET", "hash:0", "f"+strconv.Itoa(i%10), strconv.Itoa(i))
+		client.do("SADD", "set:0", strconv.Itoa(i%7))
+		client.do("ZINCRBY", "zset:0", "0.5", "m"+strconv.Itoa(i%5))
+	}
+	client.do("SET", "str:1", "expiring", "EX", "1000")
+	client.do("XADD", "stream:0", "*", "n", "1")
+	client.do("XADD", "stream:0", "*", "n", "2")
+	client.do("XGROUP", "CREATE", "stream:0", "group", "0")
+	client.do("XGROUP", "CREATECONSUMER", "stream:0", "group", "idle")
+	client.do("XREADGROUP", "GROUP", "group", "alice", "COUNT", "1", "STREAMS", "stream:0", ">")
+	client.do("XADD", "stream:1", "*", "n", "1")
+	client.do("XTRIM", "stream:1", "MAXLEN", "0")
+	client.do("XGROUP", "CREATE", "stream:2", "group", "$", "MKSTREAM")
+	before := srv.AOF.Stats().Size
+
+	if result := client.do("BGREWRITEAOF"); result.Str != "Background append only file rewriting started" {
+		t.Fatalf("BGREWRITEAOF: Expected started, got %v", result)
+	}
+	client.do("RPUSH", "list:1", "during")
+	client.do("DEL", "str:0")
+	waitRewrite(t, srv)
+	client.do("SADD", "set:1", "after")
+
+	if after := srv.AOF.Stats().Size; after >= before {
+		t.Errorf("AOF size: Expected less than %d after rewrite, got %d", before, after)
+	}
+
+	replayed, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.AOF.Close()
+
+	for _, key := range dumpKeys(3) {
+		if expected, result := dump(srv.DBs.DB(0), key), dump(replayed.DBs.DB(0), key); result != expected {
+			t.Errorf("%s after rewrite: Expected %s, got %s", key, expected, result)
+		}
+	}
+	for _, args := range [][]string{{"stream:0", "group", "-", "+", "10"}, {"stream:2", "group"}} {
+		if expected, result := command.XPending(srv.DBs.DB(0), args), command.XPending(replayed.DBs.DB(0), args); result.String() != expected.String() {
+			t.Errorf("XPENDING %v after rewrite: Expected %v, got %v", args, expected, result)
+		}
+	}
+	// The trimmed stream kept its last ID, so older IDs are still rejected
+	if result := command.XAdd(replayed.DBs.DB(0), []string{"stream:1", "0-1", "n", "2"}); result.Kind != resp.KindError {
+		t.Errorf("XADD 0-1 after rewrite of a trimmed stream: Expected error, got %v", result)
+	}
+}
+
+// TestBGRewriteAOFInMulti tests that a rewrite queued in a transaction does not replay the
+// writes of the transaction twice
+func TestBGRewriteAOFInMulti(t *testing.T) {
+	srv, connect := startServer(t)
+	client := connect()
+
+	client.do("MULTI")
+	client.do("INCR", "counter")
+	client.do("BGREWRITEAOF")
+	client.do("INCR", "counter")
+	client.do("BGREWRITEAOF")
+	result := client.do("EXEC")
+	if len(result.Array) != 4 || result.Array[1].Str != "Background append only file rewriting started" || result.Array[3].Kind != resp.KindError {
+		t.Fatalf("EXEC: Expected the first rewrite to start and the second to fail, got %v", result)
+	}
+	waitRewrite(t, srv)
+	client.do("INCR", "counter")
+
+	replayed, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.AOF.Close()
+	if result := command.Get(replayed.DBs.DB(0), []string{"counter"}); string(result.Bulk) != "3" {
+		t.Errorf("GET counter after replay: Expected 3, got %v", result)
+	}
+}
+
+// TestAutoRewriteAOF tests that the AOF is rewritten once it doubled in size since the last rewrite
+func TestAutoRewriteAOF(t *testing.T) {
+	srv := loadServer(t)
+	srv.Config.AutoAOFRewriteMinSize = 1024
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go srv.Serve(listener)
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := &testClient{t: t, conn: conn, reader: resp.NewResp(conn)}
+
+	for i := 0; i < 100; i++ {
+		client.do("SET", "key", strconv.Itoa(i))
+	}
+	// The rewrite starts in the background, once the write that triggered it is done
+	for deadline := time.Now().Add(5 * time.Second); srv.AOF.Stats().BaseSize == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("AOF was not rewritten")
+		}
+	}
+	waitRewrite(t, srv)
+
+	stats := srv.AOF.Stats()
+	if stats.BaseSize == 0 || stats.Size >= 1024 {
+		t.Errorf("AOF after automatic rewrite: Expected a small rewritten file, got %+v", stats)
+	}
+	if result := string(client.do("GET", "key").Bulk); result != "99" {
+		t.Errorf("GET after automatic rewrite: Expected 99, got %s", result)
+	}
+}
+
+// aofFiles returns the names of the files in the AOF directory, sorted
+func aofFiles(t testing.TB) string {
+	t.Helper()
+	entries, err := os.ReadDir("appendonlydir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return strings.Join(names, " ")
+}
+
+// TestAOFManifest tests that a single-file AOF is converted to a base file, and that rewrites
+// replace the base file and the incremental files written before them
+func TestAOFManifest(t *testing.T) {
+	srv := loadServer(t, []string{"SET", "key", "value"})
+
+	if _, err := os.Stat("database.aof"); !os.IsNotExist(err) {
+		t.Errorf("database.aof after conversion: Expected it to be removed, got %v", err)
+	}
+	manifest, err := os.ReadFile("appendonlydir/database.aof.manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "file database.aof.1.base.aof seq 1 type b\nfile database.aof.1.incr.aof seq 1 type i\n"
+	if string(manifest) != expected {
+		t.Errorf("Manifest after conversion: Expected %q, got %q", expected, manifest)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := srv.AOF.StartRewrite(); err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.AOF.StartRewrite(); err != aof.ErrRewriteInProgress {
+			t.Errorf("StartRewrite during a rewrite: Expected ErrRewriteInProgress, got %v", err)
+		}
+		srv.AOF.Write(commandValue("SET", "during", strconv.Itoa(i)))
+		// The values reach the temporary file while they are generated
+		written := int64(0)
+		err := srv.AOF.Rewrite(func(write func(resp.Value)) {
+			for j := 0; j < 1000; j++ {
+				write(commandValue("SET", "filler:"+strconv.Itoa(j), "value"))
+			}
+			if info, err := os.Stat("appendonlydir/temp-rewriteaof-database.aof"); err == nil {
+				written = info.Size()
+			}
+			write(commandValue("SET", "key", "rewritten"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written == 0 {
+			t.Error("Rewrite: Expected the values to be written while they are generated")
+		}
+	}
+	if files, expected := aofFiles(t), "database.aof.3.base.aof database.aof.3.incr.aof database.aof.manifest"; files != expected {
+		t.Errorf("AOF files after rewrites: Expected %s, got %s", expected, files)
+	}
+
+	srv.AOF.Close()
+	replayed, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.AOF.Close()
+	if result := string(command.Get(replayed.DBs.DB(0), []string{"key"}).Bulk); result != "rewritten" {
+		t.Errorf("GET key after replay: Expected rewritten, got %s", result)
+	}
+	if result := string(command.Get(replayed.DBs.DB(0), []string{"during"}).Bulk); result != "1" {
+		t.Errorf("GET during after replay: Expected 1, got %s", result)
+	}
+}
+
+// TestAOFInvalidManifest tests that the server refuses to start from an invalid manifest,
+// or one listing a file that does not exist
+func TestAOFInvalidManifest(t *testing.T) {
+	loadServer(t)
+
+	for _, manifest := range []string{
+		"file database.aof.1.incr.aof seq one type i\n",
+		"file database.aof.1.base.aof seq 1 type b\nfile database.aof.2.base.aof seq 2 type b\n",
+		"file ../database.aof seq 1 type i\n",
+		"file database.aof.9.base.aof seq 9 type b\nfile database.aof.1.incr.aof seq 1 type i\n",
+		"file database.aof.1.base.aof seq 1 type b\n",
+		"# Only comments\n",
+	} {
+		if err := os.WriteFile("appendonlydir/database.aof.manifest", []byte(manifest), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if srv, err := server.NewServer(config.Default()); err == nil {
+			srv.AOF.Close()
+			t.Errorf("NewServer with manifest %q: Expected error", manifest)
+		}
+	}
+}
+
+// TestAOFScan tests that Scan reports the offset of the first bad record of an AOF file
+func TestAOFScan(t *testing.T) {
+	set := string(commandValue("SET", "key", "value").Marshal())
+	cases := []struct {
+		data     string
+		offset   int
+		expected error
+	}{
+		{set + set, 2 * len(set), nil},
+		{set + set[:len(set)-1], len(set), aof.ErrTruncated},
+		{set + "*3\r\n$3\r\nSET", len(set), aof.ErrTruncated},
+		{set + "*2", len(set), aof.ErrTruncated},
+		{set + "SET key value\r\n" + set, len(set), aof.ErrBadFormat},
+		{set + "*1\r\n:1\r\n", len(set), aof.ErrBadFormat},
+		{set + "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue!!" + set, len(set), aof.ErrBadFormat},
+	}
+	for _, c := range cases {
+		commands := 0
+		offset, err := aof.Scan(strings.NewReader(c.data), func(resp.Value) { commands++ })
+		if offset != int64(c.offset) || !errors.Is(err, c.expected) || commands != c.offset/len(set) {
+			t.Errorf("Scan %q: Expected offset %d and %v, got offset %d, %v and %d commands", c.data, c.offset, c.expected, offset, err, commands)
+		}
+	}
+
+	// A file ending inside a transaction is truncated at its MULTI
+	multi := string(commandValue("MULTI").Marshal())
+	exec := string(commandValue("EXEC").Marshal())
+	for _, c := range []struct {
+		data     string
+		offset   int
+		expected error
+	}{
+		{set + multi + set + exec, len(set + multi + set + exec), nil},
+		{set + multi + set, len(set), aof.ErrIncompleteMulti},
+		{set + multi + set + set[:5], len(set), aof.ErrIncompleteMulti},
+		{multi + set + exec + multi, len(multi + set + exec), aof.ErrIncompleteMulti},
+	} {
+		offset, err := aof.Scan(strings.NewReader(c.data), func(resp.Value) {})
+		if offset != int64(c.offset) || !errors.Is(err, c.expected) {
+			t.Errorf("Scan %q: Expected offset %d and %v, got offset %d and %v", c.data, c.offset, c.expected, offset, err)
+		}
+	}
+	if !errors.Is(aof.ErrIncompleteMulti, aof.ErrTruncated) {
+		t.Error("ErrIncompleteMulti: Expected to be an ErrTruncated")
+	}
+}
+
+// TestAOFLoadIncompleteMulti tests that a transaction without its EXEC at the end of the AOF
+// is removed on startup, so the writes that follow it are still there after the next restart
+func TestAOFLoadIncompleteMulti(t *testing.T) {
+	srv := loadServer(t, []string{"SET", "a", "1"})
+	srv.AOF.Close()
+
+	incr := "appendonlydir/database.aof.1.incr.aof"
+	var data []byte
+	for _, args := range [][]string{{"SET", "c", "3"}, {"MULTI"}, {"SET", "b", "2"}} {
+		data = append(data, commandValue(args...).Marshal()...)
+	}
+	if err := os.WriteFile(incr, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without aof-load-truncated, the server does not start
+	cfg := config.Default()
+	cfg.AOFLoadTruncated = false
+	if srv, err := server.NewServer(cfg); err == nil || !strings.Contains(err.Error(), "MULTI without EXEC") {
+		if err == nil {
+			srv.AOF.Close()
+		}
+		t.Fatalf("NewServer without aof-load-truncated: Expected an error about the MULTI, got %v", err)
+	}
+
+	for restart := 1; restart <= 2; restart++ {
+		replayed, err := server.NewServer(config.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]string{"a": "1", "b": "", "c": "3", "d": "4"}
+		if restart == 1 {
+			expected["d"] = ""
+			replayed.AOF.Write(commandValue("SET", "d", "4"))
+		}
+		for key, value := range expected {
+			if result := string(command.Get(replayed.DBs.DB(0), []string{key}).Bulk); result != value {
+				t.Errorf("GET %s after restart %d: Expected %q, got %q", key, restart, value, result)
+			}
+		}
+		replayed.AOF.Close()
+	}
+}
+
+// TestAOFLoadTruncated tests that an incomplete command at the end of the AOF is dropped
+// on startup, while a bad record before the end stops the server from starting
+func TestAOFLoadTruncated(t *testing.T) {
+	srv := loadServer(t, []string{"SET", "a", "1"})
+	srv.AOF.Close()
+
+	incr := "appendonlydir/database.aof.1.incr.aof"
+	complete := commandValue("SET", "c", "3").Marshal()
+	partial := commandValue("SET", "b", "2").Marshal()
+	if err := os.WriteFile(incr, append(complete, partial[:len(partial)-3]...), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{"a": "1", "b": "", "c": "3"} {
+		if result := string(command.Get(replayed.DBs.DB(0), []string{key}).Bulk); result != expected {
+			t.Errorf("GET %s after loading a truncated AOF: Expected %q, got %q", key, expected, result)
+		}
+	}
+	replayed.AOF.Write(commandValue("SET", "d", "4"))
+	replayed.AOF.Close()
+	if data := string(readAOF(t)); data != string(complete)+string(commandValue("SET", "d", "4").Marshal()) {
+		t.Errorf("AOF after loading a truncated AOF: Expected the incomplete command to be removed, got %q", data)
+	}
+
+	// Without aof-load-truncated, the AOF is left as it is
+	os.WriteFile(incr, partial[:len(partial)-3], 0666)
+	file, err := aof.NewAOF("appendonlydir", "database.aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.LoadTruncated = false
+	if err := file.Load(func(resp.Value) {}); !errors.Is(err, aof.ErrTruncated) {
+		t.Errorf("Load truncated AOF without aof-load-truncated: Expected ErrTruncated, got %v", err)
+	}
+	file.Close()
+
+	// Bad data is never skipped
+	os.WriteFile(incr, append([]byte("garbage\r\n"), complete...), 0666)
+	if srv, err := server.NewServer(config.Default()); err == nil || !strings.Contains(err.Error(), "offset 0") {
+		if err == nil {
+			srv.AOF.Close()
+		}
+		t.Errorf("NewServer with a bad record: Expected an error with its offset, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"redis/command"
+	"redis/config"
+	"redis/resp"
+	"redis/server"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockWait gives a command sent by another client the time to block before the test goes on
+const blockWait = 50 * time.Millisecond
+
+// TestBLPop tests BLPOP and BRPOP, both when a list has elements and when they block
+func TestBLPop(t *testing.T) {
+	_, connect := startServer(t)
+	client, pusher := connect(), connect()
+
+	pusher.do("RPUSH", "second", "a", "b")
+	if result := client.do("BLPOP", "first", "second", "0"); bulks(result) != "second a" {
+		t.Errorf("BLPOP with elements: Expected second a, got %v", result)
+	}
+	if result := client.do("BRPOP", "first", "second", "0"); bulks(result) != "second b" {
+		t.Errorf("BRPOP with elements: Expected second b, got %v", result)
+	}
+
+	client.send("BLPOP", "first", "second", "0")
+	time.Sleep(blockWait)
+	pusher.do("LPUSH", "second", "c")
+	if result := client.read(); bulks(result) != "second c" {
+		t.Errorf("BLPOP after push: Expected second c, got %v", result)
+	}
+	if result := pusher.do("EXISTS", "second"); result.Num != 0 {
+		t.Errorf("EXISTS: Expected the served list to be deleted, got %v", result)
+	}
+
+	start := time.Now()
+	if result := client.do("BLPOP", "first", "0.1"); result.Kind != resp.KindNull {
+		t.Errorf("BLPOP timeout: Expected null, got %v", result)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("BLPOP timeout: Expected to wait 100ms, returned after %v", elapsed)
+	}
+
+	pusher.do("SET", "string", "value")
+	if result := client.do("BLPOP", "string", "0"); result.Kind != resp.KindError || !strings.HasPrefix(result.Str, "WRONGTYPE") {
+		t.Errorf("BLPOP wrong type: Expected WRONGTYPE, got %v", result)
+	}
+	if result := client.do("BLPOP", "first", "-1"); result.Kind != resp.KindError {
+		t.Errorf("BLPOP negative timeout: Expected error, got %v", result)
+	}
+	if result := client.do("BLPOP", "first", "soon"); result.Kind != resp.KindError {
+		t.Errorf("BLPOP invalid timeout: Expected error, got %v", result)
+	}
+}
+
+// TestBlockingFIFO tests that the clients blocked on a key are served in the order they blocked
+func TestBlockingFIFO(t *testing.T) {
+	_, connect := startServer(t)
+	pusher := connect()
+	clients := []*testClient{connect(), connect(), connect()}
+
+	for _, client := range clients {
+		client.send("BLPOP", "queue", "0")
+		time.Sleep(blockWait)
+	}
+	pusher.do("RPUSH", "queue", "first", "second")
+
+	for i, expected := range []string{"queue first", "queue second"} {
+		if result := clients[i].read(); bulks(result) != expected {
+			t.Errorf("Client %d: Expected %s, got %v", i, expected, result)
+		}
+	}
+
+	pusher.do("RPUSH", "queue", "third")
+	if result := clients[2].read(); bulks(result) != "queue third" {
+		t.Errorf("Client 2: Expected queue third, got %v", result)
+	}
+}
+
+// TestBLMoveAndBLMPop tests BLMOVE and BLMPOP
+func TestBLMoveAndBLMPop(t *testing.T) {
+	_, connect := startServer(t)
+	client, pusher := connect(), connect()
+
+	client.send("BLMOVE", "source", "destination", "RIGHT", "LEFT", "0")
+	time.Sleep(blockWait)
+	pusher.do("RPUSH", "source", "a", "b")
+	if result := client.read(); string(result.Bulk) != "b" {
+		t.Errorf("BLMOVE: Expected b, got %v", result)
+	}
+	if result := pusher.do("LRANGE", "destination", "0", "-1"); bulks(result) != "b" {
+		t.Errorf("LRANGE destination: Expected b, got %v", result)
+	}
+
+	// A client blocked on the destination is served by the move
+	client.send("BLPOP", "destination2", "0")
+	time.Sleep(blockWait)
+	pusher.do("BLMOVE", "source", "destination2", "LEFT", "LEFT", "0")
+	if result := client.read(); bulks(result) != "destination2 a" {
+		t.Errorf("BLPOP on BLMOVE destination: Expected destination2 a, got %v", result)
+	}
+
+	client.send("BLMPOP", "0", "2", "empty", "list", "LEFT", "COUNT", "2")
+	time.Sleep(blockWait)
+	pusher.do("RPUSH", "list", "x", "y", "z")
+	result := client.read()
+	if len(result.Array) != 2 || string(result.Array[0].Bulk) != "list" || bulks(result.Array[1]) != "x y" {
+		t.Errorf("BLMPOP: Expected list [x y], got %v", result)
+	}
+	if result := client.do("BLMPOP", "0", "0", "list", "LEFT"); result.Kind != resp.KindError {
+		t.Errorf("BLMPOP numkeys 0: Expected error, got %v", result)
+	}
+}
+
+// TestBlockingDisconnect tests that a client disconnecting while blocked is never served
+func TestBlockingDisconnect(t *testing.T) {
+	_, connect := startServer(t)
+	client, pusher := connect(), connect()
+
+	client.send("BLPOP", "list", "0")
+	time.Sleep(blockWait)
+	client.conn.Close()
+	time.Sleep(blockWait)
+
+	pusher.do("RPUSH", "list", "value")
+	if result := pusher.do("LLEN", "list"); result.Num != 1 {
+		t.Errorf("LLEN: Expected the element to stay in the list, got %v", result)
+	}
+}
+
+// TestBlockingInMulti tests that blocking commands do not block inside a transaction,
+// and that served clients are written to the AOF as plain pops
+func TestBlockingInMulti(t *testing.T) {
+	_, connect := startServer(t)
+	client, pusher := connect(), connect()
+
+	client.do("MULTI")
+	client.do("BLPOP", "list", "0")
+	if result := client.do("EXEC"); len(result.Array) != 1 || result.Array[0].Kind != resp.KindNull {
+		t.Errorf("EXEC: Expected [null], got %v", result)
+	}
+
+	client.send("BLPOP", "list", "0")
+	time.Sleep(blockWait)
+	pusher.do("MULTI")
+	pusher.do("RPUSH", "list", "a")
+	pusher.do("RPUSH", "list", "b")
+	pusher.do("EXEC")
+	if result := client.read(); bulks(result) != "list a" {
+		t.Errorf("BLPOP after EXEC: Expected list a, got %v", result)
+	}
+
+	data := readAOF(t)
+	if !strings.HasSuffix(string(data), "*2\r\n$4\r\nLPOP\r\n$4\r\nlist\r\n") {
+		t.Errorf("AOF: Expected the served BLPOP to be logged as LPOP, got %q", data)
+	}
+}
+
+// readEntries returns the key and the entry IDs of the first stream of an XREAD or XREADGROUP reply
+func readEntries(v resp.Value) string {
+	if len(v.Array) == 0 || len(v.Array[0].Array) != 2 {
+		return v.String()
+	}
+	return string(v.Array[0].Array[0].Bulk) + " " + entryIDs(v.Array[0].Array[1])
+}
+
+// TestXReadBlock tests XREAD and XREADGROUP with BLOCK, and that served XREADGROUP calls are
+// written to the AOF
+func TestXReadBlock(t *testing.T) {
+	_, connect := startServer(t)
+	client, adder := connect(), connect()
+
+	// $ stands for the last ID when the client blocked, so the entry added meanwhile is returned
+	adder.do("XADD", "stream", "1-0", "n", "1")
+	client.send("XREAD", "BLOCK", "0", "STREAMS", "other", "stream", "0", "$")
+	time.Sleep(blockWait)
+	adder.do("XADD", "stream", "2-0", "n", "2")
+	if result := client.read(); readEntries(result) != "stream 2-0" {
+		t.Errorf("XREAD BLOCK after XADD: Expected stream 2-0, got %v", result)
+	}
+	if result := client.do("XREAD", "BLOCK", "0", "STREAMS", "stream", "0"); readEntries(result) != "stream 1-0 2-0" {
+		t.Errorf("XREAD BLOCK with entries: Expected stream 1-0 2-0, got %v", result)
+	}
+
+	start := time.Now()
+	if result := client.do("XREAD", "BLOCK", "100", "STREAMS", "stream", "$"); result.Kind != resp.KindNull {
+		t.Errorf("XREAD BLOCK timeout: Expected null, got %v", result)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("XREAD BLOCK timeout: Expected to wait 100ms, returned after %v", elapsed)
+	}
+	for _, tt := range []struct {
+		timeout  string
+		expected string
+	}{
+		{"-1", "ERR timeout is negative"},
+		{"soon", "ERR timeout is not an integer or out of range"},
+		{"9223372036854775807", "ERR timeout is not an integer or out of range"},
+	} {
+		if result := client.do("XREAD", "BLOCK", tt.timeout, "STREAMS", "stream", "$"); result.Str != tt.expected {
+			t.Errorf("XREAD BLOCK %s: Expected %q, got %v", tt.timeout, tt.expected, result)
+		}
+	}
+
+	adder.do("XGROUP", "CREATE", "stream", "group", "$")
+	client.send("XREADGROUP", "GROUP", "group", "alice", "BLOCK", "0", "STREAMS", "stream", ">")
+	time.Sleep(blockWait)
+	adder.do("XADD", "stream", "3-0", "n", "3")
+	if result := client.read(); readEntries(result) != "stream 3-0" {
+		t.Errorf("XREADGROUP BLOCK after XADD: Expected stream 3-0, got %v", result)
+	}
+	// Reading the pending entries of the consumer does not block
+	if result := client.do("XREADGROUP", "GROUP", "group", "alice", "BLOCK", "0", "STREAMS", "stream", "3-0"); readEntries(result) != "stream " {
+		t.Errorf("XREADGROUP BLOCK with an ID: Expected no pending entries after 3-0, got %v", result)
+	}
+
+	// Nothing blocks inside a transaction
+	client.do("MULTI")
+	client.do("XREAD", "BLOCK", "0", "STREAMS", "stream", "$")
+	if result := client.do("EXEC"); len(result.Array) != 1 || result.Array[0].Kind != resp.KindNull {
+		t.Errorf("EXEC: Expected [null], got %v", result)
+	}
+
+	replayed, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.AOF.Close()
+	if result := command.XPending(replayed.DBs.DB(0), []string{"stream", "group"}); len(result.Array) == 0 || result.Array[0].Num != 1 {
+		t.Errorf("XPENDING after replay: Expected the entry delivered to alice to be pending, got %v", result)
+	}
+}
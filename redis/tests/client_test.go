@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"net"
+	"redis/resp"
+	"redis/server"
+	"testing"
+	"time"
+)
+
+// testClient is a connection to a server started by startServer
+type testClient struct {
+	t      testing.TB
+	conn   net.Conn
+	reader *resp.Resp
+}
+
+// startServer starts a server with an empty AOF on a random port
+// Returns the server and a function that connects a new client to it
+func startServer(t testing.TB) (*server.Server, func() *testClient) {
+	t.Helper()
+
+	srv := loadServer(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go srv.Serve(listener)
+
+	connect := func() *testClient {
+		t.Helper()
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return &testClient{t: t, conn: conn, reader: resp.NewResp(conn)}
+	}
+	return srv, connect
+}
+
+// send writes a command without waiting for the reply
+func (c *testClient) send(args ...string) {
+	c.t.Helper()
+	if _, err := c.conn.Write(commandValue(args...).Marshal()); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read reads the next value sent by the server, failing the test if none arrives within a second
+func (c *testClient) read() resp.Value {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	value, err := c.reader.Read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return value
+}
+
+// do sends a command and returns its reply
+func (c *testClient) do(args ...string) resp.Value {
+	c.t.Helper()
+	c.send(args...)
+	return c.read()
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"redis/resp"
+	"strings"
+	"testing"
+)
+
+// TestCommandDispatch tests that commands are found whatever their case, and that their
+// number of arguments is checked before they run
+func TestCommandDispatch(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	if result := client.do("set", "key", "value"); result.Str != "OK" {
+		t.Errorf("set: Expected OK, got %v", result)
+	}
+	if result := client.do("gEt", "key"); string(result.Bulk) != "value" {
+		t.Errorf("gEt: Expected value, got %v", result)
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"GET"}, "ERR wrong number of arguments for 'get' command"},
+		{[]string{"hgetall", "a", "b"}, "ERR wrong number of arguments for 'hgetall' command"},
+		{[]string{"MULTI", "now"}, "ERR wrong number of arguments for 'multi' command"},
+		{[]string{"SUBSCRIBE"}, "ERR wrong number of arguments for 'subscribe' command"},
+		{[]string{"NOPE", "a", "b"}, "ERR unknown command 'NOPE', with args beginning with: 'a' 'b' "},
+	}
+	for _, tt := range tests {
+		if result := client.do(tt.args...); result.Kind != resp.KindError || result.Str != tt.expected {
+			t.Errorf("%v: Expected %q, got %v", tt.args, tt.expected, result)
+		}
+	}
+
+	// Arity errors while queuing abort the transaction, like unknown commands
+	client.do("multi")
+	client.do("SET", "key", "other")
+	if result := client.do("LLEN"); result.Kind != resp.KindError {
+		t.Errorf("LLEN while queuing: Expected error, got %v", result)
+	}
+	if result := client.do("exec"); result.Kind != resp.KindError || !strings.HasPrefix(result.Str, "EXECABORT") {
+		t.Errorf("EXEC: Expected EXECABORT, got %v", result)
+	}
+	if result := client.do("GET", "key"); string(result.Bulk) != "value" {
+		t.Errorf("GET after EXECABORT: Expected value, got %v", result)
+	}
+}
+
+// TestCommandIntrospection tests COMMAND and its subcommands
+func TestCommandIntrospection(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	all := client.do("COMMAND")
+	count := client.do("COMMAND", "COUNT")
+	if count.Kind != resp.KindInteger || int(count.Num) != len(all.Array) || count.Num < 90 {
+		t.Fatalf("COMMAND COUNT: Expected the number of commands, got %v for %d commands", count, len(all.Array))
+	}
+	for _, info := range all.Array {
+		if len(info.Array) != 10 || info.Array[1].Num == 0 {
+			t.Errorf("COMMAND: Expected name, arity, flags, keys, categories, tips, specs and subcommands, got %v", info)
+		}
+	}
+
+	result := client.do("command", "info", "get", "LMOVE", "nope")
+	if len(result.Array) != 3 || result.Array[2].Kind != resp.KindNull {
+		t.Fatalf("COMMAND INFO: Expected 2 commands and null, got %v", result)
+	}
+	get := result.Array[0].Array
+	if string(get[0].Bulk) != "get" || get[1].Num != 2 || get[3].Num != 1 || get[4].Num != 1 || get[5].Num != 1 {
+		t.Errorf("COMMAND INFO get: Expected get, 2, 1, 1, 1, got %v", result.Array[0])
+	}
+	if flags := get[2].Array; len(flags) != 2 || flags[0].Str != "readonly" || flags[1].Str != "fast" {
+		t.Errorf("COMMAND INFO get: Expected readonly and fast flags, got %v", get[2])
+	}
+	if lmove := result.Array[1].Array; lmove[1].Num != 5 || lmove[4].Num != 2 || lmove[2].Array[0].Str != "write" {
+		t.Errorf("COMMAND INFO lmove: Expected arity 5, last key 2 and write, got %v", result.Array[1])
+	}
+
+	result = client.do("COMMAND", "INFO", "xread")
+	if flags := result.Array[0].Array[2]; len(flags.Array) != 3 || flags.Array[0].Str != "readonly" || flags.Array[1].Str != "blocking" || flags.Array[2].Str != "movablekeys" {
+		t.Errorf("COMMAND INFO xread: Expected readonly, blocking and movablekeys flags, got %v", flags)
+	}
+
+	result = client.do("COMMAND", "DOCS", "zadd", "nope")
+	if len(result.Array) != 2 || string(result.Array[0].Bulk) != "zadd" {
+		t.Fatalf("COMMAND DOCS: Expected zadd only, got %v", result)
+	}
+	if docs := result.Array[1].Array; string(docs[5].Bulk) != "sorted-set" || string(docs[3].Bulk) != "1.2.0" {
+		t.Errorf("COMMAND DOCS zadd: Expected group sorted-set since 1.2.0, got %v", result.Array[1])
+	}
+
+	if result := client.do("COMMAND", "COUNT", "extra"); result.Kind != resp.KindError {
+		t.Errorf("COMMAND COUNT extra: Expected error, got %v", result)
+	}
+	if result := client.do("COMMAND", "NOPE"); result.Kind != resp.KindError {
+		t.Errorf("COMMAND NOPE: Expected error, got %v", result)
+	}
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"redis/command"
+	"redis/resp"
 	"redis/storage"
 	"testing"
 )
@@ -12,13 +13,13 @@ func TestPing(t *testing.T) {
 
 	// Test PING without argument
 	result := command.Ping(s, []string{})
-	if result.Type != "string" || result.Str != "PONG" {
+	if result.Kind != resp.KindString || result.Str != "PONG" {
 		t.Errorf("Expected PONG, got %v", result)
 	}
 
 	// Test PING with argument
 	result = command.Ping(s, []string{"Hello"})
-	if result.Type != "string" || result.Str != "Hello" {
+	if result.Kind != resp.KindString || result.Str != "Hello" {
 		t.Errorf("Expected Hello, got %v", result)
 	}
 }
@@ -29,19 +30,19 @@ func TestSetAndGet(t *testing.T) {
 
 	// Test SET
 	setResult := command.Set(s, []string{"key", "value"})
-	if setResult.Type != "string" || setResult.Str != "OK" {
+	if setResult.Kind != resp.KindString || setResult.Str != "OK" {
 		t.Errorf("SET: Expected OK, got %v", setResult)
 	}
 
 	// Test GET
 	getResult := command.Get(s, []string{"key"})
-	if getResult.Type != "bulk" || getResult.Bulk != "value" {
+	if getResult.Kind != resp.KindBulk || string(getResult.Bulk) != "value" {
 		t.Errorf("GET: Expected value, got %v", getResult)
 	}
 
 	// Test GET non-existent key
 	getResult = command.Get(s, []string{"nonexistent"})
-	if getResult.Type != "null" {
+	if getResult.Kind != resp.KindNull {
 		t.Errorf("GET nonexistent: Expected null, got %v", getResult)
 	}
 }
@@ -55,13 +56,13 @@ func TestDel(t *testing.T) {
 
 	// Test DEL existing keys
 	delResult := command.Del(s, []string{"key1", "key2"})
-	if delResult.Type != "integer" || delResult.Num != 2 {
+	if delResult.Kind != resp.KindInteger || delResult.Num != 2 {
 		t.Errorf("DEL: Expected 2, got %v", delResult)
 	}
 
 	// Test DEL non-existent key
 	delResult = command.Del(s, []string{"nonexistent"})
-	if delResult.Type != "integer" || delResult.Num != 0 {
+	if delResult.Kind != resp.KindInteger || delResult.Num != 0 {
 		t.Errorf("DEL nonexistent: Expected 0, got %v", delResult)
 	}
 }
@@ -75,13 +76,13 @@ func TestExists(t *testing.T) {
 
 	// Test EXISTS on existing keys
 	existsResult := command.Exists(s, []string{"key1", "key2"})
-	if existsResult.Type != "integer" || existsResult.Num != 2 {
+	if existsResult.Kind != resp.KindInteger || existsResult.Num != 2 {
 		t.Errorf("EXISTS: Expected 2, got %v", existsResult)
 	}
 
 	// Test EXISTS on mix of existing and non-existing keys
 	existsResult = command.Exists(s, []string{"key1", "nonexistent"})
-	if existsResult.Type != "integer" || existsResult.Num != 1 {
+	if existsResult.Kind != resp.KindInteger || existsResult.Num != 1 {
 		t.Errorf("EXISTS mix: Expected 1, got %v", existsResult)
 	}
 }
@@ -92,20 +93,20 @@ func TestIncr(t *testing.T) {
 
 	// Test INCR on non-existent key
 	incrResult := command.Incr(s, []string{"counter"})
-	if incrResult.Type != "integer" || incrResult.Num != 1 {
+	if incrResult.Kind != resp.KindInteger || incrResult.Num != 1 {
 		t.Errorf("INCR new: Expected 1, got %v", incrResult)
 	}
 
 	// Test INCR on existing key
 	incrResult = command.Incr(s, []string{"counter"})
-	if incrResult.Type != "integer" || incrResult.Num != 2 {
+	if incrResult.Kind != resp.KindInteger || incrResult.Num != 2 {
 		t.Errorf("INCR existing: Expected 2, got %v", incrResult)
 	}
 
 	// Test INCR on non-integer value
 	command.Set(s, []string{"string", "hello"})
 	incrResult = command.Incr(s, []string{"string"})
-	if incrResult.Type != "error" {
+	if incrResult.Kind != resp.KindError {
 		t.Errorf("INCR non-integer: Expected error, got %v", incrResult)
 	}
 }
@@ -0,0 +1,289 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"redis/aof"
+	"redis/config"
+	"redis/resp"
+	"redis/server"
+	"strings"
+	"testing"
+)
+
+// TestConfigParse tests reading a configuration file, with the command line overriding it
+func TestConfigParse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	text := `# Comments and blank lines are ignored
+
+port 7000
+bind 127.0.0.1 "-::1"
+appendfilename "my file.aof"
+save 900 1
+save 60 1000
+auto-aof-rewrite-min-size 32mb
+APPENDFSYNC always
+proto-max-bulk-len 2gb
+`
+	if err := os.WriteFile(path, []byte(text), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load([]string{path, "--port", "7001", "--maxclients", "50", "--save", "10", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.File != path {
+		t.Errorf("File: Expected %s, got %s", path, cfg.File)
+	}
+	if cfg.Port != 7001 || cfg.MaxClients != 50 {
+		t.Errorf("Expected the command line to override the file, got port %d and maxclients %d", cfg.Port, cfg.MaxClients)
+	}
+	if strings.Join(cfg.Bind, ",") != "127.0.0.1,-::1" {
+		t.Errorf("bind: Expected 127.0.0.1 -::1, got %v", cfg.Bind)
+	}
+	if cfg.AppendFilename != "my file.aof" || cfg.AppendFsync != aof.FsyncAlways {
+		t.Errorf("Expected appendfilename 'my file.aof' and appendfsync always, got %q and %v", cfg.AppendFilename, cfg.AppendFsync)
+	}
+	if cfg.AutoAOFRewriteMinSize != 32*1024*1024 || cfg.ProtoMaxBulkLen != 2*1024*1024*1024 {
+		t.Errorf("Expected memory values of 32mb and 2gb, got %d and %d", cfg.AutoAOFRewriteMinSize, cfg.ProtoMaxBulkLen)
+	}
+	// The save lines of the file add up, and the command line replaces them
+	if len(cfg.Save) != 1 || cfg.Save[0] != (config.SaveParam{Seconds: 10, Changes: 5}) {
+		t.Errorf("save: Expected 10 5, got %v", cfg.Save)
+	}
+
+	cfg = config.Default()
+	if err := cfg.Parse("save 900 1\nsave 60 1000\n"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Save) != 2 || cfg.Save[1] != (config.SaveParam{Seconds: 60, Changes: 1000}) {
+		t.Errorf("save: Expected 900 1 60 1000, got %v", cfg.Save)
+	}
+	if err := cfg.Parse(`save ""`); err != nil || len(cfg.Save) != 0 {
+		t.Errorf(`save "": Expected no save point, got %v and %v`, cfg.Save, err)
+	}
+}
+
+// TestConfigParseErrors tests that invalid directives are rejected with the line they are on
+func TestConfigParseErrors(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"port 6379\nport abc", "at line 2\n>>> 'port abc'\nargument couldn't be parsed into an integer"},
+		{"port 70000", "argument must be between 0 and 65535 inclusive"},
+		{"appendonly maybe", "argument must be 'yes' or 'no'"},
+		{"appendfsync sometimes", "argument(s) must be one of the following: always, everysec, no"},
+		{"auto-aof-rewrite-min-size 12zb", "argument must be a memory value"},
+		{"proto-max-bulk-len 1000", "argument must be between 1048576 and"},
+		{"dbfilename dir/dump.rdb", "dbfilename can't be a path, just a filename"},
+		{"save 900", "Invalid save parameters"},
+		{"unknown-option yes", "Bad directive or wrong number of arguments"},
+		{"port 1 2", "Bad directive or wrong number of arguments"},
+		{`dir "unterminated`, "Unbalanced quotes in configuration line"},
+	}
+	for _, tt := range tests {
+		err := config.Default().Parse(tt.text)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: Expected an error containing %q, got %v", tt.text, tt.expected, err)
+		}
+	}
+
+	if _, err := config.Load([]string{"--port", "abc"}); err == nil || !strings.Contains(err.Error(), "invalid option '--port abc'") {
+		t.Errorf("--port abc: Expected an invalid option error, got %v", err)
+	}
+	if _, err := config.Load([]string{"--port"}); err == nil {
+		t.Error("--port: Expected error, got nil")
+	}
+}
+
+// TestConfigGetSet tests CONFIG GET with patterns, and that CONFIG SET applies all of its
+// parameters or none of them
+func TestConfigGetSet(t *testing.T) {
+	srv, connect := startServer(t)
+	client := connect()
+
+	result := client.do("CONFIG", "GET", "append*", "appendonly")
+	if result.Kind != resp.KindArray || len(result.Array) != 8 {
+		t.Fatalf("CONFIG GET append*: Expected 4 parameters, got %v", result)
+	}
+	if name, value := string(result.Array[0].Bulk), string(result.Array[1].Bulk); name != "appendonly" || value != "yes" {
+		t.Errorf("CONFIG GET append*: Expected appendonly yes first, got %s %s", name, value)
+	}
+	if result := client.do("CONFIG", "GET", "SAVE"); len(result.Array) != 2 || string(result.Array[1].Bulk) != "3600 1 300 100 60 10000" {
+		t.Errorf("CONFIG GET SAVE: Expected the default save points, got %v", result)
+	}
+	if result := client.do("CONFIG", "GET", "nothing*"); result.Kind != resp.KindArray || len(result.Array) != 0 {
+		t.Errorf("CONFIG GET nothing*: Expected an empty array, got %v", result)
+	}
+
+	if result := client.do("CONFIG", "SET", "appendfsync", "always", "maxclients", "500", "save", ""); result.Str != "OK" {
+		t.Fatalf("CONFIG SET: Expected OK, got %v", result)
+	}
+	if policy := srv.AOF.Stats().Policy; policy != aof.FsyncAlways {
+		t.Errorf("CONFIG SET appendfsync always: Expected the AOF policy to change, got %v", policy)
+	}
+	if result := client.do("CONFIG", "GET", "maxclients"); string(result.Array[1].Bulk) != "500" {
+		t.Errorf("CONFIG GET maxclients: Expected 500, got %v", result)
+	}
+
+	errors := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"maxclients", "100", "port", "7000"}, "ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config"},
+		{[]string{"maxclients", "100", "nope", "1"}, "ERR Unknown option or number of arguments for CONFIG SET - 'nope'"},
+		{[]string{"maxclients", "100", "MAXCLIENTS", "200"}, "ERR CONFIG SET failed (possibly related to argument 'MAXCLIENTS') - duplicate parameter"},
+		{[]string{"maxclients", "100", "appendfsync", "never"}, "ERR CONFIG SET failed (possibly related to argument 'appendfsync') - argument(s) must be one of the following: always, everysec, no"},
+		{[]string{"maxclients", "100", "dir", "/nonexistent/dir"}, "ERR CONFIG SET failed (possibly related to argument 'dir') - "},
+	}
+	for _, tt := range errors {
+		result := client.do(append([]string{"CONFIG", "SET"}, tt.args...)...)
+		if result.Kind != resp.KindError || !strings.HasPrefix(result.Str, tt.expected) {
+			t.Errorf("CONFIG SET %v: Expected %q, got %v", tt.args, tt.expected, result)
+		}
+	}
+	// None of the failed commands changed maxclients
+	if result := client.do("CONFIG", "GET", "maxclients"); string(result.Array[1].Bulk) != "500" {
+		t.Errorf("CONFIG GET maxclients after failed sets: Expected 500, got %v", result)
+	}
+
+	if result := client.do("CONFIG", "SET", "maxclients"); result.Kind != resp.KindError {
+		t.Errorf("CONFIG SET maxclients: Expected error, got %v", result)
+	}
+	if result := client.do("CONFIG", "FOO"); result.Str != "ERR unknown subcommand 'FOO'. Try CONFIG HELP." {
+		t.Errorf("CONFIG FOO: Expected unknown subcommand, got %v", result)
+	}
+
+	// CONFIG can be queued in a transaction, which already runs while no other command does
+	client.do("MULTI")
+	client.do("CONFIG", "SET", "maxclients", "600")
+	client.do("CONFIG", "GET", "maxclients")
+	if result := client.do("EXEC"); len(result.Array) != 2 || result.Array[0].Str != "OK" || bulks(result.Array[1]) != "maxclients 600" {
+		t.Errorf("CONFIG SET and GET in EXEC: Expected OK and 600, got %v", result)
+	}
+}
+
+// TestConfigMaxClients tests that clients beyond maxclients are rejected
+func TestConfigMaxClients(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+	if result := client.do("CONFIG", "SET", "maxclients", "1"); result.Str != "OK" {
+		t.Fatalf("CONFIG SET maxclients 1: Expected OK, got %v", result)
+	}
+
+	rejected := connect()
+	if result := rejected.read(); result.Str != "ERR max number of clients reached" {
+		t.Errorf("Second client: Expected max number of clients reached, got %v", result)
+	}
+	info := string(client.do("INFO", "stats").Bulk)
+	if !strings.Contains(info, "rejected_connections:1\r\n") || !strings.Contains(info, "total_connections_received:2\r\n") {
+		t.Errorf("INFO stats: Expected a rejected connection out of 2, got %q", info)
+	}
+
+	if result := client.do("CONFIG", "RESETSTAT"); result.Str != "OK" {
+		t.Fatalf("CONFIG RESETSTAT: Expected OK, got %v", result)
+	}
+	info = string(client.do("INFO", "stats").Bulk)
+	// INFO itself is counted once the counters are reset
+	for _, field := range []string{"total_connections_received:0\r\n", "total_commands_processed:1\r\n", "rejected_connections:0\r\n"} {
+		if !strings.Contains(info, field) {
+			t.Errorf("INFO stats after CONFIG RESETSTAT: Expected %q in %q", field, info)
+		}
+	}
+	if info := string(client.do("INFO", "clients").Bulk); !strings.Contains(info, "connected_clients:1\r\nmaxclients:1\r\n") {
+		t.Errorf("INFO clients: Expected 1 client out of 1, got %q", info)
+	}
+}
+
+// TestConfigRewrite tests that CONFIG REWRITE updates the configuration file in place,
+// keeping its comments and unknown lines
+func TestConfigRewrite(t *testing.T) {
+	srv, connect := startServer(t)
+	client := connect()
+
+	if result := client.do("CONFIG", "REWRITE"); result.Str != "ERR The server is running without a config file" {
+		t.Errorf("CONFIG REWRITE without a file: Expected error, got %v", result)
+	}
+
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	original := "# My configuration\nmaxclients 100\nsave 900 1\nsave 60 1000\nloglevel notice\n"
+	if err := os.WriteFile(path, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	srv.Config.File = path
+
+	if result := client.do("CONFIG", "SET", "maxclients", "200", "save", "30 2", "dbfilename", "my dump.rdb"); result.Str != "OK" {
+		t.Fatalf("CONFIG SET: Expected OK, got %v", result)
+	}
+	if result := client.do("CONFIG", "REWRITE"); result.Str != "OK" {
+		t.Fatalf("CONFIG REWRITE: Expected OK, got %v", result)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"# My configuration\nmaxclients 200\nsave 30 2\nloglevel notice\n", "# Generated by CONFIG REWRITE\n", "dbfilename \"my dump.rdb\"\n"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("CONFIG REWRITE: Expected %q in %q", line, data)
+		}
+	}
+
+	// The rewritten file is read back as the current configuration, except for the
+	// unknown lines that the server does not read
+	cfg := config.Default()
+	if err := cfg.Parse(strings.Replace(string(data), "loglevel notice\n", "", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxClients != 200 || cfg.DBFilename != "my dump.rdb" || len(cfg.Save) != 1 {
+		t.Errorf("Rewritten file: Expected maxclients 200, dbfilename 'my dump.rdb' and save 30 2, got %+v", cfg)
+	}
+
+	// A second rewrite does not change the file
+	if result := client.do("CONFIG", "REWRITE"); result.Str != "OK" {
+		t.Fatalf("CONFIG REWRITE: Expected OK, got %v", result)
+	}
+	if again, err := os.ReadFile(path); err != nil || string(again) != string(data) {
+		t.Errorf("Second CONFIG REWRITE: Expected %q, got %q", data, again)
+	}
+}
+
+// TestConfigRewriteRelativePath tests that CONFIG REWRITE updates the configuration file given
+// with a relative path, after the server changed to another dir
+func TestConfigRewriteRelativePath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	confDir, dataDir := t.TempDir(), t.TempDir()
+	if err := os.Chdir(confDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("redis.conf", []byte("dir "+dataDir+"\nmaxclients 100\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load([]string{"redis.conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := server.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.AOF.Close()
+	srv.Config.MaxClients = 200
+	if err := srv.Config.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(confDir, "redis.conf")
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), "maxclients 200\n") {
+		t.Errorf("CONFIG REWRITE: Expected maxclients 200 in %s, got %q", path, data)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "redis.conf")); !os.IsNotExist(err) {
+		t.Errorf("CONFIG REWRITE: Expected no redis.conf in dir, got %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"redis/command"
+	"redis/config"
+	"redis/rdb"
+	"redis/resp"
+	"redis/server"
+	"redis/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSelect tests that each client works in its own selected database
+func TestSelect(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+	other := connect()
+
+	client.do("SET", "key", "zero")
+	if result := client.do("SELECT", "1"); result.Str != "OK" {
+		t.Fatalf("SELECT 1: Expected OK, got %v", result)
+	}
+	if result := client.do("GET", "key"); result.Kind != resp.KindNull {
+		t.Errorf("GET key in database 1: Expected null, got %v", result)
+	}
+	client.do("SET", "key", "one")
+	if result := other.do("GET", "key"); string(result.Bulk) != "zero" {
+		t.Errorf("GET key from another client in database 0: Expected zero, got %v", result)
+	}
+	if result := client.do("DBSIZE"); result.Num != 1 {
+		t.Errorf("DBSIZE: Expected 1, got %v", result)
+	}
+
+	for _, tt := range []struct {
+		index    string
+		expected string
+	}{
+		{"16", "ERR DB index is out of range"},
+		{"-1", "ERR DB index is out of range"},
+		{"one", "ERR value is not an integer or out of range"},
+	} {
+		if result := client.do("SELECT", tt.index); result.Str != tt.expected {
+			t.Errorf("SELECT %s: Expected %q, got %v", tt.index, tt.expected, result)
+		}
+	}
+
+	// A SELECT queued in a transaction applies to the commands after it, and stays selected
+	client.do("MULTI")
+	client.do("SELECT", "2")
+	client.do("SET", "key", "two")
+	if result := client.do("EXEC"); len(result.Array) != 2 || result.Array[0].Str != "OK" {
+		t.Fatalf("EXEC: Expected OK twice, got %v", result)
+	}
+	if result := client.do("GET", "key"); string(result.Bulk) != "two" {
+		t.Errorf("GET key after EXEC: Expected two, got %v", result)
+	}
+}
+
+// TestMoveSwapFlush tests MOVE, SWAPDB, FLUSHDB and FLUSHALL
+func TestMoveSwapFlush(t *testing.T) {
+	dbs := storage.NewDatabases(16)
+	db0, db1 := dbs.DB(0), dbs.DB(1)
+
+	command.Set(db0, []string{"key", "value", "EX", "100"})
+	command.Set(db0, []string{"taken", "zero"})
+	command.Set(db1, []string{"taken", "one"})
+	if result := command.Move(db0, []string{"key", "1"}); result.Num != 1 {
+		t.Errorf("MOVE key 1: Expected 1, got %v", result)
+	}
+	if result := command.Get(db1, []string{"key"}); string(result.Bulk) != "value" {
+		t.Errorf("GET key in database 1: Expected value, got %v", result)
+	}
+	if result := command.TTL(db1, []string{"key"}); result.Num <= 0 {
+		t.Errorf("TTL key after MOVE: Expected the TTL to be kept, got %v", result)
+	}
+	if result := command.Exists(db0, []string{"key"}); result.Num != 0 {
+		t.Errorf("EXISTS key in database 0: Expected 0, got %v", result)
+	}
+	for _, tt := range []struct {
+		args     []string
+		expected resp.Value
+	}{
+		{[]string{"taken", "1"}, resp.Integer(0)},
+		{[]string{"missing", "1"}, resp.Integer(0)},
+		{[]string{"taken", "0"}, resp.Err("ERR source and destination objects are the same")},
+		{[]string{"taken", "16"}, resp.Err("ERR DB index is out of range")},
+		{[]string{"taken", "x"}, resp.Err("ERR value is not an integer or out of range")},
+	} {
+		if result := command.Move(db0, tt.args); result.String() != tt.expected.String() {
+			t.Errorf("MOVE %v: Expected %v, got %v", tt.args, tt.expected, result)
+		}
+	}
+
+	// The watchers and the data stay with the index of the database
+	watched := db0.Watch("taken")
+	if result := command.SwapDB(db0, []string{"0", "1"}); result.Str != "OK" {
+		t.Fatalf("SWAPDB 0 1: Expected OK, got %v", result)
+	}
+	if !dbs.Changed(watched) {
+		t.Error("SWAPDB: Expected the watched key to be changed")
+	}
+	dbs.Unwatch(watched)
+	if result := command.Get(db0, []string{"key"}); string(result.Bulk) != "value" {
+		t.Errorf("GET key in database 0 after SWAPDB: Expected value, got %v", result)
+	}
+	if result := command.DBSize(db1, nil); result.Num != 1 {
+		t.Errorf("DBSIZE of database 1 after SWAPDB: Expected 1, got %v", result)
+	}
+	if result := command.SwapDB(db0, []string{"0", "x"}); result.Str != "ERR invalid second DB index" {
+		t.Errorf("SWAPDB 0 x: Expected invalid second DB index, got %v", result)
+	}
+
+	if result := command.FlushDB(db0, []string{"ASYNC"}); result.Str != "OK" {
+		t.Fatalf("FLUSHDB ASYNC: Expected OK, got %v", result)
+	}
+	if result := command.DBSize(db0, nil); result.Num != 0 {
+		t.Errorf("DBSIZE after FLUSHDB: Expected 0, got %v", result)
+	}
+	if result := command.DBSize(db1, nil); result.Num != 1 {
+		t.Errorf("DBSIZE of database 1 after FLUSHDB: Expected 1, got %v", result)
+	}
+	if result := command.FlushAll(db0, []string{"NOW"}); result.Kind != resp.KindError {
+		t.Errorf("FLUSHALL NOW: Expected error, got %v", result)
+	}
+	command.FlushAll(db0, []string{"SYNC"})
+	if result := command.DBSize(db1, nil); result.Num != 0 {
+		t.Errorf("DBSIZE of database 1 after FLUSHALL: Expected 0, got %v", result)
+	}
+}
+
+// TestSwapDBServesBlocked tests that a client blocked on a key is served once SWAPDB brings it in
+func TestSwapDBServesBlocked(t *testing.T) {
+	_, connect := startServer(t)
+	blocked := connect()
+	client := connect()
+
+	client.do("SELECT", "1")
+	client.do("RPUSH", "list", "a")
+	blocked.send("BLPOP", "list", "5")
+	time.Sleep(20 * time.Millisecond)
+	client.do("SWAPDB", "0", "1")
+	if result := blocked.read(); bulks(result) != "list a" {
+		t.Errorf("BLPOP after SWAPDB: Expected list a, got %v", result)
+	}
+	if result := client.do("EXISTS", "list"); result.Num != 0 {
+		t.Errorf("EXISTS list in database 1: Expected 0, got %v", result)
+	}
+}
+
+// TestDBAOF tests that a SELECT is written to the AOF whenever a write runs in another
+// database than the previous one, and that replaying the AOF restores every database
+func TestDBAOF(t *testing.T) {
+	_, connect := startServer(t)
+	first := connect()
+	second := connect()
+
+	first.do("SET", "a", "0")
+	second.do("SELECT", "3")
+	second.do("SET", "b", "3")
+	second.do("SET", "c", "3")
+	first.do("MOVE", "a", "5")
+	first.do("SELECT", "5")
+	first.do("GET", "a")
+	second.do("FLUSHDB")
+
+	expected := ""
+	for _, args := range [][]string{
+		{"SELECT", "0"}, {"SET", "a", "0"},
+		{"SELECT", "3"}, {"SET", "b", "3"}, {"SET", "c", "3"},
+		{"SELECT", "0"}, {"MOVE", "a", "5"},
+		{"SELECT", "3"}, {"FLUSHDB"},
+	} {
+		expected += string(commandValue(args...).Marshal())
+	}
+	if data := readAOF(t); string(data) != expected {
+		t.Errorf("AOF: Expected %q, got %q", expected, data)
+	}
+
+	srv := loadServer(t,
+		[]string{"SET", "a", "0"},
+		[]string{"SELECT", "2"},
+		[]string{"MULTI"}, []string{"SET", "b", "2"}, []string{"SELECT", "4"}, []string{"SET", "c", "4"}, []string{"EXEC"},
+		[]string{"SWAPDB", "0", "1"},
+	)
+	for _, tt := range []struct {
+		db       int
+		key      string
+		expected string
+	}{
+		{1, "a", "0"},
+		{2, "b", "2"},
+		{4, "c", "4"},
+	} {
+		if result := command.Get(srv.DBs.DB(tt.db), []string{tt.key}); string(result.Bulk) != tt.expected {
+			t.Errorf("GET %s in database %d after replay: Expected %s, got %v", tt.key, tt.db, tt.expected, result)
+		}
+	}
+	if result := command.DBSize(srv.DBs.DB(0), nil); result.Num != 0 {
+		t.Errorf("DBSIZE of database 0 after replay: Expected 0, got %v", result)
+	}
+}
+
+// TestDBAOFInvalidSelect tests that an AOF selecting a database that does not exist, or
+// without an index, is refused instead of crashing the server
+func TestDBAOFInvalidSelect(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, args := range [][]string{{"SELECT"}, {"SELECT", "16"}, {"SELECT", "one"}, {"SELECT", "1", "2"}} {
+		if err := os.Chdir(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		data := append(commandValue(args...).Marshal(), commandValue("SET", "key", "value").Marshal()...)
+		if err := os.WriteFile("database.aof", data, 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		srv, err := server.NewServer(config.Default())
+		if err == nil {
+			srv.AOF.Close()
+			t.Errorf("NewServer with %v in the AOF: Expected error", args)
+		} else if !strings.Contains(err.Error(), "SELECT") {
+			t.Errorf("NewServer with %v in the AOF: Expected an error about SELECT, got %v", args, err)
+		}
+	}
+}
+
+// TestDBSnapshot tests that rewrites and RDB files keep every database
+func TestDBSnapshot(t *testing.T) {
+	dbs := storage.NewDatabases(16)
+	command.Set(dbs.DB(0), []string{"a", "0"})
+	command.Set(dbs.DB(7), []string{"b", "7"})
+
+	snap := dbs.Snapshot()
+	defer snap.Release()
+	var rewritten []string
+	snap.Rewrite(func(args []string) {
+		rewritten = append(rewritten, strings.Join(args, " "))
+	})
+	if result := strings.Join(rewritten, ","); result != "SELECT 0,SET a 0,SELECT 7,SET b 7" {
+		t.Errorf("Rewrite: Expected the keys of databases 0 and 7, got %s", result)
+	}
+
+	data := saveSnapshot(t, snap)
+	loaded := storage.NewDatabases(16)
+	d, err := rdb.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.LoadRDB(d); err != nil {
+		t.Fatal(err)
+	}
+	if result := command.Get(loaded.DB(7), []string{"b"}); string(result.Bulk) != "7" {
+		t.Errorf("GET b in database 7 after loading: Expected 7, got %v", result)
+	}
+	if result := command.DBSize(loaded.DB(0), nil); result.Num != 1 {
+		t.Errorf("DBSIZE of database 0 after loading: Expected 1, got %v", result)
+	}
+
+	// A file with more databases than the server has is refused
+	if _, err := loadRDB(data); err == nil || !strings.Contains(err.Error(), "database 7") {
+		t.Errorf("LoadRDB into a single database: Expected error, got %v", err)
+	}
+}
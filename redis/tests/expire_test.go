@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"redis/command"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExpireAndTTL tests the EXPIRE, PEXPIRE, TTL, PTTL and PERSIST commands
+func TestExpireAndTTL(t *testing.T) {
+	s := storage.NewStorage()
+
+	// Test TTL on a missing key and on a key without expiry
+	if result := command.TTL(s, []string{"key"}); result.Num != -2 {
+		t.Errorf("TTL missing: Expected -2, got %v", result)
+	}
+	command.Set(s, []string{"key", "value"})
+	if result := command.TTL(s, []string{"key"}); result.Num != -1 {
+		t.Errorf("TTL no expiry: Expected -1, got %v", result)
+	}
+
+	// Test EXPIRE and TTL
+	if result := command.Expire(s, []string{"key", "100"}); result.Kind != resp.KindInteger || result.Num != 1 {
+		t.Errorf("EXPIRE: Expected 1, got %v", result)
+	}
+	if result := command.TTL(s, []string{"key"}); result.Num != 100 {
+		t.Errorf("TTL: Expected 100, got %v", result)
+	}
+
+	// Test EXPIRE on a missing key
+	if result := command.Expire(s, []string{"missing", "100"}); result.Num != 0 {
+		t.Errorf("EXPIRE missing: Expected 0, got %v", result)
+	}
+
+	// Test PERSIST
+	if result := command.Persist(s, []string{"key"}); result.Num != 1 {
+		t.Errorf("PERSIST: Expected 1, got %v", result)
+	}
+	if result := command.PTTL(s, []string{"key"}); result.Num != -1 {
+		t.Errorf("PTTL after PERSIST: Expected -1, got %v", result)
+	}
+
+	// Test lazy expiry on access
+	command.PExpire(s, []string{"key", "20"})
+	time.Sleep(40 * time.Millisecond)
+	if result := command.Get(s, []string{"key"}); result.Kind != resp.KindNull {
+		t.Errorf("GET expired: Expected null, got %v", result)
+	}
+
+	// Test that a timestamp in the past deletes the key
+	command.Set(s, []string{"key", "value"})
+	command.PExpireAt(s, []string{"key", "1"})
+	if result := command.Exists(s, []string{"key"}); result.Num != 0 {
+		t.Errorf("PEXPIREAT past: Expected 0, got %v", result)
+	}
+
+	// Test invalid arguments
+	if result := command.Expire(s, []string{"key", "abc"}); result.Kind != resp.KindError {
+		t.Errorf("EXPIRE invalid: Expected error, got %v", result)
+	}
+}
+
+// TestSetOptions tests the EX, PX, NX, XX, GET and KEEPTTL options of SET
+func TestSetOptions(t *testing.T) {
+	s := storage.NewStorage()
+
+	// Test NX
+	if result := command.Set(s, []string{"key", "one", "NX"}); result.Str != "OK" {
+		t.Errorf("SET NX new: Expected OK, got %v", result)
+	}
+	if result := command.Set(s, []string{"key", "two", "NX"}); result.Kind != resp.KindNull {
+		t.Errorf("SET NX existing: Expected null, got %v", result)
+	}
+
+	// Test XX
+	if result := command.Set(s, []string{"other", "one", "XX"}); result.Kind != resp.KindNull {
+		t.Errorf("SET XX missing: Expected null, got %v", result)
+	}
+
+	// Test GET
+	if result := command.Set(s, []string{"key", "two", "GET"}); result.Kind != resp.KindBulk || string(result.Bulk) != "one" {
+		t.Errorf("SET GET: Expected one, got %v", result)
+	}
+
+	// Test EX and KEEPTTL
+	command.Set(s, []string{"key", "three", "EX", "100"})
+	command.Set(s, []string{"key", "four", "KEEPTTL"})
+	if result := command.TTL(s, []string{"key"}); result.Num != 100 {
+		t.Errorf("SET KEEPTTL: Expected 100, got %v", result)
+	}
+
+	// Test that a plain SET clears the TTL
+	command.Set(s, []string{"key", "five"})
+	if result := command.TTL(s, []string{"key"}); result.Num != -1 {
+		t.Errorf("SET: Expected -1, got %v", result)
+	}
+
+	// Test invalid combinations
+	if result := command.Set(s, []string{"key", "v", "NX", "XX"}); result.Kind != resp.KindError {
+		t.Errorf("SET NX XX: Expected error, got %v", result)
+	}
+	if result := command.Set(s, []string{"key", "v", "EX", "0"}); result.Kind != resp.KindError {
+		t.Errorf("SET EX 0: Expected error, got %v", result)
+	}
+	if result := command.Set(s, []string{"key", "v", "EX", "10", "PX", "10"}); result.Kind != resp.KindError {
+		t.Errorf("SET EX PX: Expected error, got %v", result)
+	}
+}
+
+// TestActiveExpireCycle tests that expired keys are removed without being accessed
+func TestActiveExpireCycle(t *testing.T) {
+	s := storage.NewStorage()
+
+	for i := 0; i < 100; i++ {
+		key := "key" + strconv.Itoa(i)
+		command.Set(s, []string{key, "value", "PX", "10"})
+	}
+	command.Set(s, []string{"persistent", "value"})
+	time.Sleep(20 * time.Millisecond)
+
+	if deleted := s.ActiveExpireCycle(); deleted != 100 {
+		t.Errorf("ActiveExpireCycle: Expected 100 deleted keys, got %d", deleted)
+	}
+	if result := command.Exists(s, []string{"persistent"}); result.Num != 1 {
+		t.Errorf("ActiveExpireCycle: Expected persistent key to survive, got %v", result)
+	}
+}
+
+// TestRewriteExpire tests that expiries are converted to absolute times for the AOF
+func TestRewriteExpire(t *testing.T) {
+	before := storage.Now()
+
+	cmd, args := command.RewriteExpire("EXPIRE", []string{"key", "10"})
+	at, _ := strconv.ParseInt(args[1], 10, 64)
+	if cmd != "PEXPIREAT" || at < before+10000 || at > storage.Now()+10000 {
+		t.Errorf("EXPIRE: Expected PEXPIREAT key ~%d, got %s %v", before+10000, cmd, args)
+	}
+
+	cmd, args = command.RewriteExpire("SET", []string{"key", "value", "NX", "EX", "10"})
+	if cmd != "SET" || args[2] != "NX" || args[3] != "PXAT" {
+		t.Errorf("SET EX: Expected SET key value NX PXAT <ms>, got %s %v", cmd, args)
+	}
+
+	cmd, args = command.RewriteExpire("GET", []string{"key"})
+	if cmd != "GET" || len(args) != 1 {
+		t.Errorf("GET: Expected unchanged command, got %s %v", cmd, args)
+	}
+}
+
+// TestServerInvalidExpire tests that the server validates the expiry of SET as given by the
+// client, before making it absolute for the AOF
+func TestServerInvalidExpire(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	for _, args := range [][]string{{"EX", "-5"}, {"EX", "0"}, {"PX", "0"}, {"PX", "-1"}} {
+		result := client.do(append([]string{"SET", "key", "value"}, args...)...)
+		if result.Str != "ERR invalid expire time in 'set' command" {
+			t.Errorf("SET key value %v: Expected invalid expire time, got %v", args, result)
+		}
+	}
+	if result := client.do("EXISTS", "key"); result.Num != 0 {
+		t.Errorf("EXISTS key: Expected 0, got %v", result)
+	}
+
+	// A valid expiry is still written to the AOF as an absolute time
+	client.do("SET", "key", "value", "EX", "100")
+	if data := string(readAOF(t)); !strings.Contains(data, "PXAT") || strings.Contains(data, "$2\r\nEX\r\n") {
+		t.Errorf("AOF: Expected SET with PXAT, got %q", data)
+	}
+}
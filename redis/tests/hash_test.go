@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"redis/command"
+	"redis/resp"
+	"redis/storage"
+	"sync"
+	"testing"
+)
+
+// TestHashFields tests the HSET, HGET, HMGET, HDEL, HLEN and HEXISTS commands
+func TestHashFields(t *testing.T) {
+	s := storage.NewStorage()
+
+	// Test HSET with new and existing fields
+	if result := command.HSet(s, []string{"user", "name", "ann", "age", "30"}); result.Num != 2 {
+		t.Errorf("HSET: Expected 2, got %v", result)
+	}
+	if result := command.HSet(s, []string{"user", "name", "bob", "city", "rome"}); result.Num != 1 {
+		t.Errorf("HSET existing: Expected 1, got %v", result)
+	}
+	if result := command.HSet(s, []string{"user", "name"}); result.Kind != resp.KindError {
+		t.Errorf("HSET odd arguments: Expected error, got %v", result)
+	}
+
+	// Test HGET and HMGET
+	if result := command.HGet(s, []string{"user", "name"}); string(result.Bulk) != "bob" {
+		t.Errorf("HGET: Expected bob, got %v", result)
+	}
+	if result := command.HGet(s, []string{"user", "missing"}); result.Kind != resp.KindNull {
+		t.Errorf("HGET missing: Expected null, got %v", result)
+	}
+	result := command.HMGet(s, []string{"user", "age", "missing", "city"})
+	if len(result.Array) != 3 || string(result.Array[0].Bulk) != "30" || result.Array[1].Kind != resp.KindNull || string(result.Array[2].Bulk) != "rome" {
+		t.Errorf("HMGET: Expected [30 null rome], got %v", result)
+	}
+
+	// Test HSETNX
+	if result := command.HSetNX(s, []string{"user", "name", "eve"}); result.Num != 0 {
+		t.Errorf("HSETNX existing: Expected 0, got %v", result)
+	}
+	if result := command.HSetNX(s, []string{"user", "zip", "00100"}); result.Num != 1 {
+		t.Errorf("HSETNX new: Expected 1, got %v", result)
+	}
+
+	// Test HLEN and HEXISTS
+	if result := command.HLen(s, []string{"user"}); result.Num != 4 {
+		t.Errorf("HLEN: Expected 4, got %v", result)
+	}
+	if result := command.HExists(s, []string{"user", "zip"}); result.Num != 1 {
+		t.Errorf("HEXISTS: Expected 1, got %v", result)
+	}
+
+	// Test HDEL removes the key together with the last field
+	if result := command.HDel(s, []string{"user", "name", "age", "missing"}); result.Num != 2 {
+		t.Errorf("HDEL: Expected 2, got %v", result)
+	}
+	command.HDel(s, []string{"user", "city", "zip"})
+	if result := command.Exists(s, []string{"user"}); result.Num != 0 {
+		t.Errorf("EXISTS empty hash: Expected 0, got %v", result)
+	}
+}
+
+// TestHashGetAll tests the HGETALL, HKEYS and HVALS commands
+func TestHashGetAll(t *testing.T) {
+	s := storage.NewStorage()
+	command.HSet(s, []string{"hash", "a", "1", "b", "2"})
+
+	result := command.HGetAll(s, []string{"hash"})
+	pairs := map[string]string{}
+	for i := 0; i+1 < len(result.Array); i += 2 {
+		pairs[string(result.Array[i].Bulk)] = string(result.Array[i+1].Bulk)
+	}
+	if len(result.Array) != 4 || pairs["a"] != "1" || pairs["b"] != "2" {
+		t.Errorf("HGETALL: Expected a 1 b 2, got %v", result)
+	}
+
+	if result := command.HKeys(s, []string{"hash"}); sortedBulks(result) != "a b" {
+		t.Errorf("HKEYS: Expected a b, got %v", result)
+	}
+	if result := command.HVals(s, []string{"hash"}); sortedBulks(result) != "1 2" {
+		t.Errorf("HVALS: Expected 1 2, got %v", result)
+	}
+
+	if result := command.HGetAll(s, []string{"missing"}); result.Kind != resp.KindMap || len(result.Array) != 0 {
+		t.Errorf("HGETALL missing: Expected empty map, got %v", result)
+	}
+}
+
+// TestHashIncr tests the HINCRBY and HINCRBYFLOAT commands
+func TestHashIncr(t *testing.T) {
+	s := storage.NewStorage()
+
+	if result := command.HIncrBy(s, []string{"hash", "counter", "5"}); result.Num != 5 {
+		t.Errorf("HINCRBY new: Expected 5, got %v", result)
+	}
+	if result := command.HIncrBy(s, []string{"hash", "counter", "-7"}); result.Num != -2 {
+		t.Errorf("HINCRBY: Expected -2, got %v", result)
+	}
+	if result := command.HIncrByFloat(s, []string{"hash", "price", "10.5"}); string(result.Bulk) != "10.5" {
+		t.Errorf("HINCRBYFLOAT new: Expected 10.5, got %v", result)
+	}
+	if result := command.HIncrByFloat(s, []string{"hash", "price", "0.25"}); string(result.Bulk) != "10.75" {
+		t.Errorf("HINCRBYFLOAT: Expected 10.75, got %v", result)
+	}
+
+	// Test non numeric fields and overflow
+	command.HSet(s, []string{"hash", "name", "ann"})
+	if result := command.HIncrBy(s, []string{"hash", "name", "1"}); result.Str != "ERR hash value is not an integer" {
+		t.Errorf("HINCRBY string: Expected error, got %v", result)
+	}
+	if result := command.HIncrByFloat(s, []string{"hash", "name", "1"}); result.Str != "ERR hash value is not a float" {
+		t.Errorf("HINCRBYFLOAT string: Expected error, got %v", result)
+	}
+	command.HSet(s, []string{"hash", "big", "9223372036854775807"})
+	if result := command.HIncrBy(s, []string{"hash", "big", "1"}); result.Kind != resp.KindError {
+		t.Errorf("HINCRBY overflow: Expected error, got %v", result)
+	}
+}
+
+// TestHashConcurrentIncr tests that field increments are atomic
+func TestHashConcurrentIncr(t *testing.T) {
+	s := storage.NewStorage()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				command.HIncrBy(s, []string{"hash", "counter", "1"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if result := command.HGet(s, []string{"hash", "counter"}); string(result.Bulk) != "1000" {
+		t.Errorf("HINCRBY concurrent: Expected 1000, got %v", result)
+	}
+}
+
+// TestHashWrongType tests that hash commands reject keys of other types and vice versa
+func TestHashWrongType(t *testing.T) {
+	s := storage.NewStorage()
+	command.Set(s, []string{"string", "value"})
+	command.HSet(s, []string{"hash", "field", "value"})
+
+	if result := command.HSet(s, []string{"string", "field", "value"}); result.Kind != resp.KindError {
+		t.Errorf("HSET on string: Expected error, got %v", result)
+	}
+	if result := command.HGetAll(s, []string{"string"}); result.Kind != resp.KindError {
+		t.Errorf("HGETALL on string: Expected error, got %v", result)
+	}
+	if result := command.Get(s, []string{"hash"}); result.Kind != resp.KindError {
+		t.Errorf("GET on hash: Expected error, got %v", result)
+	}
+	if result := command.LPush(s, []string{"hash", "a"}); result.Kind != resp.KindError {
+		t.Errorf("LPUSH on hash: Expected error, got %v", result)
+	}
+}
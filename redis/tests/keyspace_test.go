@@ -0,0 +1,348 @@
+package tests
+
+import (
+	"redis/command"
+	"redis/resp"
+	"redis/storage"
+	"strconv"
+	"testing"
+)
+
+// TestKeys tests the KEYS command with glob-style patterns
+func TestKeys(t *testing.T) {
+	s := storage.NewStorage()
+	for _, key := range []string{"hello", "hallo", "hxllo", "hllo", "heeeello", "h*llo"} {
+		command.Set(s, []string{key, "v"})
+	}
+	command.Set(s, []string{"expired", "v", "PXAT", "1"})
+
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"*", "h*llo hallo heeeello hello hllo hxllo"},
+		{"h?llo", "h*llo hallo hello hxllo"},
+		{"h*llo", "h*llo hallo heeeello hello hllo hxllo"},
+		{"h[ae]llo", "hallo hello"},
+		{"h[^e]llo", "h*llo hallo hxllo"},
+		{"h[a-b]llo", "hallo"},
+		{`h\*llo`, "h*llo"},
+		{"nothing*", ""},
+	}
+	for _, tt := range tests {
+		if result := sortedBulks(command.Keys(s, []string{tt.pattern})); result != tt.expected {
+			t.Errorf("KEYS %s: Expected %q, got %q", tt.pattern, tt.expected, result)
+		}
+	}
+}
+
+// scanAll iterates SCAN with the given options until the cursor is 0 again, calling between
+// after each call, and returns how many times each key was returned
+func scanAll(t *testing.T, s *storage.Storage, between func(), options ...string) map[string]int {
+	t.Helper()
+	seen := make(map[string]int)
+	cursor := "0"
+	for calls := 0; ; calls++ {
+		if calls > 1000 {
+			t.Fatal("SCAN: Expected the iteration to end")
+		}
+		result := command.Scan(s, append([]string{cursor}, options...))
+		if result.Kind != resp.KindArray || len(result.Array) != 2 {
+			t.Fatalf("SCAN %s: Expected a cursor and keys, got %v", cursor, result)
+		}
+		for _, key := range result.Array[1].Array {
+			seen[string(key.Bulk)]++
+		}
+		if cursor = string(result.Array[0].Bulk); cursor == "0" {
+			return seen
+		}
+		between()
+	}
+}
+
+// TestScan tests that SCAN returns every key with its MATCH, COUNT and TYPE options
+func TestScan(t *testing.T) {
+	s := storage.NewStorage()
+	for i := 0; i < 500; i++ {
+		command.Set(s, []string{"str:" + strconv.Itoa(i), "v"})
+	}
+	for i := 0; i < 100; i++ {
+		command.RPush(s, []string{"list:" + strconv.Itoa(i), "v"})
+	}
+	command.Set(s, []string{"str:expired", "v", "PXAT", "1"})
+
+	tests := []struct {
+		options  []string
+		expected int
+	}{
+		{nil, 600},
+		{[]string{"COUNT", "1"}, 600},
+		{[]string{"COUNT", "1000"}, 600},
+		{[]string{"MATCH", "list:*"}, 100},
+		{[]string{"MATCH", "str:1?", "COUNT", "50"}, 10},
+		{[]string{"TYPE", "list"}, 100},
+		{[]string{"TYPE", "STRING", "MATCH", "*:1*"}, 111},
+		{[]string{"TYPE", "hash"}, 0},
+	}
+	for _, tt := range tests {
+		seen := scanAll(t, s, func() {}, tt.options...)
+		if len(seen) != tt.expected {
+			t.Errorf("SCAN %v: Expected %d keys, got %d", tt.options, tt.expected, len(seen))
+		}
+		for key, n := range seen {
+			if n != 1 {
+				t.Errorf("SCAN %v: Expected %s once, got it %d times", tt.options, key, n)
+			}
+		}
+	}
+
+	// A cursor past the last shard ends the iteration
+	if result := command.Scan(s, []string{"100000"}); string(result.Array[0].Bulk) != "0" || len(result.Array[1].Array) != 0 {
+		t.Errorf("SCAN 100000: Expected an empty last page, got %v", result)
+	}
+
+	errors := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"abc"}, "ERR invalid cursor"},
+		{[]string{"-1"}, "ERR invalid cursor"},
+		{[]string{"0", "COUNT"}, "ERR syntax error"},
+		{[]string{"0", "COUNT", "0"}, "ERR syntax error"},
+		{[]string{"0", "COUNT", "x"}, "ERR value is not an integer or out of range"},
+		{[]string{"0", "TYPE", "car"}, "ERR unknown type name 'car'"},
+		{[]string{"0", "LIMIT", "1"}, "ERR syntax error"},
+	}
+	for _, tt := range errors {
+		if result := command.Scan(s, tt.args); result.Str != tt.expected {
+			t.Errorf("SCAN %v: Expected %q, got %v", tt.args, tt.expected, result)
+		}
+	}
+}
+
+// TestScanCoverage tests that every key present for the whole iteration is returned exactly
+// once, while keys are added and removed between the calls
+func TestScanCoverage(t *testing.T) {
+	s := storage.NewStorage()
+	for i := 0; i < 1000; i++ {
+		command.Set(s, []string{"stable:" + strconv.Itoa(i), "v"})
+		command.Set(s, []string{"removed:" + strconv.Itoa(i), "v"})
+	}
+
+	added := 0
+	seen := scanAll(t, s, func() {
+		for i := 0; i < 50; i++ {
+			command.Set(s, []string{"added:" + strconv.Itoa(added), "v"})
+			command.Del(s, []string{"removed:" + strconv.Itoa(added)})
+			added++
+		}
+	}, "COUNT", "20")
+
+	for i := 0; i < 1000; i++ {
+		if key := "stable:" + strconv.Itoa(i); seen[key] != 1 {
+			t.Errorf("SCAN: Expected %s once, got it %d times", key, seen[key])
+		}
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("SCAN: Expected %s at most once, got it %d times", key, n)
+		}
+	}
+	if added == 0 {
+		t.Error("Expected keys to change during the iteration")
+	}
+}
+
+// TestRandomKey tests that RANDOMKEY returns existing keys, and null once there is none
+func TestRandomKey(t *testing.T) {
+	s := storage.NewStorage()
+	if result := command.RandomKey(s, nil); result.Kind != resp.KindNull {
+		t.Errorf("RANDOMKEY on an empty database: Expected null, got %v", result)
+	}
+
+	command.Set(s, []string{"expired", "v", "PXAT", "1"})
+	if result := command.RandomKey(s, nil); result.Kind != resp.KindNull {
+		t.Errorf("RANDOMKEY with only an expired key: Expected null, got %v", result)
+	}
+
+	keys := map[string]bool{"a": true, "b": true, "c": true}
+	for key := range keys {
+		command.Set(s, []string{key, "v"})
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		key := string(command.RandomKey(s, nil).Bulk)
+		if !keys[key] {
+			t.Fatalf("RANDOMKEY: Expected a, b or c, got %q", key)
+		}
+		seen[key] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("RANDOMKEY: Expected different keys, got %v", seen)
+	}
+}
+
+// TestRenameAndType tests the RENAME, RENAMENX and TYPE commands
+func TestRenameAndType(t *testing.T) {
+	s := storage.NewStorage()
+	command.Set(s, []string{"str", "v", "EX", "100"})
+	command.RPush(s, []string{"list", "a"})
+	command.HSet(s, []string{"hash", "f", "v"})
+	command.SAdd(s, []string{"set", "m"})
+	command.ZAdd(s, []string{"zset", "1", "m"})
+	command.XAdd(s, []string{"stream", "*", "f", "v"})
+
+	for key, expected := range map[string]string{
+		"str": "string", "list": "list", "hash": "hash", "set": "set", "zset": "zset", "stream": "stream", "missing": "none",
+	} {
+		if result := command.Type(s, []string{key}); result.Str != expected {
+			t.Errorf("TYPE %s: Expected %s, got %v", key, expected, result)
+		}
+	}
+
+	if result := command.Rename(s, []string{"str", "renamed"}); result.Str != "OK" {
+		t.Fatalf("RENAME str renamed: Expected OK, got %v", result)
+	}
+	if result := command.Get(s, []string{"renamed"}); string(result.Bulk) != "v" {
+		t.Errorf("GET renamed: Expected v, got %v", result)
+	}
+	if result := command.TTL(s, []string{"renamed"}); result.Num <= 0 {
+		t.Errorf("TTL renamed: Expected the TTL to be kept, got %v", result)
+	}
+	if result := command.Exists(s, []string{"str"}); result.Num != 0 {
+		t.Errorf("EXISTS str after RENAME: Expected 0, got %v", result)
+	}
+
+	// RENAME overwrites the destination, and its TTL
+	command.Set(s, []string{"ttl", "v", "EX", "100"})
+	if result := command.Rename(s, []string{"list", "ttl"}); result.Str != "OK" {
+		t.Fatalf("RENAME list ttl: Expected OK, got %v", result)
+	}
+	if result := command.TTL(s, []string{"ttl"}); result.Num != -1 {
+		t.Errorf("TTL ttl after RENAME: Expected -1, got %v", result)
+	}
+	if result := command.Type(s, []string{"ttl"}); result.Str != "list" {
+		t.Errorf("TYPE ttl after RENAME: Expected list, got %v", result)
+	}
+
+	tests := []struct {
+		handler  func(*storage.Storage, []string) resp.Value
+		args     []string
+		expected resp.Value
+	}{
+		{command.Rename, []string{"missing", "x"}, resp.Err("ERR no such key")},
+		{command.Rename, []string{"hash", "hash"}, resp.OK()},
+		{command.RenameNX, []string{"missing", "x"}, resp.Err("ERR no such key")},
+		{command.RenameNX, []string{"hash", "set"}, resp.Integer(0)},
+		{command.RenameNX, []string{"hash", "hash"}, resp.Integer(0)},
+		{command.RenameNX, []string{"hash", "hash2"}, resp.Integer(1)},
+	}
+	for _, tt := range tests {
+		if result := tt.handler(s, tt.args); result.String() != tt.expected.String() {
+			t.Errorf("%v: Expected %v, got %v", tt.args, tt.expected, result)
+		}
+	}
+	if result := command.HGet(s, []string{"hash2", "f"}); string(result.Bulk) != "v" {
+		t.Errorf("HGET hash2 f: Expected v, got %v", result)
+	}
+}
+
+// TestCopyAndTouch tests the COPY and TOUCH commands
+func TestCopyAndTouch(t *testing.T) {
+	dbs := storage.NewDatabases(16)
+	s, other := dbs.DB(0), dbs.DB(1)
+	command.RPush(s, []string{"list", "a", "b"})
+	command.Expire(s, []string{"list", "100"})
+	command.Set(s, []string{"str", "v"})
+
+	if result := command.Copy(s, []string{"list", "copy"}); result.Num != 1 {
+		t.Fatalf("COPY list copy: Expected 1, got %v", result)
+	}
+	// The copy is independent of the original
+	command.RPush(s, []string{"copy", "c"})
+	if result := bulks(command.LRange(s, []string{"list", "0", "-1"})); result != "a b" {
+		t.Errorf("LRANGE list after changing the copy: Expected a b, got %s", result)
+	}
+	if result := command.TTL(s, []string{"copy"}); result.Num <= 0 {
+		t.Errorf("TTL copy: Expected the TTL to be copied, got %v", result)
+	}
+
+	tests := []struct {
+		args     []string
+		expected resp.Value
+	}{
+		{[]string{"missing", "x"}, resp.Integer(0)},
+		{[]string{"str", "list"}, resp.Integer(0)},
+		{[]string{"str", "list", "REPLACE"}, resp.Integer(1)},
+		{[]string{"str", "str", "DB", "1"}, resp.Integer(1)},
+		{[]string{"list", "str", "DB", "1"}, resp.Integer(0)},
+		{[]string{"str", "str"}, resp.Err("ERR source and destination objects are the same")},
+		{[]string{"str", "x", "DB", "16"}, resp.Err("ERR DB index is out of range")},
+		{[]string{"str", "x", "DB", "one"}, resp.Err("ERR value is not an integer or out of range")},
+		{[]string{"str", "x", "DB"}, resp.Err("ERR syntax error")},
+		{[]string{"str", "x", "NOW"}, resp.Err("ERR syntax error")},
+	}
+	for _, tt := range tests {
+		if result := command.Copy(s, tt.args); result.String() != tt.expected.String() {
+			t.Errorf("COPY %v: Expected %v, got %v", tt.args, tt.expected, result)
+		}
+	}
+	if result := command.Get(s, []string{"list"}); string(result.Bulk) != "v" {
+		t.Errorf("GET list after COPY REPLACE: Expected v, got %v", result)
+	}
+	if result := command.TTL(s, []string{"list"}); result.Num != -1 {
+		t.Errorf("TTL list after COPY REPLACE: Expected -1, got %v", result)
+	}
+	if result := command.Get(other, []string{"str"}); string(result.Bulk) != "v" {
+		t.Errorf("GET str in database 1: Expected v, got %v", result)
+	}
+
+	if result := command.Touch(s, []string{"list", "str", "missing", "str"}); result.Num != 3 {
+		t.Errorf("TOUCH: Expected 3, got %v", result)
+	}
+}
+
+// TestKeyspaceAOF tests that the keyspace commands that change data are written to the AOF
+// and replayed, while the others are not written
+func TestKeyspaceAOF(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	client.do("SET", "a", "1")
+	client.do("RENAME", "a", "b")
+	client.do("RENAMENX", "b", "b")
+	client.do("COPY", "b", "c", "DB", "2")
+	client.do("COPY", "missing", "c")
+	client.do("KEYS", "*")
+	client.do("SCAN", "0")
+	client.do("RANDOMKEY")
+	client.do("TYPE", "b")
+	client.do("TOUCH", "b")
+	client.do("RENAME", "missing", "x")
+
+	expected := ""
+	for _, args := range [][]string{
+		{"SELECT", "0"}, {"SET", "a", "1"}, {"RENAME", "a", "b"}, {"COPY", "b", "c", "DB", "2"},
+	} {
+		expected += string(commandValue(args...).Marshal())
+	}
+	if data := readAOF(t); string(data) != expected {
+		t.Errorf("AOF: Expected %q, got %q", expected, data)
+	}
+
+	srv := loadServer(t,
+		[]string{"SET", "a", "1"},
+		[]string{"RENAME", "a", "b"},
+		[]string{"COPY", "b", "c", "DB", "2"},
+		[]string{"RENAMENX", "b", "d"},
+	)
+	if result := command.Get(srv.DBs.DB(0), []string{"d"}); string(result.Bulk) != "1" {
+		t.Errorf("GET d after replay: Expected 1, got %v", result)
+	}
+	if result := command.Get(srv.DBs.DB(2), []string{"c"}); string(result.Bulk) != "1" {
+		t.Errorf("GET c in database 2 after replay: Expected 1, got %v", result)
+	}
+	if result := command.Keys(srv.DBs.DB(0), []string{"*"}); bulks(result) != "d" {
+		t.Errorf("KEYS * after replay: Expected d, got %v", result)
+	}
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"redis/command"
+	"redis/resp"
+	"redis/storage"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// bulks returns the bulk strings of an array reply, joined by spaces
+func bulks(v resp.Value) string {
+	items := make([]string, len(v.Array))
+	for i, item := range v.Array {
+		items[i] = string(item.Bulk)
+	}
+	return strings.Join(items, " ")
+}
+
+// sortedBulks is like bulks but sorts the strings first, for replies without a defined order
+func sortedBulks(v resp.Value) string {
+	items := strings.Fields(bulks(v))
+	sort.Strings(items)
+	return strings.Join(items, " ")
+}
+
+// TestPushAndPop tests the LPUSH, RPUSH, LPOP, RPOP and LLEN commands
+func TestPushAndPop(t *testing.T) {
+	s := storage.NewStorage()
+
+	// Test pushing to both ends
+	if result := command.RPush(s, []string{"list", "b", "c"}); result.Num != 2 {
+		t.Errorf("RPUSH: Expected 2, got %v", result)
+	}
+	if result := command.LPush(s, []string{"list", "a"}); result.Num != 3 {
+		t.Errorf("LPUSH: Expected 3, got %v", result)
+	}
+	if result := command.LRange(s, []string{"list", "0", "-1"}); bulks(result) != "a b c" {
+		t.Errorf("LRANGE: Expected a b c, got %v", result)
+	}
+
+	// Test popping from both ends
+	if result := command.LPop(s, []string{"list"}); result.Kind != resp.KindBulk || string(result.Bulk) != "a" {
+		t.Errorf("LPOP: Expected a, got %v", result)
+	}
+	if result := command.RPop(s, []string{"list", "5"}); bulks(result) != "c b" {
+		t.Errorf("RPOP count: Expected c b, got %v", result)
+	}
+
+	// Test that an emptied list is removed
+	if result := command.Exists(s, []string{"list"}); result.Num != 0 {
+		t.Errorf("EXISTS empty list: Expected 0, got %v", result)
+	}
+	if result := command.LPop(s, []string{"list"}); result.Kind != resp.KindNull {
+		t.Errorf("LPOP missing: Expected null, got %v", result)
+	}
+	if result := command.LLen(s, []string{"list"}); result.Num != 0 {
+		t.Errorf("LLEN missing: Expected 0, got %v", result)
+	}
+
+	// Test the ring buffer wrapping around while growing
+	for i := 0; i < 20; i++ {
+		command.LPush(s, []string{"ring", "l"})
+		command.RPush(s, []string{"ring", "r"})
+	}
+	if result := command.LLen(s, []string{"ring"}); result.Num != 40 {
+		t.Errorf("LLEN: Expected 40, got %v", result)
+	}
+	if result := command.LIndex(s, []string{"ring", "19"}); string(result.Bulk) != "l" {
+		t.Errorf("LINDEX 19: Expected l, got %v", result)
+	}
+	if result := command.LIndex(s, []string{"ring", "-20"}); string(result.Bulk) != "r" {
+		t.Errorf("LINDEX -20: Expected r, got %v", result)
+	}
+}
+
+// TestListEditing tests the LSET, LTRIM, LREM and LINSERT commands
+func TestListEditing(t *testing.T) {
+	s := storage.NewStorage()
+	command.RPush(s, []string{"list", "a", "x", "b", "x", "c", "x"})
+
+	// Test LSET
+	if result := command.LSet(s, []string{"list", "-1", "y"}); result.Str != "OK" {
+		t.Errorf("LSET: Expected OK, got %v", result)
+	}
+	if result := command.LSet(s, []string{"list", "10", "y"}); result.Kind != resp.KindError {
+		t.Errorf("LSET out of range: Expected error, got %v", result)
+	}
+	if result := command.LSet(s, []string{"missing", "0", "y"}); result.Kind != resp.KindError {
+		t.Errorf("LSET missing: Expected error, got %v", result)
+	}
+
+	// Test LREM from the tail
+	if result := command.LRem(s, []string{"list", "-1", "x"}); result.Num != 1 {
+		t.Errorf("LREM: Expected 1, got %v", result)
+	}
+	if result := command.LRange(s, []string{"list", "0", "-1"}); bulks(result) != "a x b c y" {
+		t.Errorf("LRANGE after LREM: Expected a x b c y, got %v", result)
+	}
+
+	// Test LINSERT
+	if result := command.LInsert(s, []string{"list", "BEFORE", "b", "z"}); result.Num != 6 {
+		t.Errorf("LINSERT: Expected 6, got %v", result)
+	}
+	if result := command.LInsert(s, []string{"list", "AFTER", "nope", "z"}); result.Num != -1 {
+		t.Errorf("LINSERT missing pivot: Expected -1, got %v", result)
+	}
+
+	// Test LTRIM
+	command.LTrim(s, []string{"list", "1", "-2"})
+	if result := command.LRange(s, []string{"list", "0", "-1"}); bulks(result) != "x z b c" {
+		t.Errorf("LRANGE after LTRIM: Expected x z b c, got %v", result)
+	}
+	command.LTrim(s, []string{"list", "5", "10"})
+	if result := command.Exists(s, []string{"list"}); result.Num != 0 {
+		t.Errorf("LTRIM empty range: Expected list to be removed, got %v", result)
+	}
+}
+
+// TestLMove tests the LMOVE command
+func TestLMove(t *testing.T) {
+	s := storage.NewStorage()
+	command.RPush(s, []string{"src", "a", "b", "c"})
+
+	if result := command.LMove(s, []string{"src", "dst", "LEFT", "RIGHT"}); string(result.Bulk) != "a" {
+		t.Errorf("LMOVE: Expected a, got %v", result)
+	}
+
+	// Test rotating a list onto itself
+	if result := command.LMove(s, []string{"src", "src", "RIGHT", "LEFT"}); string(result.Bulk) != "c" {
+		t.Errorf("LMOVE rotate: Expected c, got %v", result)
+	}
+	if result := command.LRange(s, []string{"src", "0", "-1"}); bulks(result) != "c b" {
+		t.Errorf("LRANGE after rotate: Expected c b, got %v", result)
+	}
+
+	if result := command.LMove(s, []string{"missing", "dst", "LEFT", "LEFT"}); result.Kind != resp.KindNull {
+		t.Errorf("LMOVE missing: Expected null, got %v", result)
+	}
+}
+
+// TestWrongType tests that list and string commands reject keys of the other type
+func TestWrongType(t *testing.T) {
+	s := storage.NewStorage()
+	command.Set(s, []string{"string", "value"})
+	command.RPush(s, []string{"list", "a"})
+
+	wrongType := "WRONGTYPE Operation against a key holding the wrong kind of value"
+	results := []resp.Value{
+		command.LPush(s, []string{"string", "a"}),
+		command.LRange(s, []string{"string", "0", "-1"}),
+		command.LMove(s, []string{"list", "string", "LEFT", "LEFT"}),
+		command.Get(s, []string{"list"}),
+		command.Incr(s, []string{"list"}),
+		command.Set(s, []string{"list", "value", "GET"}),
+	}
+	for i, result := range results {
+		if result.Kind != resp.KindError || result.Str != wrongType {
+			t.Errorf("Case %d: Expected WRONGTYPE error, got %v", i, result)
+		}
+	}
+
+	// The failed LMOVE must not have popped from the source
+	if result := command.LLen(s, []string{"list"}); result.Num != 1 {
+		t.Errorf("LLEN after failed LMOVE: Expected 1, got %v", result)
+	}
+
+	// A plain SET overwrites a key of any type
+	if result := command.Set(s, []string{"list", "value"}); result.Str != "OK" {
+		t.Errorf("SET over list: Expected OK, got %v", result)
+	}
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"redis/resp"
+	"strings"
+	"testing"
+)
+
+// TestMultiExec tests queuing commands with MULTI and running them with EXEC
+func TestMultiExec(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	if result := client.do("MULTI"); result.Str != "OK" {
+		t.Fatalf("MULTI: Expected OK, got %v", result)
+	}
+	if result := client.do("MULTI"); result.Kind != resp.KindError {
+		t.Errorf("Nested MULTI: Expected error, got %v", result)
+	}
+	for _, args := range [][]string{{"SET", "key", "1"}, {"INCR", "key"}, {"LPUSH", "key", "x"}, {"GET", "key"}} {
+		if result := client.do(args...); result.Str != "QUEUED" {
+			t.Errorf("%s: Expected QUEUED, got %v", args[0], result)
+		}
+	}
+
+	// Runtime errors do not stop the rest of the transaction
+	result := client.do("EXEC")
+	if len(result.Array) != 4 {
+		t.Fatalf("EXEC: Expected 4 replies, got %v", result)
+	}
+	if result.Array[1].Num != 2 || result.Array[2].Kind != resp.KindError || string(result.Array[3].Bulk) != "2" {
+		t.Errorf("EXEC: Expected 2, WRONGTYPE and 2, got %v", result)
+	}
+
+	if result := client.do("EXEC"); result.Kind != resp.KindError {
+		t.Errorf("EXEC without MULTI: Expected error, got %v", result)
+	}
+}
+
+// TestExecAbort tests that a command rejected while queuing discards the whole transaction
+func TestExecAbort(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	client.do("MULTI")
+	client.do("SET", "key", "value")
+	if result := client.do("NOPE"); result.Kind != resp.KindError {
+		t.Errorf("Unknown command: Expected error, got %v", result)
+	}
+	if result := client.do("EXEC"); result.Kind != resp.KindError || !strings.HasPrefix(result.Str, "EXECABORT") {
+		t.Errorf("EXEC: Expected EXECABORT, got %v", result)
+	}
+	if result := client.do("GET", "key"); result.Kind != resp.KindNull {
+		t.Errorf("GET: Expected null, got %v", result)
+	}
+}
+
+// TestDiscard tests that DISCARD drops the queued commands
+func TestDiscard(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	if result := client.do("DISCARD"); result.Kind != resp.KindError {
+		t.Errorf("DISCARD without MULTI: Expected error, got %v", result)
+	}
+	client.do("MULTI")
+	client.do("SET", "key", "value")
+	if result := client.do("DISCARD"); result.Str != "OK" {
+		t.Errorf("DISCARD: Expected OK, got %v", result)
+	}
+	if result := client.do("GET", "key"); result.Kind != resp.KindNull {
+		t.Errorf("GET: Expected null, got %v", result)
+	}
+}
+
+// TestWatch tests that EXEC fails when a watched key is modified by another client
+func TestWatch(t *testing.T) {
+	_, connect := startServer(t)
+	client, other := connect(), connect()
+	client.do("SET", "balance", "10")
+
+	// Unmodified watched keys do not abort the transaction
+	client.do("WATCH", "balance")
+	client.do("MULTI")
+	client.do("INCR", "balance")
+	if result := client.do("EXEC"); len(result.Array) != 1 || result.Array[0].Num != 11 {
+		t.Errorf("EXEC: Expected [11], got %v", result)
+	}
+
+	client.do("WATCH", "balance")
+	other.do("HSET", "unrelated", "field", "value")
+	other.do("INCR", "balance")
+	client.do("MULTI")
+	client.do("INCR", "balance")
+	if result := client.do("EXEC"); result.Kind != resp.KindNull {
+		t.Errorf("EXEC after modification: Expected null, got %v", result)
+	}
+	if result := client.do("GET", "balance"); string(result.Bulk) != "12" {
+		t.Errorf("GET: Expected 12, got %v", result)
+	}
+
+	// EXEC unwatches, so the next transaction succeeds
+	other.do("INCR", "balance")
+	client.do("MULTI")
+	client.do("INCR", "balance")
+	if result := client.do("EXEC"); len(result.Array) != 1 || result.Array[0].Num != 14 {
+		t.Errorf("EXEC after EXEC: Expected [14], got %v", result)
+	}
+
+	// Keys that do not exist yet can be watched too
+	client.do("WATCH", "missing")
+	other.do("LPUSH", "missing", "x")
+	client.do("MULTI")
+	client.do("GET", "balance")
+	if result := client.do("EXEC"); result.Kind != resp.KindNull {
+		t.Errorf("EXEC after creation: Expected null, got %v", result)
+	}
+
+	client.do("WATCH", "balance")
+	other.do("INCR", "balance")
+	if result := client.do("UNWATCH"); result.Str != "OK" {
+		t.Errorf("UNWATCH: Expected OK, got %v", result)
+	}
+	client.do("MULTI")
+	client.do("GET", "balance")
+	if result := client.do("EXEC"); len(result.Array) != 1 {
+		t.Errorf("EXEC after UNWATCH: Expected 1 reply, got %v", result)
+	}
+}
+
+// TestServerCommandsInMulti tests that the commands executed by the server itself, rather
+// than on the storage, also run when queued in a transaction
+func TestServerCommandsInMulti(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	commands := [][]string{
+		{"HELLO"}, {"SELECT", "0"}, {"INFO", "server"}, {"CONFIG", "GET", "port"},
+		{"LASTSAVE"}, {"PUBLISH", "channel", "message"}, {"PUBSUB", "NUMPAT"},
+	}
+	client.do("MULTI")
+	for _, args := range commands {
+		client.do(args...)
+	}
+	result := client.do("EXEC")
+	if len(result.Array) != len(commands) {
+		t.Fatalf("EXEC: Expected %d replies, got %v", len(commands), result)
+	}
+	for i, args := range commands {
+		if reply := result.Array[i]; reply.Kind == resp.KindError {
+			t.Errorf("%s in EXEC: Expected a reply, got %v", args[0], reply)
+		}
+	}
+}
+
+// TestMultiAOF tests that a transaction is written to the AOF as one MULTI ... EXEC block,
+// and that only complete blocks are replayed
+func TestMultiAOF(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+	client.do("MULTI")
+	client.do("SET", "a", "1")
+	client.do("PUBLISH", "channel", "message")
+	client.do("SET", "b", "2")
+	client.do("EXEC")
+
+	data := readAOF(t)
+	expected := "*2\r\n$6\r\nSELECT\r\n$1\r\n0\r\n*1\r\n$5\r\nMULTI\r\n*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n" +
+		"*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n*1\r\n$4\r\nEXEC\r\n"
+	if string(data) != expected {
+		t.Errorf("AOF: Expected %q, got %q", expected, data)
+	}
+
+	srv := loadServer(t,
+		[]string{"MULTI"}, []string{"SET", "a", "1"}, []string{"EXEC"},
+		[]string{"MULTI"}, []string{"SET", "b", "2"},
+	)
+	if value, ok, _ := srv.DBs.DB(0).Get("a"); !ok || value != "1" {
+		t.Errorf("Replayed transaction: Expected a=1, got %q", value)
+	}
+	if _, ok, _ := srv.DBs.DB(0).Get("b"); ok {
+		t.Error("Truncated transaction: Expected b not to be set")
+	}
+}
@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"redis/glob"
+	"redis/resp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pushed formats a confirmation or message pushed by the server, such as "subscribe news 1"
+func pushed(v resp.Value) string {
+	items := make([]string, len(v.Array))
+	for i, item := range v.Array {
+		if item.Kind == resp.KindInteger {
+			items[i] = strconv.FormatInt(item.Num, 10)
+		} else {
+			items[i] = string(item.Bulk)
+		}
+	}
+	return strings.Join(items, " ")
+}
+
+// TestSubscribeAndPublish tests SUBSCRIBE, UNSUBSCRIBE and PUBLISH, and the subscribed mode
+func TestSubscribeAndPublish(t *testing.T) {
+	_, connect := startServer(t)
+	subscriber, publisher := connect(), connect()
+
+	subscriber.send("SUBSCRIBE", "news", "sports")
+	for i, channel := range []string{"news", "sports"} {
+		result := subscriber.read()
+		if expected := "subscribe " + channel + " " + strconv.Itoa(i+1); pushed(result) != expected {
+			t.Errorf("SUBSCRIBE: Expected %s, got %v", expected, result)
+		}
+	}
+
+	if result := publisher.do("PUBLISH", "news", "hello"); result.Num != 1 {
+		t.Errorf("PUBLISH: Expected 1 receiver, got %v", result)
+	}
+	if result := publisher.do("PUBLISH", "weather", "sunny"); result.Num != 0 {
+		t.Errorf("PUBLISH without subscribers: Expected 0 receivers, got %v", result)
+	}
+	if result := subscriber.read(); pushed(result) != "message news hello" {
+		t.Errorf("Message: Expected message news hello, got %v", result)
+	}
+
+	// Only subscription commands and PING are allowed in subscribed mode
+	if result := subscriber.do("GET", "key"); result.Kind != resp.KindError || !strings.Contains(result.Str, "only (P)SUBSCRIBE") {
+		t.Errorf("GET in subscribed mode: Expected error, got %v", result)
+	}
+	if result := subscriber.do("PING"); pushed(result) != "pong " {
+		t.Errorf("PING in subscribed mode: Expected pong, got %v", result)
+	}
+
+	subscriber.send("UNSUBSCRIBE")
+	for i, channel := range []string{"news", "sports"} {
+		result := subscriber.read()
+		if expected := "unsubscribe " + channel + " " + strconv.Itoa(1-i); pushed(result) != expected {
+			t.Errorf("UNSUBSCRIBE: Expected %s, got %v", expected, result)
+		}
+	}
+	if result := subscriber.do("UNSUBSCRIBE"); result.Array[1].Kind != resp.KindNull || result.Array[2].Num != 0 {
+		t.Errorf("UNSUBSCRIBE without subscriptions: Expected null channel, got %v", result)
+	}
+
+	// Regular commands work again once all subscriptions are gone
+	if result := subscriber.do("GET", "key"); result.Kind != resp.KindNull {
+		t.Errorf("GET after UNSUBSCRIBE: Expected null, got %v", result)
+	}
+}
+
+// TestPSubscribe tests pattern subscriptions and the PUBSUB command
+func TestPSubscribe(t *testing.T) {
+	_, connect := startServer(t)
+	subscriber, other, publisher := connect(), connect(), connect()
+
+	if result := subscriber.do("PSUBSCRIBE", "news.*"); pushed(result) != "psubscribe news.* 1" {
+		t.Errorf("PSUBSCRIBE: Expected confirmation, got %v", result)
+	}
+	other.do("SUBSCRIBE", "news.tech")
+
+	if result := publisher.do("PUBLISH", "news.tech", "go"); result.Num != 2 {
+		t.Errorf("PUBLISH: Expected 2 receivers, got %v", result)
+	}
+	if result := subscriber.read(); pushed(result) != "pmessage news.* news.tech go" {
+		t.Errorf("Pattern message: Expected pmessage news.* news.tech go, got %v", result)
+	}
+	if result := other.read(); pushed(result) != "message news.tech go" {
+		t.Errorf("Channel message: Expected message news.tech go, got %v", result)
+	}
+
+	if result := publisher.do("PUBSUB", "CHANNELS"); bulks(result) != "news.tech" {
+		t.Errorf("PUBSUB CHANNELS: Expected news.tech, got %v", result)
+	}
+	if result := publisher.do("PUBSUB", "CHANNELS", "sports.*"); len(result.Array) != 0 {
+		t.Errorf("PUBSUB CHANNELS pattern: Expected no channels, got %v", result)
+	}
+	result := publisher.do("PUBSUB", "NUMSUB", "news.tech", "news.*")
+	if len(result.Array) != 4 || result.Array[1].Num != 1 || result.Array[3].Num != 0 {
+		t.Errorf("PUBSUB NUMSUB: Expected news.tech 1 news.* 0, got %v", result)
+	}
+	if result := publisher.do("PUBSUB", "NUMPAT"); result.Num != 1 {
+		t.Errorf("PUBSUB NUMPAT: Expected 1, got %v", result)
+	}
+
+	// Subscriptions are dropped when the connection is closed
+	subscriber.conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for publisher.do("PUBSUB", "NUMPAT").Num != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("PUBSUB NUMPAT: Expected 0 after disconnecting")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestSlowSubscriber tests that a subscriber that does not read cannot stall publishers,
+// and is disconnected once it falls too far behind
+func TestSlowSubscriber(t *testing.T) {
+	_, connect := startServer(t)
+	subscriber, publisher := connect(), connect()
+	subscriber.do("SUBSCRIBE", "firehose")
+
+	// Every PUBLISH is answered while the subscriber reads nothing, as do fails the test when
+	// a reply does not arrive, until the subscriber is dropped and no longer receives them
+	message := strings.Repeat("x", 64*1024)
+	dropped := false
+	for i := 0; i < 10000 && !dropped; i++ {
+		dropped = publisher.do("PUBLISH", "firehose", message).Num == 0
+	}
+	if !dropped {
+		t.Fatal("PUBLISH: Expected the slow subscriber to be dropped")
+	}
+	if result := publisher.do("PUBSUB", "NUMSUB", "firehose"); result.Array[1].Num != 0 {
+		t.Errorf("PUBSUB NUMSUB: Expected the slow subscriber to be removed, got %v", result)
+	}
+
+	// The messages queued before it fell behind are followed by the end of the connection
+	for i := 0; ; i++ {
+		subscriber.conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := subscriber.reader.Read(); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("Slow subscriber: Expected the connection to be closed after %d messages", i)
+			}
+			break
+		}
+	}
+}
+
+// TestSubscribeManyChannels tests that subscribing to more channels at once than a subscriber
+// may have messages queued confirms every one of them, instead of disconnecting the client
+func TestSubscribeManyChannels(t *testing.T) {
+	_, connect := startServer(t)
+	subscriber := connect()
+
+	const count = 5000
+	channels := make([]string, count)
+	for i := range channels {
+		channels[i] = "channel:" + strconv.Itoa(i)
+	}
+	subscriber.send(append([]string{"SUBSCRIBE"}, channels...)...)
+	for i, channel := range channels {
+		if result := subscriber.read(); pushed(result) != "subscribe "+channel+" "+strconv.Itoa(i+1) {
+			t.Fatalf("SUBSCRIBE: Expected confirmation %d for %s, got %v", i+1, channel, result)
+		}
+	}
+
+	subscriber.send("UNSUBSCRIBE")
+	for i := 0; i < count; i++ {
+		if result := subscriber.read(); result.Array[2].Num != int64(count-i-1) {
+			t.Fatalf("UNSUBSCRIBE: Expected %d remaining subscriptions, got %v", count-i-1, result)
+		}
+	}
+	if result := subscriber.do("PING"); result.Str != "PONG" {
+		t.Errorf("PING after UNSUBSCRIBE: Expected PONG, got %v", result)
+	}
+}
+
+// TestPublishNotInAOF tests that pub/sub commands are not written to the AOF
+func TestPublishNotInAOF(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+	client.do("PUBLISH", "channel", "message")
+	client.do("SET", "key", "value")
+
+	data := readAOF(t)
+	if strings.Contains(string(data), "PUBLISH") || !strings.Contains(string(data), "SET") {
+		t.Errorf("AOF: Expected SET without PUBLISH, got %q", data)
+	}
+}
+
+// TestGlobMatch tests the glob-style pattern matching used by PSUBSCRIBE
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, str string
+		expected     bool
+	}{
+		{"*", "", true},
+		{"a*", "a", true},
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"news.*", "news.tech", true},
+		{"news.*", "sports.tech", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"[abc", "a", true},
+		{"*a*a*a*a*a*a*a*a*b", strings.Repeat("a", 50), false},
+	}
+	for _, tt := range tests {
+		if result := glob.Match(tt.pattern, tt.str); result != tt.expected {
+			t.Errorf("Match(%q, %q): Expected %v, got %v", tt.pattern, tt.str, tt.expected, result)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"redis/command"
+	"redis/config"
+	"redis/rdb"
+	"redis/resp"
+	"redis/server"
+	"redis/storage"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRDBChecksum tests the CRC-64 variant of Redis against its check value
+func TestRDBChecksum(t *testing.T) {
+	if sum := rdb.Checksum([]byte("123456789")); sum != 0xe9c6d914c4b8d9ca {
+		t.Errorf("Checksum: Expected 0xe9c6d914c4b8d9ca, got %#x", sum)
+	}
+}
+
+// TestListpack tests that listpacks decode to the elements appended to them,
+// whatever their encoding
+func TestListpack(t *testing.T) {
+	lp := rdb.NewListpack()
+	var expected []string
+	for _, n := range []int64{0, 127, 128, -4096, 4095, 1 << 20, -1 << 40, 1<<63 - 1} {
+		lp.AppendInt(n)
+		expected = append(expected, strconv.FormatInt(n, 10))
+	}
+	for _, s := range []string{"", "field", strings.Repeat("x", 100), strings.Repeat("y", 5000)} {
+		lp.AppendString(s)
+		expected = append(expected, s)
+	}
+
+	elements, err := rdb.DecodeListpack(lp.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(elements, ",") != strings.Join(expected, ",") {
+		t.Errorf("DecodeListpack: Expected %q, got %q", expected, elements)
+	}
+	if len(lp.Bytes()) != lp.Size() || lp.Len() != len(expected) {
+		t.Errorf("Listpack: Expected %d elements in %d bytes, got %d in %d", len(expected), len(lp.Bytes()), lp.Len(), lp.Size())
+	}
+}
+
+// saveRDB returns the RDB file of the data
+func saveRDB(t testing.TB, s *storage.Storage) []byte {
+	t.Helper()
+	snap := s.Snapshot()
+	defer snap.Release()
+	return saveSnapshot(t, snap)
+}
+
+// saveSnapshot returns the RDB file of a snapshot
+func saveSnapshot(t testing.TB, snap *storage.Snapshot) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	e := rdb.NewEncoder(&buf)
+	e.WriteAux("redis-ver", "7.2.0")
+	snap.SaveRDB(e)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// loadRDB loads an RDB file into a new storage
+func loadRDB(data []byte) (*storage.Storage, error) {
+	d, err := rdb.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	s := storage.NewStorage()
+	return s, s.LoadRDB(d)
+}
+
+// TestRDBRoundTrip tests that loading a saved RDB file rebuilds the same data,
+// including expiries, streams spanning several listpacks and consumer groups
+func TestRDBRoundTrip(t *testing.T) {
+	s := storage.NewStorage()
+	run := func(args ...string) {
+		d, _ := command.Lookup(args[0])
+		d.Handler(s, args[1:])
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		if args := randomWrite(rng, 4); args[0] != "BLPOP" {
+			run(args...)
+		}
+	}
+	run("SET", "str:4", "12345")
+	run("SET", "str:5", strings.Repeat("long", 100), "PX", "100000")
+	for i := 0; i < 250; i++ {
+		run("XADD", "stream:4", "*", "n", strconv.Itoa(i), "big", strconv.Itoa(i*1000000))
+	}
+	run("XGROUP", "CREATE", "stream:4", "group", "0")
+	run("XGROUP", "CREATECONSUMER", "stream:4", "group", "idle")
+	run("XREADGROUP", "GROUP", "group", "alice", "COUNT", "120", "STREAMS", "stream:4", ">")
+	run("XREADGROUP", "GROUP", "group", "bob", "COUNT", "3", "STREAMS", "stream:4", ">")
+	run("XACK", "stream:4", "group", "0-1")
+	run("XGROUP", "CREATE", "stream:5", "empty", "$", "MKSTREAM")
+
+	loaded, err := loadRDB(saveRDB(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range dumpKeys(6) {
+		if expected, result := dump(s, key), dump(loaded, key); result != expected {
+			t.Errorf("%s after load: Expected %s, got %s", key, expected, result)
+		}
+	}
+	// Idle times grow between the two calls, so only delivery counts are compared
+	pending := func(s *storage.Storage, args []string) string {
+		result := command.XPending(s, args)
+		if len(args) > 2 {
+			for _, entry := range result.Array {
+				entry.Array[2] = resp.Integer(0)
+			}
+		}
+		return result.String()
+	}
+	for _, args := range [][]string{{"stream:4", "group"}, {"stream:4", "group", "-", "+", "200"}, {"stream:5", "empty"}} {
+		if expected, result := pending(s, args), pending(loaded, args); result != expected {
+			t.Errorf("XPENDING %v after load: Expected %v, got %v", args, expected, result)
+		}
+	}
+	if expected, result := command.PTTL(s, []string{"str:5"}).Num, command.PTTL(loaded, []string{"str:5"}).Num; result > expected || result < expected-1000 {
+		t.Errorf("PTTL after load: Expected %d, got %d", expected, result)
+	}
+}
+
+// TestRDBFormat tests loading a file written the way Redis writes it: integer and LZF
+// encoded strings, an expiry in seconds, and no checksum
+func TestRDBFormat(t *testing.T) {
+	data := []byte("REDIS0009")
+	data = append(data, rdb.OpAux, 9)
+	data = append(data, "redis-ver"...)
+	data = append(data, 5)
+	data = append(data, "7.2.4"...)
+	data = append(data, rdb.OpSelectDB, 0, rdb.OpResizeDB, 3, 1)
+	// An integer encoded as 16 bits
+	data = append(data, rdb.TypeString, 5)
+	data = append(data, "str:0"...)
+	data = append(data, 0xC1, 0x39, 0x30)
+	// 10 times "a" compressed with LZF: a literal and a back reference
+	data = append(data, rdb.TypeString, 5)
+	data = append(data, "str:1"...)
+	data = append(data, 0xC3, 5, 10, 0x00, 'a', 0xE0, 0x00, 0x00)
+	// Expiries in seconds far in the future, and in the past
+	data = append(data, rdb.OpExpireTime)
+	data = binary.LittleEndian.AppendUint32(data, uint32(time.Now().Unix()+1000))
+	data = append(data, rdb.TypeString, 5)
+	data = append(data, "str:2"...)
+	data = append(data, 1, 'x')
+	data = append(data, rdb.OpExpireTime)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = append(data, rdb.TypeString, 5)
+	data = append(data, "str:3"...)
+	data = append(data, 1, 'y')
+	data = append(data, rdb.OpEOF, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	s, err := loadRDB(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{
+		"str:0": `bulk("12345") ttl=false`,
+		"str:1": `bulk("aaaaaaaaaa") ttl=false`,
+		"str:2": `bulk("x") ttl=true`,
+		"str:3": "null ttl=false",
+	} {
+		if result := dump(s, key); result != expected {
+			t.Errorf("%s: Expected %s, got %s", key, expected, result)
+		}
+	}
+}
+
+// TestRDBChecksumMismatch tests that a corrupted file is not loaded
+func TestRDBChecksumMismatch(t *testing.T) {
+	s := storage.NewStorage()
+	s.Set("key", "value")
+	data := saveRDB(t, s)
+	data[bytes.Index(data, []byte("value"))] = 'V'
+
+	if _, err := loadRDB(data); err == nil {
+		t.Error("LoadRDB of a corrupted file: Expected error")
+	}
+}
+
+// waitBgsave waits until the background save in progress is done
+func waitBgsave(t testing.TB, client *testClient) string {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		info := string(client.do("INFO", "persistence").Bulk)
+		if strings.Contains(info, "rdb_bgsave_in_progress:0") {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("BGSAVE did not complete")
+		}
+	}
+}
+
+// TestSave tests SAVE, BGSAVE and LASTSAVE, and that the RDB file is loaded on startup
+// when there is no AOF
+func TestSave(t *testing.T) {
+	srv, connect := startServer(t)
+	srv.Config.Save = nil
+	client := connect()
+
+	client.do("SET", "str:0", "saved")
+	if result := client.do("SAVE"); result.Str != "OK" {
+		t.Fatalf("SAVE: Expected OK, got %v", result)
+	}
+	if _, err := os.Stat("dump.rdb"); err != nil {
+		t.Fatal(err)
+	}
+	if result := client.do("LASTSAVE"); time.Since(time.Unix(int64(result.Num), 0)) > time.Minute {
+		t.Errorf("LASTSAVE: Expected the current time, got %v", result)
+	}
+
+	client.do("RPUSH", "list:0", "a", "b")
+	if info := string(client.do("INFO", "persistence").Bulk); !strings.Contains(info, "rdb_changes_since_last_save:1\r\n") {
+		t.Errorf("INFO persistence: Expected a change since the last save in %q", info)
+	}
+	if result := client.do("BGSAVE", "NOW"); result.Kind != resp.KindError {
+		t.Errorf("BGSAVE NOW: Expected error, got %v", result)
+	}
+	if result := client.do("BGSAVE"); result.Str != "Background saving started" {
+		t.Fatalf("BGSAVE: Expected started, got %v", result)
+	}
+	client.do("SADD", "set:0", "after")
+	info := waitBgsave(t, client)
+	for _, field := range []string{"rdb_changes_since_last_save:1\r\n", "rdb_last_bgsave_status:ok\r\n"} {
+		if !strings.Contains(info, field) {
+			t.Errorf("INFO persistence: Expected %q in %q", field, info)
+		}
+	}
+	srv.AOF.Close()
+
+	// Without an AOF, the data saved by BGSAVE is loaded, and written to a new AOF
+	if err := os.RemoveAll("appendonlydir"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.AOF.Close()
+	if err := os.Remove("dump.rdb"); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := server.NewServer(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.AOF.Close()
+
+	for key, expected := range map[string]string{"str:0": `bulk("saved") ttl=false`, "list:0": "a b ttl=false", "set:0": " ttl=false"} {
+		if result := dump(replayed.DBs.DB(0), key); result != expected {
+			t.Errorf("%s after restart: Expected %s, got %s", key, expected, result)
+		}
+	}
+}
+
+// TestSaveInMulti tests SAVE, BGSAVE and LASTSAVE queued in a transaction
+func TestSaveInMulti(t *testing.T) {
+	srv, connect := startServer(t)
+	srv.Config.Save = nil
+	client := connect()
+
+	client.do("MULTI")
+	client.do("SET", "key", "value")
+	client.do("SAVE")
+	client.do("LASTSAVE")
+	client.do("BGSAVE")
+	result := client.do("EXEC")
+	if len(result.Array) != 4 || result.Array[1].Str != "OK" || result.Array[2].Kind != resp.KindInteger || result.Array[3].Str != "Background saving started" {
+		t.Fatalf("EXEC: Expected OK, OK, the time of the save and started, got %v", result)
+	}
+	if info := waitBgsave(t, client); !strings.Contains(info, "rdb_last_bgsave_status:ok\r\n") {
+		t.Errorf("INFO persistence: Expected a successful BGSAVE in %q", info)
+	}
+	if _, err := os.Stat("dump.rdb"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"redis/resp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// baselineReader is the RESP parser as it was before it worked on []byte, converting
+// every line to a string. It is kept to measure the current parser against
+type baselineReader struct {
+	reader *bufio.Reader
+}
+
+// read parses the next value
+func (r *baselineReader) read() (resp.Value, error) {
+	typeChar, err := r.reader.ReadByte()
+	if err != nil {
+		return resp.Value{}, err
+	}
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return resp.Value{}, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	switch typeChar {
+	case resp.ARRAY:
+		count, err := strconv.Atoi(line)
+		if err != nil {
+			return resp.Value{}, err
+		}
+		array := make([]resp.Value, count)
+		for i := range array {
+			if array[i], err = r.read(); err != nil {
+				return resp.Value{}, err
+			}
+		}
+		return resp.Array(array...), nil
+	case resp.BULK:
+		size, err := strconv.Atoi(line)
+		if err != nil {
+			return resp.Value{}, err
+		}
+		bulk := make([]byte, size)
+		if _, err := io.ReadFull(r.reader, bulk); err != nil {
+			return resp.Value{}, err
+		}
+		if _, err := r.reader.ReadString('\n'); err != nil {
+			return resp.Value{}, err
+		}
+		return resp.Bulk(string(bulk)), nil
+	case resp.INTEGER:
+		num, err := strconv.Atoi(line)
+		return resp.Integer(int64(num)), err
+	default:
+		return resp.SimpleString(line), nil
+	}
+}
+
+// baselineMarshal is the RESP2 marshaling as it was before values were appended to
+// a reused buffer, building a new slice for every value
+func baselineMarshal(v resp.Value) []byte {
+	var bytes []byte
+	switch v.Kind {
+	case resp.KindArray:
+		bytes = append(bytes, resp.ARRAY)
+		bytes = append(bytes, strconv.Itoa(len(v.Array))...)
+		bytes = append(bytes, '\r', '\n')
+		for _, item := range v.Array {
+			bytes = append(bytes, baselineMarshal(item)...)
+		}
+	case resp.KindBulk:
+		bytes = append(bytes, resp.BULK)
+		bytes = append(bytes, strconv.Itoa(len(string(v.Bulk)))...)
+		bytes = append(bytes, '\r', '\n')
+		bytes = append(bytes, string(v.Bulk)...)
+		bytes = append(bytes, '\r', '\n')
+	case resp.KindString:
+		bytes = append(bytes, resp.STRING)
+		bytes = append(bytes, v.Str...)
+		bytes = append(bytes, '\r', '\n')
+	case resp.KindInteger:
+		bytes = append(bytes, resp.INTEGER)
+		bytes = append(bytes, strconv.FormatInt(v.Num, 10)...)
+		bytes = append(bytes, '\r', '\n')
+	}
+	return bytes
+}
+
+// pipelineSize is the number of commands sent at once by the pipelining benchmarks
+const pipelineSize = 100
+
+// pipelinedCommands returns pipelineSize SET commands in RESP
+func pipelinedCommands() []byte {
+	var commands []byte
+	for i := 0; i < pipelineSize; i++ {
+		commands = append(commands, commandValue("SET", "key:"+strconv.Itoa(i), strings.Repeat("v", 64)).Marshal()...)
+	}
+	return commands
+}
+
+// benchmarkRead measures parsing pipelined commands with the given parser
+func benchmarkRead(b *testing.B, read func(io.Reader) func() (resp.Value, error)) {
+	commands := pipelinedCommands()
+	b.SetBytes(int64(len(commands)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		next := read(bytes.NewReader(commands))
+		for j := 0; j < pipelineSize; j++ {
+			if _, err := next(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkRead measures the current RESP parser
+func BenchmarkRead(b *testing.B) {
+	benchmarkRead(b, func(r io.Reader) func() (resp.Value, error) {
+		return resp.NewResp(r).Read
+	})
+}
+
+// BenchmarkBaselineRead measures the RESP parser as it was before it worked on []byte
+func BenchmarkBaselineRead(b *testing.B) {
+	benchmarkRead(b, func(r io.Reader) func() (resp.Value, error) {
+		return (&baselineReader{reader: bufio.NewReader(r)}).read
+	})
+}
+
+// replyValue is a typical reply, as sent by LRANGE
+var replyValue = commandValue("a", "list", "of", "ten", "bulk", "strings", "sent", "as", "a", "reply")
+
+// BenchmarkWrite measures buffering replies with resp.Writer
+func BenchmarkWrite(b *testing.B) {
+	writer := resp.NewWriter(io.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := writer.Write(replyValue); err != nil {
+			b.Fatal(err)
+		}
+	}
+	writer.Flush()
+}
+
+// BenchmarkBaselineWrite measures writing replies as it was done before resp.Writer,
+// marshaling each one in a new slice and writing it on its own
+func BenchmarkBaselineWrite(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := io.Discard.Write(baselineMarshal(replyValue)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPipelinedSet measures the throughput of a server receiving pipelined commands
+func BenchmarkPipelinedSet(b *testing.B) {
+	_, connect := startServer(b)
+	client := connect()
+	commands := pipelinedCommands()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := client.conn.Write(commands); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < pipelineSize; j++ {
+			if _, err := client.reader.Read(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*pipelineSize)/b.Elapsed().Seconds(), "commands/s")
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"redis/resp"
+	"testing"
+)
+
+// fuzzMaxLen is the limit of the readers in FuzzRead, low enough to be reached by the fuzzer
+const fuzzMaxLen = 64
+
+// FuzzRead tests that Read and ReadCommand never panic nor allocate from lengths they were
+// not sent the data of, whatever bytes they are given. The seed corpus is in testdata/fuzz/FuzzRead, run with:
+//
+//	go test ./tests -run '^$' -fuzz FuzzRead
+func FuzzRead(f *testing.F) {
+	f.Add([]byte("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"))
+	f.Add([]byte("SET key \"a b\"\r\n"))
+	f.Add([]byte("%1\r\n+key\r\n|1\r\n+ttl\r\n:3\r\n,1.5\r\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, read := range []func(*resp.Resp) (resp.Value, error){(*resp.Resp).Read, (*resp.Resp).ReadCommand} {
+			reader := resp.NewResp(bytes.NewReader(data))
+			reader.MaxBulkLen = fuzzMaxLen
+			reader.MaxMultiBulkLen = fuzzMaxLen
+			for {
+				value, err := read(reader)
+				if err != nil {
+					if err != io.EOF && err != io.ErrUnexpectedEOF && !errors.Is(err, resp.ErrProtocol) {
+						t.Fatalf("Read %q: Expected EOF or a protocol error, got %v", data, err)
+					}
+					break
+				}
+				checkSize(t, data, value)
+			}
+		}
+	})
+}
+
+// checkSize fails the test if a value read from data holds more bytes or values than data,
+// which would mean the reader trusted a length sent by the peer
+func checkSize(t *testing.T, data []byte, v resp.Value) {
+	t.Helper()
+	if len(v.Bulk) > len(data) || len(v.Array) > len(data) {
+		t.Fatalf("Read %q: Expected at most %d bytes or values, got %v", data, len(data), v)
+	}
+	for _, item := range append(v.Array, v.Attributes...) {
+		checkSize(t, data, item)
+	}
+}
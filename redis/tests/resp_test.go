@@ -0,0 +1,284 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"redis/resp"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRESP3RoundTrip tests that every RESP3 type is read back as it was marshaled
+func TestRESP3RoundTrip(t *testing.T) {
+	values := []resp.Value{
+		resp.Map(resp.Bulk("key"), resp.Integer(1)),
+		resp.Set(resp.Bulk("a"), resp.Bulk("b")),
+		resp.Double(1.5),
+		resp.Double(math.Inf(-1)),
+		resp.Boolean(true),
+		resp.Boolean(false),
+		resp.BigNumber("3492890328409238509324850943850943825024385"),
+		resp.Verbatim("txt", "Some string"),
+		resp.Null(),
+		resp.Push(resp.Bulk("message"), resp.Bulk("hello")),
+		resp.OK(),
+		resp.Array(resp.Double(2), resp.Null()),
+	}
+	for _, value := range values {
+		result, err := resp.NewResp(bytes.NewReader(value.MarshalRESP3())).Read()
+		if err != nil {
+			t.Errorf("Read %q: %v", value.MarshalRESP3(), err)
+			continue
+		}
+		if !reflect.DeepEqual(result, value) {
+			t.Errorf("Read %q: Expected %v, got %v", value.MarshalRESP3(), value, result)
+		}
+	}
+
+	if result, err := resp.NewResp(bytes.NewReader([]byte("!21\r\nSYNTAX invalid syntax\r\n"))).Read(); err != nil || result.Kind != resp.KindError || result.Str != "SYNTAX invalid syntax" {
+		t.Errorf("Read blob error: Expected error, got %v, %v", result, err)
+	}
+}
+
+// TestRESP2Downgrade tests that RESP3 types are marshaled to their RESP2 equivalent
+func TestRESP2Downgrade(t *testing.T) {
+	tests := []struct {
+		value    resp.Value
+		expected string
+	}{
+		{resp.Map(resp.Bulk("k"), resp.Bulk("v")), "*2\r\n$1\r\nk\r\n$1\r\nv\r\n"},
+		{resp.Set(resp.Bulk("a")), "*1\r\n$1\r\na\r\n"},
+		{resp.Double(1.5), "$3\r\n1.5\r\n"},
+		{resp.Boolean(true), ":1\r\n"},
+		{resp.BigNumber("12345678901234567890"), "$20\r\n12345678901234567890\r\n"},
+		{resp.Verbatim("txt", "hello"), "$5\r\nhello\r\n"},
+		{resp.Null(), "$-1\r\n"},
+		{resp.Push(resp.Integer(1)), "*1\r\n:1\r\n"},
+		{resp.OK(), "+OK\r\n"},
+	}
+	for _, tt := range tests {
+		if result := string(tt.value.Marshal()); result != tt.expected {
+			t.Errorf("Marshal %v: Expected %q, got %q", tt.value, tt.expected, result)
+		}
+	}
+
+	if result := string(resp.Null().MarshalRESP3()); result != "_\r\n" {
+		t.Errorf("MarshalRESP3 null: Expected _, got %q", result)
+	}
+}
+
+// TestHello tests switching protocols with HELLO
+func TestHello(t *testing.T) {
+	_, connect := startServer(t)
+	client, publisher := connect(), connect()
+	client.do("HSET", "hash", "field", "value")
+
+	if result := client.do("HGETALL", "hash"); result.Kind != resp.KindArray {
+		t.Errorf("HGETALL with RESP2: Expected array, got %v", result)
+	}
+
+	result := client.do("HELLO", "3", "SETNAME", "worker")
+	if result.Kind != resp.KindMap || len(result.Array) != 14 || string(result.Array[4].Bulk) != "proto" || result.Array[5].Num != 3 {
+		t.Fatalf("HELLO 3: Expected map with proto 3, got %v", result)
+	}
+	if result := client.do("HGETALL", "hash"); result.Kind != resp.KindMap || bulks(result) != "field value" {
+		t.Errorf("HGETALL with RESP3: Expected map, got %v", result)
+	}
+	if result := client.do("GET", "missing"); result.Kind != resp.KindNull {
+		t.Errorf("GET missing with RESP3: Expected null, got %v", result)
+	}
+
+	// Subscribed RESP3 clients get pushed messages and can run any command
+	if result := client.do("SUBSCRIBE", "news"); result.Kind != resp.KindPush || pushed(result) != "subscribe news 1" {
+		t.Errorf("SUBSCRIBE with RESP3: Expected push, got %v", result)
+	}
+	publisher.do("PUBLISH", "news", "hello")
+	if result := client.read(); result.Kind != resp.KindPush || pushed(result) != "message news hello" {
+		t.Errorf("Message with RESP3: Expected push, got %v", result)
+	}
+	if result := client.do("HGET", "hash", "field"); string(result.Bulk) != "value" {
+		t.Errorf("HGET while subscribed with RESP3: Expected value, got %v", result)
+	}
+	client.do("UNSUBSCRIBE")
+
+	if result := client.do("HELLO", "4"); result.Kind != resp.KindError || result.Str != "NOPROTO unsupported protocol version" {
+		t.Errorf("HELLO 4: Expected NOPROTO, got %v", result)
+	}
+	if result := client.do("HELLO", "2", "AUTH", "admin", "secret"); result.Kind != resp.KindError {
+		t.Errorf("HELLO AUTH unknown user: Expected error, got %v", result)
+	}
+	if result := client.do("HELLO", "2", "AUTH", "default", "anything"); result.Kind != resp.KindArray {
+		t.Errorf("HELLO 2: Expected array, got %v", result)
+	}
+	if result := client.do("HGETALL", "hash"); result.Kind != resp.KindArray {
+		t.Errorf("HGETALL back to RESP2: Expected array, got %v", result)
+	}
+}
+
+// TestInlineCommands tests parsing commands typed by hand, as with telnet or nc
+func TestInlineCommands(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"PING\r\n", []string{"PING"}},
+		{"SET key value\n", []string{"SET", "key", "value"}},
+		{"  GET \t key  \r\n", []string{"GET", "key"}},
+		{"SET \"my key\" 'it\\'s'\r\n", []string{"SET", "my key", "it's"}},
+		{"SET k \"a\\nb\\x41\\\"\"\r\n", []string{"SET", "k", "a\nbA\""}},
+		{"SET k \"\"\r\n", []string{"SET", "k", ""}},
+		{"\r\n", []string{}},
+	}
+	for _, tt := range tests {
+		result, err := resp.NewResp(bytes.NewReader([]byte(tt.line))).Read()
+		if err != nil {
+			t.Errorf("Read %q: %v", tt.line, err)
+			continue
+		}
+		if result.Kind != resp.KindArray || len(result.Array) != len(tt.expected) {
+			t.Errorf("Read %q: Expected %q, got %v", tt.line, tt.expected, result)
+			continue
+		}
+		for i, arg := range tt.expected {
+			if result.Array[i].Kind != resp.KindBulk || string(result.Array[i].Bulk) != arg {
+				t.Errorf("Read %q: Expected %q, got %v", tt.line, tt.expected, result)
+				break
+			}
+		}
+	}
+
+	for _, line := range []string{"SET k \"unterminated\r\n", "SET k 'a'b\r\n", "SET k \"a\"b\r\n"} {
+		if _, err := resp.NewResp(bytes.NewReader([]byte(line))).Read(); err != resp.ErrUnbalancedQuotes {
+			t.Errorf("Read %q: Expected unbalanced quotes, got %v", line, err)
+		}
+	}
+	long := append(bytes.Repeat([]byte("a"), resp.MaxInlineLen+1), '\n')
+	if _, err := resp.NewResp(bytes.NewReader(long)).Read(); err != resp.ErrInlineTooLong {
+		t.Errorf("Read long line: Expected too big inline request, got %v", err)
+	}
+
+	// Inline and RESP commands can be mixed on the same connection
+	_, connect := startServer(t)
+	client := connect()
+	if _, err := client.conn.Write([]byte("SET greeting \"hello world\"\r\nGET greeting\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if result := client.read(); result.Str != "OK" {
+		t.Errorf("Inline SET: Expected OK, got %v", result)
+	}
+	if result := client.read(); string(result.Bulk) != "hello world" {
+		t.Errorf("Inline GET: Expected hello world, got %v", result)
+	}
+	if result := client.do("GET", "greeting"); string(result.Bulk) != "hello world" {
+		t.Errorf("GET: Expected hello world, got %v", result)
+	}
+}
+
+// TestPipelinedReplies tests that the replies to pipelined commands all arrive, in order
+func TestPipelinedReplies(t *testing.T) {
+	_, connect := startServer(t)
+	client := connect()
+
+	var commands []byte
+	for i := 0; i < pipelineSize; i++ {
+		commands = append(commands, commandValue("INCR", "counter").Marshal()...)
+	}
+	if _, err := client.conn.Write(commands); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= pipelineSize; i++ {
+		if result := client.read(); result.Num != i {
+			t.Fatalf("INCR %d: Expected %d, got %v", i, i, result)
+		}
+	}
+	if result := client.do("GET", "counter"); string(result.Bulk) != fmt.Sprint(pipelineSize) {
+		t.Errorf("GET: Expected %d, got %v", pipelineSize, result)
+	}
+}
+
+// TestReadLimits tests that lengths sent by the peer are checked before anything is allocated
+func TestReadLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"*999999999\r\n", "Protocol error: invalid multibulk length"},
+		{"*-2\r\n", "Protocol error: invalid multibulk length"},
+		{"%-1\r\n", "Protocol error: invalid multibulk length"},
+		{"$999999999\r\n", "Protocol error: invalid bulk length"},
+		{"$-5\r\n", "Protocol error: invalid bulk length"},
+		{"$x\r\n", "Protocol error: invalid bulk length"},
+		{"$3\r\nabcde\r\n", "Protocol error: bulk string not terminated by CRLF"},
+		{":12a\r\n", "Protocol error: invalid integer: \"12a\""},
+		{"#x\r\n", "Protocol error: invalid boolean: \"x\""},
+	}
+	for _, tt := range tests {
+		reader := resp.NewResp(bytes.NewReader([]byte(tt.input)))
+		reader.MaxBulkLen = 1024
+		reader.MaxMultiBulkLen = 16
+		_, err := reader.Read()
+		if !errors.Is(err, resp.ErrProtocol) || err.Error() != tt.expected {
+			t.Errorf("Read %q: Expected %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+
+	// A length within the limits does not allocate before the data arrives
+	if _, err := resp.NewResp(bytes.NewReader([]byte("$500000000\r\nabc"))).Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Read truncated bulk: Expected unexpected EOF, got %v", err)
+	}
+	if result, err := resp.NewResp(bytes.NewReader([]byte("*-1\r\n"))).Read(); err != nil || result.Kind != resp.KindNull {
+		t.Errorf("Read null array: Expected null, got %v, %v", result, err)
+	}
+	long := append([]byte("*"), bytes.Repeat([]byte("1"), 2*resp.MaxInlineLen)...)
+	if _, err := resp.NewResp(bytes.NewReader(long)).Read(); !errors.Is(err, resp.ErrProtocol) {
+		t.Errorf("Read long header: Expected protocol error, got %v", err)
+	}
+
+	// Commands are arrays of bulk strings and nothing else
+	for input, expected := range map[string]string{
+		"*1\r\n:1\r\n":            "Protocol error: expected '$', got ':'",
+		"*1\r\n*1\r\n$1\r\na\r\n": "Protocol error: expected '$', got '*'",
+		"*1\r\n$-1\r\n":           "Protocol error: invalid bulk length",
+	} {
+		if _, err := resp.NewResp(bytes.NewReader([]byte(input))).ReadCommand(); err == nil || err.Error() != expected {
+			t.Errorf("ReadCommand %q: Expected %q, got %v", input, expected, err)
+		}
+	}
+	if result, err := resp.NewResp(bytes.NewReader([]byte("\r\n  \r\nPING\r\n"))).ReadCommand(); err != nil || bulks(result) != "PING" {
+		t.Errorf("ReadCommand after blank lines: Expected PING, got %v, %v", result, err)
+	}
+}
+
+// TestProtocolErrors tests that the server replies to invalid RESP, then closes the connection
+func TestProtocolErrors(t *testing.T) {
+	_, connect := startServer(t)
+
+	for _, input := range []string{"*999999999\r\n", "*1\r\n:1\r\n", "*1\r\n$600000000\r\n"} {
+		client := connect()
+		if _, err := client.conn.Write([]byte(input)); err != nil {
+			t.Fatal(err)
+		}
+		if result := client.read(); result.Kind != resp.KindError || !strings.HasPrefix(result.Str, "ERR Protocol error") {
+			t.Errorf("Send %q: Expected protocol error, got %v", input, result)
+		}
+		if _, err := client.reader.Read(); err != io.EOF {
+			t.Errorf("Send %q: Expected the connection to be closed, got %v", input, err)
+		}
+	}
+
+	// Empty commands get a reply, and the connection stays open
+	client := connect()
+	if _, err := client.conn.Write([]byte("*0\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if result := client.read(); result.Kind != resp.KindError || result.Str != "ERR empty command" {
+		t.Errorf("Empty command: Expected error, got %v", result)
+	}
+	if result := client.do("PING"); result.Str != "PONG" {
+		t.Errorf("PING after empty command: Expected PONG, got %v", result)
+	}
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"redis/command"
+	"redis/resp"
+	"redis/storage"
+	"testing"
+)
+
+// TestSetMembers tests the SADD, SREM, SMEMBERS, SISMEMBER, SMISMEMBER and SCARD commands
+func TestSetMembers(t *testing.T) {
+	s := storage.NewStorage()
+
+	if result := command.SAdd(s, []string{"set", "a", "b", "a", "c"}); result.Num != 3 {
+		t.Errorf("SADD: Expected 3, got %v", result)
+	}
+	if result := command.SAdd(s, []string{"set", "c", "d"}); result.Num != 1 {
+		t.Errorf("SADD existing: Expected 1, got %v", result)
+	}
+	if result := command.SMembers(s, []string{"set"}); sortedBulks(result) != "a b c d" {
+		t.Errorf("SMEMBERS: Expected a b c d, got %v", result)
+	}
+	if result := command.SIsMember(s, []string{"set", "b"}); result.Num != 1 {
+		t.Errorf("SISMEMBER: Expected 1, got %v", result)
+	}
+	result := command.SMIsMember(s, []string{"set", "a", "z"})
+	if len(result.Array) != 2 || result.Array[0].Num != 1 || result.Array[1].Num != 0 {
+		t.Errorf("SMISMEMBER: Expected [1 0], got %v", result)
+	}
+
+	if result := command.SRem(s, []string{"set", "a", "z"}); result.Num != 1 {
+		t.Errorf("SREM: Expected 1, got %v", result)
+	}
+	if result := command.SCard(s, []string{"set"}); result.Num != 3 {
+		t.Errorf("SCARD: Expected 3, got %v", result)
+	}
+
+	// Test that removing the last member deletes the key
+	command.SRem(s, []string{"set", "b", "c", "d"})
+	if result := command.Exists(s, []string{"set"}); result.Num != 0 {
+		t.Errorf("EXISTS empty set: Expected 0, got %v", result)
+	}
+}
+
+// TestSetRandom tests the SPOP and SRANDMEMBER commands
+func TestSetRandom(t *testing.T) {
+	s := storage.NewStorage()
+	command.SAdd(s, []string{"set", "a", "b", "c", "d", "e"})
+
+	if result := command.SRandMember(s, []string{"set", "10"}); sortedBulks(result) != "a b c d e" {
+		t.Errorf("SRANDMEMBER large count: Expected every member, got %v", result)
+	}
+	if result := command.SRandMember(s, []string{"set", "-8"}); len(result.Array) != 8 {
+		t.Errorf("SRANDMEMBER negative count: Expected 8 members, got %v", result)
+	}
+	if result := command.SRandMember(s, []string{"missing"}); result.Kind != resp.KindNull {
+		t.Errorf("SRANDMEMBER missing: Expected null, got %v", result)
+	}
+	// Counts whose reply could not be allocated are refused instead of crashing the server
+	for _, count := range []string{"-9223372036854775808", "-4611686018427387904", "-1000000000000"} {
+		if result := command.SRandMember(s, []string{"set", count}); result.Str != "ERR value is out of range" {
+			t.Errorf("SRANDMEMBER %s: Expected out of range, got %v", count, result)
+		}
+	}
+
+	popped := command.SPop(s, []string{"set", "3"})
+	if len(popped.Array) != 3 {
+		t.Errorf("SPOP count: Expected 3 members, got %v", popped)
+	}
+	for _, member := range popped.Array {
+		if result := command.SIsMember(s, []string{"set", string(member.Bulk)}); result.Num != 0 {
+			t.Errorf("SPOP: Expected %s to be removed", member.Bulk)
+		}
+	}
+	if result := command.SCard(s, []string{"set"}); result.Num != 2 {
+		t.Errorf("SCARD after SPOP: Expected 2, got %v", result)
+	}
+}
+
+// TestSetAlgebra tests the SINTER, SUNION and SDIFF commands and their STORE variants
+func TestSetAlgebra(t *testing.T) {
+	s := storage.NewStorage()
+	command.SAdd(s, []string{"s1", "a", "b", "c", "d"})
+	command.SAdd(s, []string{"s2", "c", "d", "e"})
+	command.SAdd(s, []string{"s3", "d", "f"})
+
+	if result := command.SInter(s, []string{"s1", "s2", "s3"}); sortedBulks(result) != "d" {
+		t.Errorf("SINTER: Expected d, got %v", result)
+	}
+	if result := command.SUnion(s, []string{"s1", "s2", "s3"}); sortedBulks(result) != "a b c d e f" {
+		t.Errorf("SUNION: Expected a b c d e f, got %v", result)
+	}
+	if result := command.SDiff(s, []string{"s1", "s2", "s3"}); sortedBulks(result) != "a b" {
+		t.Errorf("SDIFF: Expected a b, got %v", result)
+	}
+	if result := command.SInter(s, []string{"s1", "missing"}); len(result.Array) != 0 {
+		t.Errorf("SINTER missing: Expected empty array, got %v", result)
+	}
+
+	// Test the STORE variants, including overwriting a key of another type
+	command.Set(s, []string{"dst", "value"})
+	if result := command.SUnionStore(s, []string{"dst", "s2", "s3"}); result.Num != 4 {
+		t.Errorf("SUNIONSTORE: Expected 4, got %v", result)
+	}
+	if result := command.SMembers(s, []string{"dst"}); sortedBulks(result) != "c d e f" {
+		t.Errorf("SMEMBERS after SUNIONSTORE: Expected c d e f, got %v", result)
+	}
+	if result := command.SDiffStore(s, []string{"dst", "s1", "s2"}); result.Num != 2 {
+		t.Errorf("SDIFFSTORE: Expected 2, got %v", result)
+	}
+
+	// Test that a STORE variant may use its destination as a source
+	if result := command.SInterStore(s, []string{"s1", "s1", "s2"}); result.Num != 2 {
+		t.Errorf("SINTERSTORE in place: Expected 2, got %v", result)
+	}
+	if result := command.SInterStore(s, []string{"dst", "s1", "missing"}); result.Num != 0 {
+		t.Errorf("SINTERSTORE empty: Expected 0, got %v", result)
+	}
+	if result := command.Exists(s, []string{"dst"}); result.Num != 0 {
+		t.Errorf("EXISTS empty result: Expected 0, got %v", result)
+	}
+
+	// Test WRONGTYPE on any of the source keys
+	command.Set(s, []string{"string", "value"})
+	if result := command.SUnion(s, []string{"s1", "string"}); result.Kind != resp.KindError {
+		t.Errorf("SUNION on string: Expected error, got %v", result)
+	}
+}
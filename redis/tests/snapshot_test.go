@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"math/rand"
+	"redis/command"
+	"redis/storage"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSnapshotPointInTime tests that a snapshot keeps the data as it was when it was taken,
+// while every type of value is modified after it
+func TestSnapshotPointInTime(t *testing.T) {
+	s := storage.NewStorage()
+	run := func(args ...string) {
+		d, _ := command.Lookup(args[0])
+		d.Handler(s, args[1:])
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		if args := randomWrite(rng, 4); args[0] != "BLPOP" {
+			run(args...)
+		}
+	}
+	keys := dumpKeys(4)
+	before := make(map[string]string, len(keys))
+	for _, key := range keys {
+		before[key] = dump(s, key)
+	}
+
+	snap := s.Snapshot()
+	defer snap.Release()
+	for i := 0; i < 1000; i++ {
+		if args := randomWrite(rng, 4); args[0] != "BLPOP" {
+			run(args...)
+		}
+	}
+
+	changed := 0
+	loaded, err := loadRDB(saveSnapshot(t, snap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if result := dump(loaded, key); result != before[key] {
+			t.Errorf("%s in snapshot: Expected %s, got %s", key, before[key], result)
+		}
+		if dump(s, key) != before[key] {
+			changed++
+		}
+	}
+	if changed == 0 {
+		t.Error("Expected keys to be modified after the snapshot")
+	}
+}
+
+// TestSnapshotWriterLatency tests that writers are not stalled while a slow reader
+// iterates a snapshot of many keys
+func TestSnapshotWriterLatency(t *testing.T) {
+	const keys = 50000
+	s := storage.NewStorage()
+	for i := 0; i < keys; i++ {
+		s.Set("key:"+strconv.Itoa(i), "value")
+	}
+	s.HSet("hash", "field", "value")
+
+	snap := s.Snapshot()
+	var done atomic.Bool
+	var readTime time.Duration
+	go func() {
+		defer done.Store(true)
+		defer snap.Release()
+		start := time.Now()
+		n := 0
+		snap.Rewrite(func(args []string) {
+			if n++; n%1000 == 0 {
+				time.Sleep(2 * time.Millisecond)
+			}
+		})
+		readTime = time.Since(start)
+	}()
+
+	var worst time.Duration
+	writes := 0
+	rng := rand.New(rand.NewSource(3))
+	for !done.Load() {
+		start := time.Now()
+		s.Set("key:"+strconv.Itoa(rng.Intn(keys)), "changed")
+		s.HSet("hash", "field", strconv.Itoa(writes))
+		worst = max(worst, time.Since(start))
+		writes++
+	}
+
+	if readTime < 100*time.Millisecond {
+		t.Fatalf("Expected the reader to take at least 100ms, took %v", readTime)
+	}
+	if worst > 50*time.Millisecond || worst > readTime/4 {
+		t.Errorf("Expected writes to take less than 50ms while the reader took %v, the slowest took %v", readTime, worst)
+	}
+	t.Logf("%d writes while reading the snapshot for %v, the slowest took %v", writes, readTime, worst)
+}
@@ -0,0 +1,334 @@
+package tests
+
+import (
+	"redis/command"
+	"redis/resp"
+	"redis/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entryIDs returns the IDs of a list of stream entries, separated by spaces
+func entryIDs(v resp.Value) string {
+	ids := make([]string, len(v.Array))
+	for i, entry := range v.Array {
+		if entry.Kind == resp.KindArray {
+			ids[i] = string(entry.Array[0].Bulk)
+		} else {
+			ids[i] = string(entry.Bulk)
+		}
+	}
+	return strings.Join(ids, " ")
+}
+
+// addEntries adds entries with the given IDs to a stream, each with a single field
+func addEntries(s *storage.Storage, key string, ids ...string) {
+	for _, id := range ids {
+		command.XAdd(s, []string{key, id, "field", "value-" + id})
+	}
+}
+
+// TestXAddAndRange tests the XADD, XLEN, XRANGE and XREVRANGE commands
+func TestXAddAndRange(t *testing.T) {
+	s := storage.NewStorage()
+
+	if result := command.XAdd(s, []string{"stream", "1-1", "name", "alice", "age", "30"}); string(result.Bulk) != "1-1" {
+		t.Errorf("XADD explicit ID: Expected 1-1, got %v", result)
+	}
+	if result := command.XAdd(s, []string{"stream", "1-*", "name", "bob"}); string(result.Bulk) != "1-2" {
+		t.Errorf("XADD ms-*: Expected 1-2, got %v", result)
+	}
+	if result := command.XAdd(s, []string{"stream", "5", "name", "carol"}); string(result.Bulk) != "5-0" {
+		t.Errorf("XADD without sequence: Expected 5-0, got %v", result)
+	}
+	result := command.XAdd(s, []string{"stream", "*", "name", "dave"})
+	id, err := storage.ParseStreamID(string(result.Bulk), 0)
+	if err != nil || id.Ms < uint64(time.Now().UnixMilli())-1000 {
+		t.Errorf("XADD *: Expected an ID based on the current time, got %v", result)
+	}
+
+	// Test invalid IDs and arguments
+	invalid := map[string][]string{
+		"equal or smaller": {"stream", "5-0", "name", "eve"},
+		"greater than 0-0": {"other", "0-0", "name", "eve"},
+		"Invalid stream":   {"stream", "abc", "name", "eve"},
+		"wrong number":     {"stream", "*", "name"},
+	}
+	for message, args := range invalid {
+		if result := command.XAdd(s, args); result.Kind != resp.KindError || !strings.Contains(result.Str, message) {
+			t.Errorf("XADD %v: Expected error containing %q, got %v", args, message, result)
+		}
+	}
+	if result := command.XAdd(s, []string{"other", "0-*", "name", "eve"}); string(result.Bulk) != "0-1" {
+		t.Errorf("XADD 0-*: Expected 0-1, got %v", result)
+	}
+	if result := command.XAdd(s, []string{"missing", "NOMKSTREAM", "*", "name", "eve"}); result.Kind != resp.KindNull {
+		t.Errorf("XADD NOMKSTREAM: Expected null, got %v", result)
+	}
+
+	if result := command.XLen(s, []string{"stream"}); result.Num != 4 {
+		t.Errorf("XLEN: Expected 4, got %v", result)
+	}
+
+	result = command.XRange(s, []string{"stream", "-", "+"})
+	if len(result.Array) != 4 || entryIDs(result) != "1-1 1-2 5-0 "+id.String() {
+		t.Errorf("XRANGE - +: Expected all entries, got %v", result)
+	}
+	if fields := bulks(result.Array[0].Array[1]); fields != "name alice age 30" {
+		t.Errorf("XRANGE fields: Expected name alice age 30, got %v", fields)
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"stream", "1", "1"}, "1-1 1-2"},
+		{[]string{"stream", "(1-1", "5"}, "1-2 5-0"},
+		{[]string{"stream", "-", "(5-0"}, "1-1 1-2"},
+		{[]string{"stream", "-", "+", "COUNT", "2"}, "1-1 1-2"},
+		{[]string{"stream", "6", "1"}, ""},
+		{[]string{"missing", "-", "+"}, ""},
+	}
+	for _, tt := range tests {
+		if result := command.XRange(s, tt.args); entryIDs(result) != tt.expected {
+			t.Errorf("XRANGE %v: Expected %q, got %v", tt.args, tt.expected, result)
+		}
+	}
+	if result := command.XRevRange(s, []string{"stream", "5", "-", "COUNT", "2"}); entryIDs(result) != "5-0 1-2" {
+		t.Errorf("XREVRANGE: Expected 5-0 1-2, got %v", result)
+	}
+	if result := command.XRange(s, []string{"stream", "(-", "+"}); result.Kind != resp.KindError {
+		t.Errorf("XRANGE (-: Expected error, got %v", result)
+	}
+}
+
+// TestXTrim tests the XTRIM command and the trimming options of XADD
+func TestXTrim(t *testing.T) {
+	s := storage.NewStorage()
+	addEntries(s, "stream", "1", "2", "3", "4", "5")
+
+	if result := command.XTrim(s, []string{"stream", "MAXLEN", "3"}); result.Num != 2 {
+		t.Errorf("XTRIM MAXLEN: Expected 2, got %v", result)
+	}
+	if result := command.XTrim(s, []string{"stream", "MINID", "=", "4"}); result.Num != 1 {
+		t.Errorf("XTRIM MINID: Expected 1, got %v", result)
+	}
+	if result := command.XRange(s, []string{"stream", "-", "+"}); entryIDs(result) != "4-0 5-0" {
+		t.Errorf("XRANGE after XTRIM: Expected 4-0 5-0, got %v", result)
+	}
+
+	command.XAdd(s, []string{"stream", "MAXLEN", "~", "2", "LIMIT", "10", "6", "field", "value"})
+	if result := command.XRange(s, []string{"stream", "-", "+"}); entryIDs(result) != "5-0 6-0" {
+		t.Errorf("XADD MAXLEN: Expected 5-0 6-0, got %v", result)
+	}
+
+	// Trimming never lowers the ID of the next entry
+	if result := command.XAdd(s, []string{"stream", "MAXLEN", "0", "6", "field", "value"}); result.Kind != resp.KindError {
+		t.Errorf("XADD after trimming: Expected error, got %v", result)
+	}
+
+	invalid := [][]string{
+		{"stream", "MAXLEN", "-1"},
+		{"stream", "MAXLEN", "1", "LIMIT", "10"},
+		{"stream", "MAXLEN", "1", "MINID", "1"},
+		{"stream", "LENGTH", "1"},
+	}
+	for _, args := range invalid {
+		if result := command.XTrim(s, args); result.Kind != resp.KindError {
+			t.Errorf("XTRIM %v: Expected error, got %v", args, result)
+		}
+	}
+}
+
+// TestXRead tests the XREAD command
+func TestXRead(t *testing.T) {
+	s := storage.NewStorage()
+	addEntries(s, "s1", "1", "2", "3")
+	addEntries(s, "s2", "1")
+
+	result := command.XRead(s, []string{"COUNT", "2", "STREAMS", "s1", "s2", "missing", "1", "0", "0"})
+	if len(result.Array) != 2 {
+		t.Fatalf("XREAD: Expected 2 streams, got %v", result)
+	}
+	if key, ids := string(result.Array[0].Array[0].Bulk), entryIDs(result.Array[0].Array[1]); key != "s1" || ids != "2-0 3-0" {
+		t.Errorf("XREAD s1: Expected 2-0 3-0, got %v %v", key, ids)
+	}
+	if key, ids := string(result.Array[1].Array[0].Bulk), entryIDs(result.Array[1].Array[1]); key != "s2" || ids != "1-0" {
+		t.Errorf("XREAD s2: Expected 1-0, got %v %v", key, ids)
+	}
+
+	if result := command.XRead(s, []string{"STREAMS", "s1", "$"}); result.Kind != resp.KindNull {
+		t.Errorf("XREAD $: Expected null, got %v", result)
+	}
+	if result := command.XRead(s, []string{"STREAMS", "s1", "s2", "0"}); result.Kind != resp.KindError {
+		t.Errorf("XREAD unbalanced: Expected error, got %v", result)
+	}
+	if result := command.XRead(s, []string{"STREAMS", "s1", ">"}); result.Kind != resp.KindError {
+		t.Errorf("XREAD >: Expected error, got %v", result)
+	}
+}
+
+// TestConsumerGroups tests the XGROUP, XREADGROUP, XACK and XPENDING commands
+func TestConsumerGroups(t *testing.T) {
+	s := storage.NewStorage()
+	addEntries(s, "stream", "1", "2", "3")
+
+	// Test XGROUP CREATE
+	if result := command.XGroup(s, []string{"CREATE", "stream", "group", "0"}); result.Str != "OK" {
+		t.Errorf("XGROUP CREATE: Expected OK, got %v", result)
+	}
+	if result := command.XGroup(s, []string{"CREATE", "stream", "group", "$"}); !strings.HasPrefix(result.Str, "BUSYGROUP") {
+		t.Errorf("XGROUP CREATE existing: Expected BUSYGROUP, got %v", result)
+	}
+	if result := command.XGroup(s, []string{"CREATE", "missing", "group", "$"}); result.Kind != resp.KindError {
+		t.Errorf("XGROUP CREATE missing key: Expected error, got %v", result)
+	}
+	if result := command.XGroup(s, []string{"CREATE", "created", "group", "$", "MKSTREAM"}); result.Str != "OK" {
+		t.Errorf("XGROUP CREATE MKSTREAM: Expected OK, got %v", result)
+	}
+	if result := command.XLen(s, []string{"created"}); result.Kind != resp.KindInteger || result.Num != 0 {
+		t.Errorf("XLEN after MKSTREAM: Expected 0, got %v", result)
+	}
+
+	// Test XREADGROUP with new entries
+	result := command.XReadGroup(s, []string{"GROUP", "group", "alice", "COUNT", "2", "STREAMS", "stream", ">"})
+	if len(result.Array) != 1 || entryIDs(result.Array[0].Array[1]) != "1-0 2-0" {
+		t.Errorf("XREADGROUP alice: Expected 1-0 2-0, got %v", result)
+	}
+	result = command.XReadGroup(s, []string{"GROUP", "group", "bob", "STREAMS", "stream", ">"})
+	if len(result.Array) != 1 || entryIDs(result.Array[0].Array[1]) != "3-0" {
+		t.Errorf("XREADGROUP bob: Expected 3-0, got %v", result)
+	}
+	if result := command.XReadGroup(s, []string{"GROUP", "group", "bob", "STREAMS", "stream", ">"}); result.Kind != resp.KindNull {
+		t.Errorf("XREADGROUP with nothing new: Expected null, got %v", result)
+	}
+	if result := command.XReadGroup(s, []string{"GROUP", "nogroup", "bob", "STREAMS", "stream", ">"}); !strings.HasPrefix(result.Str, "NOGROUP") {
+		t.Errorf("XREADGROUP missing group: Expected NOGROUP, got %v", result)
+	}
+
+	// Test XPENDING
+	summary := command.XPending(s, []string{"stream", "group"})
+	if len(summary.Array) != 4 || summary.Array[0].Num != 3 || string(summary.Array[1].Bulk) != "1-0" || string(summary.Array[2].Bulk) != "3-0" {
+		t.Fatalf("XPENDING summary: Expected 3 entries from 1-0 to 3-0, got %v", summary)
+	}
+	if consumers := summary.Array[3].Array; len(consumers) != 2 || bulks(consumers[0]) != "alice 2" || bulks(consumers[1]) != "bob 1" {
+		t.Errorf("XPENDING consumers: Expected alice 2 and bob 1, got %v", consumers)
+	}
+	pending := command.XPending(s, []string{"stream", "group", "-", "+", "10", "alice"})
+	if entryIDs(pending) != "1-0 2-0" || string(pending.Array[0].Array[1].Bulk) != "alice" || pending.Array[0].Array[3].Num != 1 {
+		t.Errorf("XPENDING alice: Expected 1-0 2-0 delivered once, got %v", pending)
+	}
+
+	// Reading the history delivers the entries again
+	result = command.XReadGroup(s, []string{"GROUP", "group", "alice", "STREAMS", "stream", "0"})
+	if entryIDs(result.Array[0].Array[1]) != "1-0 2-0" {
+		t.Errorf("XREADGROUP history: Expected 1-0 2-0, got %v", result)
+	}
+	pending = command.XPending(s, []string{"stream", "group", "1", "1", "1"})
+	if len(pending.Array) != 1 || pending.Array[0].Array[3].Num != 2 {
+		t.Errorf("XPENDING after history: Expected 2 deliveries, got %v", pending)
+	}
+
+	// Test XACK
+	if result := command.XAck(s, []string{"stream", "group", "1-0", "3-0", "9-0"}); result.Num != 2 {
+		t.Errorf("XACK: Expected 2, got %v", result)
+	}
+	result = command.XReadGroup(s, []string{"GROUP", "group", "alice", "STREAMS", "stream", "0"})
+	if entryIDs(result.Array[0].Array[1]) != "2-0" {
+		t.Errorf("XREADGROUP history after XACK: Expected 2-0, got %v", result)
+	}
+	result = command.XReadGroup(s, []string{"GROUP", "group", "bob", "STREAMS", "stream", "0"})
+	if len(result.Array) != 1 || len(result.Array[0].Array[1].Array) != 0 {
+		t.Errorf("XREADGROUP empty history: Expected an empty list, got %v", result)
+	}
+
+	// Test NOACK and SETID
+	command.XGroup(s, []string{"SETID", "stream", "group", "0"})
+	command.XReadGroup(s, []string{"GROUP", "group", "carol", "NOACK", "STREAMS", "stream", ">"})
+	if summary := command.XPending(s, []string{"stream", "group"}); summary.Array[0].Num != 1 {
+		t.Errorf("XPENDING after NOACK: Expected 1, got %v", summary)
+	}
+
+	// Test XGROUP consumer management and DESTROY
+	if result := command.XGroup(s, []string{"CREATECONSUMER", "stream", "group", "dave"}); result.Num != 1 {
+		t.Errorf("XGROUP CREATECONSUMER: Expected 1, got %v", result)
+	}
+	if result := command.XGroup(s, []string{"DELCONSUMER", "stream", "group", "alice"}); result.Num != 1 {
+		t.Errorf("XGROUP DELCONSUMER: Expected 1, got %v", result)
+	}
+	if summary := command.XPending(s, []string{"stream", "group"}); summary.Array[0].Num != 0 {
+		t.Errorf("XPENDING after DELCONSUMER: Expected 0, got %v", summary)
+	}
+	if result := command.XGroup(s, []string{"DESTROY", "stream", "group"}); result.Num != 1 {
+		t.Errorf("XGROUP DESTROY: Expected 1, got %v", result)
+	}
+	if result := command.XPending(s, []string{"stream", "group"}); !strings.HasPrefix(result.Str, "NOGROUP") {
+		t.Errorf("XPENDING after DESTROY: Expected NOGROUP, got %v", result)
+	}
+}
+
+// TestXClaim tests the XCLAIM and XAUTOCLAIM commands
+func TestXClaim(t *testing.T) {
+	s := storage.NewStorage()
+	addEntries(s, "stream", "1", "2", "3", "4")
+	command.XGroup(s, []string{"CREATE", "stream", "group", "0"})
+	command.XReadGroup(s, []string{"GROUP", "group", "alice", "STREAMS", "stream", ">"})
+
+	// Nothing has been idle for an hour yet
+	if result := command.XClaim(s, []string{"stream", "group", "bob", "3600000", "1-0"}); len(result.Array) != 0 {
+		t.Errorf("XCLAIM fresh entry: Expected nothing, got %v", result)
+	}
+
+	result := command.XClaim(s, []string{"stream", "group", "bob", "0", "1-0", "2-0", "9-0", "JUSTID"})
+	if entryIDs(result) != "1-0 2-0" {
+		t.Errorf("XCLAIM JUSTID: Expected 1-0 2-0, got %v", result)
+	}
+	pending := command.XPending(s, []string{"stream", "group", "-", "+", "10", "bob"})
+	if entryIDs(pending) != "1-0 2-0" || pending.Array[0].Array[3].Num != 1 {
+		t.Errorf("XPENDING after XCLAIM JUSTID: Expected 1-0 2-0 delivered once, got %v", pending)
+	}
+
+	result = command.XClaim(s, []string{"stream", "group", "bob", "0", "3-0", "RETRYCOUNT", "5", "IDLE", "1000"})
+	if len(result.Array) != 1 || bulks(result.Array[0].Array[1]) != "field value-3" {
+		t.Errorf("XCLAIM: Expected entry 3-0, got %v", result)
+	}
+	pending = command.XPending(s, []string{"stream", "group", "IDLE", "1000", "-", "+", "10"})
+	if entryIDs(pending) != "3-0" || pending.Array[0].Array[3].Num != 5 {
+		t.Errorf("XPENDING IDLE: Expected 3-0 with 5 deliveries, got %v", pending)
+	}
+	if result := command.XClaim(s, []string{"stream", "group", "bob", "0", "1-0", "BOGUS"}); result.Kind != resp.KindError {
+		t.Errorf("XCLAIM invalid option: Expected error, got %v", result)
+	}
+
+	// Entries trimmed from the stream are dropped from the pending entries list by XAUTOCLAIM
+	command.XTrim(s, []string{"stream", "MINID", "2"})
+	result = command.XAutoClaim(s, []string{"stream", "group", "carol", "0", "0", "COUNT", "2"})
+	if len(result.Array) != 3 || string(result.Array[0].Bulk) != "3-0" {
+		t.Fatalf("XAUTOCLAIM: Expected the scan to continue at 3-0, got %v", result)
+	}
+	if entryIDs(result.Array[1]) != "2-0" || entryIDs(result.Array[2]) != "1-0" {
+		t.Errorf("XAUTOCLAIM: Expected 2-0 claimed and 1-0 deleted, got %v", result)
+	}
+	result = command.XAutoClaim(s, []string{"stream", "group", "carol", "0", "3-0", "JUSTID"})
+	if string(result.Array[0].Bulk) != "0-0" || entryIDs(result.Array[1]) != "3-0 4-0" {
+		t.Errorf("XAUTOCLAIM JUSTID: Expected 3-0 4-0 and a complete scan, got %v", result)
+	}
+	if summary := command.XPending(s, []string{"stream", "group"}); bulks(summary.Array[3].Array[0]) != "carol 3" {
+		t.Errorf("XPENDING after XAUTOCLAIM: Expected carol 3, got %v", summary)
+	}
+}
+
+// TestStreamWrongType tests that stream commands reject keys holding another data type
+func TestStreamWrongType(t *testing.T) {
+	s := storage.NewStorage()
+	command.Set(s, []string{"string", "value"})
+	addEntries(s, "stream", "1")
+
+	if result := command.XAdd(s, []string{"string", "*", "field", "value"}); !strings.HasPrefix(result.Str, "WRONGTYPE") {
+		t.Errorf("XADD on a string: Expected WRONGTYPE, got %v", result)
+	}
+	if result := command.Get(s, []string{"stream"}); !strings.HasPrefix(result.Str, "WRONGTYPE") {
+		t.Errorf("GET on a stream: Expected WRONGTYPE, got %v", result)
+	}
+}
@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"redis/command"
+	"redis/resp"
+	"redis/storage"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// TestZAdd tests the ZADD command and its flags
+func TestZAdd(t *testing.T) {
+	s := storage.NewStorage()
+
+	if result := command.ZAdd(s, []string{"zset", "1", "a", "2", "b"}); result.Num != 2 {
+		t.Errorf("ZADD: Expected 2, got %v", result)
+	}
+	if result := command.ZAdd(s, []string{"zset", "NX", "5", "a", "3", "c"}); result.Num != 1 {
+		t.Errorf("ZADD NX: Expected 1, got %v", result)
+	}
+	if result := command.ZScore(s, []string{"zset", "a"}); string(result.Bulk) != "1" {
+		t.Errorf("ZSCORE after NX: Expected 1, got %v", result)
+	}
+	if result := command.ZAdd(s, []string{"zset", "XX", "CH", "10", "a", "4", "d"}); result.Num != 1 {
+		t.Errorf("ZADD XX CH: Expected 1, got %v", result)
+	}
+	if result := command.ZAdd(s, []string{"zset", "GT", "CH", "5", "a", "5", "b"}); result.Num != 1 {
+		t.Errorf("ZADD GT CH: Expected 1, got %v", result)
+	}
+	if result := command.ZScore(s, []string{"zset", "a"}); string(result.Bulk) != "10" {
+		t.Errorf("ZSCORE after GT: Expected 10, got %v", result)
+	}
+	if result := command.ZAdd(s, []string{"zset", "LT", "1.5", "a"}); result.Num != 0 {
+		t.Errorf("ZADD LT: Expected 0, got %v", result)
+	}
+	if result := command.ZScore(s, []string{"zset", "a"}); string(result.Bulk) != "1.5" {
+		t.Errorf("ZSCORE after LT: Expected 1.5, got %v", result)
+	}
+
+	// Test INCR
+	if result := command.ZAdd(s, []string{"zset", "INCR", "2.5", "a"}); string(result.Bulk) != "4" {
+		t.Errorf("ZADD INCR: Expected 4, got %v", result)
+	}
+	if result := command.ZAdd(s, []string{"zset", "NX", "INCR", "1", "a"}); result.Kind != resp.KindNull {
+		t.Errorf("ZADD NX INCR: Expected null, got %v", result)
+	}
+	if result := command.ZIncrBy(s, []string{"zset", "-0.5", "c"}); string(result.Bulk) != "2.5" {
+		t.Errorf("ZINCRBY: Expected 2.5, got %v", result)
+	}
+
+	// Test invalid option combinations and scores
+	invalid := [][]string{
+		{"zset", "NX", "XX", "1", "a"},
+		{"zset", "GT", "LT", "1", "a"},
+		{"zset", "INCR", "1", "a", "2", "b"},
+		{"zset", "nan", "a"},
+		{"zset", "1", "a", "2"},
+	}
+	for _, args := range invalid {
+		if result := command.ZAdd(s, args); result.Kind != resp.KindError {
+			t.Errorf("ZADD %v: Expected error, got %v", args, result)
+		}
+	}
+	if result := command.ZCard(s, []string{"zset"}); result.Num != 3 {
+		t.Errorf("ZCARD: Expected 3, got %v", result)
+	}
+}
+
+// TestZRange tests the ZRANGE command by rank, score and member
+func TestZRange(t *testing.T) {
+	s := storage.NewStorage()
+	command.ZAdd(s, []string{"zset", "1", "a", "2", "b", "3", "c", "4", "d", "5", "e"})
+
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"zset", "0", "-1"}, "a b c d e"},
+		{[]string{"zset", "1", "2", "REV"}, "d c"},
+		{[]string{"zset", "(1", "3", "BYSCORE"}, "b c"},
+		{[]string{"zset", "-inf", "+inf", "BYSCORE", "LIMIT", "1", "2"}, "b c"},
+		{[]string{"zset", "+inf", "(3", "BYSCORE", "REV"}, "e d"},
+		{[]string{"zset", "5", "1", "BYSCORE", "REV", "LIMIT", "3", "-1"}, "b a"},
+		{[]string{"zset", "0", "1", "WITHSCORES"}, "a 1 b 2"},
+		{[]string{"zset", "10", "20", "BYSCORE"}, ""},
+	}
+	for _, c := range cases {
+		if result := command.ZRange(s, c.args); result.Kind != resp.KindArray || bulks(result) != c.expected {
+			t.Errorf("ZRANGE %v: Expected %q, got %v", c.args, c.expected, result)
+		}
+	}
+
+	// Test BYLEX on members with equal scores
+	command.ZAdd(s, []string{"lex", "0", "a", "0", "b", "0", "c", "0", "d"})
+	lexCases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"lex", "-", "+", "BYLEX"}, "a b c d"},
+		{[]string{"lex", "[b", "(d", "BYLEX"}, "b c"},
+		{[]string{"lex", "+", "[c", "BYLEX", "REV"}, "d c"},
+		{[]string{"lex", "-", "+", "BYLEX", "LIMIT", "1", "1"}, "b"},
+	}
+	for _, c := range lexCases {
+		if result := command.ZRange(s, c.args); bulks(result) != c.expected {
+			t.Errorf("ZRANGE %v: Expected %q, got %v", c.args, c.expected, result)
+		}
+	}
+
+	if result := command.ZRange(s, []string{"zset", "0", "1", "LIMIT", "0", "1"}); result.Kind != resp.KindError {
+		t.Errorf("ZRANGE LIMIT by rank: Expected error, got %v", result)
+	}
+	if result := command.ZRange(s, []string{"lex", "a", "+", "BYLEX"}); result.Kind != resp.KindError {
+		t.Errorf("ZRANGE invalid lex bound: Expected error, got %v", result)
+	}
+}
+
+// TestZRankCountAndPop tests the ZRANK, ZREVRANK, ZCOUNT, ZREM, ZPOPMIN and ZPOPMAX commands
+func TestZRankCountAndPop(t *testing.T) {
+	s := storage.NewStorage()
+	command.ZAdd(s, []string{"zset", "1", "a", "2", "b", "3", "c", "4", "d"})
+
+	if result := command.ZRank(s, []string{"zset", "c"}); result.Num != 2 {
+		t.Errorf("ZRANK: Expected 2, got %v", result)
+	}
+	if result := command.ZRevRank(s, []string{"zset", "c"}); result.Num != 1 {
+		t.Errorf("ZREVRANK: Expected 1, got %v", result)
+	}
+	if result := command.ZRank(s, []string{"zset", "z"}); result.Kind != resp.KindNull {
+		t.Errorf("ZRANK missing: Expected null, got %v", result)
+	}
+	if result := command.ZCount(s, []string{"zset", "(1", "3"}); result.Num != 2 {
+		t.Errorf("ZCOUNT: Expected 2, got %v", result)
+	}
+	if result := command.ZCount(s, []string{"zset", "5", "+inf"}); result.Num != 0 {
+		t.Errorf("ZCOUNT empty: Expected 0, got %v", result)
+	}
+
+	if result := command.ZPopMin(s, []string{"zset"}); bulks(result) != "a 1" {
+		t.Errorf("ZPOPMIN: Expected a 1, got %v", result)
+	}
+	if result := command.ZPopMax(s, []string{"zset", "2"}); bulks(result) != "d 4 c 3" {
+		t.Errorf("ZPOPMAX: Expected d 4 c 3, got %v", result)
+	}
+	if result := command.ZRem(s, []string{"zset", "b", "z"}); result.Num != 1 {
+		t.Errorf("ZREM: Expected 1, got %v", result)
+	}
+	if result := command.Exists(s, []string{"zset"}); result.Num != 0 {
+		t.Errorf("EXISTS empty zset: Expected 0, got %v", result)
+	}
+}
+
+// TestZStore tests the ZUNIONSTORE and ZINTERSTORE commands
+func TestZStore(t *testing.T) {
+	s := storage.NewStorage()
+	command.ZAdd(s, []string{"z1", "1", "a", "2", "b"})
+	command.ZAdd(s, []string{"z2", "10", "b", "20", "c"})
+	command.SAdd(s, []string{"set", "a", "c"})
+
+	if result := command.ZUnionStore(s, []string{"out", "2", "z1", "z2"}); result.Num != 3 {
+		t.Errorf("ZUNIONSTORE: Expected 3, got %v", result)
+	}
+	if result := command.ZRange(s, []string{"out", "0", "-1", "WITHSCORES"}); bulks(result) != "a 1 b 12 c 20" {
+		t.Errorf("ZRANGE after ZUNIONSTORE: Expected a 1 b 12 c 20, got %v", result)
+	}
+	if result := command.ZInterStore(s, []string{"out", "2", "z1", "z2", "WEIGHTS", "2", "0.5", "AGGREGATE", "MAX"}); result.Num != 1 {
+		t.Errorf("ZINTERSTORE: Expected 1, got %v", result)
+	}
+	if result := command.ZRange(s, []string{"out", "0", "-1", "WITHSCORES"}); bulks(result) != "b 5" {
+		t.Errorf("ZRANGE after ZINTERSTORE: Expected b 5, got %v", result)
+	}
+
+	// Plain sets are accepted as sources with a score of 1
+	command.ZUnionStore(s, []string{"out", "2", "z1", "set", "AGGREGATE", "SUM"})
+	if result := command.ZRange(s, []string{"out", "0", "-1", "WITHSCORES"}); bulks(result) != "c 1 a 2 b 2" {
+		t.Errorf("ZRANGE after ZUNIONSTORE with set: Expected c 1 a 2 b 2, got %v", result)
+	}
+
+	if result := command.ZUnionStore(s, []string{"out", "0", "z1"}); result.Kind != resp.KindError {
+		t.Errorf("ZUNIONSTORE numkeys 0: Expected error, got %v", result)
+	}
+	if result := command.ZInterStore(s, []string{"out", "3", "z1", "z2"}); result.Kind != resp.KindError {
+		t.Errorf("ZINTERSTORE numkeys too large: Expected error, got %v", result)
+	}
+}
+
+// TestZSetRandomized checks ranks and ranges against a sorted slice after random updates
+func TestZSetRandomized(t *testing.T) {
+	s := storage.NewStorage()
+	rng := rand.New(rand.NewSource(1))
+	scores := map[string]float64{}
+
+	for i := 0; i < 2000; i++ {
+		member := "m" + strconv.Itoa(rng.Intn(300))
+		if rng.Intn(4) == 0 {
+			command.ZRem(s, []string{"zset", member})
+			delete(scores, member)
+			continue
+		}
+		score := float64(rng.Intn(100))
+		command.ZAdd(s, []string{"zset", resp.FormatFloat(score), member})
+		scores[member] = score
+	}
+
+	expected := make([]string, 0, len(scores))
+	for member := range scores {
+		expected = append(expected, member)
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		a, b := expected[i], expected[j]
+		return scores[a] < scores[b] || (scores[a] == scores[b] && a < b)
+	})
+
+	result := command.ZRange(s, []string{"zset", "0", "-1"})
+	if len(result.Array) != len(expected) {
+		t.Fatalf("ZRANGE: Expected %d members, got %d", len(expected), len(result.Array))
+	}
+	for i, member := range expected {
+		if string(result.Array[i].Bulk) != member {
+			t.Fatalf("ZRANGE: Expected %s at %d, got %s", member, i, result.Array[i].Bulk)
+		}
+		if rank := command.ZRank(s, []string{"zset", member}); rank.Num != int64(i) {
+			t.Fatalf("ZRANK %s: Expected %d, got %v", member, i, rank)
+		}
+	}
+}
+
+// TestFormatFloat tests that scores are formatted like Redis does
+func TestFormatFloat(t *testing.T) {
+	cases := map[float64]string{
+		3:                  "3",
+		-2:                 "-2",
+		1.5:                "1.5",
+		0.1:                "0.1",
+		1234567.25:         "1234567.25",
+		math.Inf(1):        "inf",
+		math.Inf(-1):       "-inf",
+		1e300:              "1e+300",
+		1e16:               "10000000000000000",
+		1e17:               "1e+17",
+		1e20:               "1e+20",
+		1.5e17:             "1.5e+17",
+		123456789012345678: "1.2345678901234568e+17",
+		0.0001:             "0.0001",
+		0.00001:            "1e-05",
+		-2.5e-7:            "-2.5e-07",
+	}
+	for f, expected := range cases {
+		if got := resp.FormatFloat(f); got != expected {
+			t.Errorf("FormatFloat(%v): Expected %s, got %s", f, expected, got)
+		}
+	}
+}